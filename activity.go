@@ -0,0 +1,86 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultActivityCount = 50
+	maxActivityCount     = 1000
+)
+
+// Activity is an edit of a translation in one of the apps, returned by
+// /admin/activity
+type Activity struct {
+	App       string    `json:"app"`
+	Namespace string    `json:"namespace,omitempty"`
+	User      string    `json:"user"`
+	Source    string    `json:"source"`
+	Lang      string    `json:"lang"`
+	Time      time.Time `json:"time"`
+}
+
+// recentActivity returns at most n most recent edits made after since in
+// all apps, most recent first
+func recentActivity(apps []*App, n int, since time.Time) []Activity {
+	res := []Activity{}
+	for _, app := range apps {
+		for _, ns := range append([]string{defaultNamespace}, app.Namespaces()...) {
+			for _, e := range app.NamespaceStore(ns).RecentEdits(n) {
+				if !e.Time.After(since) {
+					continue
+				}
+				res = append(res, Activity{app.Name, ns, e.User, e.Text, e.Lang, e.Time.UTC()})
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Time.After(res[j].Time)
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// parseSince parses ?since= argument, a date in sinceFormat or time in
+// RFC 3339 format
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(sinceFormat, s)
+}
+
+// url: GET /admin/activity[?n=${n}][&since=${yyyy-mm-dd}]
+// Returns json with recent edits in all apps, most recent first, for
+// admins of the server
+func handleAdminActivity(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	if !userIsServerAdmin(decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't see activity of all apps")
+		return
+	}
+	n, err := formIntArg(r, "n", defaultActivityCount)
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	if n > maxActivityCount {
+		n = maxActivityCount
+	}
+	var since time.Time
+	if sinceStr := strings.TrimSpace(r.FormValue("since")); sinceStr != "" {
+		if since, err = parseSince(sinceStr); err != nil {
+			httpErrorf(w, "Invalid since %q, should be in yyyy-mm-dd format", sinceStr)
+			return
+		}
+	}
+	serveJSON(w, recentActivity(appState.Apps, n, since))
+}
@@ -0,0 +1,82 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestAdminActivity(t *testing.T) {
+	start := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	fake := store.NewFakeClock(start)
+	defer setClock(setClock(fake))
+	app1 := newTestApp(t, "activity1", []string{"Open", "Close"})
+	defer closeTestApp(app1)
+	app2 := newTestApp(t, "activity2", []string{"Save", "Exit"})
+	defer closeTestApp(app2)
+
+	edits := []struct {
+		app        *App
+		str, trans string
+	}{
+		{app1, "Open", "Otwórz"},
+		{app2, "Save", "Zapisz"},
+		{app1, "Close", "Zamknij"},
+		{app2, "Exit", "Wyjdź"},
+	}
+	for _, e := range edits {
+		fake.Advance(time.Hour)
+		writeTestTranslation(t, e.app, e.str, e.trans, "pl", "user1")
+	}
+
+	get := func(user, query string) (int, []Activity) {
+		r := newRequestWithCookie("GET", "/admin/activity"+query, &SecureCookieValue{User: user})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		var res []Activity
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, res
+	}
+	root, done := setTestServerAdmin()
+	defer done()
+	// admins of apps are not server admins
+	for _, user := range []string{"user1", "admin"} {
+		if code, _ := get(user, ""); code != 400 {
+			t.Fatalf("got status %d for %s, expected 400", code, user)
+		}
+	}
+	code, res := get(root, "")
+	if code != 200 || len(res) != len(edits) {
+		t.Fatalf("got status %d, %d edits, expected %d", code, len(res), len(edits))
+	}
+	// edits of both apps, most recent first
+	for i, a := range res {
+		e := edits[len(edits)-1-i]
+		exp := Activity{App: e.app.Name, User: "user1", Source: e.str, Lang: "pl", Time: start.Add(time.Duration(len(edits)-i) * time.Hour)}
+		if a != exp {
+			t.Errorf("%d: got %#v, expected %#v", i, a, exp)
+		}
+	}
+
+	if _, res = get(root, "?n=3"); len(res) != 3 || res[0].Source != "Exit" {
+		t.Fatalf("got %#v, expected 3 most recent edits", res)
+	}
+	since := start.Add(2 * time.Hour).Format(time.RFC3339)
+	if _, res = get(root, "?since="+since); len(res) != 2 || res[1].Source != "Close" {
+		t.Fatalf("got %#v, expected 2 edits after %s", res, since)
+	}
+	if _, res = get(root, "?since=2020-03-02"); len(res) != 0 {
+		t.Fatalf("got %#v, expected no edits", res)
+	}
+	if code, _ := get(root, "?since=yesterday"); code != 400 {
+		t.Fatalf("got status %d for invalid since, expected 400", code)
+	}
+}
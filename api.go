@@ -0,0 +1,348 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+func serveJSONWithStatus(w http.ResponseWriter, status int, v interface{}) {
+	d, err := json.Marshal(v)
+	if err != nil {
+		logger.Errorf("json.Marshal() failed with %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(d)
+}
+
+func serveJSON(w http.ResponseWriter, v interface{}) {
+	serveJSONWithStatus(w, http.StatusOK, v)
+}
+
+func serveJSONError(w http.ResponseWriter, status int, msg string) {
+	v := struct {
+		Error string `json:"error"`
+	}{msg}
+	serveJSONWithStatus(w, status, v)
+}
+
+// hand-maintained, must be updated when json api changes
+var openAPISpecPath = filepath.Join("docs", "openapi.json")
+
+// url: /api/openapi.json
+// Returns OpenAPI 3 description of json api
+func handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	d, err := ioutil.ReadFile(openAPISpecPath)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleOpenAPISpec(): ReadFile() failed with %s", err)
+		http.Error(w, "Failed to read api spec", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(d)
+}
+
+func getAPIApp(w http.ResponseWriter, r *http.Request) *App {
+	appName := mux.Vars(r)["name"]
+	app := findApp(appName)
+	if app == nil {
+		serveJSONError(w, http.StatusNotFound, "Application "+appName+" doesn't exist")
+	}
+	return app
+}
+
+// url: /api/v1/apps/{name}/issues[?lang=$lang]
+func handleAPIAppIssues(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	issues := []Issue{}
+	lang := strings.TrimSpace(r.FormValue("lang"))
+	if lang == "" {
+		issues = append(issues, app.AllIssues()...)
+	} else {
+		lang, err := store.ParseLangCode(lang)
+		if err != nil {
+			serveJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		issues = append(issues, app.Issues(lang)...)
+	}
+	v := struct {
+		App    string  `json:"app"`
+		Issues []Issue `json:"issues"`
+	}{app.Name, issues}
+	serveJSON(w, v)
+}
+
+// APITranslation is a translation of a string returned by json api
+type APITranslation struct {
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+	Fuzzy       bool   `json:"fuzzy,omitempty"`
+	Approved    bool   `json:"approved,omitempty"`
+	NoTranslate bool   `json:"no_translate,omitempty"`
+	MaxLen      int    `json:"max_len,omitempty"`
+	ContextURL  string `json:"context_url,omitempty"`
+}
+
+func buildAPITranslations(app *App, lang string) []*APITranslation {
+	res := []*APITranslation{}
+	for _, li := range app.store.LangInfos() {
+		if li.Code != lang {
+			continue
+		}
+		for _, tr := range li.ActiveStrings {
+			res = append(res, &APITranslation{
+				Source:      tr.String,
+				Translation: tr.Current(),
+				Fuzzy:       tr.Fuzzy,
+				Approved:    tr.Approved,
+				NoTranslate: tr.NoTranslate,
+				MaxLen:      tr.MaxLen,
+				ContextURL:  tr.ContextURL,
+			})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Source < res[j].Source
+	})
+	return res
+}
+
+// url: /api/v1/apps/{name}/translations/{lang}
+// Returns translations of all strings into lang, sorted by source string.
+// Translation of untranslated strings is empty
+func handleAPIAppTranslations(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	lang, err := store.ParseLangCode(mux.Vars(r)["lang"])
+	if err != nil {
+		serveJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v := struct {
+		App          string            `json:"app"`
+		Lang         string            `json:"lang"`
+		Translations []*APITranslation `json:"translations"`
+	}{app.Name, lang, buildAPITranslations(app, lang)}
+	serveJSON(w, v)
+}
+
+// LangProgress is translation progress of a language
+type LangProgress struct {
+	Lang         string `json:"lang"`
+	Strings      int    `json:"strings"`
+	Untranslated int    `json:"untranslated"`
+	// words in all strings and in untranslated strings, see countWords()
+	Words             int `json:"words"`
+	UntranslatedWords int `json:"untranslated_words"`
+	// percentage of translated strings or, with by=words, of words
+	Percent int `json:"percent"`
+	// number of translations in each review state, see store.State
+	States map[store.State]int `json:"states"`
+}
+
+func buildProgress(app *App) []*LangProgress {
+	words := app.WordCounts()
+	res := []*LangProgress{}
+	for _, li := range app.store.LangInfos() {
+		p := &LangProgress{
+			Lang:              li.Code,
+			Strings:           len(li.ActiveStrings),
+			Untranslated:      li.UntranslatedCount(),
+			UntranslatedWords: words[li.Code],
+			States:            countStates(li),
+		}
+		for _, tr := range li.ActiveStrings {
+			p.Words += countWords(tr.String)
+		}
+		p.Percent = translatedPercent(p)
+		res = append(res, p)
+	}
+	return res
+}
+
+// url: /api/v1/apps/{name}/progress[?by=strings|words]
+// With by=words, percent of translated work is weighted by number of
+// words in strings instead of counting every string the same
+func handleAPIAppProgress(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	by := strings.TrimSpace(r.FormValue("by"))
+	if by == "" {
+		by = "strings"
+	}
+	if by != "strings" && by != "words" {
+		serveJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid by %q, must be strings or words", by))
+		return
+	}
+	progress := buildProgress(app)
+	if by == "words" {
+		for _, p := range progress {
+			p.Percent = translatedWordsPercent(p)
+		}
+	}
+	v := struct {
+		App   string          `json:"app"`
+		By    string          `json:"by"`
+		Langs []*LangProgress `json:"langs"`
+	}{app.Name, by, progress}
+	serveJSON(w, v)
+}
+
+// APISource is a source string with its metadata, returned by json api
+type APISource struct {
+	Source      string `json:"source"`
+	NoTranslate bool   `json:"no_translate,omitempty"`
+	MaxLen      int    `json:"max_len,omitempty"`
+	ContextURL  string `json:"context_url,omitempty"`
+	Group       string `json:"group,omitempty"`
+}
+
+// sortedSources returns strings to translate, sorted
+func sortedSources(app *App) []string {
+	res := app.store.ActiveStrings()
+	sort.Strings(res)
+	return res
+}
+
+func newAPISource(app *App, s string) *APISource {
+	return &APISource{
+		Source:      s,
+		NoTranslate: app.store.IsNoTranslate(s),
+		MaxLen:      app.store.MaxLen(s),
+		ContextURL:  app.store.ContextURL(s),
+		Group:       app.store.Group(s),
+	}
+}
+
+func buildAPISources(app *App) []*APISource {
+	res := []*APISource{}
+	for _, s := range sortedSources(app) {
+		res = append(res, newAPISource(app, s))
+	}
+	return res
+}
+
+// url: /api/v1/apps/{name}/sources[?withmeta=1]
+// Returns sorted source strings, without translations. With withmeta,
+// returns APISource objects instead of strings
+func handleAPIAppSources(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	var sources interface{}
+	if r.FormValue("withmeta") == "1" {
+		sources = buildAPISources(app)
+	} else {
+		sources = sortedSources(app)
+	}
+	v := struct {
+		App     string      `json:"app"`
+		Sources interface{} `json:"sources"`
+	}{app.Name, sources}
+	serveJSON(w, v)
+}
+
+// APIStringTranslation is translation of a string into a language, returned
+// by /api/v1/apps/{name}/string
+type APIStringTranslation struct {
+	// empty if not translated
+	Value      string      `json:"value"`
+	State      store.State `json:"state"`
+	ModifiedAt *time.Time  `json:"modified_at,omitempty"`
+}
+
+// APIString is a source string with its metadata and translations into all
+// languages, by language code
+type APIString struct {
+	APISource
+	// context of the source, see store.ContextKey
+	Context      string                           `json:"context,omitempty"`
+	Translations map[string]*APIStringTranslation `json:"translations"`
+}
+
+// buildAPIString returns string with a given key or nil if it's not one of
+// strings to translate
+func buildAPIString(app *App, key string) *APIString {
+	if !app.store.IsActiveString(key) {
+		return nil
+	}
+	source, context := store.SplitContextKey(key)
+	res := &APIString{
+		APISource:    *newAPISource(app, key),
+		Context:      context,
+		Translations: make(map[string]*APIStringTranslation),
+	}
+	res.Source = source
+	for _, li := range app.store.LangInfos() {
+		for _, tr := range li.ActiveStrings {
+			if tr.String != key {
+				continue
+			}
+			t := &APIStringTranslation{Value: tr.Current(), State: tr.State()}
+			if !tr.Modified.IsZero() {
+				modified := tr.Modified.UTC()
+				t.ModifiedAt = &modified
+			}
+			res.Translations[li.Code] = t
+		}
+	}
+	return res
+}
+
+// url: /api/v1/apps/{name}/string?src=$src[&context=$context]
+// Returns a source string with its metadata and translations into all
+// languages. context is needed for strings uploaded with a context
+func handleAPIAppString(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	src := r.FormValue("src")
+	if src == "" {
+		serveJSONError(w, http.StatusBadRequest, "Missing src argument")
+		return
+	}
+	key := store.ContextKey(src, r.FormValue("context"))
+	v := buildAPIString(app, key)
+	if v == nil {
+		serveJSONError(w, http.StatusNotFound, fmt.Sprintf("String %q doesn't exist", src))
+		return
+	}
+	serveJSON(w, v)
+}
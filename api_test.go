@@ -0,0 +1,202 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d, expected 200", rr.Code)
+	}
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("unexpected openapi version %q", spec.OpenAPI)
+	}
+
+	// every api route must be described and every described path must exist
+	routes := make(map[string]bool)
+	err := makeRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		routes[path] = true
+		if strings.HasPrefix(path, "/api/") && spec.Paths[path] == nil {
+			t.Errorf("route %s is missing in %s", path, openAPISpecPath)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() failed with %s", err)
+	}
+	for path := range spec.Paths {
+		if !routes[path] {
+			t.Errorf("%s in %s is not a registered route", path, openAPISpecPath)
+		}
+	}
+}
+
+func TestNotFoundAndMethodNotAllowed(t *testing.T) {
+	app := newTestApp(t, "errors", nil)
+	defer closeTestApp(app)
+
+	tests := []struct {
+		method      string
+		url         string
+		status      int
+		contentType string
+		allow       string
+	}{
+		{"GET", "/api/v1/nothing", 404, "application/json", ""},
+		{"GET", "/nothing", 404, "", ""},
+		{"GET", "/admin/backup", 405, "text/plain; charset=utf-8", "POST"},
+		{"POST", "/api/v1/apps/errors/issues", 405, "application/json", "GET"},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest(test.method, test.url, nil))
+		if rr.Code != test.status {
+			t.Fatalf("%s %s: got status %d, expected %d", test.method, test.url, rr.Code, test.status)
+		}
+		if test.contentType != "" && rr.Header().Get("Content-Type") != test.contentType {
+			t.Fatalf("%s %s: got content type %q, expected %q", test.method, test.url, rr.Header().Get("Content-Type"), test.contentType)
+		}
+		if rr.Header().Get("Allow") != test.allow {
+			t.Fatalf("%s %s: got Allow %q, expected %q", test.method, test.url, rr.Header().Get("Allow"), test.allow)
+		}
+		if test.contentType == "application/json" {
+			var res struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil || res.Error == "" {
+				t.Fatalf("%s %s: invalid json error %q", test.method, test.url, rr.Body.String())
+			}
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/nothing", nil))
+	if !strings.Contains(rr.Body.String(), "Page /nothing doesn't exist") {
+		t.Fatalf("unexpected 404 page:\n%s", rr.Body.String())
+	}
+}
+
+func TestAPIAppSources(t *testing.T) {
+	app := newTestApp(t, "sources", []string{"World", "Hello", "Apple"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Hello", "Cześć", "pl", "user1")
+	if err := app.store.SetNoTranslate("Apple", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+	if err := app.store.SetMaxLen("World", 10); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+
+	get := func(url string, v interface{}) {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("GET %s: got status %d, expected 200", url, rr.Code)
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+			t.Fatalf("json.Unmarshal() failed with %s", err)
+		}
+	}
+
+	var res struct {
+		App     string   `json:"app"`
+		Sources []string `json:"sources"`
+	}
+	get("/api/v1/apps/sources/sources", &res)
+	if strings.Join(res.Sources, ",") != "Apple,Hello,World" {
+		t.Fatalf("got sources %v", res.Sources)
+	}
+
+	var withMeta struct {
+		Sources []APISource `json:"sources"`
+	}
+	get("/api/v1/apps/sources/sources?withmeta=1", &withMeta)
+	expected := []APISource{{Source: "Apple", NoTranslate: true}, {Source: "Hello"}, {Source: "World", MaxLen: 10}}
+	if len(withMeta.Sources) != len(expected) {
+		t.Fatalf("got %d sources, expected %d", len(withMeta.Sources), len(expected))
+	}
+	for i, s := range withMeta.Sources {
+		if s != expected[i] {
+			t.Errorf("got %#v, expected %#v", s, expected[i])
+		}
+	}
+}
+
+func TestAPIAppString(t *testing.T) {
+	modified := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	defer setClock(setClock(store.NewFakeClock(modified)))
+	app := newTestApp(t, "string", []string{"Hello", "World"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Hello", "Cześć", "pl", "user1")
+	writeTestTranslation(t, app, "Hello", "Hallo", "de", "user1")
+	if err := app.store.SetState("Hello", "pl", store.StateApproved); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+	if err := app.store.SetMaxLen("Hello", 10); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		return rr
+	}
+	rr := get("/api/v1/apps/string/string?src=Hello")
+	if rr.Code != 200 {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	var res APIString
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	if res.Source != "Hello" || res.MaxLen != 10 || res.NoTranslate || res.Context != "" {
+		t.Fatalf("got %#v", res)
+	}
+	if len(res.Translations) != store.LangsCount() {
+		t.Fatalf("got %d translations, expected one for each of %d languages", len(res.Translations), store.LangsCount())
+	}
+	expected := map[string]APIStringTranslation{
+		"pl": {Value: "Cześć", State: store.StateApproved, ModifiedAt: &modified},
+		"de": {Value: "Hallo", State: store.StateTranslated, ModifiedAt: &modified},
+		"fr": {Value: "", State: store.StateUntranslated},
+	}
+	for lang, exp := range expected {
+		got := res.Translations[lang]
+		if got == nil || got.Value != exp.Value || got.State != exp.State || (got.ModifiedAt == nil) != (exp.ModifiedAt == nil) ||
+			(got.ModifiedAt != nil && !got.ModifiedAt.Equal(*exp.ModifiedAt)) {
+			t.Errorf("%s: got %#v, expected %#v", lang, got, exp)
+		}
+	}
+	// untranslated languages don't have modification time
+	if strings.Count(rr.Body.String(), `"modified_at"`) != 2 {
+		t.Fatalf("unexpected modified_at in %s", rr.Body.String())
+	}
+
+	if rr = get("/api/v1/apps/string/string?src=Missing"); rr.Code != 404 {
+		t.Fatalf("missing string: got status %d", rr.Code)
+	}
+	if rr = get("/api/v1/apps/string/string"); rr.Code != 400 {
+		t.Fatalf("no src: got status %d", rr.Code)
+	}
+}
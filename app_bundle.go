@@ -0,0 +1,157 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// AppBundle is the entire state of an app in a single json file, used to
+// move the app to another instance. Migration is a two-step operation:
+// add Config (with a new UploadSecret) to config.json of the new instance
+// and upload the bundle with /admin/import/{appname}
+type AppBundle struct {
+	// UploadSecret and StringsWebhook (which has secrets) are not exported.
+	// Glossary is the current glossary, including the one uploaded with
+	// /uploadglossary
+	Config     AppConfig
+	Strings    *store.Bundle
+	Namespaces map[string]*store.Bundle `json:",omitempty"`
+}
+
+// ExportBundle returns the entire state of the app
+func (a *App) ExportBundle() *AppBundle {
+	b := &AppBundle{
+		Config:  a.AppConfig,
+		Strings: a.store.ExportBundle(),
+	}
+	b.Config.UploadSecret = ""
+	b.Config.StringsWebhook = nil
+	a.mu.Lock()
+	b.Config.Glossary = a.glossary
+	a.mu.Unlock()
+	for _, ns := range a.Namespaces() {
+		if b.Namespaces == nil {
+			b.Namespaces = make(map[string]*store.Bundle)
+		}
+		b.Namespaces[ns] = a.NamespaceStore(ns).ExportBundle()
+	}
+	return b
+}
+
+// ImportBundle recreates strings, translations and glossary of the app from
+// a bundle. The app must not have any strings yet. Other settings from
+// b.Config are not used, they're taken from config.json
+func (a *App) ImportBundle(b *AppBundle) error {
+	if b.Strings == nil {
+		return errors.New("bundle has no strings")
+	}
+	if err := validateGlossary(b.Config.Glossary); err != nil {
+		return err
+	}
+	if err := b.Strings.Validate(); err != nil {
+		return err
+	}
+	for ns, nsBundle := range b.Namespaces {
+		if ns == defaultNamespace || !isValidNamespace(ns) {
+			return fmt.Errorf("invalid namespace %q", ns)
+		}
+		if err := nsBundle.Validate(); err != nil {
+			return fmt.Errorf("namespace %q: %s", ns, err)
+		}
+	}
+	for _, st := range a.allStores() {
+		if !st.IsEmpty() {
+			return fmt.Errorf("app %q already has strings", a.Name)
+		}
+	}
+
+	if err := a.store.ImportBundle(b.Strings); err != nil {
+		return err
+	}
+	for ns, nsBundle := range b.Namespaces {
+		st, err := a.createNamespace(ns)
+		if err != nil {
+			return err
+		}
+		if err = st.ImportBundle(nsBundle); err != nil {
+			return fmt.Errorf("namespace %q: %s", ns, err)
+		}
+	}
+	if len(b.Config.Glossary) > 0 {
+		if err := writeGlossary(a.glossaryFilePath(), b.Config.Glossary); err != nil {
+			return err
+		}
+		a.SetGlossary(b.Config.Glossary)
+	}
+	return nil
+}
+
+// url: /admin/export/{appname}.json
+// Downloads the entire state of the app as AppBundle
+func handleAdminExport(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't export the app")
+		return
+	}
+	d, err := json.MarshalIndent(app.ExportBundle(), "", "  ")
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleAdminExport(): json.MarshalIndent() failed with %s", err)
+		http.Error(w, "Failed to export the app", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", app.Name+".json"))
+	w.Write(d)
+}
+
+// url: POST /admin/import/{appname}
+// POST body is AppBundle in json format, as returned by /admin/export. The
+// app must be in config.json and must not have any strings yet
+func handleAdminImport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't import the app")
+		return
+	}
+	d, err := ioutil.ReadAll(r.Body)
+	if isRequestTooLarge(err) {
+		httpRequestTooLarge(w)
+		return
+	}
+	if err != nil {
+		httpErrorf(w, "Failed to read the bundle: %s", err)
+		return
+	}
+	var b AppBundle
+	if err = json.Unmarshal(d, &b); err != nil {
+		httpErrorf(w, "Error parsing the bundle: %s", err)
+		return
+	}
+	if err = app.ImportBundle(&b); err != nil {
+		httpErrorf(w, "Failed to import the bundle: %s", err)
+		return
+	}
+	logger.ForRequest(r).Noticef("handleAdminImport(): imported %d strings into %s", len(b.Strings.Strings), appName)
+	fmt.Fprintf(w, "Imported %d strings\n", len(b.Strings.Strings))
+}
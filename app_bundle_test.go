@@ -0,0 +1,66 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAppBundleExportImport(t *testing.T) {
+	src := newTestApp(t, "bundlesrc", []string{"Open", "Close"})
+	defer closeTestApp(src)
+	writeTestTranslation(t, src, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, src, "Close", "Schließen", "de", "user2")
+	menu, err := src.createNamespace("menu")
+	if err != nil {
+		t.Fatalf("createNamespace() failed with %s", err)
+	}
+	if _, _, _, err = menu.UpdateStringsList([]string{"File"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+
+	handler := makeHTTPServer().Handler
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequestWithCookie("GET", "/admin/export/bundlesrc.json", &SecureCookieValue{User: "user1"}))
+	if rr.Code != 400 {
+		t.Fatalf("non-admin: got status %d, expected 400", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequestWithCookie("GET", "/admin/export/bundlesrc.json", &SecureCookieValue{User: "admin"}))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d, expected 200", rr.Code)
+	}
+	d := rr.Body.Bytes()
+	var b AppBundle
+	if err = json.Unmarshal(d, &b); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	if b.Config.Name != "bundlesrc" || b.Config.UploadSecret != "" {
+		t.Fatalf("unexpected config %#v", b.Config)
+	}
+
+	dst := newTestApp(t, "bundledst", nil)
+	defer closeTestApp(dst)
+	importBundle := func() int {
+		r := newRequestWithCookie("POST", "/admin/import/bundledst", &SecureCookieValue{User: "admin"})
+		r.Body = ioutil.NopCloser(bytes.NewReader(d))
+		r.ContentLength = int64(len(d))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	if code := importBundle(); code != 200 {
+		t.Fatalf("import: got status %d, expected 200", code)
+	}
+	got, want := dst.ExportBundle(), src.ExportBundle()
+	if !reflect.DeepEqual(got.Strings, want.Strings) || !reflect.DeepEqual(got.Namespaces, want.Namespaces) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	if code := importBundle(); code != 400 {
+		t.Fatalf("second import: got status %d, expected 400", code)
+	}
+}
@@ -0,0 +1,205 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// TranslationChange describes a difference in translation of a string
+type TranslationChange struct {
+	Lang     string `json:"lang"`
+	String   string `json:"string"`
+	Snapshot string `json:"snapshot"`
+	Live     string `json:"live"`
+}
+
+// SnapshotDiff describes how live data of an app differs from a backup
+// snapshot. Added means present in live data but not in the snapshot, i.e.
+// it would be lost by restoring the snapshot
+type SnapshotDiff struct {
+	Snapshot            string               `json:"snapshot"`
+	AddedStrings        []string             `json:"added_strings"`
+	RemovedStrings      []string             `json:"removed_strings"`
+	AddedTranslations   []*TranslationChange `json:"added_translations"`
+	RemovedTranslations []*TranslationChange `json:"removed_translations"`
+	ChangedTranslations []*TranslationChange `json:"changed_translations"`
+}
+
+// findSnapshot returns name of backup file whose base name is snapshot
+func findSnapshot(bs BackupStore, snapshot string) (string, error) {
+	files, err := bs.List()
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if isBackupFile(f.Name) && path.Base(f.Name) == snapshot {
+			return f.Name, nil
+		}
+	}
+	return "", fmt.Errorf("snapshot %q doesn't exist", snapshot)
+}
+
+// readSnapshotStore extracts translations of the app from a zipped backup
+// and returns their languages info
+func readSnapshotStore(zipData []byte, app *App) ([]*store.LangInfo, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.ToSlash(filepath.Join(app.DataDir, "translations.csv"))
+	for _, f := range zr.File {
+		if filepath.ToSlash(f.Name) != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		d, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		tmp, err := ioutil.TempFile("", "apptranslator-snapshot")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+		_, err = tmp.Write(d)
+		tmp.Close()
+		if err != nil {
+			return nil, err
+		}
+		s, err := store.NewStoreCsv(tmp.Name())
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		return s.LangInfos(), nil
+	}
+	return nil, fmt.Errorf("snapshot doesn't have %q", name)
+}
+
+// all languages have the same set of active strings
+func activeStrings(langs []*store.LangInfo) map[string]bool {
+	res := make(map[string]bool)
+	if len(langs) > 0 {
+		for _, tr := range langs[0].ActiveStrings {
+			res[tr.String] = true
+		}
+	}
+	return res
+}
+
+func diffSnapshot(snapshot string, snapLangs, liveLangs []*store.LangInfo) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		Snapshot:            snapshot,
+		AddedStrings:        []string{},
+		RemovedStrings:      []string{},
+		AddedTranslations:   []*TranslationChange{},
+		RemovedTranslations: []*TranslationChange{},
+		ChangedTranslations: []*TranslationChange{},
+	}
+	snap := translationsByLang(snapLangs)
+	live := translationsByLang(liveLangs)
+	snapStrs, liveStrs := activeStrings(snapLangs), activeStrings(liveLangs)
+	for str := range liveStrs {
+		if !snapStrs[str] {
+			diff.AddedStrings = append(diff.AddedStrings, str)
+		}
+	}
+	for str := range snapStrs {
+		if !liveStrs[str] {
+			diff.RemovedStrings = append(diff.RemovedStrings, str)
+		}
+	}
+	sort.Strings(diff.AddedStrings)
+	sort.Strings(diff.RemovedStrings)
+
+	for _, li := range store.Languages {
+		lang := li.Code
+		strs := make(map[string]bool)
+		for str := range live[lang] {
+			strs[str] = true
+		}
+		for str := range snap[lang] {
+			strs[str] = true
+		}
+		for str := range strs {
+			c := &TranslationChange{Lang: lang, String: str, Snapshot: snap[lang][str], Live: live[lang][str]}
+			switch {
+			case c.Snapshot == c.Live:
+				continue
+			case c.Snapshot == "":
+				diff.AddedTranslations = append(diff.AddedTranslations, c)
+			case c.Live == "":
+				diff.RemovedTranslations = append(diff.RemovedTranslations, c)
+			default:
+				diff.ChangedTranslations = append(diff.ChangedTranslations, c)
+			}
+		}
+	}
+	for _, changes := range [][]*TranslationChange{diff.AddedTranslations, diff.RemovedTranslations, diff.ChangedTranslations} {
+		sortTranslationChanges(changes)
+	}
+	return diff
+}
+
+func sortTranslationChanges(changes []*TranslationChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Lang != changes[j].Lang {
+			return changes[i].Lang < changes[j].Lang
+		}
+		return changes[i].String < changes[j].String
+	})
+}
+
+// url: /admin/diff/{appname}?snapshot=${snapshot}
+// Returns differences between live data of the app and a backup snapshot,
+// in json format. snapshot is the name of backup file, without directory
+func handleBackupDiff(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't see backups")
+		return
+	}
+	if backupStore == nil {
+		httpErrorf(w, "Backups are not configured")
+		return
+	}
+	snapshot := r.FormValue("snapshot")
+	key, err := findSnapshot(backupStore, snapshot)
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	d, err := backupStore.Get(key)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleBackupDiff(): Get(%q) failed with %s", key, err)
+		http.Error(w, "Failed to download snapshot", http.StatusInternalServerError)
+		return
+	}
+	snapLangs, err := readSnapshotStore(d, app)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleBackupDiff(): readSnapshotStore(%q) failed with %s", key, err)
+		http.Error(w, "Failed to read snapshot", http.StatusInternalServerError)
+		return
+	}
+	serveJSON(w, diffSnapshot(snapshot, snapLangs, app.store.LangInfos()))
+}
@@ -0,0 +1,144 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeBackupStore keeps backup files in memory
+type fakeBackupStore struct {
+	files map[string][]byte
+	times map[string]time.Time
+}
+
+func newFakeBackupStore() *fakeBackupStore {
+	return &fakeBackupStore{
+		files: make(map[string][]byte),
+		times: make(map[string]time.Time),
+	}
+}
+
+func (s *fakeBackupStore) List() ([]BackupInfo, error) {
+	var res []BackupInfo
+	for name, d := range s.files {
+		res = append(res, BackupInfo{Name: name, Time: s.times[name], Size: int64(len(d))})
+	}
+	return res, nil
+}
+
+func (s *fakeBackupStore) Put(local, remote string) error {
+	d, err := ioutil.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	s.files[remote] = d
+	s.times[remote] = time.Now()
+	return nil
+}
+
+func (s *fakeBackupStore) Get(remote string) ([]byte, error) {
+	d, ok := s.files[remote]
+	if !ok {
+		return nil, fmt.Errorf("%q doesn't exist", remote)
+	}
+	return d, nil
+}
+
+func (s *fakeBackupStore) Del(remote string) error {
+	delete(s.files, remote)
+	delete(s.times, remote)
+	return nil
+}
+
+// zipFiles returns zip archive with given files, indexed by name
+func zipFiles(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, d := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zw.Create() failed with %s", err)
+		}
+		w.Write(d)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close() failed with %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBackupDiff(t *testing.T) {
+	snap := newTestApp(t, "snap", []string{"Open", "Close", "Exit"})
+	writeTestTranslation(t, snap, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, snap, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, snap, "Open", "Öffnen", "de", "user1")
+	snapData, err := ioutil.ReadFile(filepath.Join(snap.DataDir, "translations.csv"))
+	closeTestApp(snap)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile() failed with %s", err)
+	}
+
+	app := newTestApp(t, "diff", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Save", "Zapisz", "pl", "user1")
+	writeTestTranslation(t, app, "Open", "Offnen", "de", "user1")
+
+	const snapshot = "121011_1121_c7fedc06cf4b08fef66090eaa0ad7a68dc13a325.zip"
+	bs := newFakeBackupStore()
+	name := filepath.Join(app.DataDir, "translations.csv")
+	bs.files["apptranslator/"+snapshot] = zipFiles(t, map[string][]byte{name: snapData})
+	backupStore = bs
+	defer func() { backupStore = nil }()
+
+	url := "/admin/diff/diff?snapshot=" + snapshot
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, newRequestWithCookie("GET", url, &SecureCookieValue{User: "admin"}))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d, body %s", rr.Code, rr.Body.String())
+	}
+	var got SnapshotDiff
+	if err = json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	exp := SnapshotDiff{
+		Snapshot:       snapshot,
+		AddedStrings:   []string{"Save"},
+		RemovedStrings: []string{"Exit"},
+		AddedTranslations: []*TranslationChange{
+			{Lang: "pl", String: "Save", Live: "Zapisz"},
+		},
+		RemovedTranslations: []*TranslationChange{
+			{Lang: "pl", String: "Close", Snapshot: "Zamknij"},
+		},
+		ChangedTranslations: []*TranslationChange{
+			{Lang: "de", String: "Open", Snapshot: "Öffnen", Live: "Offnen"},
+		},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %#v, expected %#v", got, exp)
+	}
+
+	for _, test := range []struct {
+		url, user string
+	}{
+		{url, ""},
+		{"/admin/diff/diff?snapshot=missing.zip", "admin"},
+		{"/admin/diff/nosuchapp?snapshot=" + snapshot, "admin"},
+	} {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, newRequestWithCookie("GET", test.url, &SecureCookieValue{User: test.user}))
+		if rr.Code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", test.url, rr.Code)
+		}
+	}
+}
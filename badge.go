@@ -0,0 +1,99 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// badge colors by minimum percentage of translated strings, like colors
+// of shields.io badges
+var badgeColors = []struct {
+	minPercent int
+	color      string
+}{
+	{100, "#4c1"},
+	{90, "#97ca00"},
+	{70, "#dfb317"},
+	{40, "#fe7d37"},
+	{0, "#e05d44"},
+}
+
+func badgeColor(percent int) string {
+	for _, c := range badgeColors {
+		if percent >= c.minPercent {
+			return c.color
+		}
+	}
+	return badgeColors[len(badgeColors)-1].color
+}
+
+// translatedPercent returns percentage of translated strings, rounded down
+// so that 100% means that everything is translated
+func translatedPercent(p *LangProgress) int {
+	if p.Strings == 0 {
+		return 100
+	}
+	return (p.Strings - p.Untranslated) * 100 / p.Strings
+}
+
+// approximate width of text in the badge font, in pixels
+func badgeTextWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
+
+// buildBadge returns svg of a badge with label on the left and value on
+// the right, in the style of shields.io badges
+func buildBadge(label, value, color string) string {
+	lw, vw := badgeTextWidth(label), badgeTextWidth(value)
+	label, value = html.EscapeString(label), html.EscapeString(value)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text>
+</g>
+</svg>
+`, lw+vw, label, value, lw+vw, lw, lw, vw, color, lw+vw, lw/2, label, lw+vw/2, value)
+}
+
+// badges are embedded in pages of other sites, which shouldn't check for
+// changes on every view
+const badgeMaxAgeSecs = 300
+
+// url: /badge/{appname}/{lang}.svg
+// Returns an svg badge with percentage of strings translated into lang, for
+// embedding in READMEs
+func handleBadge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	app := findApp(vars["appname"])
+	if app == nil {
+		http.Error(w, fmt.Sprintf("Application %q doesn't exist", vars["appname"]), http.StatusNotFound)
+		return
+	}
+	lang, err := store.ParseLangCode(vars["lang"])
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", badgeMaxAgeSecs))
+	if modTime, err := fileModTime(app.store.FilePath()); err == nil {
+		if checkNotModified(w, r, modTime) {
+			return
+		}
+	}
+	percent := 0
+	for _, p := range buildProgress(app) {
+		if p.Lang == lang {
+			percent = p.Percent
+		}
+	}
+	label := store.LangNameByCode(lang)
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.Write([]byte(buildBadge(label, fmt.Sprintf("%d%%", percent), badgeColor(percent))))
+}
@@ -0,0 +1,56 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBadge(t *testing.T) {
+	app := newTestApp(t, "badge", []string{"Open", "Close", "Save", "Exit"})
+	defer closeTestApp(app)
+	for _, s := range []string{"Open", "Close", "Save"} {
+		writeTestTranslation(t, app, s, s+" (pl)", "pl", "user1")
+	}
+	for _, s := range []string{"Open", "Close", "Save", "Exit"} {
+		writeTestTranslation(t, app, s, s+" (de)", "de", "user1")
+	}
+
+	tests := []struct {
+		url     string
+		percent string
+		color   string
+	}{
+		{"/badge/badge/pl.svg", "75%", "#dfb317"},
+		{"/badge/badge/de.svg", "100%", "#4c1"},
+		{"/badge/badge/fr.svg", "0%", "#e05d44"},
+		// language codes are normalized
+		{"/badge/badge/PL.svg", "75%", "#dfb317"},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", test.url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: got status %d", test.url, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "image/svg+xml") {
+			t.Fatalf("%s: got Content-Type %q", test.url, ct)
+		}
+		if rr.Header().Get("Cache-Control") == "" || rr.Header().Get("Last-Modified") == "" {
+			t.Fatalf("%s: no caching headers in %v", test.url, rr.Header())
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, ">"+test.percent+"</text>") || !strings.Contains(body, `fill="`+test.color+`"`) {
+			t.Fatalf("%s: expected %s in color %s:\n%s", test.url, test.percent, test.color, body)
+		}
+	}
+
+	for url, status := range map[string]int{"/badge/nosuchapp/pl.svg": 404, "/badge/badge/xx.svg": 400} {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != status {
+			t.Errorf("%s: got status %d, expected %d", url, rr.Code, status)
+		}
+	}
+}
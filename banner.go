@@ -0,0 +1,37 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+)
+
+// name of the cookie that remembers which banner was dismissed by the user
+const bannerCookieName = "banner_dismissed"
+
+// SiteBanner is a message, like a maintenance notice, shown at the top of
+// every page
+type SiteBanner struct {
+	// plain text, markup is escaped
+	Text string
+	// changes when Text changes, so that a new banner is shown to users
+	// who dismissed the previous one
+	ID string
+}
+
+// Cookie returns name of the cookie that remembers dismissed banner
+func (b *SiteBanner) Cookie() string {
+	return bannerCookieName
+}
+
+// siteBanner returns banner configured with config.Banner or nil if there's
+// none. Used by "banner" template
+func siteBanner() *SiteBanner {
+	text := strings.TrimSpace(config.Banner)
+	if text == "" {
+		return nil
+	}
+	id := fmt.Sprintf("%x", sha1.Sum([]byte(text)))
+	return &SiteBanner{Text: text, ID: id[:8]}
+}
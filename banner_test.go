@@ -0,0 +1,42 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSiteBanner(t *testing.T) {
+	app := newTestApp(t, "banner", []string{"Open"})
+	defer closeTestApp(app)
+	defer func() { config.Banner = "" }()
+
+	get := func(url string) string {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: got status %d", url, rr.Code)
+		}
+		return rr.Body.String()
+	}
+	urls := []string{"/", "/app/banner", "/app/banner/pl", "/stats/banner"}
+	for _, url := range urls {
+		if body := get(url); strings.Contains(body, "site-banner") {
+			t.Fatalf("%s: unexpected banner in %s", url, body)
+		}
+	}
+
+	config.Banner = "Maintenance at <b>10pm</b>"
+	for _, url := range urls {
+		body := get(url)
+		if !strings.Contains(body, "Maintenance at &lt;b&gt;10pm&lt;/b&gt;") || !strings.Contains(body, bannerCookieName+"="+siteBanner().ID) {
+			t.Fatalf("%s: expected escaped banner, got %s", url, body)
+		}
+	}
+	id := siteBanner().ID
+	config.Banner = "Maintenance is over"
+	if siteBanner().ID == id {
+		t.Fatalf("banner id didn't change with its text")
+	}
+}
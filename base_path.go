@@ -0,0 +1,56 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// isValidBasePath returns true for "" and paths like "/translate", without
+// a trailing slash
+func isValidBasePath(p string) bool {
+	if p == "" {
+		return true
+	}
+	return strings.HasPrefix(p, "/") && !strings.HasSuffix(p, "/") && !strings.Contains(p, "//") && !strings.ContainsAny(p, "?#% ")
+}
+
+// withBasePath returns url of path on the public server, which is mounted
+// at config.BasePath. Handlers use paths relative to config.BasePath and
+// only links sent to browsers are prefixed
+func withBasePath(path string) string {
+	return config.BasePath + path
+}
+
+// basePathWriter prefixes redirects to local paths with config.BasePath
+type basePathWriter struct {
+	http.ResponseWriter
+}
+
+func (w *basePathWriter) WriteHeader(code int) {
+	loc := w.Header().Get("Location")
+	if strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		w.Header().Set("Location", withBasePath(loc))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// withBasePathHandler serves h at config.BasePath. Urls outside of it are
+// not found
+func withBasePathHandler(h http.Handler) http.Handler {
+	if config.BasePath == "" {
+		return h
+	}
+	strip := http.StripPrefix(config.BasePath, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == config.BasePath {
+			http.Redirect(w, r, config.BasePath+"/", http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, config.BasePath+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		strip.ServeHTTP(&basePathWriter{w}, r)
+	})
+}
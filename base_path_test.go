@@ -0,0 +1,65 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	app := newTestApp(t, "basepath", []string{"Hello"})
+	defer closeTestApp(app)
+	config.BasePath = "/translate"
+	defer func() { config.BasePath = "" }()
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		return rr
+	}
+
+	for _, url := range []string{"/translate/", "/translate/app/basepath", "/translate/s/css/bootstrap.css", "/translate/api/v1/apps/basepath/progress"} {
+		if rr := get(url); rr.Code != 200 {
+			t.Errorf("GET %s: got status %d, expected 200", url, rr.Code)
+		}
+	}
+	for _, url := range []string{"/", "/app/basepath", "/translatex/"} {
+		if rr := get(url); rr.Code != 404 {
+			t.Errorf("GET %s: got status %d, expected 404", url, rr.Code)
+		}
+	}
+	if rr := get("/translate"); rr.Code != 301 || rr.Header().Get("Location") != "/translate/" {
+		t.Errorf("GET /translate: got status %d, location %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	body := get("/translate/app/basepath").Body.String()
+	for _, s := range []string{`href="/translate/"`, `href="/translate/login?redirect=`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("app page doesn't contain %s", s)
+		}
+	}
+	if strings.Contains(body, `href="/s/`) {
+		t.Errorf("app page has links without base path")
+	}
+	body = get("/translate/app/basepath/de").Body.String()
+	if !strings.Contains(body, `href="/translate/app/basepath?`) && !strings.Contains(body, `href="/translate/app/basepath/de?`) {
+		t.Errorf("translations page doesn't have list links with base path")
+	}
+
+	rr := get("/translate/logout?redirect=/app/basepath")
+	if rr.Code != 302 || rr.Header().Get("Location") != "/translate/app/basepath" {
+		t.Errorf("GET /translate/logout: got status %d, location %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	for _, p := range []string{"", "/translate", "/a/b"} {
+		if !isValidBasePath(p) {
+			t.Errorf("isValidBasePath(%q) is false", p)
+		}
+	}
+	for _, p := range []string{"/", "translate", "/translate/", "//host"} {
+		if isValidBasePath(p) {
+			t.Errorf("isValidBasePath(%q) is true", p)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicAuthConfig describes an admin who logs in with http basic auth,
+// for instances without access to Twitter. The admin can administer all
+// apps and the server
+type BasicAuthConfig struct {
+	User string
+	// bcrypt hash of the password, e.g. from: htpasswd -nbB user password
+	PasswordHash string
+}
+
+func validateBasicAuthConfig(c *BasicAuthConfig) error {
+	if c == nil {
+		return nil
+	}
+	if strings.TrimSpace(c.User) == "" {
+		return errors.New("AdminBasicAuth.User is empty")
+	}
+	if _, err := bcrypt.Cost([]byte(c.PasswordHash)); err != nil {
+		return errors.New("AdminBasicAuth.PasswordHash is not a bcrypt hash")
+	}
+	return nil
+}
+
+// basic auth admin is known as basicAuthUserPrefix followed by
+// BasicAuthConfig.User. Twitter screen names can't have ':', so a user
+// logged in with Twitter can't be taken for the basic auth admin
+const basicAuthUserPrefix = "basic:"
+
+// basicAuthAdminUser returns user name of basic auth admin or "" if basic
+// auth is not enabled
+func basicAuthAdminUser() string {
+	if config.AdminBasicAuth == nil {
+		return ""
+	}
+	return basicAuthUserPrefix + config.AdminBasicAuth.User
+}
+
+// basicAuthAdmin returns basic auth admin if r has valid basic auth
+// credentials, "" otherwise. Invalid credentials count as failed logins
+func basicAuthAdmin(r *http.Request) string {
+	c := config.AdminBasicAuth
+	if c == nil {
+		return ""
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok || loginLimit.IsBlocked(clientIP(r)) {
+		return ""
+	}
+	// password is checked even if user doesn't match, so that the time of
+	// the check doesn't tell if the user is valid
+	userOk := subtle.ConstantTimeCompare([]byte(user), []byte(c.User)) == 1
+	passwordOk := bcrypt.CompareHashAndPassword([]byte(c.PasswordHash), []byte(password)) == nil
+	if !userOk || !passwordOk {
+		logger.ForRequest(r).Noticef("invalid basic auth credentials for user %q", user)
+		loginFailed(r)
+		return ""
+	}
+	return basicAuthUserPrefix + c.User
+}
+
+// url: GET /login/basic?redirect=$redirect
+// Asks the browser for basic auth credentials, which it then sends with
+// every request
+func handleLoginBasic(w http.ResponseWriter, r *http.Request) {
+	if config.AdminBasicAuth == nil {
+		httpErrorf(w, "Basic auth is not enabled")
+		return
+	}
+	if !checkLoginAllowed(w, r) {
+		return
+	}
+	if basicAuthAdmin(r) == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="apptranslator", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	redirect := strings.TrimSpace(r.FormValue("redirect"))
+	if redirect == "" || !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		redirect = "/"
+	}
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
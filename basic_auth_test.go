@@ -0,0 +1,76 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAdminBasicAuth(t *testing.T) {
+	app := newTestApp(t, "basicauth", nil)
+	defer closeTestApp(app)
+	hash, err := bcrypt.GenerateFromPassword([]byte("pa55"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword() failed with %s", err)
+	}
+	defer func() { config.AdminBasicAuth = nil }()
+
+	userFor := func(user, password string) string {
+		r := httptest.NewRequest("GET", "/whoami", nil)
+		r.SetBasicAuth(user, password)
+		defer loginLimit.Reset(clientIP(r))
+		return decodeUserFromCookie(r)
+	}
+
+	// disabled
+	if user := userFor("ops", "pa55"); user != "" {
+		t.Fatalf("got user %q with basic auth disabled", user)
+	}
+
+	config.AdminBasicAuth = &BasicAuthConfig{User: "ops", PasswordHash: string(hash)}
+	if err = validateBasicAuthConfig(config.AdminBasicAuth); err != nil {
+		t.Fatalf("validateBasicAuthConfig() failed with %s", err)
+	}
+	user := userFor("ops", "pa55")
+	if user != "basic:ops" || !userIsAdmin(app, user) {
+		t.Fatalf("got user %q, expected admin basic:ops", user)
+	}
+	// a Twitter user with the same name is not the basic auth admin
+	r := newRequestWithCookie("GET", "/whoami", &SecureCookieValue{User: "ops"})
+	if user := decodeUserFromCookie(r); user != "ops" || userIsAdmin(app, user) {
+		t.Fatalf("got user %q, expected ops who is not an admin", user)
+	}
+	if user := userFor("ops", "wrong"); user != "" {
+		t.Fatalf("got user %q with wrong password", user)
+	}
+	if user := userFor("admin", "pa55"); user != "" {
+		t.Fatalf("got user %q with wrong user", user)
+	}
+	// cookie takes precedence over basic auth
+	r = newRequestWithCookie("GET", "/whoami", &SecureCookieValue{User: "user1"})
+	r.SetBasicAuth("ops", "pa55")
+	if user := decodeUserFromCookie(r); user != "user1" {
+		t.Fatalf("got user %q, expected user1", user)
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/login/basic?redirect=/app/basicauth", nil))
+	if rr.Code != 401 || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("got status %d, expected 401 with WWW-Authenticate", rr.Code)
+	}
+	r = httptest.NewRequest("GET", "/login/basic?redirect=/app/basicauth", nil)
+	r.SetBasicAuth("ops", "pa55")
+	rr = httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, r)
+	if rr.Code != 302 || rr.Header().Get("Location") != "/app/basicauth" {
+		t.Fatalf("got status %d, location %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	for _, c := range []*BasicAuthConfig{{User: "", PasswordHash: string(hash)}, {User: "ops", PasswordHash: "pa55"}} {
+		if validateBasicAuthConfig(c) == nil {
+			t.Errorf("expected an error for %#v", c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/kjk/u"
+)
+
+// checkConfig is used by -check-config command line option. It reads config
+// file and checks that apps are valid and their data directories exist,
+// without starting the server. Prints a summary to w and returns the exit
+// code for the process
+func checkConfig(path string, w io.Writer) int {
+	if err := readConfig(path); err != nil {
+		fmt.Fprintf(w, "%s: %s\n", path, err)
+		return 1
+	}
+	dir := findDataDir()
+	if dir == "" {
+		fmt.Fprintf(w, "data directory (%v) doesn't exist\n", dataDirsToCheck)
+		return 1
+	}
+	fmt.Fprintf(w, "data directory: %s\n", dir)
+	if len(config.Apps) == 0 {
+		fmt.Fprintf(w, "no apps defined in %s\n", path)
+		return 1
+	}
+	nProblems := 0
+	seen := make(map[string]bool)
+	for _, appConfig := range config.Apps {
+		app := NewApp(&appConfig)
+		problem := ""
+		storePath := filepath.Join(dir, app.DataDir, "translations.csv")
+		if invalidField := appInvalidField(app); invalidField != "" {
+			problem = "invalid field " + describeInvalidField(app, invalidField)
+		} else if seen[app.Name] {
+			problem = "duplicate app name"
+		} else if !u.PathExists(storePath) {
+			problem = fmt.Sprintf("data file %s doesn't exist", storePath)
+		}
+		seen[app.Name] = true
+		if problem != "" {
+			fmt.Fprintf(w, "app %q: %s\n", app.Name, problem)
+			nProblems++
+			continue
+		}
+		fmt.Fprintf(w, "app %q: ok, data file %s\n", app.Name, storePath)
+	}
+	if nProblems > 0 {
+		fmt.Fprintf(w, "%s: %d of %d apps have problems\n", path, nProblems, len(config.Apps))
+		return 1
+	}
+	fmt.Fprintf(w, "%s: ok, %d apps\n", path, len(config.Apps))
+	return 0
+}
@@ -0,0 +1,63 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const checkConfigTestKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestCheckConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apptranslator-test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = os.Mkdir(filepath.Join(dir, "sumatra"), 0755); err != nil {
+		t.Fatalf("os.Mkdir() failed with %s", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "sumatra", "translations.csv"), nil, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+	savedConfig, savedDataDir, savedSecureCookies := config, dataDir, secureCookies
+	defer func() { config, dataDir, secureCookies = savedConfig, savedDataDir, savedSecureCookies }()
+	dataDir = dir
+
+	check := func(apps string) (int, string) {
+		config = savedConfig
+		path := filepath.Join(dir, "config.json")
+		conf := `{"CookieAuthKeyHexStr": "` + checkConfigTestKey + `", "CookieEncrKeyHexStr": "` + checkConfigTestKey + `", "Apps": [` + apps + `]}`
+		if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+		var buf bytes.Buffer
+		code := checkConfig(path, &buf)
+		return code, buf.String()
+	}
+
+	sumatra := `{"Name": "SumatraPDF", "DataDir": "sumatra", "AdminTwitterUser": "kjk", "UploadSecret": "secret"}`
+	if code, out := check(sumatra); code != 0 || !strings.Contains(out, "ok, 1 apps") {
+		t.Fatalf("valid config: got %d, %q", code, out)
+	}
+	tests := []struct {
+		apps string
+		msg  string
+	}{
+		{"", "no apps defined"},
+		{`{"Name": "Other", "DataDir": "other", "AdminTwitterUser": "kjk", "UploadSecret": "secret"}`, "doesn't exist"},
+		{`{"Name": "SumatraPDF", "DataDir": "sumatra", "UploadSecret": "secret"}`, `invalid field "AdminTwitterUser"`},
+		{sumatra + "," + sumatra, "duplicate app name"},
+		{"{", "invalid character"},
+	}
+	for _, test := range tests {
+		code, out := check(test.apps)
+		if code != 1 || !strings.Contains(out, test.msg) {
+			t.Errorf("%s: got %d, %q, expected %q", test.apps, code, out, test.msg)
+		}
+	}
+}
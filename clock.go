@@ -0,0 +1,17 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import "github.com/kjk/apptranslator/store"
+
+// clock is used instead of time.Now() and time.Sleep(), so that tests can
+// control time with store.FakeClock
+var clock = store.RealClock
+
+// setClock sets the clock of the server and of stores. Returns the previous
+// clock
+func setClock(c store.Clock) store.Clock {
+	prev := clock
+	clock = c
+	store.SetClock(c)
+	return prev
+}
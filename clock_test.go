@@ -0,0 +1,73 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestFakeClockBackupInterval(t *testing.T) {
+	fake := store.NewFakeClock(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	defer setClock(setClock(fake))
+	app := newTestApp(t, "clockbackup", []string{"Open"})
+	defer closeTestApp(app)
+	app.BackupFreqHours = 1
+	defer func() {
+		backupMu.Lock()
+		delete(lastAppBackup, "s3")
+		backupMu.Unlock()
+	}()
+
+	bs := newFakeBackupStore()
+	targets := []*BackupTarget{{Config: &BackupConfig{Name: "s3", S3Dir: "apptranslator", LocalDir: app.DataDir}, Store: bs}}
+	backup := func() time.Time {
+		if _, err := doBackup(targets, false); err != nil {
+			t.Fatalf("doBackup() failed with %s", err)
+		}
+		backupMu.Lock()
+		defer backupMu.Unlock()
+		return lastAppBackup["s3"][app.Name]
+	}
+
+	start := fake.Now()
+	if last := backup(); !last.Equal(start) {
+		t.Fatalf("app was backed up at %s, expected %s", last, start)
+	}
+	fake.Advance(30 * time.Minute)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	if last := backup(); !last.Equal(start) {
+		t.Fatalf("app was backed up before its interval passed, at %s", last)
+	}
+	fake.Advance(31 * time.Minute)
+	if last := backup(); !last.Equal(fake.Now()) {
+		t.Fatalf("app was backed up at %s, expected %s", last, fake.Now())
+	}
+
+	// edits are timestamped with the clock
+	edits := app.store.RecentEdits(1)
+	if len(edits) != 1 || !edits[0].Time.Equal(start.Add(30*time.Minute)) {
+		t.Fatalf("unexpected edits %#v", edits)
+	}
+}
+
+func TestFakeClockTimingHandler(t *testing.T) {
+	fake := store.NewFakeClock(time.Now())
+	defer setClock(setClock(fake))
+
+	slow := makeTimingHandler(func(w http.ResponseWriter, r *http.Request) {
+		clock.Sleep(2 * time.Second)
+	})
+	slow(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow?x=1", nil))
+	notices := logger.Notices.GetOrdered()
+	if len(notices) == 0 || !strings.Contains(notices[0].Msg, `"/slow?x=1" took 2.000000 seconds`) {
+		t.Fatalf("slow request was not logged")
+	}
+	if !notices[0].Time.Equal(fake.Now()) {
+		t.Fatalf("notice logged at %s, expected %s", notices[0].Time, fake.Now())
+	}
+}
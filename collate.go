@@ -0,0 +1,50 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// newCollator returns a collator that sorts strings according to the rules
+// of locale, which can be one of our language codes or a BCP 47 tag. Empty
+// locale gives neutral collation, which is better than byte order for
+// accented characters in any language. Collator is not safe for concurrent
+// use
+func newCollator(locale string) (*collate.Collator, error) {
+	tag := language.Und
+	if locale != "" {
+		var err error
+		// machine translation services use BCP 47 codes
+		if tag, err = language.Parse(machineLangCode(locale)); err != nil {
+			return nil, fmt.Errorf("invalid locale %q", locale)
+		}
+	}
+	return collate.New(tag), nil
+}
+
+// collatedLess compares strings with c, using byte order for strings that
+// collate as equal so that the order is deterministic. nil c means neutral
+// collation
+func collatedLess(c *collate.Collator) func(s1, s2 string) bool {
+	if c == nil {
+		c = collate.New(language.Und)
+	}
+	return func(s1, s2 string) bool {
+		if n := c.CompareString(s1, s2); n != 0 {
+			return n < 0
+		}
+		return s1 < s2
+	}
+}
+
+// sortStringsCollated sorts strs with c. nil c means neutral collation
+func sortStringsCollated(strs []string, c *collate.Collator) {
+	less := collatedLess(c)
+	sort.Slice(strs, func(i, j int) bool {
+		return less(strs[i], strs[j])
+	})
+}
@@ -0,0 +1,41 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSortStringsCollated(t *testing.T) {
+	strs := []string{"Zebra", "éclair", "apple", "Über", "eagle", "über", "Ärger"}
+
+	byteSorted := append([]string(nil), strs...)
+	sort.Strings(byteSorted)
+	exp := []string{"Zebra", "apple", "eagle", "Ärger", "Über", "éclair", "über"}
+	if !reflect.DeepEqual(byteSorted, exp) {
+		t.Fatalf("got %v, expected %v", byteSorted, exp)
+	}
+
+	collated := append([]string(nil), strs...)
+	sortStringsCollated(collated, nil)
+	exp = []string{"apple", "Ärger", "eagle", "éclair", "über", "Über", "Zebra"}
+	if !reflect.DeepEqual(collated, exp) {
+		t.Fatalf("got %v, expected %v", collated, exp)
+	}
+
+	// in Swedish "ä" goes after "z" and "ü" is sorted as "y"
+	c, err := newCollator("sv")
+	if err != nil {
+		t.Fatalf("newCollator() failed with %s", err)
+	}
+	sortStringsCollated(collated, c)
+	exp = []string{"apple", "eagle", "éclair", "über", "Über", "Zebra", "Ärger"}
+	if !reflect.DeepEqual(collated, exp) {
+		t.Fatalf("got %v, expected %v", collated, exp)
+	}
+
+	if _, err = newCollator("not a locale!"); err == nil {
+		t.Fatalf("newCollator() should fail for invalid locale")
+	}
+}
@@ -0,0 +1,54 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// CompactResult is returned by /admin/compact/{appname}. Sizes and numbers
+// of records are totals of store files of all namespaces of the app
+type CompactResult struct {
+	App            string `json:"app"`
+	SizeBefore     int64  `json:"size_before"`
+	SizeAfter      int64  `json:"size_after"`
+	RecordsBefore  int    `json:"records_before"`
+	RecordsAfter   int    `json:"records_after"`
+	RecordsRemoved int    `json:"records_removed"`
+}
+
+// url: POST /admin/compact/{appname}
+// Compacts store files of the app now, instead of waiting for the periodic
+// compaction, e.g. after a big cleanup and before a backup
+func handleAdminCompact(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't compact this app")
+		return
+	}
+	res := &CompactResult{App: app.Name}
+	for _, st := range app.allStores() {
+		stats, err := st.CompactWithStats()
+		if err != nil {
+			logger.ForRequest(r).Errorf("handleAdminCompact(): CompactWithStats() of %s failed with %s", st.FilePath(), err)
+			http.Error(w, "Failed to compact the app", http.StatusInternalServerError)
+			return
+		}
+		res.SizeBefore += stats.SizeBefore
+		res.SizeAfter += stats.SizeAfter
+		res.RecordsBefore += stats.RecordsBefore
+		res.RecordsAfter += stats.RecordsAfter
+	}
+	res.RecordsRemoved = res.RecordsBefore - res.RecordsAfter
+	logger.ForRequest(r).Noticef("handleAdminCompact(): compacted %s from %d to %d bytes", app.Name, res.SizeBefore, res.SizeAfter)
+	serveJSON(w, res)
+}
@@ -0,0 +1,59 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAdminCompact(t *testing.T) {
+	app := newTestApp(t, "compact", []string{"Open", "Close"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	// only the last state of the flag is kept by compaction
+	for i := 0; i < 20; i++ {
+		if err := app.store.SetNoTranslate("Close", i%2 == 0); err != nil {
+			t.Fatalf("SetNoTranslate() failed with %s", err)
+		}
+	}
+
+	compact := func(user string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := newRequestWithCookie("POST", "/admin/compact/compact", &SecureCookieValue{User: user})
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	if rr := compact("user1"); rr.Code != 400 {
+		t.Fatalf("non-admin: got status %d", rr.Code)
+	}
+	rr := compact("admin")
+	if rr.Code != 200 {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	var res CompactResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	fi, err := os.Stat(app.store.FilePath())
+	if err != nil {
+		t.Fatalf("os.Stat() failed with %s", err)
+	}
+	if res.SizeAfter != fi.Size() || res.SizeAfter >= res.SizeBefore {
+		t.Fatalf("got sizes %d => %d, file size %d", res.SizeBefore, res.SizeAfter, fi.Size())
+	}
+	if res.RecordsRemoved < 19 || res.RecordsRemoved != res.RecordsBefore-res.RecordsAfter {
+		t.Fatalf("got records %d => %d, removed %d", res.RecordsBefore, res.RecordsAfter, res.RecordsRemoved)
+	}
+	if app.store.IsNoTranslate("Close") || app.store.EditsCount() != 1 {
+		t.Fatalf("compaction changed the content")
+	}
+
+	// compacting again doesn't change anything
+	rr = compact("admin")
+	res = CompactResult{}
+	if err = json.Unmarshal(rr.Body.Bytes(), &res); err != nil || res.RecordsRemoved != 0 || res.SizeBefore != res.SizeAfter {
+		t.Fatalf("got %#v, %v", res, err)
+	}
+}
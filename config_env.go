@@ -0,0 +1,69 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// configEnvRx matches references to environment variables in config values,
+// e.g. "AwsSecret": "${AWS_SECRET}"
+var configEnvRx = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandConfigEnv replaces ${VAR} in string values of json config d with
+// values of environment variables, so that secrets don't have to be in the
+// config file. It's an error if a variable is not set
+func expandConfigEnv(d []byte) ([]byte, error) {
+	if !bytes.Contains(d, []byte("${")) {
+		return d, nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(d))
+	// keep numbers as they are written
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := expandEnvValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func expandEnvValue(v interface{}) (interface{}, error) {
+	var err error
+	switch v := v.(type) {
+	case string:
+		return expandEnvString(v)
+	case map[string]interface{}:
+		for key, val := range v {
+			if v[key], err = expandEnvValue(val); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			if v[i], err = expandEnvValue(val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+func expandEnvString(s string) (string, error) {
+	var err error
+	res := configEnvRx.ReplaceAllStringFunc(s, func(ref string) string {
+		name := configEnvRx.FindStringSubmatch(ref)[1]
+		val, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s used in config is not set", name)
+		}
+		return val
+	})
+	return res, err
+}
@@ -0,0 +1,68 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandConfigEnv(t *testing.T) {
+	os.Setenv("AT_TEST_AWS_SECRET", "s3cr3t")
+	os.Setenv("AT_TEST_EMPTY", "")
+	defer os.Unsetenv("AT_TEST_AWS_SECRET")
+	defer os.Unsetenv("AT_TEST_EMPTY")
+	os.Unsetenv("AT_TEST_MISSING")
+
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{`{"AwsSecret":"${AT_TEST_AWS_SECRET}"}`, `{"AwsSecret":"s3cr3t"}`},
+		{`{"Apps":[{"UploadSecret":"pre-${AT_TEST_AWS_SECRET}-${AT_TEST_EMPTY}post"}]}`, `{"Apps":[{"UploadSecret":"pre-s3cr3t-post"}]}`},
+		// numbers and other values are not changed
+		{`{"AwsSecret":"${AT_TEST_AWS_SECRET}","MaxUploadBytes":12345678901234567890,"ReadOnly":true}`, `{"AwsSecret":"s3cr3t","MaxUploadBytes":12345678901234567890,"ReadOnly":true}`},
+		// only ${VAR} is expanded
+		{`{"AwsSecret":"$AT_TEST_AWS_SECRET {AT_TEST_AWS_SECRET}"}`, `{"AwsSecret":"$AT_TEST_AWS_SECRET {AT_TEST_AWS_SECRET}"}`},
+	}
+	for _, test := range tests {
+		got, err := expandConfigEnv([]byte(test.in))
+		if err != nil || string(got) != test.exp {
+			t.Errorf("expandConfigEnv(%s): got %s, %v, expected %s", test.in, got, err, test.exp)
+		}
+	}
+
+	_, err := expandConfigEnv([]byte(`{"AwsSecret":"${AT_TEST_MISSING}"}`))
+	if err == nil || !strings.Contains(err.Error(), "AT_TEST_MISSING") {
+		t.Fatalf("got error %v, expected an error about AT_TEST_MISSING", err)
+	}
+
+	// in config files
+	dir, err := ioutil.TempDir("", "configenv")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	savedConfig, savedSecureCookies := config, secureCookies
+	defer func() { config, secureCookies = savedConfig, savedSecureCookies }()
+	conf := "CookieAuthKeyHexStr: " + checkConfigTestKey + "\nCookieEncrKeyHexStr: " + checkConfigTestKey + "\nAwsSecret: ${AT_TEST_AWS_SECRET}\nAwsAccess: literal\n"
+	path := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+	if err = readConfig(path); err != nil {
+		t.Fatalf("readConfig() failed with %s", err)
+	}
+	if stringEmpty(config.AwsSecret) || *config.AwsSecret != "s3cr3t" || stringEmpty(config.AwsAccess) || *config.AwsAccess != "literal" {
+		t.Fatalf("got AwsSecret %v, AwsAccess %v", config.AwsSecret, config.AwsAccess)
+	}
+	conf = strings.Replace(conf, "AT_TEST_AWS_SECRET", "AT_TEST_MISSING", 1)
+	if err = ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+	if err = readConfig(path); err == nil || !strings.Contains(err.Error(), "AT_TEST_MISSING") {
+		t.Fatalf("got error %v, expected an error about AT_TEST_MISSING", err)
+	}
+}
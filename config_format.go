@@ -0,0 +1,72 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// configToJSON converts content d of config file path to json, so that
+// config files in all formats are read the same way, with the same names
+// of fields. Format is detected from extension of path: .yaml or .yml for
+// YAML, .toml for TOML, anything else is json and is returned as is
+func configToJSON(path string, d []byte) ([]byte, error) {
+	var v interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(d, &v); err != nil {
+			return nil, err
+		}
+		v = yamlToJSONValue(v)
+	case ".toml":
+		var m map[string]interface{}
+		if _, err := toml.Decode(string(d), &m); err != nil {
+			return nil, err
+		}
+		v = m
+	default:
+		return d, nil
+	}
+	return json.Marshal(v)
+}
+
+// decodeConfig decodes json config d into v. Unlike json.Unmarshal() it
+// fails on fields that v doesn't have, so that a typo in a field name
+// doesn't silently leave a feature disabled
+func decodeConfig(d []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the config")
+	}
+	return nil
+}
+
+// yamlToJSONValue converts maps decoded by yaml, which have keys of any
+// type, to maps with string keys that can be encoded as json
+func yamlToJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = yamlToJSONValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = yamlToJSONValue(val)
+		}
+	}
+	return v
+}
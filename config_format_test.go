@@ -0,0 +1,167 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+const testJSONConfig = `{
+  "CookieAuthKeyHexStr": "` + checkConfigTestKey + `",
+  "CookieEncrKeyHexStr": "` + checkConfigTestKey + `",
+  "MaxUploadBytes": 1048576,
+  "ReadOnly": true,
+  "LangFallbacks": {"by": "ru"},
+  "SMTP": {"Host": "smtp.example.com", "Port": 587, "From": "at@example.com", "To": ["me@example.com"]},
+  "Apps": [
+    {
+      "Name": "SumatraPDF",
+      "DataDir": "sumatrapdf",
+      "AdminTwitterUser": "kjk",
+      "UploadSecret": "secret",
+      "Reviewers": ["reviewer1", "reviewer2"],
+      "LanguageOrder": ["de", "pl"],
+      "Glossary": {"de": {"Account": "Konto"}}
+    }
+  ]
+}`
+
+const testYAMLConfig = `# comments are the reason to use YAML
+CookieAuthKeyHexStr: "` + checkConfigTestKey + `"
+CookieEncrKeyHexStr: "` + checkConfigTestKey + `"
+MaxUploadBytes: 1048576
+ReadOnly: true
+LangFallbacks:
+  by: ru
+SMTP:
+  Host: smtp.example.com
+  Port: 587
+  From: at@example.com
+  To: [me@example.com]
+Apps:
+  - Name: SumatraPDF
+    DataDir: sumatrapdf
+    AdminTwitterUser: kjk
+    UploadSecret: secret
+    Reviewers: [reviewer1, reviewer2]
+    LanguageOrder: [de, pl]
+    Glossary:
+      de:
+        Account: Konto
+`
+
+const testTOMLConfig = `# comments work in TOML too
+CookieAuthKeyHexStr = "` + checkConfigTestKey + `"
+CookieEncrKeyHexStr = "` + checkConfigTestKey + `"
+MaxUploadBytes = 1048576
+ReadOnly = true
+
+[LangFallbacks]
+by = "ru"
+
+[SMTP]
+Host = "smtp.example.com"
+Port = 587
+From = "at@example.com"
+To = ["me@example.com"]
+
+[[Apps]]
+Name = "SumatraPDF"
+DataDir = "sumatrapdf"
+AdminTwitterUser = "kjk"
+UploadSecret = "secret"
+Reviewers = ["reviewer1", "reviewer2"]
+LanguageOrder = ["de", "pl"]
+
+[Apps.Glossary.de]
+Account = "Konto"
+`
+
+func TestConfigFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configformats")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	savedConfig, savedSecureCookies := config, secureCookies
+	defer func() {
+		config, secureCookies = savedConfig, savedSecureCookies
+		setReadOnly(false)
+		delete(store.LangFallbacks, "by")
+	}()
+
+	read := func(name, conf string) interface{} {
+		config = savedConfig
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+		if err := readConfig(path); err != nil {
+			t.Fatalf("%s: readConfig() failed with %s", name, err)
+		}
+		return config
+	}
+	exp := read("config.json", testJSONConfig)
+	if len(config.Apps) != 1 || config.SMTP == nil || config.Apps[0].Glossary["de"]["Account"] != "Konto" {
+		t.Fatalf("unexpected config %#v", config)
+	}
+	for name, conf := range map[string]string{"config.yaml": testYAMLConfig, "config.yml": testYAMLConfig, "config.toml": testTOMLConfig} {
+		if got := read(name, conf); !reflect.DeepEqual(got, exp) {
+			t.Errorf("%s: got %#v, expected %#v", name, got, exp)
+		}
+	}
+
+	config = savedConfig
+	path := filepath.Join(dir, "invalid.yaml")
+	if err = ioutil.WriteFile(path, []byte("Apps: [\n"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+	if readConfig(path) == nil {
+		t.Fatalf("expected an error for invalid YAML")
+	}
+}
+
+func TestConfigUnknownFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configunknown")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	savedConfig, savedSecureCookies := config, secureCookies
+	defer func() {
+		config, secureCookies = savedConfig, savedSecureCookies
+		setReadOnly(false)
+		delete(store.LangFallbacks, "by")
+	}()
+
+	read := func(name, conf string) error {
+		config = savedConfig
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+		return readConfig(path)
+	}
+	if err = read("config.json", testJSONConfig); err != nil {
+		t.Fatalf("readConfig() failed with %s", err)
+	}
+	for _, c := range []struct{ name, conf, field string }{
+		{"config.json", `{"AwsAccesss": "key"}`, "AwsAccesss"},
+		{"app.json", `{"Apps": [{"Name": "app", "DataDir": "app", "UploadSecrett": "secret"}]}`, "UploadSecrett"},
+		{"config.yaml", "AwsAccesss: key\n", "AwsAccesss"},
+		{"config.toml", "AwsAccesss = \"key\"\n", "AwsAccesss"},
+	} {
+		if err := read(c.name, c.conf); err == nil || !strings.Contains(err.Error(), c.field) {
+			t.Errorf("%s: got error %v, expected one naming %s", c.name, err, c.field)
+		}
+	}
+	if err = read("trailing.json", testJSONConfig+"}"); err == nil {
+		t.Fatalf("expected an error for data after the config")
+	}
+}
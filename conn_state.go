@@ -0,0 +1,43 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// connStates is the state of every open connection to our servers,
+// reported by /metrics
+var (
+	connStatesMu sync.Mutex
+	connStates   = make(map[net.Conn]http.ConnState)
+)
+
+// trackConnState is http.Server.ConnState hook that keeps connStates up
+// to date. Closed and hijacked connections are no longer ours
+func trackConnState(c net.Conn, state http.ConnState) {
+	connStatesMu.Lock()
+	defer connStatesMu.Unlock()
+	if state == http.StateClosed || state == http.StateHijacked {
+		delete(connStates, c)
+		return
+	}
+	connStates[c] = state
+}
+
+// connCounts returns number of open connections in states new, active
+// and idle
+func connCounts() map[http.ConnState]int {
+	res := map[http.ConnState]int{
+		http.StateNew:    0,
+		http.StateActive: 0,
+		http.StateIdle:   0,
+	}
+	connStatesMu.Lock()
+	defer connStatesMu.Unlock()
+	for _, state := range connStates {
+		res[state]++
+	}
+	return res
+}
@@ -0,0 +1,119 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+// isValidContextURL returns true for absolute http and https urls. Other
+// schemes (e.g. javascript:) are not allowed because urls are shown as links
+func isValidContextURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isImageURL returns true if s looks like an url of an image, which is
+// shown as a thumbnail instead of a link
+func isImageURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// IsImageURL is used in templates, see isImageURL()
+func (m *ModelAppTranslations) IsImageURL(s string) bool {
+	return isImageURL(s)
+}
+
+// parseStringsMap parses json object mapping source strings to values.
+// Strings must be in strs
+func parseStringsMap(s string, strs []string) (map[string]string, error) {
+	res := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return res, nil
+	}
+	if err := json.Unmarshal([]byte(s), &res); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, str := range strs {
+		known[str] = true
+	}
+	for str := range res {
+		if !known[str] {
+			return nil, fmt.Errorf("unknown string %q", str)
+		}
+	}
+	return res, nil
+}
+
+// parseContextURLs parses json object mapping source strings to context
+// urls, uploaded in "contexturls" field of /uploadstrings. Strings must be
+// in strs
+func parseContextURLs(s string, strs []string) (map[string]string, error) {
+	res, err := parseStringsMap(s, strs)
+	if err != nil {
+		return nil, err
+	}
+	for str, u := range res {
+		if u != "" && !isValidContextURL(u) {
+			return nil, fmt.Errorf("invalid context url %q of %q", u, str)
+		}
+	}
+	return res, nil
+}
+
+func setContextURLs(st *store.StoreCsv, urls map[string]string) error {
+	for str, u := range urls {
+		if err := st.SetContextURL(str, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// url: /contexturl?app=${app}&lang=${lang}&string=${string}&val=${url}
+// empty val removes the url
+func handleContextURL(w http.ResponseWriter, r *http.Request) {
+	app, langCode := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't change strings")
+		return
+	}
+	str := strings.TrimSpace(r.FormValue("string"))
+	if !app.store.IsActiveString(str) {
+		httpErrorf(w, "String %q doesn't exist", str)
+		return
+	}
+	contextURL := strings.TrimSpace(r.FormValue("val"))
+	if contextURL != "" && !isValidContextURL(contextURL) {
+		httpErrorf(w, "Invalid url %q, must be http or https url", contextURL)
+		return
+	}
+	if err := app.store.SetContextURL(str, contextURL); err != nil {
+		httpErrorf(w, "Failed to change string %q", err)
+		return
+	}
+	msg := fmt.Sprintf("Removed context url of %q", str)
+	if contextURL != "" {
+		msg = fmt.Sprintf("Set context url of %q", str)
+	}
+	u := fmt.Sprintf("/app/%s/%s?msg=%s", app.Name, langCode, url.QueryEscape(msg))
+	http.Redirect(w, r, u, http.StatusFound)
+}
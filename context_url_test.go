@@ -0,0 +1,106 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestIsValidContextURL(t *testing.T) {
+	for _, s := range []string{"https://example.com/a.png", "http://example.com/page#open"} {
+		if !isValidContextURL(s) {
+			t.Errorf("%q should be valid", s)
+		}
+	}
+	for _, s := range []string{"javascript:alert(1)", "/shots/a.png", "ftp://example.com/a.png", "https://"} {
+		if isValidContextURL(s) {
+			t.Errorf("%q should not be valid", s)
+		}
+	}
+	if !isImageURL("https://example.com/Open.PNG?v=2") || isImageURL("https://example.com/docs/open") {
+		t.Errorf("isImageURL() is wrong")
+	}
+}
+
+func TestContextURLs(t *testing.T) {
+	app := newTestApp(t, "contexturl", nil)
+	defer closeTestApp(app)
+	handler := makeHTTPServer().Handler
+
+	upload := func(contextURLs string) int {
+		form := url.Values{
+			"app":         {"contexturl"},
+			"secret":      {"secret"},
+			"strings":     {"AppTranslator strings\nOpen\nClose"},
+			"contexturls": {contextURLs},
+		}
+		r := httptest.NewRequest("POST", "/uploadstrings", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	apiTranslations := func() map[string]*APITranslation {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/contexturl/translations/pl", nil))
+		if rr.Code != 200 {
+			t.Fatalf("got status %d, expected 200", rr.Code)
+		}
+		var res struct {
+			Translations []*APITranslation `json:"translations"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("json.Unmarshal() failed with %s", err)
+		}
+		m := make(map[string]*APITranslation)
+		for _, tr := range res.Translations {
+			m[tr.Source] = tr
+		}
+		return m
+	}
+
+	shot := "https://example.com/shots/open.png"
+	if code := upload(`{"Open": "` + shot + `"}`); code != 200 {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	trs := apiTranslations()
+	if trs["Open"] == nil || trs["Open"].ContextURL != shot || trs["Close"] == nil || trs["Close"].ContextURL != "" {
+		t.Fatalf("unexpected translations %#v", trs)
+	}
+	for _, bad := range []string{`{"Open": "javascript:alert(1)"}`, `{"Save": "` + shot + `"}`, `[`} {
+		if code := upload(bad); code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", bad, code)
+		}
+	}
+
+	// strings didn't change, but context urls are still updated
+	page := "https://example.com/docs/close"
+	if code := upload(`{"Close": "` + page + `"}`); code != 204 {
+		t.Fatalf("got status %d, expected 204", code)
+	}
+	if got := app.store.ContextURL("Close"); got != page {
+		t.Fatalf("got %q, expected %q", got, page)
+	}
+
+	set := func(user, val string) int {
+		u := "/contexturl?app=contexturl&lang=pl&string=Open&val=" + url.QueryEscape(val)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequestWithCookie("GET", u, &SecureCookieValue{User: user}))
+		return rr.Code
+	}
+	if code := set("user1", ""); code != 400 {
+		t.Fatalf("non-admin: got status %d, expected 400", code)
+	}
+	if code := set("admin", "javascript:alert(1)"); code != 400 {
+		t.Fatalf("invalid url: got status %d, expected 400", code)
+	}
+	if code := set("admin", ""); code != 302 {
+		t.Fatalf("got status %d, expected 302", code)
+	}
+	if trs := apiTranslations(); trs["Open"].ContextURL != "" {
+		t.Fatalf("context url wasn't removed: %#v", trs["Open"])
+	}
+}
@@ -0,0 +1,54 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/securecookie"
+)
+
+// CookieKeys are hex-encoded keys that sign and encrypt cookies, see
+// securecookie.New
+type CookieKeys struct {
+	AuthKeyHexStr string
+	EncrKeyHexStr string
+}
+
+// configCookieKeys returns cookie keys from config, newest first. Those are
+// config.CookieKeys or, if not set, CookieAuthKeyHexStr and
+// CookieEncrKeyHexStr
+func configCookieKeys() ([]CookieKeys, error) {
+	hasPair := config.CookieAuthKeyHexStr != nil || config.CookieEncrKeyHexStr != nil
+	if len(config.CookieKeys) > 0 {
+		if hasPair {
+			return nil, errors.New("set either CookieKeys or CookieAuthKeyHexStr and CookieEncrKeyHexStr")
+		}
+		return config.CookieKeys, nil
+	}
+	if config.CookieAuthKeyHexStr == nil || config.CookieEncrKeyHexStr == nil {
+		return nil, errors.New("CookieAuthKeyHexStr and CookieEncrKeyHexStr must be set")
+	}
+	return []CookieKeys{{*config.CookieAuthKeyHexStr, *config.CookieEncrKeyHexStr}}, nil
+}
+
+// newCookieCodecs returns codecs for keys, in the same order. Cookies are
+// encoded with the first codec and decoded with any of them, so that when
+// new keys are added before the old ones, cookies of logged in users remain
+// valid until the old keys are removed
+func newCookieCodecs(keys []CookieKeys) ([]securecookie.Codec, error) {
+	var pairs [][]byte
+	for i, k := range keys {
+		auth, err := hex.DecodeString(k.AuthKeyHexStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth key %d: %s", i, err)
+		}
+		encr, err := hex.DecodeString(k.EncrKeyHexStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encr key %d: %s", i, err)
+		}
+		pairs = append(pairs, auth, encr)
+	}
+	return securecookie.CodecsFromPairs(pairs...), nil
+}
@@ -0,0 +1,83 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCookieKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookiekeys")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	savedConfig, savedSecureCookies := config, secureCookies
+	defer func() { config, secureCookies = savedConfig, savedSecureCookies }()
+
+	const oldKey = checkConfigTestKey
+	const newKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+	readConf := func(conf string) error {
+		config = savedConfig
+		path := filepath.Join(dir, "config.json")
+		if err := ioutil.WriteFile(path, []byte(conf), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+		return readConfig(path)
+	}
+	// cookie returns a cookie for user, encoded with current keys
+	cookie := func(user string) string {
+		rr := httptest.NewRecorder()
+		setSecureCookie(rr, httptest.NewRequest("GET", "/", nil), &SecureCookieValue{User: user})
+		return rr.Header().Get("Set-Cookie")
+	}
+	userOf := func(cookie string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Cookie", cookie)
+		if v := getSecureCookie(r); v != nil {
+			return v.User
+		}
+		return ""
+	}
+
+	if err = readConf(`{"CookieAuthKeyHexStr": "` + oldKey + `", "CookieEncrKeyHexStr": "` + oldKey + `"}`); err != nil {
+		t.Fatalf("readConfig() failed with %s", err)
+	}
+	oldCookie := cookie("user1")
+
+	rotated := `{"CookieKeys": [
+		{"AuthKeyHexStr": "` + newKey + `", "EncrKeyHexStr": "` + newKey + `"},
+		{"AuthKeyHexStr": "` + oldKey + `", "EncrKeyHexStr": "` + oldKey + `"}]}`
+	if err = readConf(rotated); err != nil {
+		t.Fatalf("readConfig() failed with %s", err)
+	}
+	if user := userOf(oldCookie); user != "user1" {
+		t.Fatalf("got user %q from cookie signed with the old key", user)
+	}
+	newCookie := cookie("user2")
+	if user := userOf(newCookie); user != "user2" {
+		t.Fatalf("got user %q from cookie signed with the new key", user)
+	}
+
+	// new cookies are signed with the new key, so they are not valid
+	// after going back to the old key
+	if err = readConf(`{"CookieKeys": [{"AuthKeyHexStr": "` + oldKey + `", "EncrKeyHexStr": "` + oldKey + `"}]}`); err != nil {
+		t.Fatalf("readConfig() failed with %s", err)
+	}
+	if user := userOf(newCookie); user != "" {
+		t.Fatalf("got user %q from cookie signed with a removed key", user)
+	}
+
+	for _, conf := range []string{
+		`{}`,
+		`{"CookieKeys": [{"AuthKeyHexStr": "xyz", "EncrKeyHexStr": "` + oldKey + `"}]}`,
+		`{"CookieAuthKeyHexStr": "` + oldKey + `", "CookieEncrKeyHexStr": "` + oldKey + `", "CookieKeys": [{"AuthKeyHexStr": "` + newKey + `", "EncrKeyHexStr": "` + newKey + `"}]}`,
+	} {
+		if readConf(conf) == nil {
+			t.Errorf("expected an error for %s", conf)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// writeCoverageCsv writes a matrix of active strings of the app (rows) by
+// languages (columns) to w. A cell is 1 if the string doesn't need a
+// translation into the language anymore and 0 otherwise or, if values is
+// true, the current translation
+func writeCoverageCsv(w io.Writer, app *App, values bool) error {
+	langs := append([]*store.LangInfo(nil), app.store.LangInfos()...)
+	app.sortLangsByPriority(langs)
+	// translations of each string, indexed like langs
+	byString := make(map[string][]*store.Translation)
+	for i, li := range langs {
+		for _, tr := range li.ActiveStrings {
+			if byString[tr.String] == nil {
+				byString[tr.String] = make([]*store.Translation, len(langs))
+			}
+			byString[tr.String][i] = tr
+		}
+	}
+	strs := make([]string, 0, len(byString))
+	for str := range byString {
+		strs = append(strs, str)
+	}
+	sort.Strings(strs)
+
+	cw := csv.NewWriter(w)
+	rec := []string{"string"}
+	for _, li := range langs {
+		rec = append(rec, li.Code)
+	}
+	if err := cw.Write(rec); err != nil {
+		return err
+	}
+	for _, str := range strs {
+		rec = append(rec[:0], str)
+		for _, tr := range byString[str] {
+			cell := ""
+			switch {
+			case values:
+				if tr != nil {
+					cell = tr.Current()
+				}
+			case tr != nil && !tr.NeedsTranslation():
+				cell = "1"
+			default:
+				cell = "0"
+			}
+			rec = append(rec, cell)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// url: /coverage/{appname}.csv[?values=1]
+// Returns csv with translation status of every string in every language,
+// for reports about completeness of translations. See writeCoverageCsv
+func handleCoverage(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		http.Error(w, fmt.Sprintf("Application %q doesn't exist", appName), http.StatusNotFound)
+		return
+	}
+	values := strings.TrimSpace(r.FormValue("values")) == "1"
+	if modTime, err := fileModTime(app.store.FilePath()); err == nil {
+		if checkNotModified(w, r, modTime) {
+			return
+		}
+	}
+	w.Header().Set("Content-Type", contentTypeForTransFormat(formatCsv))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", app.Name+"-coverage.csv"))
+	if err := writeCoverageCsv(w, app, values); err != nil {
+		logger.ForRequest(r).Errorf("handleCoverage(): writeCoverageCsv() failed with %s", err)
+	}
+}
@@ -0,0 +1,79 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestCoverageCsv(t *testing.T) {
+	app := newTestApp(t, "coverage", []string{"Open", "Close", "OK"})
+	defer closeTestApp(app)
+	app.LanguageOrder = []string{"pl", "de"}
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, app, "Open", "Öffnen", "de", "user1")
+	if err := app.store.SetNoTranslate("OK", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	get := func(url string) [][]string {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: got status %d", url, rr.Code)
+		}
+		recs, err := csv.NewReader(strings.NewReader(rr.Body.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: csv.ReadAll() failed with %s", url, err)
+		}
+		// strings and the header, a column for every language and strings
+		if len(recs) != 4 {
+			t.Fatalf("%s: got %d rows, expected 4", url, len(recs))
+		}
+		for _, rec := range recs {
+			if len(rec) != len(store.Languages)+1 {
+				t.Fatalf("%s: got %d columns, expected %d", url, len(rec), len(store.Languages)+1)
+			}
+		}
+		return recs
+	}
+
+	recs := get("/coverage/coverage.csv")
+	// languages from LanguageOrder go first
+	if got := strings.Join(recs[0][:3], ","); got != "string,pl,de" {
+		t.Fatalf("got header %q", got)
+	}
+	exp := []string{"Close,1,0", "OK,1,1", "Open,1,1"}
+	for i, e := range exp {
+		if got := strings.Join(recs[i+1][:3], ","); got != e {
+			t.Errorf("row %d: got %q, expected %q", i+1, got, e)
+		}
+		// other languages have no translations, OK doesn't need them
+		expOther := "0"
+		if recs[i+1][0] == "OK" {
+			expOther = "1"
+		}
+		if got := recs[i+1][3]; got != expOther {
+			t.Errorf("row %d: got %q in %s, expected %q", i+1, got, recs[0][3], expOther)
+		}
+	}
+
+	recs = get("/coverage/coverage.csv?values=1")
+	exp = []string{"Close,Zamknij,", "OK,,", "Open,Otwórz,Öffnen"}
+	for i, e := range exp {
+		if got := strings.Join(recs[i+1][:3], ","); got != e {
+			t.Errorf("row %d: got %q, expected %q", i+1, got, e)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/coverage/nosuchapp.csv", nil))
+	if rr.Code != 404 {
+		t.Fatalf("got status %d, expected 404", rr.Code)
+	}
+}
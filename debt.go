@@ -0,0 +1,147 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+// how often numbers of untranslated strings are recorded
+var debtSnapshotFreq = 24 * time.Hour
+
+// about a year of daily samples
+const defaultDebtSamples = 365
+
+func debtSamplesToKeep() int {
+	if config.DebtSamples > 0 {
+		return config.DebtSamples
+	}
+	return defaultDebtSamples
+}
+
+// DebtSample is the number of untranslated strings of an app in each
+// language at a given time
+type DebtSample struct {
+	Time         time.Time      `json:"time"`
+	Untranslated map[string]int `json:"untranslated"`
+}
+
+// protects debt files of all apps
+var debtMu sync.Mutex
+
+// samples are kept next to translations of the default namespace
+func (a *App) debtFilePath() string {
+	return filepath.Join(filepath.Dir(a.store.FilePath()), "debt.json")
+}
+
+// readDebt returns samples of app, oldest first. Must be called with debtMu
+// locked
+func readDebt(app *App) ([]DebtSample, error) {
+	d, err := ioutil.ReadFile(app.debtFilePath())
+	if os.IsNotExist(err) {
+		return []DebtSample{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res []DebtSample
+	if err = json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// snapshotDebt appends the current number of untranslated strings of app
+// to its samples, keeping at most debtSamplesToKeep() newest samples.
+// Nothing is done if the newest sample is more recent than
+// debtSnapshotFreq, e.g. after a restart of the server
+func snapshotDebt(app *App, now time.Time) error {
+	sample := DebtSample{Time: now.UTC(), Untranslated: make(map[string]int)}
+	for _, li := range app.store.LangInfos() {
+		sample.Untranslated[li.Code] = li.UntranslatedCount()
+	}
+	debtMu.Lock()
+	defer debtMu.Unlock()
+	samples, err := readDebt(app)
+	if err != nil {
+		return err
+	}
+	if n := len(samples); n > 0 && now.Sub(samples[n-1].Time) < debtSnapshotFreq {
+		return nil
+	}
+	samples = append(samples, sample)
+	if n := len(samples) - debtSamplesToKeep(); n > 0 {
+		samples = samples[n:]
+	}
+	d, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+	// written to a temporary file first so that a crash doesn't leave
+	// partially written samples
+	path := app.debtFilePath()
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, d, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func debtSnapshotLoop() {
+	for {
+		for _, app := range appState.Apps {
+			if err := snapshotDebt(app, clock.Now()); err != nil {
+				logger.Errorf("snapshotDebt() of %s failed with %s", app.Name, err)
+			}
+		}
+		clock.Sleep(debtSnapshotFreq)
+	}
+}
+
+// url: GET /api/v1/apps/{name}/debt[?lang=$lang]
+// Returns numbers of untranslated strings over time, oldest first, for
+// charting. Only of lang if given
+func handleAPIAppDebt(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	lang := strings.TrimSpace(r.FormValue("lang"))
+	if lang != "" {
+		var err error
+		if lang, err = store.ParseLangCode(lang); err != nil {
+			serveJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	debtMu.Lock()
+	samples, err := readDebt(app)
+	debtMu.Unlock()
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleAPIAppDebt(): readDebt() of %s failed with %s", app.Name, err)
+		serveJSONError(w, http.StatusInternalServerError, "Failed to read samples")
+		return
+	}
+	if lang != "" {
+		for i, s := range samples {
+			samples[i].Untranslated = map[string]int{lang: s.Untranslated[lang]}
+		}
+	}
+	v := struct {
+		App     string       `json:"app"`
+		Samples []DebtSample `json:"samples"`
+	}{app.Name, samples}
+	serveJSON(w, v)
+}
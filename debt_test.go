@@ -0,0 +1,73 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestDebt(t *testing.T) {
+	fake := store.NewFakeClock(time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC))
+	defer setClock(setClock(fake))
+	app := newTestApp(t, "debt", []string{"Open", "Save", "Close"})
+	defer closeTestApp(app)
+	defer func() { config.DebtSamples = 0 }()
+	config.DebtSamples = 3
+
+	getDebt := func(query string) (int, []DebtSample) {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/debt/debt"+query, nil))
+		var res struct {
+			App     string       `json:"app"`
+			Samples []DebtSample `json:"samples"`
+		}
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, res.Samples
+	}
+
+	if code, samples := getDebt(""); code != 200 || len(samples) != 0 {
+		t.Fatalf("got status %d, %v", code, samples)
+	}
+	start := fake.Now()
+	// samples with 3, 2, 1 and 0 untranslated pl strings
+	for _, str := range []string{"", "Open", "Save", "Close"} {
+		if str != "" {
+			writeTestTranslation(t, app, str, str+" (pl)", "pl", "user1")
+		}
+		if err := snapshotDebt(app, fake.Now()); err != nil {
+			t.Fatalf("snapshotDebt() failed with %s", err)
+		}
+		// e.g. after a restart, a recent sample is not repeated
+		if err := snapshotDebt(app, fake.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("snapshotDebt() failed with %s", err)
+		}
+		fake.Advance(debtSnapshotFreq)
+	}
+
+	// the oldest sample is dropped, the rest are oldest first
+	code, samples := getDebt("?lang=pl_PL")
+	if code != 200 || len(samples) != 3 {
+		t.Fatalf("got status %d, %v", code, samples)
+	}
+	for i, s := range samples {
+		expTime := start.Add(time.Duration(i+1) * debtSnapshotFreq)
+		exp := 2 - i
+		if !s.Time.Equal(expTime) || len(s.Untranslated) != 1 || s.Untranslated["pl"] != exp {
+			t.Fatalf("sample %d: got %#v, expected %d at %s", i, s, exp, expTime)
+		}
+	}
+	if _, samples = getDebt(""); samples[0].Untranslated["de"] != 3 {
+		t.Fatalf("got %v, expected 3 untranslated de strings", samples[0].Untranslated)
+	}
+	if code, _ := getDebt("?lang=x!"); code != 400 {
+		t.Fatalf("got status %d, expected 400", code)
+	}
+}
@@ -0,0 +1,282 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+	"golang.org/x/text/collate"
+)
+
+// ExportOptions controls what goes into an export
+type ExportOptions struct {
+	// if true, untranslated strings are exported with source string as
+	// translation, otherwise translation is empty
+	FallbackToSource bool
+	// if true, untranslated strings are not exported. Takes precedence
+	// over FallbackToSource
+	OnlyTranslated bool
+	// if true, only approved translations are exported, see store.State.
+	// Strings without approved translation are not exported
+	OnlyApproved bool
+	// order of exported strings, neutral collation if nil
+	Collator *collate.Collator
+	// only strings in this namespace are exported, see namespaces.go
+	Namespace string
+	// if true, strings not translated in the app are translated with
+	// translations of the same strings in app's SharedFrom app
+	Shared bool
+	// transformation of source strings, one of keyStyles. Not transformed
+	// if empty
+	KeyStyle string
+}
+
+func validateFallbackChain(chains map[string][]string) error {
+	for lang, chain := range chains {
+		if !store.IsValidLangCode(lang) {
+			return fmt.Errorf("invalid FallbackChain language %q", lang)
+		}
+		for _, fallback := range chain {
+			if !store.IsValidLangCode(fallback) {
+				return fmt.Errorf("invalid FallbackChain entry %q => %q", lang, fallback)
+			}
+		}
+	}
+	return nil
+}
+
+// fallbackLangs returns languages lang falls back to, in order. Chain from
+// config.FallbackChain takes precedence over fallback from regional variant
+// to base language (e.g. from "br" to "pt")
+func fallbackLangs(lang string) []string {
+	if chain, ok := config.FallbackChain[lang]; ok {
+		return chain
+	}
+	if fallback := store.FallbackLangCode(lang); fallback != "" {
+		return []string{fallback}
+	}
+	return nil
+}
+
+// resolveTranslation returns translation of src into lang, following
+// fallbacks of lang until a language with translation is found. Each
+// language is tried only once, so cycles in fallbacks are harmless
+func resolveTranslation(translations map[string]map[string]string, src, lang string) (string, bool) {
+	seen := make(map[string]bool)
+	var resolve func(lang string) (string, bool)
+	resolve = func(lang string) (string, bool) {
+		if seen[lang] {
+			return "", false
+		}
+		seen[lang] = true
+		if trans := translations[lang][src]; trans != "" {
+			return trans, true
+		}
+		for _, fallback := range fallbackLangs(lang) {
+			if trans, ok := resolve(fallback); ok {
+				return trans, true
+			}
+		}
+		return "", false
+	}
+	return resolve(lang)
+}
+
+// TranslationWithFallback returns translation of src into lang. If it's not
+// translated into lang, it returns translation into the language lang falls
+// back to (e.g. "pt" for "br", "ca" for "ca-xv" or as configured in
+// config.FallbackChain)
+func (a *App) TranslationWithFallback(src, lang string) (string, bool) {
+	return resolveTranslation(currentTranslations(a), src, lang)
+}
+
+// sharedTranslations returns current translations of app's SharedFrom app
+// or nil if it doesn't have one
+func sharedTranslations(app *App) map[string]map[string]string {
+	if app.SharedFrom == "" {
+		return nil
+	}
+	shared := findApp(app.SharedFrom)
+	if shared == nil {
+		logger.Errorf("SharedFrom app %q of %q doesn't exist", app.SharedFrom, app.Name)
+		return nil
+	}
+	return currentTranslations(shared)
+}
+
+// exportEntries returns translations of all active strings in
+// opts.Namespace into lang, sorted by source string with opts.Collator.
+// Strings that should not be translated are exported as is. Source strings
+// are transformed with opts.KeyStyle before sorting. Sources that can't be
+// transformed, see keyStyleProblems(), must be checked by the caller
+func exportEntries(app *App, lang string, opts *ExportOptions) []TransEntry {
+	st := app.NamespaceStore(opts.Namespace)
+	if st == nil {
+		return nil
+	}
+	translations := translationsByLang(st.LangInfos())
+	var shared map[string]map[string]string
+	if opts.Shared {
+		shared = sharedTranslations(app)
+	}
+	approved := make(map[string]bool)
+	for _, li := range st.LangInfos() {
+		if li.Code != lang || !opts.OnlyApproved {
+			continue
+		}
+		for _, tr := range li.ActiveStrings {
+			approved[tr.String] = tr.State() == store.StateApproved
+		}
+	}
+	transform := keyStyles[opts.KeyStyle]
+	var res []TransEntry
+	for src := range translations[lang] {
+		noTranslate := st.IsNoTranslate(src)
+		if opts.OnlyApproved && !approved[src] && !noTranslate {
+			continue
+		}
+		trans, ok := resolveTranslation(translations, src, lang)
+		if !ok && shared != nil && !noTranslate {
+			trans, ok = resolveTranslation(shared, src, lang)
+		}
+		if !ok && opts.OnlyTranslated && !noTranslate {
+			continue
+		}
+		if !ok && (opts.FallbackToSource || noTranslate) {
+			trans = src
+		}
+		e := TransEntry{
+			Lang:        lang,
+			Source:      src,
+			Translation: trans,
+			NoTranslate: noTranslate,
+		}
+		if transform != nil {
+			e.Source = transform(src)
+		}
+		res = append(res, e)
+	}
+	less := collatedLess(opts.Collator)
+	sort.Slice(res, func(i, j int) bool {
+		return less(res[i].Source, res[j].Source)
+	})
+	return res
+}
+
+// ExportTranslations returns translations of the app into lang in a given
+// format, see EncodeTranslations
+func (a *App) ExportTranslations(lang, format string, opts *ExportOptions) ([]byte, error) {
+	return EncodeTranslations(lang, exportEntries(a, lang, opts), format)
+}
+
+// WriteTranslations is ExportTranslations() that writes translations to w as
+// they are serialized, instead of returning the whole file
+func (a *App) WriteTranslations(w io.Writer, lang, format string, opts *ExportOptions) error {
+	return writeTransFile(w, lang, exportEntries(a, lang, opts), format)
+}
+
+func contentTypeForTransFormat(format string) string {
+	switch format {
+	case formatCsv:
+		return "text/csv; charset=utf-8"
+	case formatPo:
+		return "text/x-gettext-translation; charset=utf-8"
+	case formatJson:
+		return "application/json"
+	case formatXliff:
+		return "application/x-xliff+xml"
+	case formatBin:
+		return "application/octet-stream"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// url: /export?app=$app&lang=$lang&format=$format[&fallback=source][&only=translated|approved][&locale=$locale][&ns=$namespace][&shared=1][&keystyle=none|snake|camel|dotToSlash]
+// Returns translations of all strings into lang in a given format (see
+// transfile.go for description of formats). In addition to formats we can
+// import, translations can be exported as xliff and in binary format of
+// package transbin. Strings are sorted
+// according to locale, neutral collation by default. With shared=1, strings
+// not translated in the app get translations from app's SharedFrom app.
+// With only=approved, only approved translations are exported. Source
+// strings can be transformed to keys in a given style, see keyStyles. If
+// some strings become empty or the same keys, 400 lists them.
+// Last-Modified is modification time of the store, 304 is returned if it
+// didn't change since If-Modified-Since
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	app, lang := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	format := strings.TrimSpace(r.FormValue("format"))
+	if !isValidExportFormat(format) {
+		httpErrorf(w, "Invalid format %q", format)
+		return
+	}
+	only := strings.TrimSpace(r.FormValue("only"))
+	if only != "" && only != "translated" && only != "approved" {
+		httpErrorf(w, "Invalid only %q", only)
+		return
+	}
+	ns := strings.TrimSpace(r.FormValue("ns"))
+	st := app.NamespaceStore(ns)
+	if st == nil {
+		httpErrorf(w, "Namespace %q doesn't exist", ns)
+		return
+	}
+	collator, err := newCollator(strings.TrimSpace(r.FormValue("locale")))
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	shared := r.FormValue("shared") == "1"
+	keyStyle := strings.TrimSpace(r.FormValue("keystyle"))
+	if keyStyle != "" && !isValidKeyStyle(keyStyle) {
+		httpErrorf(w, "Invalid keystyle %q", keyStyle)
+		return
+	}
+	if problems := keyStyleProblems(st.ActiveStrings(), keyStyle); len(problems) > 0 {
+		httpErrorf(w, "Strings can't be exported with keystyle %s:\n%s", keyStyle, strings.Join(problems, "\n"))
+		return
+	}
+	// the store is append-only, so its modification time changes with
+	// every change of translations
+	modTime, err := fileModTime(st.FilePath())
+	if sharedApp := findApp(app.SharedFrom); shared && sharedApp != nil && err == nil {
+		var sharedModTime time.Time
+		if sharedModTime, err = fileModTime(sharedApp.store.FilePath()); err == nil && sharedModTime.After(modTime) {
+			modTime = sharedModTime
+		}
+	}
+	if err == nil {
+		if checkNotModified(w, r, modTime) {
+			return
+		}
+	} else {
+		logger.ForRequest(r).Errorf("fileModTime() failed with %s", err)
+	}
+	opts := &ExportOptions{
+		FallbackToSource: r.FormValue("fallback") == "source",
+		OnlyTranslated:   only == "translated",
+		OnlyApproved:     only == "approved",
+		Collator:         collator,
+		Namespace:        ns,
+		Shared:           shared,
+		KeyStyle:         keyStyle,
+	}
+	fileName := fmt.Sprintf("%s-%s.%s", app.Name, lang, format)
+	if ns != defaultNamespace {
+		fileName = fmt.Sprintf("%s-%s-%s.%s", app.Name, ns, lang, format)
+	}
+	w.Header().Set("Content-Type", contentTypeForTransFormat(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	// streamed, so it's too late to change the status if writing fails
+	if err = app.WriteTranslations(w, lang, format, opts); err != nil {
+		logger.ForRequest(r).Errorf("WriteTranslations() failed with %s", err)
+	}
+}
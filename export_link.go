@@ -0,0 +1,116 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	// name under which export links are signed, so that cookies can't be
+	// used as links and the other way around
+	exportLinkName         = "exportlink"
+	defaultExportLinkHours = 7 * 24
+	// securecookie rejects values older than 30 days
+	maxExportLinkHours = 30 * 24
+)
+
+// ExportLink is what a signed export link gives access to
+type ExportLink struct {
+	App    string
+	Lang   string
+	Format string
+	// unix time after which the link is no longer valid
+	Expires int64
+}
+
+// encodeExportLink returns token of a link to export described by l,
+// signed and encrypted with cookie keys
+func encodeExportLink(l *ExportLink) (string, error) {
+	return securecookie.EncodeMulti(exportLinkName, l, secureCookies...)
+}
+
+// decodeExportLink returns export link encoded in token. It fails if the
+// token wasn't made by encodeExportLink() with one of current cookie keys
+// or if the link has expired at now
+func decodeExportLink(token string, now time.Time) (*ExportLink, error) {
+	var l ExportLink
+	if err := securecookie.DecodeMulti(exportLinkName, token, &l, secureCookies...); err != nil {
+		return nil, err
+	}
+	if now.Unix() > l.Expires {
+		return nil, fmt.Errorf("link expired at %s", time.Unix(l.Expires, 0).UTC().Format(time.RFC3339))
+	}
+	return &l, nil
+}
+
+// ExportLinkResult is returned by /exportlink
+type ExportLinkResult struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// url: POST /exportlink?app=$app&lang=$lang&format=$format[&hours=$hours]
+// Returns a link to export of translations that can be downloaded without
+// logging in until it expires, e.g. by a translation vendor. Links are
+// valid for a week by default, at most 30 days
+func handleExportLink(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	app, lang := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't create export links")
+		return
+	}
+	format := strings.TrimSpace(r.FormValue("format"))
+	if !isValidExportFormat(format) {
+		httpErrorf(w, "Invalid format %q", format)
+		return
+	}
+	hours, err := formIntArg(r, "hours", defaultExportLinkHours)
+	if err != nil || hours == 0 || hours > maxExportLinkHours {
+		httpErrorf(w, "Invalid hours %q, must be between 1 and %d", r.FormValue("hours"), maxExportLinkHours)
+		return
+	}
+	expires := clock.Now().Add(time.Duration(hours) * time.Hour).UTC().Truncate(time.Second)
+	token, err := encodeExportLink(&ExportLink{app.Name, lang, format, expires.Unix()})
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleExportLink(): encodeExportLink() failed with %s", err)
+		http.Error(w, "Failed to create the link", http.StatusInternalServerError)
+		return
+	}
+	url := requestScheme(r) + "://" + r.Host + withBasePath("/dl/"+token)
+	logger.ForRequest(r).Noticef("handleExportLink(): link to %s of %s in %s valid until %s", lang, app.Name, format, expires)
+	serveJSON(w, &ExportLinkResult{URL: url, Expires: expires})
+}
+
+// url: GET /dl/{token}
+// Serves export described by a link from /exportlink, if it's valid
+func handleExportLinkDownload(w http.ResponseWriter, r *http.Request) {
+	l, err := decodeExportLink(mux.Vars(r)["token"], clock.Now())
+	if err != nil {
+		logger.ForRequest(r).Noticef("handleExportLinkDownload(): invalid link: %s", err)
+		http.Error(w, "Invalid or expired link", http.StatusForbidden)
+		return
+	}
+	app := findApp(l.App)
+	if app == nil {
+		http.Error(w, fmt.Sprintf("Application %q doesn't exist", l.App), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeForTransFormat(l.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s.%s", app.Name, l.Lang, l.Format)))
+	// streamed, so it's too late to change the status if writing fails
+	if err = app.WriteTranslations(w, l.Lang, l.Format, &ExportOptions{}); err != nil {
+		logger.ForRequest(r).Errorf("handleExportLinkDownload(): WriteTranslations() failed with %s", err)
+	}
+}
@@ -0,0 +1,85 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestExportLink(t *testing.T) {
+	fake := store.NewFakeClock(time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC))
+	defer setClock(setClock(fake))
+	app := newTestApp(t, "exportlink", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+
+	createLink := func(user, query string) (int, *ExportLinkResult) {
+		r := newRequestWithCookie("POST", "/exportlink?app=exportlink&lang=pl&format=csv"+query, &SecureCookieValue{User: user})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		var res ExportLinkResult
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+	download := func(url string) (int, string) {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		return rr.Code, rr.Body.String()
+	}
+
+	for _, user := range []string{"", "user1"} {
+		if code, _ := createLink(user, ""); code != 400 {
+			t.Fatalf("%q: got status %d, expected 400", user, code)
+		}
+	}
+	for _, hours := range []string{"0", "721", "x"} {
+		if code, _ := createLink("admin", "&hours="+hours); code != 400 {
+			t.Fatalf("hours %s: got status %d, expected 400", hours, code)
+		}
+	}
+	code, link := createLink("admin", "&hours=2")
+	if code != 200 || !link.Expires.Equal(fake.Now().Add(2*time.Hour)) {
+		t.Fatalf("got status %d, %#v", code, link)
+	}
+	if !strings.HasPrefix(link.URL, "http://example.com/dl/") {
+		t.Fatalf("unexpected url %q", link.URL)
+	}
+	path := strings.TrimPrefix(link.URL, "http://example.com")
+
+	// valid link, without logging in
+	code, body := download(path)
+	if code != 200 || !strings.Contains(body, "Otwórz") {
+		t.Fatalf("got status %d, body %q", code, body)
+	}
+
+	// tampered link
+	token := strings.TrimPrefix(path, "/dl/")
+	tampered := []byte(token)
+	i := len(tampered) / 2
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if code, _ = download("/dl/" + string(tampered)); code != 403 {
+		t.Fatalf("got status %d for tampered link, expected 403", code)
+	}
+	if _, err := decodeExportLink(string(tampered), fake.Now()); err == nil {
+		t.Fatalf("decodeExportLink() accepted a tampered link")
+	}
+
+	// expired link
+	fake.Advance(2*time.Hour + time.Second)
+	if code, _ = download(path); code != 403 {
+		t.Fatalf("got status %d for expired link, expected 403", code)
+	}
+}
@@ -0,0 +1,334 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+	"github.com/kjk/apptranslator/transbin"
+)
+
+func TestFallbackChain(t *testing.T) {
+	app := newTestApp(t, "chain", []string{"Open", "Save", "Exit"})
+	defer closeTestApp(app)
+	defer func() { config.FallbackChain = nil }()
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Open", "Öffnen", "de", "user1")
+	writeTestTranslation(t, app, "Save", "Enregistrer", "fr", "user1")
+
+	config.FallbackChain = map[string][]string{"pl": {"de"}, "de": {"fr"}}
+	tests := []struct {
+		src   string
+		lang  string
+		exp   string
+		expOk bool
+	}{
+		{"Open", "pl", "Otwórz", true},
+		{"Save", "pl", "Enregistrer", true},
+		{"Save", "de", "Enregistrer", true},
+		{"Exit", "pl", "", false},
+	}
+	for _, test := range tests {
+		got, ok := app.TranslationWithFallback(test.src, test.lang)
+		if got != test.exp || ok != test.expOk {
+			t.Fatalf("%s in %s: got %q, %v, expected %q, %v", test.src, test.lang, got, ok, test.exp, test.expOk)
+		}
+	}
+
+	// cycles stop after trying each language once
+	config.FallbackChain = map[string][]string{"pl": {"de", "pl"}, "de": {"pl"}}
+	if got, ok := app.TranslationWithFallback("Save", "pl"); ok {
+		t.Fatalf("got %q, expected no translation", got)
+	}
+	exp := []TransEntry{
+		{"pl", "Exit", "Exit", false},
+		{"pl", "Open", "Otwórz", false},
+		{"pl", "Save", "Save", false},
+	}
+	entries := exportEntries(app, "pl", &ExportOptions{FallbackToSource: true})
+	if !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, expected %#v", entries, exp)
+	}
+
+	if err := validateFallbackChain(map[string][]string{"pl": {"xx"}}); err == nil {
+		t.Fatalf("expected error for invalid language")
+	}
+}
+
+func TestTranslationWithFallback(t *testing.T) {
+	app := newTestApp(t, "fallback", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Abrir (pt)", "pt", "user1")
+	writeTestTranslation(t, app, "Open", "Abrir (br)", "br", "user1")
+	writeTestTranslation(t, app, "Close", "Fechar", "pt", "user1")
+	writeTestTranslation(t, app, "Close", "Tancar", "ca", "user1")
+
+	tests := []struct {
+		src   string
+		lang  string
+		exp   string
+		expOk bool
+	}{
+		{"Open", "br", "Abrir (br)", true},
+		{"Close", "br", "Fechar", true},
+		{"Save", "br", "", false},
+		{"Close", "ca-xv", "Tancar", true},
+		{"Open", "ca-xv", "", false},
+	}
+	for _, test := range tests {
+		got, ok := app.TranslationWithFallback(test.src, test.lang)
+		if got != test.exp || ok != test.expOk {
+			t.Fatalf("%s in %s: got %q, %v, expected %q, %v", test.src, test.lang, got, ok, test.exp, test.expOk)
+		}
+	}
+
+	exp := []TransEntry{
+		{"br", "Close", "Fechar", false},
+		{"br", "Open", "Abrir (br)", false},
+		{"br", "Save", "", false},
+	}
+	entries := exportEntries(app, "br", &ExportOptions{})
+	if !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, expected %#v", entries, exp)
+	}
+	var buf bytes.Buffer
+	if err := writeTransFile(&buf, "br", entries, formatPo); err != nil {
+		t.Fatalf("writeTransFile() failed with %s", err)
+	}
+	entries, err := parseTransFile(buf.Bytes(), formatPo)
+	if err != nil || !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, %v, expected %#v", entries, err, exp)
+	}
+
+	exp[2].Translation = "Save"
+	entries = exportEntries(app, "br", &ExportOptions{FallbackToSource: true})
+	if !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, expected %#v", entries, exp)
+	}
+}
+
+func TestExportNoTranslate(t *testing.T) {
+	app := newTestApp(t, "notranslate", []string{"Open", "SumatraPDF"})
+	defer closeTestApp(app)
+	if err := app.store.SetNoTranslate("SumatraPDF", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+	if err := validateEdit(app.store, "SumatraPDF", "de"); err == nil {
+		t.Fatalf("validateEdit() should reject a string that should not be translated")
+	}
+
+	exp := []TransEntry{
+		{"de", "Open", "", false},
+		{"de", "SumatraPDF", "SumatraPDF", true},
+	}
+	entries := exportEntries(app, "de", &ExportOptions{})
+	if !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, expected %#v", entries, exp)
+	}
+	var buf bytes.Buffer
+	if err := writeTransFile(&buf, "de", entries, formatPo); err != nil {
+		t.Fatalf("writeTransFile() failed with %s", err)
+	}
+	if !strings.Contains(buf.String(), "#, no-translate\nmsgid \"SumatraPDF\"") {
+		t.Fatalf("string not marked in po file:\n%s", buf.String())
+	}
+	entries, err := parseTransFile(buf.Bytes(), formatPo)
+	if err != nil || !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, %v, expected %#v", entries, err, exp)
+	}
+
+	buf.Reset()
+	if err := writeTransFile(&buf, "de", entries, formatXliff); err != nil {
+		t.Fatalf("writeTransFile() failed with %s", err)
+	}
+	s := buf.String()
+	if !strings.Contains(s, `<trans-unit id="2" translate="no">`) || strings.Count(s, `translate="no"`) != 1 {
+		t.Fatalf("string not marked in xliff file:\n%s", s)
+	}
+}
+
+func TestExportDisabledLanguage(t *testing.T) {
+	app := newTestApp(t, "disabledlang", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	defer store.SetDisabledLangs(nil)
+
+	export := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=disabledlang&lang=pl&format=csv", nil))
+		return rr
+	}
+	if err := store.SetDisabledLangs([]string{"pl"}); err != nil {
+		t.Fatalf("SetDisabledLangs() failed with %s", err)
+	}
+	if rr := export(); rr.Code != 400 {
+		t.Fatalf("got status %d exporting disabled language, expected 400", rr.Code)
+	}
+	if strings.Contains(string(translationsForApp(app)), "pl:") {
+		t.Fatalf("disabled language in translations for /dltrans")
+	}
+	if err := store.SetDisabledLangs(nil); err != nil {
+		t.Fatalf("SetDisabledLangs() failed with %s", err)
+	}
+	if rr := export(); rr.Code != 200 || !strings.Contains(rr.Body.String(), "Otwórz") {
+		t.Fatalf("got status %d, %q after enabling the language", rr.Code, rr.Body.String())
+	}
+}
+
+func TestExportOnlyTranslated(t *testing.T) {
+	app := newTestApp(t, "onlytranslated", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Abrir", "pt", "user1")
+	writeTestTranslation(t, app, "Close", "Fechar (br)", "br", "user1")
+
+	// "Open" falls back to pt translation, "Save" is not translated
+	exp := []TransEntry{
+		{"br", "Close", "Fechar (br)", false},
+		{"br", "Open", "Abrir", false},
+	}
+	for _, format := range []string{formatCsv, formatPo, formatJson, formatXliff, formatBin} {
+		url := "/export?app=onlytranslated&lang=br&only=translated&fallback=source&format=" + format
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: got status %d", format, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "Save") {
+			t.Fatalf("%s: untranslated string exported:\n%s", format, rr.Body.String())
+		}
+		if format == formatXliff {
+			var doc xliffDoc
+			if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil || len(doc.File.Units) != len(exp) {
+				t.Fatalf("%s: invalid file %v:\n%s", format, err, rr.Body.String())
+			}
+			continue
+		}
+		if format == formatBin {
+			lang, entries, err := transbin.Decode(rr.Body.Bytes())
+			if err != nil || lang != "br" || len(entries) != len(exp) || entries[0].Translation != exp[0].Translation {
+				t.Fatalf("%s: got %q, %#v, %v", format, lang, entries, err)
+			}
+			continue
+		}
+		entries, err := parseTransFile(rr.Body.Bytes(), format)
+		if err != nil || !reflect.DeepEqual(entries, exp) {
+			t.Fatalf("%s: got %#v, %v, expected %#v", format, entries, err, exp)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	url := "/export?app=onlytranslated&lang=br&format=csv&only=untranslated"
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+	if rr.Code != 400 {
+		t.Fatalf("got status %d, expected 400", rr.Code)
+	}
+}
+
+func TestExportLastModified(t *testing.T) {
+	app := newTestApp(t, "lastmodified", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	modTime := time.Date(2020, 5, 1, 10, 20, 30, 0, time.UTC)
+	// sub-second part of mtime must be ignored
+	if err := os.Chtimes(app.store.FilePath(), modTime, modTime.Add(500*time.Millisecond)); err != nil {
+		t.Fatalf("os.Chtimes() failed with %s", err)
+	}
+
+	export := func(since time.Time) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/export?app=lastmodified&lang=pl&format=csv", nil)
+		if !since.IsZero() {
+			r.Header.Set("If-Modified-Since", since.Format(http.TimeFormat))
+		}
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	rr := export(time.Time{})
+	if rr.Code != 200 || rr.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+		t.Fatalf("got status %d, Last-Modified %q", rr.Code, rr.Header().Get("Last-Modified"))
+	}
+	for _, since := range []time.Time{modTime, modTime.Add(time.Hour)} {
+		if rr := export(since); rr.Code != 304 || rr.Body.Len() != 0 {
+			t.Fatalf("If-Modified-Since %s: got status %d", since, rr.Code)
+		}
+	}
+	if rr := export(modTime.Add(-time.Second)); rr.Code != 200 || !strings.Contains(rr.Body.String(), "Otwórz") {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestExportShared(t *testing.T) {
+	common := newTestApp(t, "common", []string{"Open", "Close", "Cancel"})
+	defer closeTestApp(common)
+	writeTestTranslation(t, common, "Open", "Otwórz (common)", "pl", "user1")
+	writeTestTranslation(t, common, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, common, "Cancel", "Anuluj", "pl", "user1")
+	app := newTestApp(t, "sharedto", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	app.SharedFrom = "common"
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+
+	// own translation takes precedence, "Close" comes from shared app and
+	// "Cancel" is not exported because it's not a string of the app
+	exp := []TransEntry{
+		{"pl", "Close", "Zamknij", false},
+		{"pl", "Open", "Otwórz", false},
+		{"pl", "Save", "", false},
+	}
+	entries := exportEntries(app, "pl", &ExportOptions{Shared: true})
+	if !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, expected %#v", entries, exp)
+	}
+	exp[0].Translation = ""
+	if entries = exportEntries(app, "pl", &ExportOptions{}); !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("without shared: got %#v, expected %#v", entries, exp)
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=sharedto&lang=pl&format=csv&shared=1", nil))
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), "pl,Close,Zamknij") {
+		t.Fatalf("got status %d, body:\n%s", rr.Code, rr.Body.String())
+	}
+
+	a := NewApp(&AppConfig{Name: "a", DataDir: "a", AdminTwitterUser: "admin", UploadSecret: "secret", SharedFrom: "a"})
+	if got := appInvalidField(a); got != "SharedFrom" {
+		t.Fatalf("got invalid field %q, expected SharedFrom", got)
+	}
+}
+
+func TestExportStreaming(t *testing.T) {
+	var strs []string
+	for i := 0; i < 2*transFileFlushEntries+7; i++ {
+		strs = append(strs, fmt.Sprintf("S\"%d\",\n", i))
+	}
+	app := newTestApp(t, "streaming", strs)
+	defer closeTestApp(app)
+	for i := 0; i < len(strs); i += 3 {
+		writeTestTranslation(t, app, strs[i], fmt.Sprintf("Napis %d", i), "pl", "user1")
+	}
+
+	for _, format := range []string{formatCsv, formatPo} {
+		buffered, err := app.ExportTranslations("pl", format, &ExportOptions{})
+		if err != nil {
+			t.Fatalf("%s: ExportTranslations() failed with %s", format, err)
+		}
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=streaming&lang=pl&format="+format, nil))
+		if rr.Code != 200 || !bytes.Equal(rr.Body.Bytes(), buffered) {
+			t.Fatalf("%s: got status %d, streamed output differs from buffered output", format, rr.Code)
+		}
+		entries, err := parseTransFile(rr.Body.Bytes(), format)
+		if err != nil || len(entries) != len(strs) {
+			t.Fatalf("%s: got %d entries, %v, expected %d", format, len(entries), err, len(strs))
+		}
+	}
+}
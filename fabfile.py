@@ -65,6 +65,7 @@ def zip_files(zip_path):
 	add_dir_files(zf, "scripts")
 	add_dir_files(zf, "tmpl")
 	add_dir_files(zf, "static")
+	zf.write("docs/openapi.json")
 	zf.close()
 
 
@@ -0,0 +1,208 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kjk/apptranslator/store"
+	"github.com/kjk/u"
+)
+
+// Glossary maps language to source terms and their required translations,
+// e.g. {"de": {"Account": "Konto"}}
+type Glossary map[string]map[string]string
+
+func validateGlossary(g Glossary) error {
+	for lang, terms := range g {
+		if !store.IsValidLangCode(lang) {
+			return fmt.Errorf("invalid lang code %q", lang)
+		}
+		for term, required := range terms {
+			if strings.TrimSpace(term) == "" || strings.TrimSpace(required) == "" {
+				return fmt.Errorf("empty glossary term for language %q", lang)
+			}
+		}
+	}
+	return nil
+}
+
+func readGlossary(path string) (Glossary, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var g Glossary
+	if err = json.Unmarshal(d, &g); err != nil {
+		return nil, err
+	}
+	return g, validateGlossary(g)
+}
+
+func writeGlossary(path string, g Glossary) error {
+	d, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, d, 0644)
+}
+
+// glossary uploaded with /uploadglossary, overrides glossary from config
+func (a *App) glossaryFilePath() string {
+	return filepath.Join(getDataDir(), a.DataDir, "glossary.json")
+}
+
+func readAppGlossary(app *App) error {
+	path := app.glossaryFilePath()
+	if !u.PathExists(path) {
+		return nil
+	}
+	g, err := readGlossary(path)
+	if err != nil {
+		return fmt.Errorf("readGlossary(%q) failed with %s", path, err)
+	}
+	app.SetGlossary(g)
+	return nil
+}
+
+// SetGlossary replaces glossary of the app
+func (a *App) SetGlossary(g Glossary) {
+	a.mu.Lock()
+	a.glossary = g
+	// glossary issues are different now
+	a.issues = nil
+	a.mu.Unlock()
+}
+
+// GlossaryForLang returns glossary terms for a given language
+func (a *App) GlossaryForLang(lang string) map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.glossary[lang]
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// containsWord returns true if s contains word as a whole word, ignoring case
+func containsWord(s, word string) bool {
+	s = strings.ToLower(s)
+	word = strings.ToLower(word)
+	for off := 0; off < len(s); {
+		idx := strings.Index(s[off:], word)
+		if idx == -1 {
+			return false
+		}
+		start := off + idx
+		end := start + len(word)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(s) || !isWordRune(after)) {
+			return true
+		}
+		off = start + 1
+	}
+	return false
+}
+
+// matchingGlossaryTerms returns glossary terms used in source, sorted
+func matchingGlossaryTerms(terms map[string]string, source string) []string {
+	var res []string
+	for term := range terms {
+		if containsWord(source, term) {
+			res = append(res, term)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// glossaryProblems returns a function that reports glossary terms used in
+// the source string whose required translation is not in the translation.
+// Required translation is matched ignoring case and it can be a part of
+// a longer word, because translations are inflected and compounded
+// (e.g. "Benutzerkonto" for "User account")
+func glossaryProblems(terms map[string]string) func(source, translation string) []string {
+	return func(source, translation string) []string {
+		var res []string
+		trans := strings.ToLower(translation)
+		for _, term := range matchingGlossaryTerms(terms, source) {
+			required := terms[term]
+			if !strings.Contains(trans, strings.ToLower(required)) {
+				res = append(res, fmt.Sprintf("%q should be translated as %q", term, required))
+			}
+		}
+		return res
+	}
+}
+
+// CheckGlossary returns translations in a given language that don't use
+// translations of glossary terms required by the glossary
+func (a *App) CheckGlossary(lang string) []Issue {
+	terms := a.GlossaryForLang(lang)
+	if len(terms) == 0 {
+		return nil
+	}
+	return a.checkTranslations(lang, issueGlossary, glossaryProblems(terms))
+}
+
+// glossaryHints returns required translations of glossary terms for strings
+// in a given language, indexed by source string, to show them when editing
+func glossaryHints(app *App, li *store.LangInfo) map[string][]string {
+	res := make(map[string][]string)
+	terms := app.GlossaryForLang(li.Code)
+	if len(terms) == 0 {
+		return res
+	}
+	for _, tr := range li.ActiveStrings {
+		for _, term := range matchingGlossaryTerms(terms, tr.String) {
+			res[tr.String] = append(res[tr.String], term+" => "+terms[term])
+		}
+	}
+	return res
+}
+
+// url: POST /uploadglossary?app=$appName&secret=$uploadSecret
+// POST data is in "glossary" field, in json format:
+/*
+{
+  "de": { "Account": "Konto" }
+}
+*/
+// Failures are returned as UploadError json
+func handleUploadGlossary(w http.ResponseWriter, r *http.Request) {
+	app := getUploadApp(w, r, "glossary")
+	if app == nil {
+		return
+	}
+	appName := app.Name
+	var g Glossary
+	if err := json.Unmarshal([]byte(normalizeUploadedText(r.FormValue("glossary"))), &g); err != nil {
+		serveUploadError(w, uploadErrParse, "Error parsing uploaded glossary: %s", err)
+		return
+	}
+	if err := validateGlossary(g); err != nil {
+		serveUploadError(w, uploadErrParse, "Invalid glossary: %s", err)
+		return
+	}
+	if err := writeGlossary(app.glossaryFilePath(), g); err != nil {
+		logger.ForRequest(r).Errorf("writeGlossary() failed with %s", err)
+		http.Error(w, "Failed to save glossary", http.StatusInternalServerError)
+		return
+	}
+	app.SetGlossary(g)
+	n := 0
+	for _, terms := range g {
+		n += len(terms)
+	}
+	logger.ForRequest(r).Noticef("handleUploadGlossary(): uploaded %d terms for %s", n, appName)
+	fmt.Fprintf(w, "Uploaded %d glossary terms\n", n)
+}
@@ -0,0 +1,62 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestGlossaryProblems(t *testing.T) {
+	check := glossaryProblems(map[string]string{"Account": "Konto", "Save": "Speichern"})
+	tests := []struct {
+		source      string
+		translation string
+		exp         []string
+	}{
+		{"Account settings", "Kontoeinstellungen", nil},
+		{"Open your account", "Öffnen Sie Ihr Konto", nil},
+		{"Account settings", "Benutzereinstellungen", []string{`"Account" should be translated as "Konto"`}},
+		{"Save account", "Konto sichern", []string{`"Save" should be translated as "Speichern"`}},
+		// only whole words match
+		{"Accountant", "Buchhalter", nil},
+		{"Saved", "Gesichert", nil},
+	}
+	for _, test := range tests {
+		got := check(test.source, test.translation)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("%q => %q: got %#v, expected %#v", test.source, test.translation, got, test.exp)
+		}
+	}
+}
+
+func TestCheckGlossary(t *testing.T) {
+	app := newTestApp(t, "glossary", []string{"Account", "Delete account", "Open"})
+	defer closeTestApp(app)
+	app.SetGlossary(Glossary{"de": {"Account": "Konto"}})
+	writeTestTranslation(t, app, "Account", "Konto", "de", "user1")
+	writeTestTranslation(t, app, "Delete account", "Benutzer löschen", "de", "user1")
+	writeTestTranslation(t, app, "Delete account", "Usuń użytkownika", "pl", "user1")
+
+	issues := app.Issues("de")
+	if len(issues) != 1 || issues[0].Kind != issueGlossary || issues[0].Source != "Delete account" {
+		t.Fatalf("unexpected issues %#v", issues)
+	}
+	if issues := app.Issues("pl"); len(issues) != 0 {
+		t.Fatalf("unexpected issues %#v", issues)
+	}
+	var li *store.LangInfo
+	for _, l := range app.store.LangInfos() {
+		if l.Code == "de" {
+			li = l
+		}
+	}
+	exp := map[string][]string{
+		"Account":        {"Account => Konto"},
+		"Delete account": {"Account => Konto"},
+	}
+	if got := glossaryHints(app, li); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %#v, expected %#v", got, exp)
+	}
+}
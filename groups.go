@@ -0,0 +1,130 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+// longer group names are probably a mistake
+const maxGroupNameLen = 100
+
+// isValidGroupName returns true for a single-line name of reasonable length.
+// Empty name means no group
+func isValidGroupName(s string) bool {
+	return s == strings.TrimSpace(s) && !strings.ContainsAny(s, "\r\n") && utf8.RuneCountInString(s) <= maxGroupNameLen
+}
+
+// StringGroup is a group of related strings, shown together in a
+// collapsible section
+type StringGroup struct {
+	// "" for strings that are not in a group
+	Name    string
+	Strings []*store.Translation
+}
+
+// groupLess orders strings by group name, with strings that are not in
+// a group last
+func groupLess(g1, g2 string) bool {
+	if g1 == "" || g2 == "" {
+		return g1 != "" && g2 == ""
+	}
+	return g1 < g2
+}
+
+// sortByGroup orders strs by group, see groupLess(). The order of
+// strings within a group doesn't change
+func sortByGroup(strs []*store.Translation) {
+	sort.SliceStable(strs, func(i, j int) bool {
+		return groupLess(strs[i].Group, strs[j].Group)
+	})
+}
+
+// groupStrings buckets strs by group. Groups are ordered by name, with
+// strings that are not in a group last, in a group with empty name
+func groupStrings(strs []*store.Translation) []*StringGroup {
+	byName := make(map[string]*StringGroup)
+	var res []*StringGroup
+	for _, tr := range strs {
+		g := byName[tr.Group]
+		if g == nil {
+			g = &StringGroup{Name: tr.Group}
+			byName[tr.Group] = g
+			res = append(res, g)
+		}
+		g.Strings = append(g.Strings, tr)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return groupLess(res[i].Name, res[j].Name)
+	})
+	return res
+}
+
+// HasGroups returns true if some strings on the page are in a group, in
+// which case strings are shown in sections
+func (m *ModelAppTranslations) HasGroups() bool {
+	return len(m.Groups) > 1 || len(m.Groups) == 1 && m.Groups[0].Name != ""
+}
+
+// parseGroups parses json object mapping source strings to their groups,
+// uploaded in "groups" field of /uploadstrings. Strings must be in strs
+func parseGroups(s string, strs []string) (map[string]string, error) {
+	res, err := parseStringsMap(s, strs)
+	if err != nil {
+		return nil, err
+	}
+	for str, group := range res {
+		if !isValidGroupName(group) {
+			return nil, fmt.Errorf("invalid group %q of %q", group, str)
+		}
+	}
+	return res, nil
+}
+
+func setGroups(st *store.StoreCsv, groups map[string]string) error {
+	for str, group := range groups {
+		if err := st.SetGroup(str, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// url: /group?app=${app}&lang=${lang}&string=${string}&val=${group}
+// empty val removes the string from its group
+func handleGroup(w http.ResponseWriter, r *http.Request) {
+	app, langCode := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't change strings")
+		return
+	}
+	str := strings.TrimSpace(r.FormValue("string"))
+	if !app.store.IsActiveString(str) {
+		httpErrorf(w, "String %q doesn't exist", str)
+		return
+	}
+	group := strings.TrimSpace(r.FormValue("val"))
+	if !isValidGroupName(group) {
+		httpErrorf(w, "Invalid group %q, must be a single line of at most %d characters", group, maxGroupNameLen)
+		return
+	}
+	if err := app.store.SetGroup(str, group); err != nil {
+		httpErrorf(w, "Failed to change string %q", err)
+		return
+	}
+	msg := fmt.Sprintf("Removed %q from its group", str)
+	if group != "" {
+		msg = fmt.Sprintf("Moved %q to group %q", str, group)
+	}
+	u := fmt.Sprintf("/app/%s/%s?msg=%s", app.Name, langCode, url.QueryEscape(msg))
+	http.Redirect(w, r, u, http.StatusFound)
+}
@@ -0,0 +1,101 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStringGroups(t *testing.T) {
+	app := newTestApp(t, "groups", nil)
+	defer closeTestApp(app)
+	handler := makeHTTPServer().Handler
+
+	upload := func(groups string) int {
+		form := url.Values{
+			"app":     {"groups"},
+			"secret":  {"secret"},
+			"strings": {"AppTranslator strings\nOpen\nClose\nCopy\nPaste\nAbout"},
+			"groups":  {groups},
+		}
+		r := httptest.NewRequest("POST", "/uploadstrings", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	if code := upload(`{"Open": "File", "Close": "File", "Copy": "Edit", "Paste": "Edit"}`); code != 200 {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	for _, bad := range []string{`{"Open": "File\nMenu"}`, `{"Save": "File"}`, `[`} {
+		if code := upload(bad); code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", bad, code)
+		}
+	}
+
+	groupsOf := func(m *ModelAppTranslations) map[string][]string {
+		res := make(map[string][]string)
+		var names []string
+		for _, g := range m.Groups {
+			names = append(names, g.Name)
+			for _, tr := range g.Strings {
+				res[g.Name] = append(res[g.Name], tr.String)
+			}
+		}
+		// groups are ordered by name, ungrouped strings are last
+		if exp := []string{"Edit", "File", ""}; !reflect.DeepEqual(names, exp) {
+			t.Fatalf("got groups %q, expected %q", names, exp)
+		}
+		return res
+	}
+	m := buildModelAppTranslations(app, "pl", "")
+	m.paginate(&url.URL{Path: "/app/groups/pl"}, statusAll, sortSource, 1, defaultPerPage)
+	exp := map[string][]string{
+		"Edit": {"Copy", "Paste"},
+		"File": {"Close", "Open"},
+		"":     {"About"},
+	}
+	if got := groupsOf(m); !reflect.DeepEqual(got, exp) || !m.HasGroups() {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/app/groups/pl", nil))
+	body := rr.Body.String()
+	if rr.Code != 200 || !strings.Contains(body, "<summary><b>Edit</b> (2)</summary>") || !strings.Contains(body, "<b>Other strings</b> (1)") {
+		t.Fatalf("got status %d, body:\n%s", rr.Code, body)
+	}
+
+	set := func(user, val string) int {
+		u := "/group?app=groups&lang=pl&string=About&val=" + url.QueryEscape(val)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newRequestWithCookie("GET", u, &SecureCookieValue{User: user}))
+		return rr.Code
+	}
+	if code := set("user1", "Help"); code != 400 {
+		t.Fatalf("non-admin: got status %d, expected 400", code)
+	}
+	if code := set("admin", "Help\nMenu"); code != 400 {
+		t.Fatalf("invalid group: got status %d, expected 400", code)
+	}
+	if code := set("admin", "Edit"); code != 302 {
+		t.Fatalf("got status %d, expected 302", code)
+	}
+	if got := app.store.Group("About"); got != "Edit" {
+		t.Fatalf("got group %q, expected Edit", got)
+	}
+
+	// without groups, strings are not shown in sections
+	for _, str := range []string{"Open", "Close", "Copy", "Paste", "About"} {
+		if err := app.store.SetGroup(str, ""); err != nil {
+			t.Fatalf("SetGroup() failed with %s", err)
+		}
+	}
+	m = buildModelAppTranslations(app, "pl", "")
+	if m.HasGroups() || len(m.Groups) != 1 || len(m.Groups[0].Strings) != 5 {
+		t.Fatalf("unexpected groups %#v", m.Groups)
+	}
+}
@@ -58,7 +58,7 @@ func buildModelApp(app *App, loggedUser string, sortedByName bool) *ModelApp {
 	edits := app.store.RecentEdits(10)
 	editsDisplay := make([]EditDisplay, len(edits), len(edits))
 	for i, e := range edits {
-		ed := EditDisplay{Edit: e, TextDisplay: strTruncate(e.Text, 42)}
+		ed := EditDisplay{Edit: e, TextDisplay: strTruncate(displayString(e.Text), 42)}
 		editsDisplay[i] = ed
 	}
 	model := &ModelApp{
@@ -76,6 +76,7 @@ func buildModelApp(app *App, loggedUser string, sortedByName bool) *ModelApp {
 	if sortedByName {
 		store.SortLangsByName(model.Langs)
 	}
+	app.sortLangsByPriority(model.Langs)
 	return model
 }
 
@@ -0,0 +1,128 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppBranding(t *testing.T) {
+	app := newTestApp(t, "branded", []string{"Open"})
+	defer closeTestApp(app)
+
+	get := func(url string) string {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: got status %d", url, rr.Code)
+		}
+		return rr.Body.String()
+	}
+	body := get("/app/branded")
+	if !strings.Contains(body, defaultThemeColor) || strings.Contains(body, "app-logo") {
+		t.Fatalf("expected default branding, got %s", body)
+	}
+
+	app.LogoURL = "https://example.com/logo.png"
+	app.ThemeColor = "#ff8800"
+	for _, url := range []string{"/app/branded", "/app/branded/pl", "/stats/branded", "/todo/branded/pl"} {
+		body = get(url)
+		if !strings.Contains(body, `src="https://example.com/logo.png"`) || !strings.Contains(body, "#ff8800") {
+			t.Fatalf("%s: expected logo and theme color, got %s", url, body)
+		}
+	}
+
+	tests := []struct {
+		color string
+		valid bool
+	}{
+		{"", true},
+		{"#fff", true},
+		{"#0088CC", true},
+		{"0088cc", false},
+		{"#0088c", false},
+		{"#gggggg", false},
+		{"red", false},
+	}
+	for _, test := range tests {
+		a := NewApp(&AppConfig{Name: "a", DataDir: "a", AdminTwitterUser: "admin", UploadSecret: "secret", ThemeColor: test.color})
+		got := appInvalidField(a)
+		if test.valid && got != "" || !test.valid && got != "ThemeColor" {
+			t.Fatalf("%q: got invalid field %q", test.color, got)
+		}
+	}
+}
+
+func TestLanguageOrder(t *testing.T) {
+	app := newTestApp(t, "langorder", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Öffnen", "de", "user1")
+	writeTestTranslation(t, app, "Open", "Ouvrir", "fr", "user1")
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+
+	defaultOrder := buildModelApp(app, "", true).Langs
+	app.LanguageOrder = []string{"pl", "fr"}
+	langs := buildModelApp(app, "", true).Langs
+	if langs[0].Code != "pl" || langs[1].Code != "fr" {
+		t.Fatalf("got %s, %s, expected pl, fr", langs[0].Code, langs[1].Code)
+	}
+	// other languages follow in the default order
+	var rest []string
+	for _, li := range defaultOrder {
+		if li.Code != "pl" && li.Code != "fr" {
+			rest = append(rest, li.Code)
+		}
+	}
+	for i, code := range rest {
+		if langs[i+2].Code != code {
+			t.Fatalf("language %d is %s, expected %s", i+2, langs[i+2].Code, code)
+		}
+	}
+
+	exp := ":Open\npl:Otwórz\nfr:Ouvrir\nde:Öffnen\n"
+	if got := string(translationsForApp(app)); got != exp {
+		t.Fatalf("got %q, expected %q", got, exp)
+	}
+
+	// codes are normalized without changing the config
+	order := []string{"de_DE", "pt-BR"}
+	a := NewApp(&AppConfig{Name: "a", DataDir: "a", AdminTwitterUser: "admin", UploadSecret: "secret", LanguageOrder: order})
+	if got := appInvalidField(a); got != "" || !reflect.DeepEqual(a.LanguageOrder, []string{"de", "br"}) || order[0] != "de_DE" {
+		t.Fatalf("got invalid field %q, order %v, config order %v", got, a.LanguageOrder, order)
+	}
+	for _, order := range [][]string{{"xx"}, {"pl", "pl"}, {"pl", "pl_PL"}} {
+		a := NewApp(&AppConfig{Name: "a", DataDir: "a", AdminTwitterUser: "admin", UploadSecret: "secret", LanguageOrder: order})
+		if got := appInvalidField(a); got != "LanguageOrder" {
+			t.Fatalf("%v: got invalid field %q", order, got)
+		}
+	}
+}
+
+func TestAppDataDirValidation(t *testing.T) {
+	tests := []struct {
+		dir   string
+		valid bool
+	}{
+		{"sumatra", true},
+		{"apps/sumatra", true},
+		{"apps/../sumatra", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../escape", false},
+		{"apps/../../escape", false},
+		{"/var/data/sumatra", false},
+		{filepath.Join(os.TempDir(), "sumatra"), false},
+	}
+	for _, test := range tests {
+		a := NewApp(&AppConfig{Name: "a", DataDir: test.dir, AdminTwitterUser: "admin", UploadSecret: "secret"})
+		got := appInvalidField(a)
+		if test.valid && got != "" || !test.valid && got != "DataDir" {
+			t.Fatalf("%q: got invalid field %q", test.dir, got)
+		}
+	}
+}
@@ -2,37 +2,189 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/kjk/apptranslator/store"
+	"golang.org/x/text/collate"
 
 	"github.com/gorilla/mux"
 )
 
+// values of status argument, used to show only some of the strings
+const (
+	statusAll          = ""
+	statusUntranslated = "untranslated"
+	statusTranslated   = "translated"
+	statusFuzzy        = "fuzzy"
+)
+
+// values of sort argument. By default strings that need translation go first
+const (
+	sortDefault  = ""
+	sortSource   = "source"
+	sortModified = "modified"
+)
+
+func isValidStatus(status string) bool {
+	switch status {
+	case statusAll, statusUntranslated, statusTranslated, statusFuzzy:
+		return true
+	}
+	return false
+}
+
+func isValidSort(sortBy string) bool {
+	switch sortBy {
+	case sortDefault, sortSource, sortModified:
+		return true
+	}
+	return false
+}
+
+func hasStatus(tr *store.Translation, status string) bool {
+	switch status {
+	case statusUntranslated:
+		return tr.NeedsTranslation()
+	case statusTranslated:
+		return tr.IsTranslated() && !tr.Fuzzy
+	case statusFuzzy:
+		return tr.IsTranslated() && tr.Fuzzy
+	}
+	return true
+}
+
+// filterStrings returns strings with a given status, sorted by sortBy.
+// Sorting by source uses c, neutral collation if nil. strs is not modified
+func filterStrings(strs []*store.Translation, status, sortBy string, c *collate.Collator) []*store.Translation {
+	res := make([]*store.Translation, 0, len(strs))
+	for _, tr := range strs {
+		if hasStatus(tr, status) {
+			res = append(res, tr)
+		}
+	}
+	switch sortBy {
+	case sortSource:
+		less := collatedLess(c)
+		sort.Slice(res, func(i, j int) bool {
+			return less(res[i].String, res[j].String)
+		})
+	case sortModified:
+		sort.Sort(store.ByModified{TranslationSeq: res})
+	}
+	return res
+}
+
 type ModelAppTranslations struct {
-	App                  *App
-	LangInfo             *store.LangInfo
-	User                 string
-	UserIsAdmin          bool
+	App *App
+	// namespace of shown strings, see namespaces.go
+	Namespace string
+	// all namespaces of the app, empty if it only has the default one
+	Namespaces  []string
+	LangInfo    *store.LangInfo
+	User        string
+	UserIsAdmin bool
+	// true if user can approve translations
+	UserIsReviewer       bool
 	StringsCount         int
 	TransProgressPercent int
 	RedirectUrl          string
 	Message              string
+	// problems with translations, indexed by source string
+	Issues map[string][]string
+	// required translations of glossary terms, indexed by source string
+	Glossary map[string][]string
+	// true if admin can fill untranslated strings with machine translations
+	CanMachineTranslate bool
+	// active strings on the current page
+	Strings []*store.Translation
+	// Strings grouped by their group
+	Groups     []*StringGroup
+	Pagination *Pagination
+	Status     string
+	Sort       string
+	url        *url.URL
+	// used when sorting by source, neutral collation if nil
+	collator *collate.Collator
+}
+
+// paginate limits Strings to a given page of active strings with a given
+// status, sorted by sortBy. u is the url of the page, used to build links
+// to other pages
+func (m *ModelAppTranslations) paginate(u *url.URL, status, sortBy string, page, perPage int) {
+	all := filterStrings(m.LangInfo.ActiveStrings, status, sortBy, m.collator)
+	// strings of a group are shown together, even if they don't fit on
+	// one page
+	sortByGroup(all)
+	m.Status = status
+	m.Sort = sortBy
+	m.url = u
+	m.Pagination = newPagination(u, len(all), page, perPage)
+	start, end := m.Pagination.Bounds()
+	m.Strings = all[start:end]
+	m.Groups = groupStrings(m.Strings)
+}
+
+// ListURL returns url of the first page of strings with a given value of
+// status or sort argument
+func (m *ModelAppTranslations) ListURL(name, val string) string {
+	u := *m.url
+	q := u.Query()
+	q.Del("page")
+	if val == "" {
+		q.Del(name)
+	} else {
+		q.Set(name, val)
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
 }
 
 func buildModelAppTranslations(app *App, langCode, user string) *ModelAppTranslations {
+	return buildModelNamespaceTranslations(app, defaultNamespace, langCode, user)
+}
+
+// buildModelNamespaceTranslations is buildModelAppTranslations() of strings
+// in namespace ns, which must exist
+func buildModelNamespaceTranslations(app *App, ns, langCode, user string) *ModelAppTranslations {
 	model := &ModelAppTranslations{
 		App:         app,
+		Namespace:   ns,
 		User:        user,
 		UserIsAdmin: userIsAdmin(app, user)}
+	model.UserIsReviewer = userIsReviewer(app, user)
+	model.CanMachineTranslate = model.UserIsAdmin && machineTranslator != nil
+	if namespaces := app.Namespaces(); len(namespaces) > 0 {
+		model.Namespaces = append([]string{defaultNamespace}, namespaces...)
+	}
 
-	modelApp := buildModelApp(app, user, false)
-	for _, langInfo := range modelApp.Langs {
+	langs := buildModelApp(app, user, false).Langs
+	if ns != defaultNamespace {
+		langs = app.NamespaceStore(ns).LangInfos()
+		// other actions, issues and machine translation only work with
+		// strings in the default namespace
+		model.UserIsAdmin = false
+		model.UserIsReviewer = false
+		model.CanMachineTranslate = false
+	}
+	for _, langInfo := range langs {
 		if langInfo.Code != langCode {
 			continue
 		}
 		model.LangInfo = langInfo
+		if ns == defaultNamespace {
+			model.Issues = issuesBySource(app.Issues(langCode))
+		}
+		model.Glossary = glossaryHints(app, langInfo)
 		model.StringsCount = len(langInfo.ActiveStrings)
+		u := &url.URL{Path: fmt.Sprintf("/app/%s/%s", app.Name, langCode)}
+		if ns != defaultNamespace {
+			u.RawQuery = url.Values{"ns": {ns}}.Encode()
+		}
+		model.paginate(u, statusAll, sortDefault, 1, defaultPerPage)
 		if 0 == model.StringsCount {
 			model.TransProgressPercent = 100
 		} else {
@@ -46,7 +198,7 @@ func buildModelAppTranslations(app *App, langCode, user string) *ModelAppTransla
 	panic("buildModelAppTranslations() failed")
 }
 
-// url: /app/{appname}/{lang}?msg=${msg}
+// url: /app/{appname}/{lang}?msg=${msg}&page=${page}&per=${perPage}&status=${status}&sort=${sort}&locale=${locale}&ns=${ns}
 func handleAppTranslations(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	appName := vars["appname"]
@@ -56,14 +208,51 @@ func handleAppTranslations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	langCode := vars["lang"]
+	langCode := store.NormalizeLangCode(vars["lang"])
 	if !store.IsValidLangCode(langCode) {
 		httpErrorf(w, "Invalid language: %q", langCode)
 		return
 	}
+	page, err := formIntArg(r, "page", 1)
+	if err != nil || page == 0 {
+		httpErrorf(w, "Invalid page %q", r.FormValue("page"))
+		return
+	}
+	perPage, err := formIntArg(r, "per", defaultPerPage)
+	if err != nil || perPage == 0 {
+		httpErrorf(w, "Invalid per %q", r.FormValue("per"))
+		return
+	}
+	status := r.FormValue("status")
+	if !isValidStatus(status) {
+		httpErrorf(w, "Invalid status %q", status)
+		return
+	}
+	sortBy := r.FormValue("sort")
+	if !isValidSort(sortBy) {
+		httpErrorf(w, "Invalid sort %q", sortBy)
+		return
+	}
+	collator, err := newCollator(r.FormValue("locale"))
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	ns := strings.TrimSpace(r.FormValue("ns"))
+	if app.NamespaceStore(ns) == nil {
+		httpErrorf(w, "Namespace %q doesn't exist", ns)
+		return
+	}
 	msg := r.FormValue("msg")
 	//fmt.Printf("handleAppTranslations() appName=%s, lang=%s\n", app.Name, langCode)
-	model := buildModelAppTranslations(app, langCode, decodeUserFromCookie(r))
+	model := buildModelNamespaceTranslations(app, ns, langCode, decodeUserFromCookie(r))
+	// links to other pages shouldn't repeat the message
+	u := *r.URL
+	q := u.Query()
+	q.Del("msg")
+	u.RawQuery = q.Encode()
+	model.collator = collator
+	model.paginate(&u, status, sortBy, page, perPage)
 	model.Message = msg
 	model.RedirectUrl = r.URL.String()
 	ExecTemplate(w, tmplAppTrans, model)
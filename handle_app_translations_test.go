@@ -0,0 +1,69 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFilterStrings(t *testing.T) {
+	app := newTestApp(t, "filter", []string{"Open", "Close", "Save", "About", "Exit", "Help"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Save", "Zapisz", "pl", "user1")
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	if err := app.store.WriteFuzzyTranslation("Close", "Zamknij", "pl", machineUser); err != nil {
+		t.Fatalf("WriteFuzzyTranslation() failed with %s", err)
+	}
+	if err := app.store.SetNoTranslate("Exit", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	tests := []struct {
+		status, sortBy string
+		exp            []string
+	}{
+		{statusAll, sortDefault, []string{"About", "Help", "Close", "Exit", "Open", "Save"}},
+		{statusAll, sortSource, []string{"About", "Close", "Exit", "Help", "Open", "Save"}},
+		{statusAll, sortModified, []string{"Close", "Open", "Save", "About", "Exit", "Help"}},
+		{statusUntranslated, sortDefault, []string{"About", "Help"}},
+		{statusUntranslated, sortSource, []string{"About", "Help"}},
+		{statusUntranslated, sortModified, []string{"About", "Help"}},
+		{statusTranslated, sortDefault, []string{"Open", "Save"}},
+		{statusTranslated, sortSource, []string{"Open", "Save"}},
+		{statusTranslated, sortModified, []string{"Open", "Save"}},
+		{statusFuzzy, sortDefault, []string{"Close"}},
+		{statusFuzzy, sortSource, []string{"Close"}},
+		{statusFuzzy, sortModified, []string{"Close"}},
+	}
+	model := buildModelAppTranslations(app, "pl", "")
+	for _, test := range tests {
+		model.paginate(&url.URL{Path: "/app/filter/pl"}, test.status, test.sortBy, 1, defaultPerPage)
+		var got []string
+		for _, tr := range model.Strings {
+			got = append(got, tr.String)
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("status=%q sort=%q: got %v, expected %v", test.status, test.sortBy, got, test.exp)
+		}
+	}
+	// filtering must not change the order of cached strings
+	model = buildModelAppTranslations(app, "pl", "")
+	if got := model.Strings[0].String; got != "About" {
+		t.Fatalf("got %q, expected %q", got, "About")
+	}
+
+	for _, url := range []string{"/app/filter/pl?status=foo", "/app/filter/pl?sort=foo"} {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", url, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/app/filter/pl?status=fuzzy&sort=modified", nil))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d", rr.Code)
+	}
+}
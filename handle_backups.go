@@ -0,0 +1,120 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+)
+
+// BackupListEntry describes a backup file on /admin/backups page
+type BackupListEntry struct {
+	Snapshot string `json:"snapshot"`
+	Time     string `json:"time"`
+	Size     int64  `json:"size"`
+	// url of /admin/backups/download for this file
+	URL string `json:"url"`
+}
+
+// listBackups returns backup files in bs, newest first
+func listBackups(bs BackupStore) ([]*BackupListEntry, error) {
+	files, err := bs.List()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*BackupListEntry, 0)
+	for _, f := range files {
+		if !isBackupFile(f.Name) {
+			continue
+		}
+		snapshot := path.Base(f.Name)
+		e := &BackupListEntry{
+			Snapshot: snapshot,
+			Size:     f.Size,
+			URL:      withBasePath("/admin/backups/download?snapshot=" + url.QueryEscape(snapshot)),
+		}
+		if !f.Time.IsZero() {
+			e.Time = f.Time.UTC().Format("2006-01-02 15:04:05")
+		}
+		res = append(res, e)
+	}
+	// names start with time of backup
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Snapshot > res[j].Snapshot
+	})
+	return res, nil
+}
+
+type ModelBackups struct {
+	PageTitle   string
+	User        string
+	RedirectUrl string
+	Backups     []*BackupListEntry
+}
+
+// checkBackupsAccess returns false and writes an error if backups can't be
+// accessed by the user. Backups have data of all apps, so only the server
+// admin can access them, not admins of apps
+func checkBackupsAccess(w http.ResponseWriter, r *http.Request) bool {
+	if !userIsServerAdmin(decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't see backups")
+		return false
+	}
+	if backupStore == nil {
+		httpErrorf(w, "Backups are not configured")
+		return false
+	}
+	return true
+}
+
+// url: /admin/backups[?format=json]
+// Lists backup files, as html or json (if format=json or Accept header
+// asks for json)
+func handleBackups(w http.ResponseWriter, r *http.Request) {
+	if !checkBackupsAccess(w, r) {
+		return
+	}
+	backups, err := listBackups(backupStore)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleBackups(): listBackups() failed with %s", err)
+		http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+		return
+	}
+	if wantsJSON(r) {
+		serveJSON(w, backups)
+		return
+	}
+	model := &ModelBackups{
+		PageTitle:   "Backups",
+		User:        decodeUserFromCookie(r),
+		RedirectUrl: r.URL.String(),
+		Backups:     backups,
+	}
+	ExecTemplate(w, tmplBackups, model)
+}
+
+// url: /admin/backups/download?snapshot=${snapshot}
+// Downloads a backup file through the server, so that backup storage
+// doesn't have to be public
+func handleBackupDownload(w http.ResponseWriter, r *http.Request) {
+	if !checkBackupsAccess(w, r) {
+		return
+	}
+	snapshot := r.FormValue("snapshot")
+	name, err := findSnapshot(backupStore, snapshot)
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	d, err := backupStore.Get(name)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleBackupDownload(): Get(%q) failed with %s", name, err)
+		http.Error(w, "Failed to download backup", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshot))
+	w.Write(d)
+}
@@ -0,0 +1,79 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackups(t *testing.T) {
+	app := newTestApp(t, "backups", []string{"Open"})
+	defer closeTestApp(app)
+
+	bs := newFakeBackupStore()
+	snapshots := []string{
+		"121011_1121_c7fedc06cf4b08fef66090eaa0ad7a68dc13a325.zip",
+		"121012_0930_0000000000000000000000000000000000000000.zip",
+		"121011_2300_1111111111111111111111111111111111111111.zip",
+	}
+	for i, snapshot := range snapshots {
+		name := "apptranslator/" + snapshot
+		bs.files[name] = []byte(strings.Repeat("x", i+1))
+		bs.times[name] = time.Date(2012, 10, 11, i, 0, 0, 0, time.UTC)
+	}
+	// not a backup file, shouldn't be listed
+	bs.files["apptranslator/notes.txt"] = []byte("notes")
+	backupStore = bs
+	defer func() { backupStore = nil }()
+
+	get := func(url, user string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, newRequestWithCookie("GET", url, &SecureCookieValue{User: user}))
+		return rr
+	}
+
+	root, done := setTestServerAdmin()
+	defer done()
+	rr := get("/admin/backups?format=json", root)
+	if rr.Code != 200 {
+		t.Fatalf("got status %d", rr.Code)
+	}
+	var got []*BackupListEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	exp := []*BackupListEntry{
+		{snapshots[1], "2012-10-11 01:00:00", 2, "/admin/backups/download?snapshot=" + snapshots[1]},
+		{snapshots[2], "2012-10-11 02:00:00", 3, "/admin/backups/download?snapshot=" + snapshots[2]},
+		{snapshots[0], "2012-10-11 00:00:00", 1, "/admin/backups/download?snapshot=" + snapshots[0]},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+
+	rr = get("/admin/backups", root)
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), exp[0].URL) {
+		t.Fatalf("got status %d, body %s", rr.Code, rr.Body.String())
+	}
+
+	rr = get(exp[1].URL, root)
+	if rr.Code != 200 || rr.Body.String() != "xxx" || rr.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+
+	for _, test := range []struct{ url, user string }{
+		{"/admin/backups", ""},
+		{"/admin/backups", "admin"},
+		{exp[0].URL, "user1"},
+		{exp[0].URL, "admin"},
+		{"/admin/backups/download?snapshot=notes.txt", root},
+	} {
+		if rr = get(test.url, test.user); rr.Code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", test.url, rr.Code)
+		}
+	}
+}
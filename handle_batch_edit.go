@@ -0,0 +1,107 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+// BatchEdit is a single edit in a batch submitted to /batchedit
+type BatchEdit struct {
+	Source string `json:"source"`
+	Lang   string `json:"lang"`
+	Value  string `json:"value"`
+}
+
+// BatchEditResult is a result of applying a single BatchEdit
+type BatchEditResult struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// applyBatchEdits validates all edits before applying any of them. If any
+// edit is invalid, nothing is applied. Lang codes of edits are normalized.
+// Edits are applied to strings of st, which is the store of the app or one
+// of its namespaces. Returns a result for each edit and true if edits were
+// applied
+func applyBatchEdits(st *store.StoreCsv, edits []BatchEdit, user string) ([]BatchEditResult, bool) {
+	res := make([]BatchEditResult, len(edits))
+	valid := true
+	for i, e := range edits {
+		lang, err := store.ParseLangCode(e.Lang)
+		if err == nil {
+			edits[i].Lang = lang
+			err = validateEdit(st, e.Source, lang)
+		}
+		if err != nil {
+			res[i].Error = err.Error()
+			valid = false
+		}
+	}
+	if !valid {
+		return res, false
+	}
+	for i, e := range edits {
+		if err := st.WriteNewTranslation(e.Source, e.Value, e.Lang, user); err != nil {
+			logger.Errorf("applyBatchEdits(): WriteNewTranslation() failed with %s", err)
+			res[i].Error = err.Error()
+			continue
+		}
+		res[i].Ok = true
+	}
+	return res, true
+}
+
+// url: POST /batchedit?app=$app[&ns=$ns]
+// POST body is a json array of edits:
+// [{"source": "Open", "lang": "pl", "value": "Otwórz"}, ...]
+// Returns json with result for each edit:
+// {"applied": true, "results": [{"ok": true}, ...]}
+func handleBatchEdit(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	app := getAppArg(w, r)
+	if app == nil {
+		return
+	}
+	user := decodeUserFromCookie(r)
+	if user == "" {
+		httpErrorf(w, "User doesn't exist")
+		return
+	}
+	ns := strings.TrimSpace(r.FormValue("ns"))
+	st := app.NamespaceStore(ns)
+	if st == nil {
+		httpErrorf(w, "Namespace %q doesn't exist", ns)
+		return
+	}
+	var edits []BatchEdit
+	if err := json.NewDecoder(r.Body).Decode(&edits); err != nil {
+		if isRequestTooLarge(err) {
+			httpRequestTooLarge(w)
+			return
+		}
+		httpErrorf(w, "Invalid json: %s", err)
+		return
+	}
+	for i := range edits {
+		edits[i].Source = strings.TrimSpace(edits[i].Source)
+		edits[i].Lang = strings.TrimSpace(edits[i].Lang)
+	}
+	results, applied := applyBatchEdits(st, edits, user)
+	v := struct {
+		Applied bool              `json:"applied"`
+		Results []BatchEditResult `json:"results"`
+	}{applied, results}
+	status := http.StatusOK
+	if !applied {
+		status = http.StatusBadRequest
+	} else {
+		logger.ForRequest(r).Noticef("%s applied %d edits to %s", user, len(edits), app.Name)
+	}
+	serveJSONWithStatus(w, status, v)
+}
@@ -0,0 +1,53 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestApplyBatchEdits(t *testing.T) {
+	app := newTestApp(t, "batch", []string{"Open", "Close"})
+	defer closeTestApp(app)
+
+	edits := []BatchEdit{
+		{"Open", "pl", "Otwórz"},
+		{"Missing", "pl", "Brak"},
+		{"Close", "de", "Schließen"},
+	}
+	res, applied := applyBatchEdits(app.store, edits, "user1")
+	if applied || res[0].Error != "" || res[1].Error == "" || res[2].Error != "" {
+		t.Fatalf("unexpected results %#v", res)
+	}
+	if n := app.store.EditsCount(); n != 0 {
+		t.Fatalf("invalid batch applied %d edits", n)
+	}
+
+	edits = append(edits[:1], edits[2:]...)
+	res, applied = applyBatchEdits(app.store, edits, "user1")
+	if !applied || !res[0].Ok || !res[1].Ok {
+		t.Fatalf("unexpected results %#v", res)
+	}
+	edits2 := app.store.EditsByUser("user1")
+	if len(edits2) != 2 {
+		t.Fatalf("got %d edits by user1, expected 2", len(edits2))
+	}
+
+	// lang codes are normalized like in other edits
+	edits = []BatchEdit{{"Open", "PL", "Otwórz plik"}, {"Close", "de_DE", "Schließen"}}
+	res, applied = applyBatchEdits(app.store, edits, "user1")
+	if !applied || !res[0].Ok || !res[1].Ok || edits[0].Lang != "pl" || edits[1].Lang != "de" {
+		t.Fatalf("unexpected results %#v of %#v", res, edits)
+	}
+
+	// disabled languages can't be edited
+	defer store.SetDisabledLangs(nil)
+	if err := store.SetDisabledLangs([]string{"pl"}); err != nil {
+		t.Fatalf("SetDisabledLangs() failed with %s", err)
+	}
+	res, applied = applyBatchEdits(app.store, []BatchEdit{{"Open", "pl", "Otwórz"}}, "user1")
+	if applied || res[0].Error == "" {
+		t.Fatalf("unexpected results %#v", res)
+	}
+}
@@ -61,11 +61,20 @@ func translationsForApp(app *App) []byte {
 		n := len(ltarr)
 		// TODO: to be more efficient, allocate translations array outside of loop
 		translations := make([]string, n, n)
+		priorities := make(map[string]int, n)
 		for _, lt := range ltarr {
 			n--
 			translations[n] = fmt.Sprintf("%s:%s\n", lt.lang, escapeTrans(lt.trans))
+			priorities[translations[n]] = app.langPriority(lt.lang)
 		}
-		sort.Strings(translations)
+		// languages from app.LanguageOrder go first
+		sort.Slice(translations, func(i, j int) bool {
+			p1, p2 := priorities[translations[i]], priorities[translations[j]]
+			if p1 != p2 {
+				return p1 < p2
+			}
+			return translations[i] < translations[j]
+		})
 		for _, trans := range translations {
 			io.WriteString(&w, trans)
 		}
@@ -102,14 +111,14 @@ func handleDownloadTranslations(w http.ResponseWriter, r *http.Request) {
 	sha1 := sha1HexOfBytes(b)
 	sha2 := sha1HexOfBytes(b)
 	if sha1 != sha2 {
-		logger.Errorf("sha1 != sha2 (%s != %s)", sha1, sha2)
+		logger.ForRequest(r).Errorf("sha1 != sha2 (%s != %s)", sha1, sha2)
 	}
 	if sha1 == sha1In {
 		io.WriteString(w, "No change\n")
-		logger.Noticef("Translations download for %s with sha1 %s, didn't change", appName, sha1In)
+		logger.ForRequest(r).Noticef("Translations download for %s with sha1 %s, didn't change", appName, sha1In)
 		return
 	}
 	io.WriteString(w, fmt.Sprintf("%s\n", sha1))
 	w.Write(b)
-	logger.Noticef("Translations download for %s with sha1 %s, our sha1 %s", appName, sha1In, sha1)
+	logger.ForRequest(r).Noticef("Translations download for %s with sha1 %s, our sha1 %s", appName, sha1In, sha1)
 }
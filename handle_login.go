@@ -2,7 +2,12 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -10,24 +15,62 @@ import (
 	"strings"
 
 	"github.com/garyburd/go-oauth/oauth"
+	"github.com/gorilla/securecookie"
 )
 
 type SecureCookieValue struct {
 	User        string
 	TwitterTemp string
+	// random value round-tripped through twitter oauth callback url, to
+	// prevent login CSRF
+	OAuthState string
 }
 
-func setSecureCookie(w http.ResponseWriter, cookieVal *SecureCookieValue) {
+// cookieSameSite returns SameSite attribute of cookies, from config.CookieSameSite
+func cookieSameSite() http.SameSite {
+	switch strings.ToLower(config.CookieSameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+func isValidCookieSameSite(s string) bool {
+	switch strings.ToLower(s) {
+	case "", "lax", "strict", "none":
+		return true
+	}
+	return false
+}
+
+// setCookieAttributes hardens the cookie. In production we're served over
+// https so the cookie can be marked as Secure. Elsewhere it's Secure if the
+// request came over https, possibly via a trusted proxy
+func setCookieAttributes(r *http.Request, cookie *http.Cookie) {
+	cookie.HttpOnly = true
+	cookie.Secure = *inProduction || isHTTPS(r)
+	cookie.SameSite = cookieSameSite()
+	// browsers reject SameSite=None cookies that are not Secure
+	if cookie.SameSite == http.SameSiteNoneMode && !cookie.Secure {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+}
+
+func setSecureCookie(w http.ResponseWriter, r *http.Request, cookieVal *SecureCookieValue) {
 	val := make(map[string]string)
 	val["user"] = cookieVal.User
 	val["twittertemp"] = cookieVal.TwitterTemp
-	if encoded, err := secureCookie.Encode(cookieName, val); err == nil {
+	val["oauthstate"] = cookieVal.OAuthState
+	if encoded, err := securecookie.EncodeMulti(cookieName, val, secureCookies...); err == nil {
 		// TODO: set expiration (Expires    time.Time) long time in the future?
 		cookie := &http.Cookie{
 			Name:  cookieName,
 			Value: encoded,
 			Path:  "/",
 		}
+		setCookieAttributes(r, cookie)
 		http.SetCookie(w, cookie)
 	} else {
 		fmt.Printf("setSecureCookie(): error encoding secure cookie %s\n", err)
@@ -38,13 +81,14 @@ const WeekInSeconds = 60 * 60 * 24 * 7
 
 // to delete the cookie value (e.g. for logging out), we need to set an
 // invalid value
-func deleteSecureCookie(w http.ResponseWriter) {
+func deleteSecureCookie(w http.ResponseWriter, r *http.Request) {
 	cookie := &http.Cookie{
 		Name:   cookieName,
 		Value:  "deleted",
 		MaxAge: WeekInSeconds,
 		Path:   "/",
 	}
+	setCookieAttributes(r, cookie)
 	http.SetCookie(w, cookie)
 }
 
@@ -56,7 +100,7 @@ func getSecureCookie(r *http.Request) *SecureCookieValue {
 			return nil
 		}
 		val := make(map[string]string)
-		if err = secureCookie.Decode(cookieName, cookie.Value, &val); err != nil {
+		if err = securecookie.DecodeMulti(cookieName, cookie.Value, &val, secureCookies...); err != nil {
 			// most likely expired cookie, so ignore. Ideally should delete the
 			// cookie, but that requires access to http.ResponseWriter, so not
 			// convenient for us
@@ -74,14 +118,19 @@ func getSecureCookie(r *http.Request) *SecureCookieValue {
 			fmt.Printf("Error decoding cookie, no 'twittertemp' field\n")
 			return nil
 		}
+		// cookies set before we started using oauth state don't have it
+		ret.OAuthState = val["oauthstate"]
 	}
 	return ret
 }
 
 func decodeUserFromCookie(r *http.Request) string {
+	if user := devAdminUser(); user != "" {
+		return user
+	}
 	cookie := getSecureCookie(r)
-	if nil == cookie {
-		return ""
+	if nil == cookie || cookie.User == "" {
+		return basicAuthAdmin(r)
 	}
 	return cookie.User
 }
@@ -95,12 +144,19 @@ func decodeTwitterTempFromCookie(r *http.Request) string {
 }
 
 // getTwitter gets a resource from the Twitter API and decodes the json response to data.
-func getTwitter(cred *oauth.Credentials, urlStr string, params url.Values, data interface{}) error {
+// The request is aborted when ctx is done or after outboundTimeout()
+func getTwitter(ctx context.Context, cred *oauth.Credentials, urlStr string, params url.Values, data interface{}) error {
 	if params == nil {
 		params = make(url.Values)
 	}
 	oauthClient.SignParam(cred, "GET", urlStr, params)
-	resp, err := http.Get(urlStr + "?" + params.Encode())
+	req, err := http.NewRequest("GET", urlStr+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := outboundContext(ctx)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -113,25 +169,58 @@ func getTwitter(cred *oauth.Credentials, urlStr string, params url.Values, data
 	return json.Unmarshal(bodyData, data)
 }
 
-// url: GET /oauthtwittercb?redirect=$redirect
+func genOAuthState() (string, error) {
+	var d [16]byte
+	if _, err := rand.Read(d[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(d[:]), nil
+}
+
+// checkOAuthState verifies that state in oauth callback url matches the
+// state we've set in the cookie in /login
+func checkOAuthState(r *http.Request) (*SecureCookieValue, error) {
+	cookie := getSecureCookie(r)
+	if cookie == nil || cookie.OAuthState == "" {
+		return nil, errors.New("no oauth state in cookie")
+	}
+	state := r.FormValue("state")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(cookie.OAuthState)) != 1 {
+		return nil, errors.New("oauth state doesn't match")
+	}
+	return cookie, nil
+}
+
+// url: GET /oauthtwittercb?redirect=$redirect&state=$state
 func handleOauthTwitterCallback(w http.ResponseWriter, r *http.Request) {
 	//fmt.Printf("handleOauthTwitterCallback()\n")
+	if !checkLoginAllowed(w, r) {
+		return
+	}
 	redirect := strings.TrimSpace(r.FormValue("redirect"))
 	if redirect == "" {
 		httpErrorf(w, "Missing redirect value for /login")
 		return
 	}
+	cookie, err := checkOAuthState(r)
+	if err != nil {
+		logger.ForRequest(r).Noticef("handleOauthTwitterCallback(): %s", err)
+		loginFailed(r)
+		httpErrorf(w, "Invalid login request, please try again")
+		return
+	}
 	tempCred := oauth.Credentials{
 		Token: r.FormValue("oauth_token"),
 	}
-	tempCred.Secret = decodeTwitterTempFromCookie(r)
+	tempCred.Secret = cookie.TwitterTemp
 	if "" == tempCred.Secret {
 		http.Error(w, "Error getting temp token secret from cookie, ", 500)
 		return
 	}
 	//fmt.Printf("  tempCred.Secret: %s\n", tempCred.Secret)
-	tokenCred, _, err := oauthClient.RequestToken(http.DefaultClient, &tempCred, r.FormValue("oauth_verifier"))
+	tokenCred, _, err := oauthClient.RequestToken(outboundClient(), &tempCred, r.FormValue("oauth_verifier"))
 	if err != nil {
+		loginFailed(r)
 		http.Error(w, "Error getting request token, "+err.Error(), 500)
 		return
 	}
@@ -140,42 +229,55 @@ func handleOauthTwitterCallback(w http.ResponseWriter, r *http.Request) {
 
 	var info map[string]interface{}
 	if err := getTwitter(
+		r.Context(),
 		tokenCred,
 		"https://api.twitter.com/1.1/account/verify_credentials.json",
 		nil,
 		&info); err != nil {
+		loginFailed(r)
 		http.Error(w, "Error getting timeline, "+err.Error(), 500)
 		return
 	}
 	if user, ok := info["screen_name"].(string); ok {
 		//fmt.Printf("  username: %s\n", user)
-		cookie := getSecureCookie(r)
 		cookie.User = user
-		setSecureCookie(w, cookie)
+		loginLimit.Reset(clientIP(r))
+		// state can only be used once
+		cookie.OAuthState = ""
+		setSecureCookie(w, r, cookie)
 	}
 	http.Redirect(w, r, redirect, 302)
 }
 
 // url: GET /login?redirect=$redirect
 func handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !checkLoginAllowed(w, r) {
+		return
+	}
 	redirect := strings.TrimSpace(r.FormValue("redirect"))
 	if redirect == "" {
 		httpErrorf(w, "Missing redirect value for /login")
 		return
 	}
+	state, err := genOAuthState()
+	if err != nil {
+		http.Error(w, "Error generating oauth state, "+err.Error(), 500)
+		return
+	}
 	q := url.Values{
 		"redirect": {redirect},
+		"state":    {state},
 	}.Encode()
 
-	cb := "http://" + r.Host + "/oauthtwittercb" + "?" + q
+	cb := requestScheme(r) + "://" + r.Host + withBasePath("/oauthtwittercb") + "?" + q
 	//fmt.Printf("handleLogin: cb=%s\n", cb)
-	tempCred, err := oauthClient.RequestTemporaryCredentials(http.DefaultClient, cb, nil)
+	tempCred, err := oauthClient.RequestTemporaryCredentials(outboundClient(), cb, nil)
 	if err != nil {
 		http.Error(w, "Error getting temp cred, "+err.Error(), 500)
 		return
 	}
-	cookie := &SecureCookieValue{TwitterTemp: tempCred.Secret}
-	setSecureCookie(w, cookie)
+	cookie := &SecureCookieValue{TwitterTemp: tempCred.Secret, OAuthState: state}
+	setSecureCookie(w, r, cookie)
 	http.Redirect(w, r, oauthClient.AuthorizationURL(tempCred, nil), 302)
 }
 
@@ -186,6 +288,6 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 		httpErrorf(w, "Missing redirect value for /logout")
 		return
 	}
-	deleteSecureCookie(w)
+	deleteSecureCookie(w, r)
 	http.Redirect(w, r, redirect, 302)
 }
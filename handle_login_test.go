@@ -0,0 +1,137 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newRequestWithCookie returns a request with a secure cookie set to val
+func newRequestWithCookie(method, url string, val *SecureCookieValue) *http.Request {
+	rr := httptest.NewRecorder()
+	setSecureCookie(rr, httptest.NewRequest(method, url, nil), val)
+	r := httptest.NewRequest(method, url, nil)
+	for _, c := range rr.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestCheckOAuthState(t *testing.T) {
+	state, err := genOAuthState()
+	if err != nil {
+		t.Fatalf("genOAuthState() failed with %s", err)
+	}
+	val := &SecureCookieValue{TwitterTemp: "temp", OAuthState: state}
+
+	r := newRequestWithCookie("GET", "/oauthtwittercb?redirect=/&state="+state, val)
+	cookie, err := checkOAuthState(r)
+	if err != nil || cookie.TwitterTemp != "temp" {
+		t.Fatalf("checkOAuthState() failed for matching state, %v", err)
+	}
+
+	urls := []string{
+		"/oauthtwittercb?redirect=/&state=bad",
+		"/oauthtwittercb?redirect=/",
+	}
+	for _, url := range urls {
+		r = newRequestWithCookie("GET", url, val)
+		if _, err = checkOAuthState(r); err == nil {
+			t.Fatalf("%s: checkOAuthState() should fail for mismatched state", url)
+		}
+		rr := httptest.NewRecorder()
+		handleOauthTwitterCallback(rr, r)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: got status %d, expected %d", url, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	// cookie without a state, e.g. when callback wasn't initiated by /login
+	r = newRequestWithCookie("GET", "/oauthtwittercb?redirect=/&state=", &SecureCookieValue{TwitterTemp: "temp"})
+	if _, err = checkOAuthState(r); err == nil {
+		t.Fatalf("checkOAuthState() should fail without state in cookie")
+	}
+}
+
+func TestCookieAttributes(t *testing.T) {
+	defer func(prod bool, sameSite string) {
+		*inProduction = prod
+		config.CookieSameSite = sameSite
+	}(*inProduction, config.CookieSameSite)
+
+	tests := []struct {
+		production bool
+		sameSite   string
+		expSecure  bool
+		exp        http.SameSite
+	}{
+		{false, "", false, http.SameSiteLaxMode},
+		{true, "", true, http.SameSiteLaxMode},
+		{true, "strict", true, http.SameSiteStrictMode},
+		{true, "none", true, http.SameSiteNoneMode},
+		// SameSite=None requires Secure
+		{false, "none", false, http.SameSiteLaxMode},
+	}
+	for _, test := range tests {
+		*inProduction = test.production
+		config.CookieSameSite = test.sameSite
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		setSecureCookie(rr, r, &SecureCookieValue{User: "user1"})
+		deleteSecureCookie(rr, r)
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 2 {
+			t.Fatalf("got %d cookies, expected 2", len(cookies))
+		}
+		for _, c := range cookies {
+			if !c.HttpOnly || c.Secure != test.expSecure || c.SameSite != test.exp {
+				t.Fatalf("production: %v, SameSite: %q, unexpected cookie %q", test.production, test.sameSite, c.String())
+			}
+		}
+	}
+}
+
+func TestDevAdminUser(t *testing.T) {
+	app := newTestApp(t, "devadmin", nil)
+	defer closeTestApp(app)
+	defer func(prod bool) {
+		*inProduction = prod
+		config.DevAdminUser = ""
+	}(*inProduction)
+	config.DevAdminUser = "dev"
+
+	*inProduction = false
+	r := httptest.NewRequest("GET", "/", nil)
+	if user := decodeUserFromCookie(r); user != "dev" || !userIsAdmin(app, user) {
+		t.Fatalf("got user %q, expected admin %q", user, "dev")
+	}
+
+	*inProduction = true
+	if user := decodeUserFromCookie(r); user != "" {
+		t.Fatalf("got user %q in production, expected none", user)
+	}
+	if userIsAdmin(app, "dev") {
+		t.Fatalf("DevAdminUser is an admin in production")
+	}
+	r = newRequestWithCookie("GET", "/", &SecureCookieValue{User: "user1"})
+	if user := decodeUserFromCookie(r); user != "user1" {
+		t.Fatalf("got user %q, expected %q", user, "user1")
+	}
+}
+
+func TestServerAdminTwitterUsers(t *testing.T) {
+	app := newTestApp(t, "serveradmin", nil)
+	defer closeTestApp(app)
+	defer func() { config.ServerAdminTwitterUsers = nil }()
+	config.ServerAdminTwitterUsers = []string{"ops"}
+
+	if !userIsServerAdmin("ops") || !userIsAdmin(app, "ops") {
+		t.Fatalf("ops is not a server admin")
+	}
+	for _, user := range []string{"", "admin", "user1"} {
+		if userIsServerAdmin(user) {
+			t.Fatalf("%q is a server admin", user)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RenameSource changes text of a source string (e.g. to fix a typo) while
+// keeping its translations into all languages. newKey must not exist.
+// If config.FuzzyOnSourceChange is set, translations are marked as fuzzy
+// because they might no longer match the source
+func (a *App) RenameSource(oldKey, newKey string) error {
+	if newKey == "" {
+		return fmt.Errorf("new string is empty")
+	}
+	if !a.store.IsActiveString(oldKey) {
+		return fmt.Errorf("string %q doesn't exist", oldKey)
+	}
+	// list of strings changed, so the next upload must be applied even if
+	// it's the same as the last one
+	a.SetStringsHash(defaultNamespace, "")
+	if err := a.store.RenameString(oldKey, newKey); err != nil {
+		return err
+	}
+	if !config.FuzzyOnSourceChange {
+		return nil
+	}
+	return a.markTranslationsFuzzy(newKey)
+}
+
+// markTranslationsFuzzy marks all translations of str as needing a review
+func (a *App) markTranslationsFuzzy(str string) error {
+	for lang, translations := range currentTranslations(a) {
+		if translations[str] == "" {
+			continue
+		}
+		if err := a.store.SetFuzzy(str, lang, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// url: POST /admin/rename?app=${app}&old=${old}&new=${new}
+func handleRenameSource(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	app := getAppArg(w, r)
+	if app == nil {
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't change strings")
+		return
+	}
+	oldKey := r.FormValue("old")
+	newKey := strings.TrimSpace(r.FormValue("new"))
+	if err := app.RenameSource(oldKey, newKey); err != nil {
+		httpErrorf(w, "Failed to rename string: %s", err)
+		return
+	}
+	logger.ForRequest(r).Noticef("renamed string %q to %q in %s", oldKey, newKey, app.Name)
+	serveJSON(w, map[string]string{"old": oldKey, "new": newKey})
+}
@@ -0,0 +1,73 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRenameSource(t *testing.T) {
+	app := newTestApp(t, "rename", []string{"Opne", "Close"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Opne", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Opne", "Öffnen", "de", "user1")
+
+	rename := func(user, oldKey, newKey string) int {
+		u := "/admin/rename?app=rename&old=" + url.QueryEscape(oldKey) + "&new=" + url.QueryEscape(newKey)
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, newRequestWithCookie("POST", u, &SecureCookieValue{User: user}))
+		return rr.Code
+	}
+	if code := rename("user1", "Opne", "Open"); code != 400 {
+		t.Fatalf("non-admin: got status %d, expected 400", code)
+	}
+	if code := rename("admin", "Opne", "Open"); code != 200 {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	if app.store.IsActiveString("Opne") {
+		t.Fatalf("old string still exists")
+	}
+	for lang, exp := range map[string]string{"pl": "Otwórz", "de": "Öffnen"} {
+		if got, _ := app.TranslationWithFallback("Open", lang); got != exp {
+			t.Fatalf("%s: got %q, expected %q", lang, got, exp)
+		}
+	}
+
+	if err := app.RenameSource("Open", "Close"); err == nil {
+		t.Fatalf("renaming to existing string should fail")
+	}
+	if err := app.RenameSource("Opne", "Open again"); err == nil {
+		t.Fatalf("renaming non-existing string should fail")
+	}
+	if code := rename("admin", "Close", ""); code != 400 {
+		t.Fatalf("empty new string: got status %d, expected 400", code)
+	}
+}
+
+func TestRenameSourceFuzzy(t *testing.T) {
+	app := newTestApp(t, "renamefuzzy", []string{"Opne", "Close"})
+	defer closeTestApp(app)
+	defer func() { config.FuzzyOnSourceChange = false }()
+	writeTestTranslation(t, app, "Opne", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+
+	if err := app.RenameSource("Opne", "Open"); err != nil {
+		t.Fatalf("RenameSource() failed with %s", err)
+	}
+	if app.store.IsFuzzy("Open", "pl") {
+		t.Fatalf("translation is fuzzy without FuzzyOnSourceChange")
+	}
+
+	config.FuzzyOnSourceChange = true
+	if err := app.RenameSource("Open", "Open file"); err != nil {
+		t.Fatalf("RenameSource() failed with %s", err)
+	}
+	if !app.store.IsFuzzy("Open file", "pl") {
+		t.Fatalf("translation of changed string is not fuzzy")
+	}
+	// untranslated and other strings are not affected
+	if app.store.IsFuzzy("Open file", "de") || app.store.IsFuzzy("Close", "pl") {
+		t.Fatalf("unexpected fuzzy translations")
+	}
+}
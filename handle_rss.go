@@ -7,7 +7,6 @@ import (
 	"html/template"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/kjk/apptranslator/store"
 	atom "github.com/thomas11/atomgenerator"
@@ -58,7 +57,7 @@ func templateToString(t *template.Template, data interface{}) string {
 
 func getRssAll(app *App) string {
 	edits := app.store.RecentEdits(10)
-	pubTime := time.Now()
+	pubTime := clock.Now()
 	if len(edits) > 0 {
 		pubTime = edits[0].Time
 	}
@@ -89,7 +88,7 @@ func getRssAll(app *App) string {
 }
 
 func getRssForLang(app *App, lang string) string {
-	pubTime := time.Now()
+	pubTime := clock.Now()
 	edits := app.store.EditsForLang(lang, 10)
 	if len(edits) > 0 {
 		pubTime = edits[0].Time
@@ -131,7 +130,7 @@ func handleRss(w http.ResponseWriter, r *http.Request) {
 		httpErrorf(w, "Application %q doesn't exist", appName)
 		return
 	}
-	lang := strings.TrimSpace(r.FormValue("lang"))
+	lang := store.NormalizeLangCode(r.FormValue("lang"))
 	if 0 == len(lang) {
 		s := getRssAll(app)
 		w.Write([]byte(s))
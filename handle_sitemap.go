@@ -0,0 +1,46 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// buildSitemap returns urls of pages of active apps that have a public
+// website. baseURL is the scheme and host of this server
+func buildSitemap(baseURL string) *sitemapURLSet {
+	res := &sitemapURLSet{}
+	for _, app := range activeApps() {
+		if app.Url == "" {
+			continue
+		}
+		loc := baseURL + withBasePath("/app/"+url.PathEscape(app.Name))
+		res.URLs = append(res.URLs, sitemapURL{Loc: loc})
+	}
+	return res
+}
+
+// url: /sitemap.xml
+func handleSitemap(w http.ResponseWriter, r *http.Request) {
+	sitemap := buildSitemap(requestScheme(r) + "://" + r.Host)
+	d, err := xml.MarshalIndent(sitemap, "", "  ")
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleSitemap(): xml.MarshalIndent() failed with %s", err)
+		http.Error(w, "Failed to build sitemap", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	w.Write(d)
+}
@@ -0,0 +1,39 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSitemap(t *testing.T) {
+	apps := []*App{
+		newTestApp(t, "public", nil),
+		newTestApp(t, "archived", nil),
+		newTestApp(t, "nourl", nil),
+	}
+	for _, app := range apps {
+		defer closeTestApp(app)
+	}
+	apps[0].Url = "https://example.com"
+	apps[1].Url = "https://example.org"
+	apps[1].Archived = true
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://translate.example.com/sitemap.xml", nil))
+	body := rr.Body.String()
+	if rr.Code != 200 || !strings.Contains(body, "<loc>http://translate.example.com/app/public</loc>") {
+		t.Fatalf("sitemap doesn't have the public app:\n%s", body)
+	}
+	if strings.Contains(body, "/app/archived") || strings.Contains(body, "/app/nourl") {
+		t.Fatalf("sitemap has archived app or app without url:\n%s", body)
+	}
+
+	// archived apps are not on the main page
+	rr = httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if body = rr.Body.String(); !strings.Contains(body, `href="/app/public"`) || strings.Contains(body, `href="/app/archived"`) {
+		t.Fatalf("unexpected main page:\n%s", body)
+	}
+}
@@ -0,0 +1,78 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// format of ?since= argument
+const sinceFormat = "2006-01-02"
+
+type ModelStats struct {
+	App         *App
+	PageTitle   string
+	Since       string
+	Translators []*store.Translator
+	User        string
+	RedirectUrl string
+}
+
+// EditsByUser returns number of edits made by each user
+func (a *App) EditsByUser() map[string]int {
+	return a.EditsByUserSince(time.Time{})
+}
+
+// EditsByUserSince returns number of edits made by each user since a given time
+func (a *App) EditsByUserSince(since time.Time) map[string]int {
+	return a.store.EditsCountByUser(since)
+}
+
+// leaderboard returns translators sorted by number of edits, most active first
+func leaderboard(editsByUser map[string]int) []*store.Translator {
+	res := make([]*store.Translator, 0, len(editsByUser))
+	for user, n := range editsByUser {
+		res = append(res, &store.Translator{Name: user, TranslationsCount: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].TranslationsCount != res[j].TranslationsCount {
+			return res[i].TranslationsCount > res[j].TranslationsCount
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// url: /stats/{appname}[?since=${yyyy-mm-dd}]
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	var since time.Time
+	sinceStr := strings.TrimSpace(r.FormValue("since"))
+	if sinceStr != "" {
+		var err error
+		if since, err = time.Parse(sinceFormat, sinceStr); err != nil {
+			httpErrorf(w, "Invalid since %q, should be in yyyy-mm-dd format", sinceStr)
+			return
+		}
+	}
+	model := &ModelStats{
+		App:         app,
+		PageTitle:   fmt.Sprintf("Translators of %s", app.Name),
+		Since:       sinceStr,
+		Translators: leaderboard(app.EditsByUserSince(since)),
+		User:        decodeUserFromCookie(r),
+		RedirectUrl: r.URL.String(),
+	}
+	ExecTemplate(w, tmplStats, model)
+}
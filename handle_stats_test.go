@@ -0,0 +1,29 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEditsByUser(t *testing.T) {
+	app := newTestApp(t, "stats", []string{"Open", "Close"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, app, "Open", "Öffnen", "de", "user2")
+	writeTestTranslation(t, app, "Open", "Ouvrir", "fr", "user1")
+
+	exp := map[string]int{"user1": 3, "user2": 1}
+	if got := app.EditsByUser(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	board := leaderboard(app.EditsByUser())
+	if len(board) != 2 || board[0].Name != "user1" || board[1].Name != "user2" {
+		t.Fatalf("unexpected leaderboard %v", board)
+	}
+	if got := app.EditsByUserSince(time.Now().Add(time.Hour)); len(got) != 0 {
+		t.Fatalf("got %v, expected no edits", got)
+	}
+}
@@ -0,0 +1,137 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+const (
+	defaultTodoLimit = 100
+	maxTodoLimit     = 1000
+)
+
+// TodoPage is a page of strings that still need to be translated into
+// a given language
+type TodoPage struct {
+	App    string   `json:"app"`
+	Lang   string   `json:"lang"`
+	Total  int      `json:"total"`
+	Offset int      `json:"offset"`
+	Limit  int      `json:"limit"`
+	Todo   []string `json:"strings"`
+}
+
+// Untranslated returns strings that still need a translation into lang,
+// sorted by string
+func (a *App) Untranslated(lang string) []string {
+	res := make([]string, 0)
+	for _, li := range a.store.LangInfos() {
+		if li.Code != lang {
+			continue
+		}
+		for _, tr := range li.UntranslatedStrings() {
+			res = append(res, tr.String)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func buildTodoPage(app *App, lang string, offset, limit int) *TodoPage {
+	todo := app.Untranslated(lang)
+	page := &TodoPage{
+		App:    app.Name,
+		Lang:   lang,
+		Total:  len(todo),
+		Offset: offset,
+		Limit:  limit,
+		Todo:   []string{},
+	}
+	if offset < len(todo) {
+		// offset + limit could overflow
+		end := len(todo)
+		if limit < len(todo)-offset {
+			end = offset + limit
+		}
+		page.Todo = todo[offset:end]
+	}
+	return page
+}
+
+func wantsJSON(r *http.Request) bool {
+	if r.FormValue("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+type ModelTodo struct {
+	*TodoPage
+	AppInfo     *App
+	PageTitle   string
+	LangName    string
+	PrevOffset  int
+	NextOffset  int
+	HasPrev     bool
+	HasNext     bool
+	User        string
+	RedirectUrl string
+}
+
+// url: /todo/{appname}/{lang}[?offset=${offset}&limit=${limit}&format=json]
+// Returns strings that are not yet translated into lang, as html or json
+// (if format=json or Accept header asks for json)
+func handleTodo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appName := vars["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	lang := store.NormalizeLangCode(vars["lang"])
+	if !store.IsValidLangCode(lang) {
+		httpErrorf(w, "Invalid language: %q", lang)
+		return
+	}
+	offset, err := formIntArg(r, "offset", 0)
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	limit, err := formIntArg(r, "limit", defaultTodoLimit)
+	if err != nil || limit == 0 {
+		httpErrorf(w, "Invalid limit %q", r.FormValue("limit"))
+		return
+	}
+	if limit > maxTodoLimit {
+		limit = maxTodoLimit
+	}
+	page := buildTodoPage(app, lang, offset, limit)
+	if wantsJSON(r) {
+		serveJSON(w, page)
+		return
+	}
+	model := &ModelTodo{
+		TodoPage:    page,
+		AppInfo:     app,
+		PageTitle:   fmt.Sprintf("Untranslated strings of %s", app.Name),
+		LangName:    store.LangNameByCode(lang),
+		PrevOffset:  offset - limit,
+		NextOffset:  offset + limit,
+		HasPrev:     offset > 0,
+		HasNext:     offset < page.Total && limit < page.Total-offset,
+		User:        decodeUserFromCookie(r),
+		RedirectUrl: r.URL.String(),
+	}
+	if model.PrevOffset < 0 {
+		model.PrevOffset = 0
+	}
+	ExecTemplate(w, tmplTodo, model)
+}
@@ -0,0 +1,63 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTodo(t *testing.T) {
+	app := newTestApp(t, "todo", []string{"Open", "Close", "Save", "About", "Exit"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Save", "Zapisz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Schließen", "de", "user1")
+
+	exp := []string{"About", "Close", "Exit"}
+	if got := app.Untranslated("pl"); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+
+	tests := []struct {
+		url string
+		exp TodoPage
+	}{
+		{"/todo/todo/pl?format=json", TodoPage{"todo", "pl", 3, 0, defaultTodoLimit, []string{"About", "Close", "Exit"}}},
+		{"/todo/todo/pl?format=json&offset=1&limit=1", TodoPage{"todo", "pl", 3, 1, 1, []string{"Close"}}},
+		{"/todo/todo/pl?format=json&offset=2&limit=5", TodoPage{"todo", "pl", 3, 2, 5, []string{"Exit"}}},
+		{"/todo/todo/pl?format=json&offset=10", TodoPage{"todo", "pl", 3, 10, defaultTodoLimit, []string{}}},
+		{"/todo/todo/pl?format=json&offset=1&limit=9223372036854775807", TodoPage{"todo", "pl", 3, 1, maxTodoLimit, []string{"Close", "Exit"}}},
+		{"/todo/todo/de?format=json", TodoPage{"todo", "de", 4, 0, defaultTodoLimit, []string{"About", "Exit", "Open", "Save"}}},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", test.url, nil))
+		if rr.Code != 200 {
+			t.Fatalf("%s: got status %d", test.url, rr.Code)
+		}
+		var got TodoPage
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: json.Unmarshal() failed with %s", test.url, err)
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("%s: got %#v, expected %#v", test.url, got, test.exp)
+		}
+	}
+
+	// html page with links to next pages doesn't overflow either
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/todo/todo/pl?offset=9223372036854775807", nil))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d", rr.Code)
+	}
+
+	for _, url := range []string{"/todo/todo/pl?offset=-1", "/todo/todo/pl?limit=0", "/todo/todo/xx"} {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", url, rr.Code)
+		}
+	}
+}
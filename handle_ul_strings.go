@@ -2,10 +2,15 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+
+	"github.com/kjk/apptranslator/store"
 )
 
 type CantParseError struct {
@@ -18,12 +23,21 @@ func (e *CantParseError) Error() string {
 }
 
 func normalizeNewlines(s string) string {
-	s = strings.Replace(s, "\n\r", "\n", -1)
+	s = strings.Replace(s, "\r\n", "\n", -1)
 	return strings.Replace(s, "\r", "\n", -1)
 }
 
+// byte order mark, added at the beginning of utf-8 files by some editors
+const utf8BOM = "\ufeff"
+
+// normalizeUploadedText removes byte order mark and normalizes line endings
+// of uploaded files, which are often edited on Windows
+func normalizeUploadedText(s string) string {
+	return normalizeNewlines(strings.TrimPrefix(s, utf8BOM))
+}
+
 func parseUploadedStrings(s string) ([]string, error) {
-	s = normalizeNewlines(s)
+	s = normalizeUploadedText(s)
 	lines := strings.Split(s, "\n")
 	if len(lines) < 2 {
 		return nil, errors.New("not enough lines")
@@ -40,7 +54,211 @@ func parseUploadedStrings(s string) ([]string, error) {
 	return lines, nil
 }
 
-// url: POST /uploadstrings?app=$appName&secret=$uploadSecret
+// parseUploadedTxtStrings parses strings uploaded with format=txt, where
+// each non-empty line is a string to translate. Repeated lines are
+// uploaded once
+func parseUploadedTxtStrings(s string) ([]string, error) {
+	var res []string
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(normalizeUploadedText(s), "\n") {
+		if strings.TrimSpace(l) == "" || seen[l] {
+			continue
+		}
+		seen[l] = true
+		res = append(res, l)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no strings")
+	}
+	return res, nil
+}
+
+// addStrings returns strs followed by those of newStrings that are not
+// in strs
+func addStrings(strs, newStrings []string) []string {
+	res := append([]string(nil), strs...)
+	seen := make(map[string]bool)
+	for _, s := range strs {
+		seen[s] = true
+	}
+	for _, s := range newStrings {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// uploadedContextString is a string with a context, uploaded with
+// format=json, see store.ContextKey
+type uploadedContextString struct {
+	Source  string `json:"source"`
+	Context string `json:"context"`
+}
+
+// parseUploadedJSONStrings parses strings uploaded with format=json, which
+// is a json array of strings to translate. Strings with a context are
+// objects: {"source": "Open", "context": "file state"}
+func parseUploadedJSONStrings(s string) ([]string, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(s, utf8BOM)), &elems); err != nil {
+		return nil, err
+	}
+	var strs []string
+	seen := make(map[string]bool)
+	for _, elem := range elems {
+		var str string
+		if err := json.Unmarshal(elem, &str); err != nil {
+			var cs uploadedContextString
+			if err = json.Unmarshal(elem, &cs); err != nil {
+				return nil, fmt.Errorf("%s is not a string or an object with source and context", elem)
+			}
+			if strings.TrimSpace(cs.Source) == "" {
+				return nil, errors.New("found empty string")
+			}
+			str = store.ContextKey(cs.Source, strings.TrimSpace(cs.Context))
+		}
+		if strings.TrimSpace(str) == "" {
+			return nil, errors.New("found empty string")
+		}
+		if seen[str] {
+			return nil, fmt.Errorf("duplicate string %q", str)
+		}
+		seen[str] = true
+		strs = append(strs, str)
+	}
+	if len(strs) == 0 {
+		return nil, errors.New("no strings")
+	}
+	return strs, nil
+}
+
+func isValidStringsFormat(format string) bool {
+	switch format {
+	case "", "txt", "json":
+		return true
+	}
+	return false
+}
+
+// parseStringsUpload returns strings uploaded in s in a given format. With
+// txt format the uploaded strings are added to strings already in namespace
+// ns, unless remove is true, in which case strings that were not uploaded
+// are removed
+func parseStringsUpload(app *App, ns, s, format string, remove bool) ([]string, error) {
+	switch format {
+	case "":
+		return parseUploadedStrings(s)
+	case "json":
+		return parseUploadedJSONStrings(s)
+	case "txt":
+		newStrings, err := parseUploadedTxtStrings(s)
+		if err != nil || remove {
+			return newStrings, err
+		}
+		if st := app.NamespaceStore(ns); st != nil {
+			newStrings = addStrings(st.ActiveStrings(), newStrings)
+		}
+		return newStrings, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// parseUploadStringsRequest returns strings uploaded to /uploadstrings
+func parseUploadStringsRequest(r *http.Request, app *App, ns string) ([]string, error) {
+	format := strings.TrimSpace(r.FormValue("format"))
+	return parseStringsUpload(app, ns, r.FormValue("strings"), format, r.FormValue("remove") == "1")
+}
+
+// StringsDiff describes how an upload changed strings to translate
+type StringsDiff struct {
+	Added     []string
+	Deleted   []string
+	Undeleted []string
+}
+
+// String returns a description of the changes, one line per kind of change,
+// empty if nothing changed
+func (d *StringsDiff) String() string {
+	msg := ""
+	if len(d.Added) > 0 {
+		msg += fmt.Sprintf("New strings: %v\n", d.Added)
+	}
+	if len(d.Deleted) > 0 {
+		msg += fmt.Sprintf("Deleted strings: %v\n", d.Deleted)
+	}
+	if len(d.Undeleted) > 0 {
+		msg += fmt.Sprintf("Undeleted strings: %v\n", d.Undeleted)
+	}
+	return msg
+}
+
+// updateStrings makes newStrings the strings to translate in namespace ns,
+// creating it if necessary. Returns nil diff if they didn't change since
+// the last upload
+func updateStrings(app *App, ns string, newStrings []string) (*StringsDiff, error) {
+	// the same strings are usually uploaded on every build of the app,
+	// there's no need to write them again
+	hash := hashStrings(newStrings)
+	if hash == app.StringsHash(ns) {
+		return nil, nil
+	}
+	st, err := app.createNamespace(ns)
+	if err != nil {
+		return nil, err
+	}
+	added, deleted, undeleted, err := st.UpdateStringsList(newStrings)
+	if err != nil {
+		return nil, err
+	}
+	app.SetStringsHash(ns, hash)
+	return &StringsDiff{Added: added, Deleted: deleted, Undeleted: undeleted}, nil
+}
+
+// hashStrings returns a hash of a set of strings, independent of their order
+func hashStrings(strs []string) string {
+	sorted := append([]string(nil), strs...)
+	sort.Strings(sorted)
+	h := sha1.New()
+	for _, s := range sorted {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// StringsHash returns hash of the last uploaded list of strings in
+// namespace ns
+func (a *App) StringsHash(ns string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stringsHashes[ns]
+}
+
+// SetStringsHash remembers hash of the last uploaded list of strings in
+// namespace ns
+func (a *App) SetStringsHash(ns, hash string) {
+	a.mu.Lock()
+	a.stringsHashes[ns] = hash
+	a.mu.Unlock()
+}
+
+// setUploadedMeta sets context urls and groups uploaded with strings
+func setUploadedMeta(st *store.StoreCsv, contextURLs, groups map[string]string) error {
+	if err := setContextURLs(st, contextURLs); err != nil {
+		return err
+	}
+	return setGroups(st, groups)
+}
+
+// url: POST /uploadstrings?app=$appName&secret=$uploadSecret[&ns=$namespace][&format=txt[&remove=1]|&format=json]
+// Strings are uploaded to a given namespace, which is created if it
+// doesn't exist, or to the default namespace. Optional "contexturls" field
+// is a json object mapping strings to urls of screenshots or pages showing
+// where they're used. Optional "groups" field is a json object mapping
+// strings to names of groups of related strings
 // POST data is in the format:
 /*
 AppTranslator strings
@@ -48,45 +266,56 @@ string to translate 1
 string to translate 2
 ...
 */
+// With format=txt, POST data is just strings, one per line. Empty lines
+// are skipped and uploaded strings are added to existing strings. With
+// remove=1 existing strings that were not uploaded are removed. With
+// format=json, POST data is a json array of strings
+// Failures are returned as UploadError json
 func handleUploadStrings(w http.ResponseWriter, r *http.Request) {
-	appName := strings.TrimSpace(r.FormValue("app"))
-	app := findApp(appName)
+	app := getUploadApp(w, r, "strings")
 	if app == nil {
-		logger.Noticef("Someone tried to upload strings for non-existing app %s", appName)
-		httpErrorf(w, "Application %q doesn't exist", appName)
 		return
 	}
-	secret := strings.TrimSpace(r.FormValue("secret"))
-	if secret != app.UploadSecret {
-		logger.Noticef("Someone tried to upload strings for %s with invalid secret %s", appName, secret)
-		httpErrorf(w, "Invalid secret for app %q", appName)
+	appName := app.Name
+	ns := strings.TrimSpace(r.FormValue("ns"))
+	if !isValidNamespace(ns) {
+		serveUploadError(w, uploadErrParse, "Invalid namespace %q", ns)
 		return
 	}
-	s := r.FormValue("strings")
-	if newStrings, err := parseUploadedStrings(s); err != nil {
-		logger.Noticef("parseUploadedStrings() failed with %s", err)
-		httpErrorf(w, "Error parsing uploaded strings")
+	if newStrings, err := parseUploadStringsRequest(r, app, ns); err != nil {
+		logger.ForRequest(r).Noticef("parseUploadStringsRequest() failed with %s", err)
+		serveUploadError(w, uploadErrParse, "Error parsing uploaded strings: %s", err)
 		return
 	} else {
-		logger.Noticef("handleUploadString(): uploading %d strings for %s", len(newStrings), appName)
-		added, deleted, undeleted, err := app.store.UpdateStringsList(newStrings)
+		contextURLs, err := parseContextURLs(r.FormValue("contexturls"), newStrings)
 		if err != nil {
-			logger.Errorf("UpdateStringsList() failed with %s", err)
-		} else {
-			msg := ""
-			if len(added) > 0 {
-				msg += fmt.Sprintf("New strings: %v\n", added)
-			}
-			if len(deleted) > 0 {
-				msg += fmt.Sprintf("Deleted strings: %v\n", deleted)
-			}
-			if len(undeleted) > 0 {
-				msg += fmt.Sprintf("Undeleted strings: %v\n", undeleted)
-			}
-			if len(msg) > 0 {
-				logger.Notice(msg)
-			}
-			w.Write([]byte(msg))
+			serveUploadError(w, uploadErrParse, "Error parsing uploaded context urls: %s", err)
+			return
+		}
+		groups, err := parseGroups(r.FormValue("groups"), newStrings)
+		if err != nil {
+			serveUploadError(w, uploadErrParse, "Error parsing uploaded groups: %s", err)
+			return
+		}
+		diff, err := updateStrings(app, ns, newStrings)
+		if err != nil {
+			logger.ForRequest(r).Errorf("updateStrings() failed with %s", err)
+			http.Error(w, "Failed to update strings", http.StatusInternalServerError)
+			return
+		}
+		if err = setUploadedMeta(app.NamespaceStore(ns), contextURLs, groups); err != nil {
+			logger.ForRequest(r).Errorf("setUploadedMeta() failed with %s", err)
+		}
+		if diff == nil {
+			logger.ForRequest(r).Noticef("handleUploadString(): %d strings for %s didn't change", len(newStrings), appName)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		logger.ForRequest(r).Noticef("handleUploadString(): uploaded %d strings for %s", len(newStrings), appName)
+		msg := diff.String()
+		if len(msg) > 0 {
+			logger.Notice(msg)
 		}
+		w.Write([]byte(msg))
 	}
 }
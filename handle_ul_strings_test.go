@@ -0,0 +1,126 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHashStrings(t *testing.T) {
+	if hashStrings([]string{"Open", "Close"}) != hashStrings([]string{"Close", "Open"}) {
+		t.Fatalf("hash depends on order of strings")
+	}
+	if hashStrings([]string{"Open", "Close"}) == hashStrings([]string{"OpenClose"}) {
+		t.Fatalf("hash of different strings is the same")
+	}
+}
+
+func TestUploadSameStrings(t *testing.T) {
+	app := newTestApp(t, "upload", nil)
+	defer closeTestApp(app)
+
+	var body string
+	post := func(strs string) int {
+		form := url.Values{
+			"app":     {"upload"},
+			"secret":  {"secret"},
+			"strings": {"AppTranslator strings\n" + strs},
+		}
+		r := httptest.NewRequest("POST", "/uploadstrings", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		body = rr.Body.String()
+		return rr.Code
+	}
+	storeSize := func() int64 {
+		fi, err := os.Stat(filepath.Join(app.DataDir, "translations.csv"))
+		if err != nil {
+			t.Fatalf("os.Stat() failed with %s", err)
+		}
+		return fi.Size()
+	}
+
+	if code := post("Open\nClose"); code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", code, http.StatusOK)
+	}
+	size := storeSize()
+	edits := app.store.EditsCount()
+	if code := post("Close\nOpen"); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected %d", code, http.StatusNoContent)
+	}
+	if storeSize() != size || app.store.EditsCount() != edits {
+		t.Fatalf("uploading the same strings changed the store")
+	}
+	if code := post("Open\nSave"); code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", code, http.StatusOK)
+	}
+	if storeSize() == size || !app.store.IsActiveString("Save") || app.store.IsActiveString("Close") {
+		t.Fatalf("changed strings weren't applied")
+	}
+	if exp := "New strings: [Save]\nDeleted strings: [Close]\n"; body != exp {
+		t.Fatalf("got %q, expected %q", body, exp)
+	}
+	if code := post("Open\nClose"); code != http.StatusOK || body != "Deleted strings: [Save]\nUndeleted strings: [Close]\n" {
+		t.Fatalf("got status %d, body %q", code, body)
+	}
+}
+
+func TestUploadTxtStrings(t *testing.T) {
+	app := newTestApp(t, "uploadtxt", nil)
+	defer closeTestApp(app)
+
+	post := func(strs string, args ...string) int {
+		form := url.Values{
+			"app":     {"uploadtxt"},
+			"secret":  {"secret"},
+			"format":  {"txt"},
+			"strings": {strs},
+		}
+		for i := 0; i+1 < len(args); i += 2 {
+			form.Set(args[i], args[i+1])
+		}
+		r := httptest.NewRequest("POST", "/uploadstrings", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	expectStrings := func(exp ...string) {
+		got := app.store.ActiveStrings()
+		sort.Strings(got)
+		sort.Strings(exp)
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("got strings %v, expected %v", got, exp)
+		}
+	}
+
+	if code := post("Open\r\n\r\nClose\n  \nOpen\n"); code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", code, http.StatusOK)
+	}
+	expectStrings("Open", "Close")
+	if code := post("Save\nOpen"); code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", code, http.StatusOK)
+	}
+	expectStrings("Open", "Close", "Save")
+	if code := post("Close\nSave"); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected %d", code, http.StatusNoContent)
+	}
+	if code := post("Save\nExit", "remove", "1"); code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", code, http.StatusOK)
+	}
+	expectStrings("Save", "Exit")
+	if code := post("\n\n"); code != http.StatusBadRequest {
+		t.Fatalf("got status %d, expected %d", code, http.StatusBadRequest)
+	}
+	if code := post("Open", "format", "xml"); code != http.StatusBadRequest {
+		t.Fatalf("got status %d, expected %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,185 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+// translations imported from files are attributed to this user
+const importUser = "import"
+
+// currentTranslations returns current translations of active strings, indexed
+// by language and string. Untranslated strings map to ""
+func currentTranslations(app *App) map[string]map[string]string {
+	return translationsByLang(app.store.LangInfos())
+}
+
+func translationsByLang(langs []*store.LangInfo) map[string]map[string]string {
+	res := make(map[string]map[string]string)
+	for _, li := range langs {
+		m := make(map[string]string)
+		for _, tr := range li.ActiveStrings {
+			m[tr.String] = tr.Current()
+		}
+		res[li.Code] = m
+	}
+	return res
+}
+
+// ImportConflict is an imported translation that differs from the current,
+// not empty, translation
+type ImportConflict struct {
+	Lang     string `json:"lang"`
+	Source   string `json:"source"`
+	Current  string `json:"current"`
+	Imported string `json:"imported"`
+}
+
+// ImportReport is returned by importers of translations with report=1
+type ImportReport struct {
+	Conflicts []ImportConflict `json:"conflicts"`
+	Unknown   []string         `json:"unknown"`
+}
+
+type importMode int
+
+const (
+	// translations that differ from current ones are not imported
+	importKeepCurrent importMode = iota
+	// translations that differ from current ones replace them
+	importOverwrite
+	// nothing is imported, conflicts and unknown strings are only reported
+	importReportOnly
+)
+
+// importTranslations applies translations of strings in st that differ from
+// the current ones. Strings that should not be translated are skipped.
+// Existing translations are only replaced in importOverwrite mode.
+// Returns number of translations written, strings that are not known and
+// conflicts with existing translations
+func importTranslations(st *store.StoreCsv, entries []TransEntry, mode importMode) (int, *ImportReport, error) {
+	current := translationsByLang(st.LangInfos())
+	report := &ImportReport{Conflicts: []ImportConflict{}, Unknown: []string{}}
+	isUnknown := make(map[string]bool)
+	n := 0
+	for _, e := range entries {
+		trans, ok := current[e.Lang][e.Source]
+		if !ok {
+			if !isUnknown[e.Source] {
+				isUnknown[e.Source] = true
+				report.Unknown = append(report.Unknown, e.Source)
+			}
+			continue
+		}
+		if e.Translation == "" || e.Translation == trans || st.IsNoTranslate(e.Source) {
+			continue
+		}
+		if trans != "" {
+			report.Conflicts = append(report.Conflicts, ImportConflict{e.Lang, e.Source, trans, e.Translation})
+			if mode != importOverwrite {
+				continue
+			}
+		}
+		if mode == importReportOnly {
+			continue
+		}
+		if err := st.WriteNewTranslation(e.Source, e.Translation, e.Lang, importUser); err != nil {
+			return n, report, err
+		}
+		n++
+	}
+	return n, report, nil
+}
+
+// url: POST /uploadtranslations?app=$appName&secret=$uploadSecret&format=$format[&report=1][&overwrite=1][&ns=$ns]
+// POST data is in "translations" field, in csv, po, json or tsv format (see
+// transfile.go for description of the formats). Failures are returned as
+// UploadError json
+func handleUploadTranslations(w http.ResponseWriter, r *http.Request) {
+	app := getUploadApp(w, r, "translations")
+	if app == nil {
+		return
+	}
+	format := strings.TrimSpace(r.FormValue("format"))
+	if !isValidTransFormat(format) {
+		serveUploadError(w, uploadErrParse, "Invalid format %q", format)
+		return
+	}
+	entries, err := DecodeTranslations([]byte(r.FormValue("translations")), format)
+	if invalid, ok := err.(*InvalidTransError); ok {
+		serveUploadError(w, uploadErrParse, "Invalid translations:\n%s", strings.Join(invalid.Problems, "\n"))
+		return
+	}
+	if err != nil {
+		logger.ForRequest(r).Noticef("DecodeTranslations() failed with %s", err)
+		serveUploadError(w, uploadErrParse, "Error parsing uploaded translations: %s", err)
+		return
+	}
+	serveImportTranslations(w, r, app, entries)
+}
+
+// serveImportTranslations imports entries into namespace ns (default if not
+// given) and responds with a report of how many translations were imported
+// and which strings are not known. Translations that differ from current
+// ones are only imported with overwrite=1. With report=1 nothing is
+// imported and the response is ImportReport json
+func serveImportTranslations(w http.ResponseWriter, r *http.Request, app *App, entries []TransEntry) {
+	ns := strings.TrimSpace(r.FormValue("ns"))
+	st := app.NamespaceStore(ns)
+	if st == nil {
+		serveUploadError(w, uploadErrParse, "Namespace %q doesn't exist", ns)
+		return
+	}
+	mode := importKeepCurrent
+	if r.FormValue("report") == "1" {
+		mode = importReportOnly
+	} else if r.FormValue("overwrite") == "1" {
+		mode = importOverwrite
+	}
+	n, report, err := importTranslations(st, entries, mode)
+	if err != nil {
+		logger.ForRequest(r).Errorf("importTranslations() failed with %s", err)
+		http.Error(w, "Failed to import translations", http.StatusInternalServerError)
+		return
+	}
+	if mode == importReportOnly {
+		serveJSON(w, report)
+		return
+	}
+	msg := fmt.Sprintf("Imported %d translations\n", n)
+	if len(report.Unknown) > 0 {
+		msg += fmt.Sprintf("Unknown strings: %v\n", report.Unknown)
+	}
+	if len(report.Conflicts) > 0 && mode != importOverwrite {
+		msg += fmt.Sprintf("Skipped %d translations that differ from current ones, see them with report=1 and replace with overwrite=1\n", len(report.Conflicts))
+	}
+	logger.ForRequest(r).Noticef("%s: %s", app.Name, msg)
+	w.Write([]byte(msg))
+}
+
+// url: POST /uploadlangtranslations?app=$appName&secret=$uploadSecret&lang=$lang[&report=1][&overwrite=1][&ns=$ns]
+// POST data is in "translations" field, a json object mapping source strings
+// to translations in language lang. Sources that don't exist are ignored and
+// listed in the response. Failures are returned as UploadError json
+func handleUploadLangTranslations(w http.ResponseWriter, r *http.Request) {
+	app := getUploadApp(w, r, "translations")
+	if app == nil {
+		return
+	}
+	lang := strings.TrimSpace(r.FormValue("lang"))
+	entries, err := DecodeLangTranslations([]byte(r.FormValue("translations")), lang)
+	if invalid, ok := err.(*InvalidTransError); ok {
+		serveUploadError(w, uploadErrParse, "Invalid translations:\n%s", strings.Join(invalid.Problems, "\n"))
+		return
+	}
+	if err != nil {
+		logger.ForRequest(r).Noticef("DecodeLangTranslations() failed with %s", err)
+		serveUploadError(w, uploadErrParse, "Error parsing uploaded translations: %s", err)
+		return
+	}
+	serveImportTranslations(w, r, app, entries)
+}
@@ -0,0 +1,170 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUploadLangTranslations(t *testing.T) {
+	app := newTestApp(t, "uploadlang", []string{"Open", "Close"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+
+	post := func(lang, translations string) (int, string) {
+		form := url.Values{
+			"app":          {"uploadlang"},
+			"secret":       {"secret"},
+			"lang":         {lang},
+			"translations": {translations},
+		}
+		r := httptest.NewRequest("POST", "/uploadlangtranslations", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr.Code, rr.Body.String()
+	}
+
+	edits := app.store.EditsCount()
+	for _, empty := range []string{"", "{}"} {
+		code, body := post("pl", empty)
+		if code != 200 || !strings.Contains(body, "Imported 0 translations") || app.store.EditsCount() != edits {
+			t.Fatalf("empty payload %q: got status %d, body %q", empty, code, body)
+		}
+	}
+
+	code, body := post("pl", `{"Open": "Otwórz", "Close": "Zamknij", "Exit": "Wyjdź"}`)
+	if code != 200 {
+		t.Fatalf("got status %d, body %q", code, body)
+	}
+	if !strings.Contains(body, "Imported 1 translations") || !strings.Contains(body, "Unknown strings: [Exit]") {
+		t.Fatalf("unexpected report %q", body)
+	}
+	if got := currentTranslations(app)["pl"]["Open"]; got != "Otwórz" {
+		t.Fatalf("got translation %q, expected %q", got, "Otwórz")
+	}
+	if got := currentTranslations(app)["de"]["Open"]; got != "" {
+		t.Fatalf("translation was applied to another language: %q", got)
+	}
+
+	if code, body = post("xx-invalid", `{"Open": "Open"}`); code != 400 {
+		t.Fatalf("invalid lang: got status %d, body %q", code, body)
+	}
+	if code, body = post("pl", `{"Open": "Otwórz", "Open": "Otwórz"}`); code != 400 {
+		t.Fatalf("duplicate string: got status %d, body %q", code, body)
+	}
+	if code, body = post("pl", `{"Open": "Otwórz"} {}`); code != 400 {
+		t.Fatalf("trailing data: got status %d, body %q", code, body)
+	}
+}
+
+func TestUploadTsvTranslations(t *testing.T) {
+	app := newTestApp(t, "uploadtsv", []string{"Open", "Save\nas"})
+	defer closeTestApp(app)
+
+	post := func(translations string) (int, string) {
+		form := url.Values{
+			"app":          {"uploadtsv"},
+			"secret":       {"secret"},
+			"format":       {"tsv"},
+			"translations": {translations},
+		}
+		r := httptest.NewRequest("POST", "/uploadtranslations", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr.Code, rr.Body.String()
+	}
+
+	code, body := post("source\tde\tpl\nOpen\tÖffnen\tOtwórz\n\"Save\nas\"\t\tZapisz jako\nExit\tBeenden\tWyjdź\n")
+	if code != 200 {
+		t.Fatalf("got status %d, body %q", code, body)
+	}
+	if !strings.Contains(body, "Imported 3 translations") || !strings.Contains(body, "Unknown strings: [Exit]\n") {
+		t.Fatalf("unexpected report %q", body)
+	}
+	current := currentTranslations(app)
+	if current["de"]["Open"] != "Öffnen" || current["pl"]["Open"] != "Otwórz" || current["pl"]["Save\nas"] != "Zapisz jako" {
+		t.Fatalf("unexpected translations %v %v", current["de"], current["pl"])
+	}
+	if current["de"]["Save\nas"] != "" {
+		t.Fatalf("empty cell was imported as %q", current["de"]["Save\nas"])
+	}
+
+	if code, body = post("source\txx-invalid\nOpen\tOpen\n"); code != 400 {
+		t.Fatalf("invalid lang: got status %d, body %q", code, body)
+	}
+	if code, body = post("source\tde\t\nOpen\tÖffnen\t\n"); code != 400 {
+		t.Fatalf("missing lang: got status %d, body %q", code, body)
+	}
+	if code, body = post("source\tde\nOpen\tÖffnen\textra\n"); code != 400 {
+		t.Fatalf("wrong number of cells: got status %d, body %q", code, body)
+	}
+}
+
+func TestUploadTranslationsConflicts(t *testing.T) {
+	app := newTestApp(t, "uploadconflicts", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+
+	post := func(args ...string) (int, string) {
+		form := url.Values{
+			"app":          {"uploadconflicts"},
+			"secret":       {"secret"},
+			"lang":         {"pl"},
+			"translations": {`{"Open": "Otwieranie", "Close": "Zamknij", "Save": "Zapisz"}`},
+		}
+		for i := 0; i < len(args); i += 2 {
+			form.Set(args[i], args[i+1])
+		}
+		r := httptest.NewRequest("POST", "/uploadlangtranslations", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr.Code, rr.Body.String()
+	}
+
+	// report doesn't change anything
+	edits := app.store.EditsCount()
+	code, body := post("report", "1")
+	var report ImportReport
+	if err := json.Unmarshal([]byte(body), &report); code != 200 || err != nil {
+		t.Fatalf("got status %d, body %q, %v", code, body, err)
+	}
+	exp := ImportReport{
+		Conflicts: []ImportConflict{{Lang: "pl", Source: "Open", Current: "Otwórz", Imported: "Otwieranie"}},
+		Unknown:   []string{},
+	}
+	if !reflect.DeepEqual(report, exp) {
+		t.Fatalf("got %#v, expected %#v", report, exp)
+	}
+	if app.store.EditsCount() != edits {
+		t.Fatalf("report changed the store")
+	}
+
+	// conflicts are skipped without overwrite
+	code, body = post()
+	if code != 200 || !strings.Contains(body, "Imported 1 translations") || !strings.Contains(body, "Skipped 1 translations") {
+		t.Fatalf("got status %d, body %q", code, body)
+	}
+	current := currentTranslations(app)["pl"]
+	if current["Open"] != "Otwórz" || current["Save"] != "Zapisz" {
+		t.Fatalf("unexpected translations %v", current)
+	}
+
+	code, body = post("overwrite", "1")
+	if code != 200 || !strings.Contains(body, "Imported 1 translations") || strings.Contains(body, "Skipped") {
+		t.Fatalf("got status %d, body %q", code, body)
+	}
+	if got := currentTranslations(app)["pl"]["Open"]; got != "Otwieranie" {
+		t.Fatalf("got translation %q, expected %q", got, "Otwieranie")
+	}
+	if code, body = post("report", "1"); code != 200 || !strings.Contains(body, `"conflicts":[]`) {
+		t.Fatalf("got status %d, body %q after overwrite", code, body)
+	}
+}
@@ -0,0 +1,32 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import "net/http"
+
+// WhoAmI describes the logged in user and what they can do
+type WhoAmI struct {
+	// empty if not logged in
+	User      string `json:"user"`
+	Anonymous bool   `json:"anonymous"`
+	// role in each app, indexed by app name
+	Roles map[string]string `json:"roles"`
+}
+
+func buildWhoAmI(user string) *WhoAmI {
+	res := &WhoAmI{
+		User:      user,
+		Anonymous: user == "",
+		Roles:     make(map[string]string),
+	}
+	for _, app := range appState.Apps {
+		res.Roles[app.Name] = userRole(app, user)
+	}
+	return res
+}
+
+// url: /whoami
+// Returns json with the logged in user and their role in each app. Not
+// logged in user is anonymous, not an error
+func handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, buildWhoAmI(decodeUserFromCookie(r)))
+}
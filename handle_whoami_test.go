@@ -0,0 +1,47 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWhoAmI(t *testing.T) {
+	app := newTestApp(t, "whoami", nil)
+	defer closeTestApp(app)
+
+	tests := []struct {
+		r   *http.Request
+		exp WhoAmI
+	}{
+		{
+			httptest.NewRequest("GET", "/whoami", nil),
+			WhoAmI{"", true, map[string]string{"whoami": roleNone}},
+		},
+		{
+			newRequestWithCookie("GET", "/whoami", &SecureCookieValue{User: "admin"}),
+			WhoAmI{"admin", false, map[string]string{"whoami": roleAdmin}},
+		},
+		{
+			newRequestWithCookie("GET", "/whoami", &SecureCookieValue{User: "user1"}),
+			WhoAmI{"user1", false, map[string]string{"whoami": roleTranslator}},
+		},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, test.r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("got status %d, expected %d", rr.Code, http.StatusOK)
+		}
+		var got WhoAmI
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("json.Unmarshal() failed with %s", err)
+		}
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("got %#v, expected %#v", got, test.exp)
+		}
+	}
+}
@@ -58,11 +58,15 @@ func getAppLangArg(w http.ResponseWriter, r *http.Request) (*App, string) {
 	if app == nil {
 		return nil, ""
 	}
-	langCode := strings.TrimSpace(r.FormValue("lang"))
+	langCode := store.NormalizeLangCode(r.FormValue("lang"))
 	if !store.IsValidLangCode(langCode) {
 		httpErrorf(w, "Invalid lang code %q", langCode)
 		return nil, ""
 	}
+	if store.IsLangDisabled(langCode) {
+		httpErrorf(w, "Language %q is disabled", langCode)
+		return nil, ""
+	}
 	return app, langCode
 }
 
@@ -73,8 +77,9 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	user := decodeUserFromCookie(r)
+	apps := activeApps()
 	model := &ModelMain{
-		Apps:        &appState.Apps,
+		Apps:        &apps,
 		User:        user,
 		UserIsAdmin: false,
 		RedirectUrl: r.URL.String(),
@@ -83,7 +88,24 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 	ExecTemplate(w, tmplMain, model)
 }
 
-// url: /edittranslation?string=${string}&translation=${translation}
+// validateEdit checks if translation of str in st into lang can be edited
+func validateEdit(st *store.StoreCsv, str, lang string) error {
+	if !store.IsValidLangCode(lang) {
+		return fmt.Errorf("Invalid lang code %q", lang)
+	}
+	if store.IsLangDisabled(lang) {
+		return fmt.Errorf("Language %q is disabled", lang)
+	}
+	if !st.IsActiveString(str) {
+		return fmt.Errorf("String %q doesn't exist", str)
+	}
+	if st.IsNoTranslate(str) {
+		return fmt.Errorf("String %q should not be translated", str)
+	}
+	return nil
+}
+
+// url: /edittranslation?string=${string}&translation=${translation}[&note=${note}][&ns=${ns}]
 func handleEditTranslation(w http.ResponseWriter, r *http.Request) {
 	app, langCode := getAppLangArg(w, r)
 	if app == nil {
@@ -94,15 +116,33 @@ func handleEditTranslation(w http.ResponseWriter, r *http.Request) {
 		httpErrorf(w, "User doesn't exist")
 		return
 	}
+	ns := strings.TrimSpace(r.FormValue("ns"))
+	st := app.NamespaceStore(ns)
+	if st == nil {
+		httpErrorf(w, "Namespace %q doesn't exist", ns)
+		return
+	}
 	str := strings.TrimSpace(r.FormValue("string"))
 	translation := r.FormValue("translation")
+	note := strings.TrimSpace(r.FormValue("note"))
+	if err := validateEdit(st, str, langCode); err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
 
-	if err := app.store.WriteNewTranslation(str, translation, langCode, user); err != nil {
+	if err := st.WriteNewTranslationWithNote(str, translation, langCode, user, note); err != nil {
 		httpErrorf(w, "Failed to add a translation %q", err)
 		return
 	}
 	msg := fmt.Sprintf("Edited translation of %q to be %q", str, translation)
-	url := fmt.Sprintf("/app/%s/%s?msg=%s", app.Name, langCode, url.QueryEscape(msg))
+	if problem := maxLenProblem(st.MaxLen(str), translation); problem != "" {
+		msg += fmt.Sprintf(". Warning: %s", problem)
+	}
+	q := url.Values{"msg": {msg}}
+	if ns != defaultNamespace {
+		q.Set("ns", ns)
+	}
+	url := fmt.Sprintf("/app/%s/%s?%s", app.Name, langCode, q.Encode())
 	http.Redirect(w, r, url, http.StatusFound)
 }
 
@@ -138,37 +178,167 @@ func handleDuplicateTranslation(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, url, http.StatusFound)
 }
 
-// // https://blog.gopheracademy.com/advent-2016/exposing-go-on-the-internet/
-func makeHTTPServer() *http.Server {
+// url: /notranslate?app=${app}&lang=${lang}&string=${string}&val=${0|1}
+func handleNoTranslate(w http.ResponseWriter, r *http.Request) {
+	app, langCode := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	user := decodeUserFromCookie(r)
+	if !userIsAdmin(app, user) {
+		httpErrorf(w, "User can't change strings")
+		return
+	}
+	str := strings.TrimSpace(r.FormValue("string"))
+	if !app.store.IsActiveString(str) {
+		httpErrorf(w, "String %q doesn't exist", str)
+		return
+	}
+	noTranslate := r.FormValue("val") == "1"
+	if err := app.store.SetNoTranslate(str, noTranslate); err != nil {
+		httpErrorf(w, "Failed to change string %q", err)
+		return
+	}
+	msg := fmt.Sprintf("Marked %q as a string to translate", str)
+	if noTranslate {
+		msg = fmt.Sprintf("Marked %q as a string not to translate", str)
+	}
+	url := fmt.Sprintf("/app/%s/%s?msg=%s", app.Name, langCode, url.QueryEscape(msg))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// url: /maxlen?app=${app}&lang=${lang}&string=${string}&val=${maxLen}
+// val of 0 removes the limit
+func handleMaxLen(w http.ResponseWriter, r *http.Request) {
+	app, langCode := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't change strings")
+		return
+	}
+	str := strings.TrimSpace(r.FormValue("string"))
+	if !app.store.IsActiveString(str) {
+		httpErrorf(w, "String %q doesn't exist", str)
+		return
+	}
+	maxLen, err := formIntArg(r, "val", 0)
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	if err = app.store.SetMaxLen(str, maxLen); err != nil {
+		httpErrorf(w, "Failed to change string %q", err)
+		return
+	}
+	msg := fmt.Sprintf("Removed length limit of %q", str)
+	if maxLen > 0 {
+		msg = fmt.Sprintf("Limited translations of %q to %d characters", str, maxLen)
+	}
+	url := fmt.Sprintf("/app/%s/%s?msg=%s", app.Name, langCode, url.QueryEscape(msg))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func makeRouter() *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/app/{appname}", makeTimingHandler(handleApp))
 	r.HandleFunc("/app/{appname}/edits", makeTimingHandler(handleAppEdits))
 	r.HandleFunc("/app/{appname}/{lang}", makeTimingHandler(handleAppTranslations))
 	r.HandleFunc("/user/{user}", makeTimingHandler(handleUser))
-	r.HandleFunc("/edittranslation", makeTimingHandler(handleEditTranslation))
-	r.HandleFunc("/duptranslation", makeTimingHandler(handleDuplicateTranslation))
+	r.HandleFunc("/stats/{appname}", makeTimingHandler(handleStats))
+	r.HandleFunc("/todo/{appname}/{lang}", makeTimingHandler(handleTodo))
+	r.HandleFunc("/edittranslation", makeTimingHandler(makeMutatingHandler(handleEditTranslation)))
+	r.HandleFunc("/duptranslation", makeTimingHandler(makeMutatingHandler(handleDuplicateTranslation)))
+	r.HandleFunc("/notranslate", makeTimingHandler(makeMutatingHandler(handleNoTranslate)))
+	r.HandleFunc("/maxlen", makeTimingHandler(makeMutatingHandler(handleMaxLen)))
+	r.HandleFunc("/contexturl", makeTimingHandler(makeMutatingHandler(handleContextURL)))
+	r.HandleFunc("/group", makeTimingHandler(makeMutatingHandler(handleGroup)))
+	r.HandleFunc("/setstate", makeTimingHandler(makeMutatingHandler(handleSetState)))
+	r.HandleFunc("/approveall/{appname}/{lang}", makeTimingHandler(makeMutatingHandler(handleApproveAll)))
+	r.HandleFunc("/prefillsource/{appname}/{lang}", makeTimingHandler(makeMutatingHandler(handlePrefillSource)))
+	// not wrapped in makeMutatingHandler so that jobs can be polled in
+	// read-only mode, POST checks it
+	r.HandleFunc("/admin/machinetranslate", makeTimingHandler(handleMachineTranslate))
+	r.HandleFunc("/admin/compact/{appname}", makeTimingHandler(makeMutatingHandler(handleAdminCompact)))
+	r.HandleFunc("/admin/diff/{appname}", makeTimingHandler(handleBackupDiff))
+	r.HandleFunc("/admin/backup", makeTimingHandler(handleBackupNow))
+	r.HandleFunc("/admin/backups", makeTimingHandler(handleBackups))
+	r.HandleFunc("/admin/backups/download", makeTimingHandler(handleBackupDownload))
+	r.HandleFunc("/admin/readonly", makeTimingHandler(handleReadOnly))
+	r.HandleFunc("/admin/activity", makeTimingHandler(handleAdminActivity))
+	r.HandleFunc("/admin/revalidate/{appname}", makeTimingHandler(handleAdminRevalidate))
+	r.HandleFunc("/admin/rename", makeTimingHandler(makeMutatingHandler(handleRenameSource)))
+	r.HandleFunc("/admin/export/{appname}.json", makeTimingHandler(handleAdminExport))
+	r.HandleFunc("/admin/import/{appname}", makeTimingHandler(makeMutatingHandler(makeUploadHandler(handleAdminImport))))
+	r.HandleFunc("/batchedit", makeTimingHandler(makeMutatingHandler(makeUploadHandler(handleBatchEdit))))
 	r.HandleFunc("/dltrans", makeTimingHandler(handleDownloadTranslations))
-	r.HandleFunc("/uploadstrings", makeTimingHandler(handleUploadStrings))
+	r.HandleFunc("/export", makeTimingHandler(handleExport))
+	r.HandleFunc("/exportlink", makeTimingHandler(handleExportLink))
+	r.HandleFunc("/dl/{token}", makeTimingHandler(handleExportLinkDownload))
+	r.HandleFunc("/uploadstrings", makeTimingHandler(makeMutatingHandler(makeUploadHandler(handleUploadStrings))))
+	r.HandleFunc("/uploadtranslations", makeTimingHandler(makeMutatingHandler(makeUploadHandler(handleUploadTranslations))))
+	r.HandleFunc("/uploadlangtranslations", makeTimingHandler(makeMutatingHandler(makeUploadHandler(handleUploadLangTranslations))))
+	r.HandleFunc("/uploadglossary", makeTimingHandler(makeMutatingHandler(makeUploadHandler(handleUploadGlossary))))
+	r.HandleFunc("/webhook/{appname}", makeTimingHandler(makeMutatingJSONHandler(makeUploadHandler(handleWebhook))))
+	r.HandleFunc("/badge/{appname}/{lang}.svg", makeTimingHandler(handleBadge))
+	r.HandleFunc("/coverage/{appname}.csv", makeTimingHandler(handleCoverage))
 	r.HandleFunc("/rss", makeTimingHandler(handleRss))
+	r.HandleFunc("/sitemap.xml", makeTimingHandler(handleSitemap))
+	r.HandleFunc("/api/v1/apps/{name}/debt", makeTimingHandler(handleAPIAppDebt))
+	r.HandleFunc("/api/v1/apps/{name}/history", makeTimingHandler(handleAPIAppHistory))
+	r.HandleFunc("/api/v1/apps/{name}/issues", makeTimingHandler(handleAPIAppIssues))
+	r.HandleFunc("/api/v1/apps/{name}/progress", makeTimingHandler(handleAPIAppProgress))
+	r.HandleFunc("/api/v1/apps/{name}/sources", makeTimingHandler(handleAPIAppSources))
+	r.HandleFunc("/api/v1/apps/{name}/string", makeTimingHandler(handleAPIAppString))
+	r.HandleFunc("/api/v1/apps/{name}/translations/{lang}", makeTimingHandler(handleAPIAppTranslations))
+	r.HandleFunc("/api/openapi.json", makeTimingHandler(handleOpenAPISpec))
 
 	r.HandleFunc("/login", handleLogin)
+	r.HandleFunc("/login/basic", handleLoginBasic)
 	r.HandleFunc("/oauthtwittercb", handleOauthTwitterCallback)
 	r.HandleFunc("/logout", handleLogout)
 	r.HandleFunc("/logs", makeTimingHandler(handleLogs))
+	r.HandleFunc("/version", handleVersion)
+	r.HandleFunc("/health", handleHealth)
+	r.HandleFunc("/metrics", makeTimingHandler(handleMetrics))
+	r.HandleFunc("/whoami", makeTimingHandler(handleWhoAmI))
+	r.HandleFunc("/preview", makeTimingHandler(handlePreview))
 	r.HandleFunc("/", makeTimingHandler(handleMain))
+	r.NotFoundHandler = http.HandlerFunc(http404)
+	return r
+}
 
+const (
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 5 * time.Second
+	defaultIdleTimeout  = 120 * time.Second
+)
+
+func timeoutOrDefault(secs int, def time.Duration) time.Duration {
+	if secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return def
+}
+
+// // https://blog.gopheracademy.com/advent-2016/exposing-go-on-the-internet/
+// Timeouts protect from slow clients and hung connections
+func makeHTTPServer() *http.Server {
 	smux := &http.ServeMux{}
 
 	smux.HandleFunc("/s/", makeTimingHandler(handleStatic))
-	smux.Handle("/", r)
+	smux.Handle("/", makeRouter())
 
 	srv := &http.Server{
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
-		// TODO: 1.8 only
-		// IdleTimeout:  120 * time.Second,
-		Handler: smux,
+		ReadTimeout:  timeoutOrDefault(config.ReadTimeoutSecs, defaultReadTimeout),
+		WriteTimeout: timeoutOrDefault(config.WriteTimeoutSecs, defaultWriteTimeout),
+		IdleTimeout:  timeoutOrDefault(config.IdleTimeoutSecs, defaultIdleTimeout),
+		Handler:      withRequestID(withBasePathHandler(smux)),
+		// 0 is http.DefaultMaxHeaderBytes
+		MaxHeaderBytes: config.MaxHeaderBytes,
+		ConnState:      trackConnState,
 	}
-	// TODO: track connections and their state
+	srv.SetKeepAlivesEnabled(!config.DisableKeepAlives)
 	return srv
 }
@@ -0,0 +1,91 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTimeouts(t *testing.T) {
+	srv := makeHTTPServer()
+	if srv.ReadTimeout != defaultReadTimeout || srv.WriteTimeout != defaultWriteTimeout || srv.IdleTimeout != defaultIdleTimeout {
+		t.Fatalf("unexpected default timeouts %s, %s, %s", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	defer func() {
+		config.ReadTimeoutSecs = 0
+		config.WriteTimeoutSecs = 0
+		config.IdleTimeoutSecs = 0
+	}()
+	config.ReadTimeoutSecs = 10
+	config.WriteTimeoutSecs = 30
+	config.IdleTimeoutSecs = 60
+	srv = makeHTTPServer()
+	if srv.ReadTimeout != 10*time.Second || srv.WriteTimeout != 30*time.Second || srv.IdleTimeout != 60*time.Second {
+		t.Fatalf("unexpected timeouts %s, %s, %s", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+func TestServerConnections(t *testing.T) {
+	defer func() {
+		config.MaxHeaderBytes = 0
+		config.DisableKeepAlives = false
+	}()
+	config.MaxHeaderBytes = 4096
+	srv := makeHTTPServer()
+	if srv.MaxHeaderBytes != 4096 {
+		t.Fatalf("got MaxHeaderBytes %d, expected 4096", srv.MaxHeaderBytes)
+	}
+
+	var ts *httptest.Server
+	start := func(srv *http.Server) {
+		ts = httptest.NewUnstartedServer(srv.Handler)
+		ts.Config = srv
+		ts.Start()
+	}
+	start(srv)
+	get := func(header string) int {
+		req, _ := http.NewRequest("GET", ts.URL+"/health", nil)
+		req.Header.Set("X-Test", header)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET failed with %s", err)
+		}
+		io.Copy(ioutil.Discard, rsp.Body)
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	if code := get(strings.Repeat("x", 8192)); code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("got status %d for large header, expected 431", code)
+	}
+	if code := get("x"); code != 200 {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	// the connection is kept open for next requests
+	waitForConns := func(state http.ConnState, exp int) {
+		for i := 0; i < 100 && connCounts()[state] != exp; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if n := connCounts()[state]; n != exp {
+			t.Fatalf("got %d connections in state %s, expected %d", n, state, exp)
+		}
+	}
+	waitForConns(http.StateIdle, 1)
+	http.DefaultClient.CloseIdleConnections()
+	waitForConns(http.StateIdle, 0)
+
+	ts.Close()
+	config.DisableKeepAlives = true
+	start(makeHTTPServer())
+	defer ts.Close()
+	if code := get("x"); code != 200 {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	waitForConns(http.StateIdle, 0)
+	waitForConns(http.StateActive, 0)
+}
@@ -0,0 +1,185 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BackupStatus describes results of backups, of all data or of one app
+type BackupStatus struct {
+	LastSuccess time.Time
+	LastError   time.Time
+	// error of the last failed backup
+	Error string
+}
+
+// failed returns true if the last backup failed
+func (s *BackupStatus) failed() bool {
+	return s.LastError.After(s.LastSuccess)
+}
+
+// update records result of a backup done at t, which failed if err is not nil
+func (s *BackupStatus) update(t time.Time, err error) {
+	if err != nil {
+		s.LastError = t
+		s.Error = err.Error()
+	} else {
+		s.LastSuccess = t
+	}
+}
+
+// backupStatus, appBackupStatus (by app name) and targetBackupStatus (by
+// name of backup target) are updated after every backup. They have their
+// own lock so that health checks don't wait for backups in progress
+var (
+	backupStatusMu     sync.Mutex
+	backupStatus       BackupStatus
+	appBackupStatus    = make(map[string]*BackupStatus)
+	targetBackupStatus = make(map[string]*BackupStatus)
+	// if there was no successful backup, backups are overdue relative
+	// to this time
+	backupStatusSince = clock.Now()
+)
+
+// backups are overdue if there was no successful backup for this many
+// backup intervals
+const backupOverdueIntervals = 2
+
+// recordBackupResult updates backup status of apps included in a backup
+// done at t, which failed if err is not nil
+func recordBackupResult(apps []*App, t time.Time, err error) {
+	backupStatusMu.Lock()
+	defer backupStatusMu.Unlock()
+	backupStatus.update(t, err)
+	for _, app := range apps {
+		s := appBackupStatus[app.Name]
+		if s == nil {
+			s = &BackupStatus{}
+			appBackupStatus[app.Name] = s
+		}
+		s.update(t, err)
+	}
+}
+
+// recordTargetBackupResult updates backup status of a backup target after
+// a backup done at t, which failed if err is not nil
+func recordTargetBackupResult(target string, t time.Time, err error) {
+	backupStatusMu.Lock()
+	defer backupStatusMu.Unlock()
+	s := targetBackupStatus[target]
+	if s == nil {
+		s = &BackupStatus{}
+		targetBackupStatus[target] = s
+	}
+	s.update(t, err)
+}
+
+// isBackupOverdue returns true if there was no successful backup for
+// backupOverdueIntervals intervals of freq
+func isBackupOverdue(lastSuccess time.Time, freq time.Duration, now time.Time) bool {
+	if lastSuccess.IsZero() {
+		lastSuccess = backupStatusSince
+	}
+	return now.Sub(lastSuccess) > backupOverdueIntervals*freq
+}
+
+// BackupHealth is backup status reported by /health
+type BackupHealth struct {
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   *time.Time `json:"last_error,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Failed      bool       `json:"failed"`
+	Overdue     bool       `json:"overdue"`
+}
+
+// AppHealth is status of an app reported by /health
+type AppHealth struct {
+	Name   string        `json:"name"`
+	Backup *BackupHealth `json:"backup,omitempty"`
+}
+
+// BackupTargetHealth is status of a backup target reported by /health
+type BackupTargetHealth struct {
+	Name   string        `json:"name"`
+	Backup *BackupHealth `json:"backup"`
+}
+
+// Health is returned by /health
+type Health struct {
+	Ok bool `json:"ok"`
+	// true if backups are failing or overdue
+	Degraded bool `json:"degraded"`
+	// nil if backups are not enabled
+	Backup *BackupHealth `json:"backup,omitempty"`
+	// status of each backup target. A failing target makes the server
+	// degraded even if backups to other targets succeed
+	BackupTargets []BackupTargetHealth `json:"backup_targets,omitempty"`
+	Apps          []AppHealth          `json:"apps"`
+	// apps that failed to load, which also makes the server degraded
+	FailedApps []FailedApp `json:"failed_apps,omitempty"`
+}
+
+func newBackupHealth(s *BackupStatus, freq time.Duration, now time.Time) *BackupHealth {
+	res := &BackupHealth{
+		Error:   s.Error,
+		Failed:  s.failed(),
+		Overdue: isBackupOverdue(s.LastSuccess, freq, now),
+	}
+	if !s.LastSuccess.IsZero() {
+		t := s.LastSuccess.UTC()
+		res.LastSuccess = &t
+	}
+	if !s.LastError.IsZero() {
+		t := s.LastError.UTC()
+		res.LastError = &t
+		// error is only interesting if the last backup failed
+		if !res.Failed {
+			res.Error = ""
+		}
+	}
+	return res
+}
+
+// buildHealth returns the health of the server at now
+func buildHealth(now time.Time) *Health {
+	backupStatusMu.Lock()
+	defer backupStatusMu.Unlock()
+	res := &Health{Ok: true, Apps: []AppHealth{}, FailedApps: appState.FailedApps}
+	res.Degraded = len(res.FailedApps) > 0
+	backupsEnabled := backupStore != nil
+	if backupsEnabled {
+		res.Backup = newBackupHealth(&backupStatus, backupLoopFreq(), now)
+		res.Degraded = res.Degraded || res.Backup.Failed || res.Backup.Overdue
+		for _, t := range backupTargets {
+			s := targetBackupStatus[t.name()]
+			if s == nil {
+				s = &BackupStatus{}
+			}
+			th := BackupTargetHealth{Name: t.name(), Backup: newBackupHealth(s, backupLoopFreq(), now)}
+			res.Degraded = res.Degraded || th.Backup.Failed || th.Backup.Overdue
+			res.BackupTargets = append(res.BackupTargets, th)
+		}
+	}
+	for _, app := range appState.Apps {
+		ah := AppHealth{Name: app.Name}
+		if freq := appBackupFreq(app); backupsEnabled && freq > 0 {
+			s := appBackupStatus[app.Name]
+			if s == nil {
+				s = &BackupStatus{}
+			}
+			ah.Backup = newBackupHealth(s, freq, now)
+			res.Degraded = res.Degraded || ah.Backup.Failed || ah.Backup.Overdue
+		}
+		res.Apps = append(res.Apps, ah)
+	}
+	return res
+}
+
+// url: /health
+// Returns Health json for monitoring. Status is 200 as long as the server
+// runs, problems like failing backups are reported with "degraded"
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, buildHealth(clock.Now()))
+}
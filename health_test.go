@@ -0,0 +1,78 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthBackupStatus(t *testing.T) {
+	app := newTestApp(t, "health", []string{"Open"})
+	defer closeTestApp(app)
+	resetStatus := func() {
+		backupStatus = BackupStatus{}
+		appBackupStatus = make(map[string]*BackupStatus)
+		backupStatusSince = time.Now()
+	}
+	resetStatus()
+	defer resetStatus()
+
+	getHealth := func() *Health {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+		if rr.Code != 200 {
+			t.Fatalf("got status %d, expected 200", rr.Code)
+		}
+		var res Health
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("json.Unmarshal() failed with %s", err)
+		}
+		return &res
+	}
+
+	// backups not enabled
+	if h := getHealth(); !h.Ok || h.Degraded || h.Backup != nil {
+		t.Fatalf("unexpected health %#v", h)
+	}
+
+	bs := newFakeBackupStore()
+	backupStore = bs
+	defer func() { backupStore = nil }()
+	config := &BackupConfig{S3Dir: "apptranslator", LocalDir: app.DataDir}
+	if _, err := doBackup([]*BackupTarget{{Config: config, Store: bs}}, true); err != nil {
+		t.Fatalf("doBackup() failed with %s", err)
+	}
+	h := getHealth()
+	if h.Degraded || h.Backup == nil || h.Backup.LastSuccess == nil || h.Backup.Failed {
+		t.Fatalf("unexpected health after backup %#v", h)
+	}
+
+	// a failed backup
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	if _, err := doBackup([]*BackupTarget{{Config: config, Store: &failingBackupStore{bs}}}, true); err == nil {
+		t.Fatalf("doBackup() should fail")
+	}
+	if h = getHealth(); !h.Degraded || !h.Backup.Failed || h.Backup.Error == "" {
+		t.Fatalf("expected failed backup in %#v", h.Backup)
+	}
+
+	// a successful backup, but the last backup of the app is stale
+	if _, err := doBackup([]*BackupTarget{{Config: config, Store: bs}}, true); err != nil {
+		t.Fatalf("doBackup() failed with %s", err)
+	}
+	if h = getHealth(); h.Degraded {
+		t.Fatalf("unexpected degraded health %#v", h.Backup)
+	}
+	appBackupStatus[app.Name].LastSuccess = time.Now().Add(-3 * backupFreq)
+	h = getHealth()
+	if !h.Ok || !h.Degraded || h.Backup.Overdue {
+		t.Fatalf("expected degraded health with stale app backup %#v", h)
+	}
+	for _, ah := range h.Apps {
+		if ah.Name == app.Name && (ah.Backup == nil || !ah.Backup.Overdue) {
+			t.Fatalf("expected overdue backup of %s, got %#v", app.Name, ah.Backup)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// APIEdit is an edit of a translation, returned by json api
+type APIEdit struct {
+	Lang        string    `json:"lang"`
+	User        string    `json:"user"`
+	Translation string    `json:"translation"`
+	Note        string    `json:"note,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// APIHistory is a page of edits of translations of a string, most recent
+// first
+type APIHistory struct {
+	App    string `json:"app"`
+	Source string `json:"source"`
+	// number of edits matching the filters, on all pages
+	Total  int       `json:"total"`
+	Offset int       `json:"offset"`
+	Limit  int       `json:"limit"`
+	Edits  []APIEdit `json:"edits"`
+}
+
+// filterEdits returns edits by user into lang, all users or languages if
+// they are empty
+func filterEdits(edits []store.Edit, user, lang string) []store.Edit {
+	res := make([]store.Edit, 0, len(edits))
+	for _, e := range edits {
+		if (user == "" || e.User == user) && (lang == "" || e.Lang == lang) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// url: GET /api/v1/apps/{name}/history?src=$src[&context=$context][&user=$user][&lang=$lang][&offset=$offset][&limit=$limit]
+// Returns edits of translations of a source string, most recent first,
+// optionally only by user or into lang. limit edits are returned, starting
+// at offset. Also works for strings that are no longer translated
+func handleAPIAppHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	app := getAPIApp(w, r)
+	if app == nil {
+		return
+	}
+	src := r.FormValue("src")
+	if src == "" {
+		serveJSONError(w, http.StatusBadRequest, "Missing src argument")
+		return
+	}
+	lang := strings.TrimSpace(r.FormValue("lang"))
+	if lang != "" {
+		var err error
+		if lang, err = store.ParseLangCode(lang); err != nil {
+			serveJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	offset, err := formIntArg(r, "offset", 0)
+	if err != nil {
+		serveJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := formIntArg(r, "limit", defaultHistoryLimit)
+	if err != nil || limit == 0 {
+		serveJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit %q", r.FormValue("limit")))
+		return
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	key := store.ContextKey(src, r.FormValue("context"))
+	// history of strings that were deleted is still available
+	if !app.store.HasString(key) {
+		serveJSONError(w, http.StatusNotFound, fmt.Sprintf("String %q doesn't exist", src))
+		return
+	}
+	edits := filterEdits(app.store.StringHistory(key), strings.TrimSpace(r.FormValue("user")), lang)
+	res := &APIHistory{
+		App:    app.Name,
+		Source: src,
+		Total:  len(edits),
+		Offset: offset,
+		Limit:  limit,
+		Edits:  []APIEdit{},
+	}
+	for i := offset; i < len(edits) && i < offset+limit; i++ {
+		e := edits[i]
+		res.Edits = append(res.Edits, APIEdit{Lang: e.Lang, User: e.User, Translation: e.Translation, Note: e.Note, Time: e.Time.UTC()})
+	}
+	serveJSON(w, res)
+}
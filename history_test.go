@@ -0,0 +1,94 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIHistory(t *testing.T) {
+	app := newTestApp(t, "history", []string{"Open"})
+	defer closeTestApp(app)
+	// oldest first: pl by user1 and user2 in turns, then de by user1
+	for i := 0; i < 6; i++ {
+		user := []string{"user1", "user2"}[i%2]
+		writeTestTranslation(t, app, "Open", fmt.Sprintf("Otwórz %d", i), "pl", user)
+	}
+	writeTestTranslation(t, app, "Open", "Öffnen", "de", "user1")
+
+	getHistory := func(query string) (int, *APIHistory) {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/history/history?src=Open"+query, nil))
+		var res APIHistory
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+	translations := func(h *APIHistory) []string {
+		res := []string{}
+		for _, e := range h.Edits {
+			res = append(res, e.Translation)
+		}
+		return res
+	}
+
+	code, h := getHistory("")
+	if code != 200 || h.Total != 7 || len(h.Edits) != 7 || h.Edits[0].Translation != "Öffnen" || h.Limit != defaultHistoryLimit {
+		t.Fatalf("got status %d, %#v", code, h)
+	}
+
+	// newest first, filtered by user
+	_, h = getHistory("&user=user2")
+	if got := fmt.Sprint(translations(h)); h.Total != 3 || got != "[Otwórz 5 Otwórz 3 Otwórz 1]" {
+		t.Fatalf("got %d edits %s", h.Total, got)
+	}
+	// filtered by lang
+	_, h = getHistory("&lang=de_DE")
+	if h.Total != 1 || len(h.Edits) != 1 || h.Edits[0].User != "user1" {
+		t.Fatalf("got %#v", h)
+	}
+	_, h = getHistory("&lang=pl&user=user1")
+	if got := fmt.Sprint(translations(h)); got != "[Otwórz 4 Otwórz 2 Otwórz 0]" {
+		t.Fatalf("got %s", got)
+	}
+
+	// paging
+	for _, c := range []struct {
+		query string
+		exp   string
+	}{
+		{"&lang=pl&limit=2", "[Otwórz 5 Otwórz 4]"},
+		{"&lang=pl&limit=2&offset=2", "[Otwórz 3 Otwórz 2]"},
+		{"&lang=pl&limit=2&offset=4", "[Otwórz 1 Otwórz 0]"},
+		{"&lang=pl&limit=4&offset=5", "[Otwórz 0]"},
+		{"&lang=pl&offset=6", "[]"},
+		{"&lang=pl&offset=100", "[]"},
+	} {
+		code, h = getHistory(c.query)
+		if got := fmt.Sprint(translations(h)); code != 200 || h.Total != 6 || got != c.exp {
+			t.Errorf("%s: got status %d, total %d, %s, expected %s", c.query, code, h.Total, got, c.exp)
+		}
+	}
+
+	for _, query := range []string{"&offset=-1", "&limit=0", "&limit=x", "&lang=x!"} {
+		if code, _ = getHistory(query); code != 400 {
+			t.Errorf("%s: got status %d, expected 400", query, code)
+		}
+	}
+	if code, _ = getHistory("x"); code != 404 {
+		t.Errorf("got status %d for missing string, expected 404", code)
+	}
+
+	// history of a deleted string is kept
+	if _, _, _, err := app.store.UpdateStringsList([]string{"Close"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	if code, h = getHistory(""); code != 200 || h.Total != 7 {
+		t.Fatalf("got status %d, %#v for a deleted string", code, h)
+	}
+}
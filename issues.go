@@ -0,0 +1,348 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// kinds of issues
+const (
+	issuePlaceholder = "placeholder"
+	issueMarkup      = "markup"
+	issueGlossary    = "glossary"
+	issueMaxLen      = "maxlen"
+)
+
+// Issue describes a problem with a translation
+type Issue struct {
+	Kind        string `json:"kind"`
+	Lang        string `json:"lang"`
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+	Msg         string `json:"msg"`
+}
+
+// matches printf-style placeholders (%s, %d, %.2f, %1$s, %%) and
+// {0}/{name} style placeholders. Space flag is not supported, so that "100%
+// done" doesn't have a placeholder "% d"
+var rePlaceholder = regexp.MustCompile(`%%|%(\d+\$)?[-+#0]*\d*(\.\d+)?(hh|h|ll|l|L|q|j|z|t)?[diouxXeEfFgGaAcspSn@]|\{[A-Za-z0-9_]+\}`)
+
+func extractPlaceholders(s string) []string {
+	var res []string
+	for _, ph := range rePlaceholder.FindAllString(s, -1) {
+		if ph != "%%" {
+			res = append(res, ph)
+		}
+	}
+	return res
+}
+
+// printf-style placeholders without explicit position must be in the same
+// order in source and translation
+func isOrderedPlaceholder(ph string) bool {
+	return strings.HasPrefix(ph, "%") && !strings.Contains(ph, "$")
+}
+
+func filterOrderedPlaceholders(phs []string) []string {
+	var res []string
+	for _, ph := range phs {
+		if isOrderedPlaceholder(ph) {
+			res = append(res, ph)
+		}
+	}
+	return res
+}
+
+// placeholderProblems compares placeholders in source string and its
+// translation and returns a description of each mismatch
+func placeholderProblems(source, translation string) []string {
+	inSource := extractPlaceholders(source)
+	inTrans := extractPlaceholders(translation)
+	var res []string
+	missing, extra := diffMultisets(inSource, inTrans)
+	for _, ph := range missing {
+		res = append(res, fmt.Sprintf("missing %s", ph))
+	}
+	for _, ph := range extra {
+		res = append(res, fmt.Sprintf("extra %s", ph))
+	}
+	if len(res) > 0 {
+		return res
+	}
+	ordered1 := filterOrderedPlaceholders(inSource)
+	ordered2 := filterOrderedPlaceholders(inTrans)
+	if strings.Join(ordered1, " ") != strings.Join(ordered2, " ") {
+		res = append(res, fmt.Sprintf("placeholders in different order, expected %s", strings.Join(ordered1, " ")))
+	}
+	return res
+}
+
+// checkTranslations returns an issue of a given kind for each problem
+// reported by checkFn for current translations in a given language
+func (a *App) checkTranslations(lang, kind string, checkFn func(source, translation string) []string) []Issue {
+	var res []Issue
+	for _, li := range a.store.LangInfos() {
+		if li.Code != lang {
+			continue
+		}
+		for _, tr := range li.ActiveStrings {
+			trans := tr.Current()
+			if trans == "" {
+				continue
+			}
+			for _, msg := range checkFn(tr.String, trans) {
+				issue := Issue{
+					Kind:        kind,
+					Lang:        lang,
+					Source:      tr.String,
+					Translation: trans,
+					Msg:         msg,
+				}
+				res = append(res, issue)
+			}
+		}
+	}
+	return res
+}
+
+var reTag = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)[^<>]*?(/?)>`)
+
+// tags that don't have a closing tag
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true, "input": true, "wbr": true}
+
+type markupInfo struct {
+	// opening and void tags, in order
+	tags []string
+	// "parent>child" for each tag nested in another tag
+	nesting []string
+	// problems with balancing of tags
+	problems []string
+}
+
+func parseMarkup(s string) *markupInfo {
+	res := &markupInfo{}
+	var stack []string
+	for _, m := range reTag.FindAllStringSubmatch(s, -1) {
+		isClose, name, isSelfClose := m[1] == "/", strings.ToLower(m[2]), m[3] == "/"
+		if !isClose {
+			res.tags = append(res.tags, name)
+			if len(stack) > 0 {
+				res.nesting = append(res.nesting, stack[len(stack)-1]+">"+name)
+			}
+			if !isSelfClose && !voidTags[name] {
+				stack = append(stack, name)
+			}
+			continue
+		}
+		if len(stack) == 0 {
+			res.problems = append(res.problems, fmt.Sprintf("unexpected </%s>", name))
+			continue
+		}
+		top := stack[len(stack)-1]
+		if top != name {
+			res.problems = append(res.problems, fmt.Sprintf("</%s> closes <%s>", name, top))
+		}
+		stack = stack[:len(stack)-1]
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		res.problems = append(res.problems, fmt.Sprintf("unclosed <%s>", stack[i]))
+	}
+	return res
+}
+
+// diffMultisets returns elements of a1 missing from a2 and elements
+// of a2 not in a1, taking number of occurrences into account
+func diffMultisets(a1, a2 []string) ([]string, []string) {
+	counts := make(map[string]int)
+	for _, s := range a1 {
+		counts[s]++
+	}
+	for _, s := range a2 {
+		counts[s]--
+	}
+	var missing, extra []string
+	// iterate over slices, not the map, for a stable order
+	for _, s := range a1 {
+		if counts[s] > 0 {
+			missing = append(missing, s)
+			counts[s]--
+		}
+	}
+	for _, s := range a2 {
+		if counts[s] < 0 {
+			extra = append(extra, s)
+			counts[s]++
+		}
+	}
+	return missing, extra
+}
+
+// markupProblems compares html tags in source string and its translation
+// and returns a description of each mismatch
+func markupProblems(source, translation string) []string {
+	m1 := parseMarkup(source)
+	m2 := parseMarkup(translation)
+	var res []string
+	// if source isn't well-formed, translation can't be expected to be
+	if len(m1.problems) == 0 {
+		res = append(res, m2.problems...)
+	}
+	missing, extra := diffMultisets(m1.tags, m2.tags)
+	for _, tag := range missing {
+		res = append(res, fmt.Sprintf("missing <%s>", tag))
+	}
+	for _, tag := range extra {
+		res = append(res, fmt.Sprintf("extra <%s>", tag))
+	}
+	if len(res) > 0 {
+		return res
+	}
+	missing, extra = diffMultisets(m1.nesting, m2.nesting)
+	if len(missing) > 0 || len(extra) > 0 {
+		res = append(res, "tags are nested differently")
+	}
+	return res
+}
+
+// maxLenProblem returns a description of the problem if translation is
+// longer than maxLen characters. maxLen of 0 means there is no limit
+func maxLenProblem(maxLen int, translation string) string {
+	n := utf8.RuneCountInString(translation)
+	if maxLen <= 0 || n <= maxLen {
+		return ""
+	}
+	return fmt.Sprintf("translation has %d characters, maximum is %d", n, maxLen)
+}
+
+// CheckMaxLen returns translations in a given language that are longer
+// than allowed for the source string
+func (a *App) CheckMaxLen(lang string) []Issue {
+	return a.checkTranslations(lang, issueMaxLen, func(source, translation string) []string {
+		if msg := maxLenProblem(a.store.MaxLen(source), translation); msg != "" {
+			return []string{msg}
+		}
+		return nil
+	})
+}
+
+// CheckPlaceholders returns translations in a given language whose
+// placeholders don't match placeholders of the source string
+func (a *App) CheckPlaceholders(lang string) []Issue {
+	return a.checkTranslations(lang, issuePlaceholder, placeholderProblems)
+}
+
+// CheckMarkup returns translations in a given language whose html tags
+// don't match tags in the source string
+func (a *App) CheckMarkup(lang string) []Issue {
+	return a.checkTranslations(lang, issueMarkup, markupProblems)
+}
+
+// issuesCache are issues found in translations of an app, by language,
+// computed from a given generation of its store
+type issuesCache struct {
+	generation int
+	byLang     map[string][]Issue
+}
+
+// Issues returns problems with translations in a given language. Checks are
+// expensive, so issues are cached until the next change to the store or
+// glossary of the app or revalidation with Revalidate()
+func (a *App) Issues(lang string) []Issue {
+	gen := a.store.Generation()
+	a.mu.Lock()
+	if c := a.issues; c != nil && c.generation == gen {
+		if res, ok := c.byLang[lang]; ok {
+			a.mu.Unlock()
+			return res
+		}
+	}
+	a.mu.Unlock()
+
+	res := a.findIssues(lang)
+	a.mu.Lock()
+	if a.issues == nil || a.issues.generation != gen {
+		a.issues = &issuesCache{generation: gen, byLang: make(map[string][]Issue)}
+	}
+	a.issues.byLang[lang] = res
+	a.mu.Unlock()
+	return res
+}
+
+// Revalidate runs all checks of translations in all languages again,
+// replacing cached issues, and returns them
+func (a *App) Revalidate() []Issue {
+	a.mu.Lock()
+	a.issues = nil
+	a.mu.Unlock()
+	return a.AllIssues()
+}
+
+// findIssues runs all checks of translations in a given language
+func (a *App) findIssues(lang string) []Issue {
+	res := a.CheckPlaceholders(lang)
+	res = append(res, a.CheckMarkup(lang)...)
+	res = append(res, a.CheckMaxLen(lang)...)
+	return append(res, a.CheckGlossary(lang)...)
+}
+
+// AllIssues returns problems with translations in all languages
+func (a *App) AllIssues() []Issue {
+	var res []Issue
+	for _, li := range a.store.LangInfos() {
+		res = append(res, a.Issues(li.Code)...)
+	}
+	return res
+}
+
+// issuesBySource groups issue messages by source string, for showing them
+// next to translations in the UI
+func issuesBySource(issues []Issue) map[string][]string {
+	res := make(map[string][]string)
+	for _, issue := range issues {
+		res[issue.Source] = append(res[issue.Source], issue.Msg)
+	}
+	return res
+}
+
+// RevalidateResult is returned by /admin/revalidate/{appname}
+type RevalidateResult struct {
+	App string `json:"app"`
+	// number of issues of each kind
+	Issues map[string]int `json:"issues"`
+}
+
+// url: POST /admin/revalidate/{appname}
+// Checks all translations of the app again and caches found issues, which
+// are then served by the issues api and pages without checking again.
+// Needed after changes that checks depend on, but which are not changes
+// of the store, like changes of config
+func handleAdminRevalidate(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", appName)
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't revalidate this app")
+		return
+	}
+	res := &RevalidateResult{App: app.Name, Issues: make(map[string]int)}
+	for _, kind := range []string{issuePlaceholder, issueMarkup, issueGlossary, issueMaxLen} {
+		res.Issues[kind] = 0
+	}
+	for _, issue := range app.Revalidate() {
+		res.Issues[issue.Kind]++
+	}
+	logger.ForRequest(r).Noticef("handleAdminRevalidate(): %s has %v issues", app.Name, res.Issues)
+	serveJSON(w, res)
+}
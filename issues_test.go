@@ -0,0 +1,190 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPlaceholderProblems(t *testing.T) {
+	tests := []struct {
+		source      string
+		translation string
+		exp         []string
+	}{
+		{"Page %d of %d", "Strona %d z %d", nil},
+		{"100%% done", "100%% gotowe", nil},
+		// a single % followed by a space is not a placeholder
+		{"100% done", "Gotowe w 100%", nil},
+		{"Done in 50% of cases", "W 50% przypadków", nil},
+		{"Open %s", "Otwórz", []string{"missing %s"}},
+		{"Open {0}", "Otwórz", []string{"missing {0}"}},
+		{"Open", "Otwórz %s", []string{"extra %s"}},
+		{"Open {name}", "Otwórz {name} {name}", []string{"extra {name}"}},
+		{"%s of %d", "%d z %s", []string{"placeholders in different order, expected %s %d"}},
+		// positional and named placeholders can be re-ordered
+		{"%1$s of %2$d", "%2$d z %1$s", nil},
+		{"{0} of {1}", "{1} z {0}", nil},
+	}
+	for _, test := range tests {
+		got := placeholderProblems(test.source, test.translation)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("%q => %q: got %#v, expected %#v", test.source, test.translation, got, test.exp)
+		}
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	app := newTestApp(t, "issues", []string{"Open %s", "Close"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open %s", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, app, "Open %s", "Öffnen %s", "de", "user1")
+
+	if issues := app.CheckPlaceholders("de"); len(issues) != 0 {
+		t.Fatalf("unexpected issues %#v", issues)
+	}
+	issues := app.CheckPlaceholders("pl")
+	if len(issues) != 1 || issues[0].Source != "Open %s" || issues[0].Kind != issuePlaceholder {
+		t.Fatalf("unexpected issues %#v", issues)
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/issues/issues", nil))
+	var res struct {
+		Issues []Issue `json:"issues"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	if !reflect.DeepEqual(res.Issues, issues) {
+		t.Fatalf("got %#v, expected %#v", res.Issues, issues)
+	}
+}
+
+func TestMaxLenProblem(t *testing.T) {
+	tests := []struct {
+		maxLen      int
+		translation string
+		exp         string
+	}{
+		// at the limit
+		{5, "Start", ""},
+		{6, "Otwórz", ""},
+		// over the limit, counted in characters, not bytes
+		{5, "Otwórz", "translation has 6 characters, maximum is 5"},
+		{2, "日本語", "translation has 3 characters, maximum is 2"},
+		// no limit
+		{0, "Otwórz", ""},
+	}
+	for _, test := range tests {
+		if got := maxLenProblem(test.maxLen, test.translation); got != test.exp {
+			t.Fatalf("%d, %q: got %q, expected %q", test.maxLen, test.translation, got, test.exp)
+		}
+	}
+}
+
+func TestCheckMaxLen(t *testing.T) {
+	app := newTestApp(t, "maxlen", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	if err := app.store.SetMaxLen("Open", 6); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+	if err := app.store.SetMaxLen("Close", 6); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+	// "Otwórz" has 7 bytes but 6 characters
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, app, "Save", "Zapisz plik na dysku", "pl", "user1")
+
+	issues := app.CheckMaxLen("pl")
+	if len(issues) != 1 || issues[0].Source != "Close" || issues[0].Kind != issueMaxLen {
+		t.Fatalf("unexpected issues %#v", issues)
+	}
+}
+
+func TestMarkupProblems(t *testing.T) {
+	tests := []struct {
+		source      string
+		translation string
+		exp         []string
+	}{
+		{"<b>Open</b> file", "<b>Otwórz</b> plik", nil},
+		{"Line<br>break", "Linia<br/>łamana", nil},
+		{`See <a href="x">help</a>`, `Zobacz <a href="y">pomoc</a>`, nil},
+		{"<b>Open</b> file", "<b>Otwórz plik", []string{"unclosed <b>"}},
+		{"<b>Open</b> file", "Otwórz</b> plik", []string{"unexpected </b>", "missing <b>"}},
+		{"Open file", "<i>Otwórz</i> plik", []string{"extra <i>"}},
+		{"<b>Open</b> file", "<b>Otwórz</b> <b>plik</b>", []string{"extra <b>"}},
+		{"<b><i>Open</i></b>", "<i><b>Otwórz</b></i>", []string{"tags are nested differently"}},
+		{"<b><i>Open</i></b>", "<b><i>Otwórz</b></i>", []string{"</b> closes <i>", "</i> closes <b>"}},
+	}
+	for _, test := range tests {
+		got := markupProblems(test.source, test.translation)
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("%q => %q: got %#v, expected %#v", test.source, test.translation, got, test.exp)
+		}
+	}
+}
+
+func TestIssuesCache(t *testing.T) {
+	app := newTestApp(t, "issuescache", []string{"Open file", "Close"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open file", "Otwórz plik", "pl", "user1")
+
+	if issues := app.Issues("pl"); len(issues) != 0 {
+		t.Fatalf("unexpected issues %#v", issues)
+	}
+	// changes that are not changes of the store or glossary are not seen
+	// until revalidation
+	app.mu.Lock()
+	app.glossary = Glossary{"pl": {"file": "dokument"}}
+	app.mu.Unlock()
+	if issues := app.Issues("pl"); len(issues) != 0 {
+		t.Fatalf("issues were not cached, got %#v", issues)
+	}
+
+	revalidate := func(user string) (int, *RevalidateResult) {
+		r := newRequestWithCookie("POST", "/admin/revalidate/issuescache", &SecureCookieValue{User: user})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		var res RevalidateResult
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+	if code, _ := revalidate("user1"); code != 400 {
+		t.Fatalf("got status %d for not admin, expected 400", code)
+	}
+	code, res := revalidate("admin")
+	if code != 200 || res.Issues[issueGlossary] != 1 || res.Issues[issuePlaceholder] != 0 {
+		t.Fatalf("got status %d, %#v", code, res)
+	}
+	issues := app.Issues("pl")
+	if len(issues) != 1 || issues[0].Kind != issueGlossary {
+		t.Fatalf("unexpected issues %#v after revalidation", issues)
+	}
+
+	// edits invalidate the cache
+	app.mu.Lock()
+	app.glossary = nil
+	app.mu.Unlock()
+	if issues := app.Issues("pl"); len(issues) != 1 {
+		t.Fatalf("issues were not cached, got %#v", issues)
+	}
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+	if issues := app.Issues("pl"); len(issues) != 0 {
+		t.Fatalf("cached issues %#v after an edit", issues)
+	}
+	// and so do changes of the glossary
+	app.SetGlossary(Glossary{"pl": {"file": "dokument"}})
+	if issues := app.Issues("pl"); len(issues) != 1 {
+		t.Fatalf("got issues %#v after changing the glossary", issues)
+	}
+}
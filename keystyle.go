@@ -0,0 +1,97 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// keyStyles transform source strings used as keys in exported files to the
+// style expected by a platform, e.g. snake_case. Stored strings don't change
+var keyStyles = map[string]func(string) string{
+	"none":       func(s string) string { return s },
+	"snake":      snakeCaseKey,
+	"camel":      camelCaseKey,
+	"dotToSlash": func(s string) string { return strings.Replace(s, ".", "/", -1) },
+}
+
+func isValidKeyStyle(style string) bool {
+	_, ok := keyStyles[style]
+	return ok
+}
+
+// keyStyleProblems returns descriptions of sources that can't be exported
+// with key style: those that become an empty key and those that become the
+// same key as another source. Sorted by key
+func keyStyleProblems(sources []string, style string) []string {
+	transform := keyStyles[style]
+	if transform == nil {
+		return nil
+	}
+	byKey := make(map[string][]string)
+	for _, src := range sources {
+		key := transform(src)
+		byKey[key] = append(byKey[key], src)
+	}
+	var res []string
+	for key, srcs := range byKey {
+		if key == "" {
+			res = append(res, fmt.Sprintf("%q become an empty key", srcs))
+		} else if len(srcs) > 1 {
+			res = append(res, fmt.Sprintf("%q become the same key %q", srcs, key))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// keyWords splits s into words, which are separated by characters other
+// than letters and digits and by changes from lower to upper case letter,
+// as in "openFile"
+func keyWords(s string) []string {
+	var res []string
+	var word []rune
+	prev := rune(0)
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				res = append(res, string(word))
+				word = nil
+			}
+			prev = r
+			continue
+		}
+		if unicode.IsUpper(r) && unicode.IsLower(prev) && len(word) > 0 {
+			res = append(res, string(word))
+			word = nil
+		}
+		word = append(word, r)
+		prev = r
+	}
+	if len(word) > 0 {
+		res = append(res, string(word))
+	}
+	return res
+}
+
+// snakeCaseKey returns s as snake case, e.g. "Open file" is "open_file"
+func snakeCaseKey(s string) string {
+	return strings.ToLower(strings.Join(keyWords(s), "_"))
+}
+
+// camelCaseKey returns s as camel case, e.g. "Open file" is "openFile"
+func camelCaseKey(s string) string {
+	words := keyWords(s)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if i > 0 {
+			r := []rune(word)
+			r[0] = unicode.ToUpper(r[0])
+			word = string(r)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
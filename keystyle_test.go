@@ -0,0 +1,106 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyStyles(t *testing.T) {
+	tests := []struct {
+		src                      string
+		snake, camel, dotToSlash string
+	}{
+		{"Open file", "open_file", "openFile", "Open file"},
+		{"menu.file.saveAs", "menu_file_save_as", "menuFileSaveAs", "menu/file/saveAs"},
+		{"  Save all files...", "save_all_files", "saveAllFiles", "  Save all files///"},
+		{"PDF viewer 2", "pdf_viewer_2", "pdfViewer2", "PDF viewer 2"},
+		{"Zażółć gęślą", "zażółć_gęślą", "zażółćGęślą", "Zażółć gęślą"},
+		{"", "", "", ""},
+	}
+	for _, test := range tests {
+		got := []string{keyStyles["snake"](test.src), keyStyles["camel"](test.src), keyStyles["dotToSlash"](test.src)}
+		exp := []string{test.snake, test.camel, test.dotToSlash}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("%q: got %q, expected %q", test.src, got, exp)
+		}
+		if got := keyStyles["none"](test.src); got != test.src {
+			t.Errorf("%q: got %q with style none", test.src, got)
+		}
+		// transforming keys again doesn't change them
+		for style, transform := range keyStyles {
+			key := transform(test.src)
+			if again := transform(key); again != key {
+				t.Errorf("%s: %q became %q and then %q", style, test.src, key, again)
+			}
+		}
+	}
+	if isValidKeyStyle("kebab") {
+		t.Fatalf("kebab is not a valid key style")
+	}
+}
+
+func TestKeyStyleProblems(t *testing.T) {
+	sources := []string{"Open file", "open_file", "openFile", "...", "Save as"}
+	exp := []string{
+		`["..."] become an empty key`,
+		`["Open file" "open_file" "openFile"] become the same key "open_file"`,
+	}
+	if got := keyStyleProblems(sources, "snake"); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %q, expected %q", got, exp)
+	}
+	for _, style := range []string{"", "none"} {
+		if got := keyStyleProblems(sources, style); len(got) != 0 {
+			t.Fatalf("got %q for style %q", got, style)
+		}
+	}
+}
+
+func TestExportKeyStyle(t *testing.T) {
+	app := newTestApp(t, "keystyle", []string{"Open file", "Open, Close", "Save as"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open file", "Otwórz plik", "pl", "user1")
+	writeTestTranslation(t, app, "Save as", "Zapisz jako", "pl", "user1")
+
+	entries := exportEntries(app, "pl", &ExportOptions{KeyStyle: "snake"})
+	// sorted by keys, not by source strings
+	exp := []TransEntry{
+		{"pl", "open_close", "", false},
+		{"pl", "open_file", "Otwórz plik", false},
+		{"pl", "save_as", "Zapisz jako", false},
+	}
+	if !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, expected %#v", entries, exp)
+	}
+	// stored strings don't change
+	if !app.store.IsActiveString("Open file") || app.store.IsActiveString("save_as") {
+		t.Fatalf("export changed stored strings")
+	}
+	none := exportEntries(app, "pl", &ExportOptions{KeyStyle: "none"})
+	if !reflect.DeepEqual(none, exportEntries(app, "pl", &ExportOptions{})) || none[0].Source != "Open file" {
+		t.Fatalf("keystyle none changed the export: %#v", none)
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=keystyle&lang=pl&format=json&keystyle=camel", nil))
+	if rr.Code != 200 || rr.Body.String() != "{\n  \"pl\": {\n    \"openClose\": \"\",\n    \"openFile\": \"Otwórz plik\",\n    \"saveAs\": \"Zapisz jako\"\n  }\n}" {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=keystyle&lang=pl&format=json&keystyle=kebab", nil))
+	if rr.Code != 400 {
+		t.Fatalf("got status %d for invalid keystyle, expected 400", rr.Code)
+	}
+
+	// strings that become the same key are not exported
+	if _, _, _, err := app.store.UpdateStringsList([]string{"Open file", "open_file", "Save as"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	rr = httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=keystyle&lang=pl&format=json&keystyle=snake", nil))
+	if rr.Code != 400 || !strings.Contains(rr.Body.String(), `["Open file" "open_file"] become the same key "open_file"`) {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+}
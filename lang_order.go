@@ -0,0 +1,56 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func validateLanguageOrder(langs []string) error {
+	seen := make(map[string]bool)
+	for _, lang := range langs {
+		code, err := store.ParseLangCode(lang)
+		if err != nil {
+			return err
+		}
+		if seen[code] {
+			return fmt.Errorf("duplicate lang code %q", lang)
+		}
+		seen[code] = true
+	}
+	return nil
+}
+
+// normalizeLanguageOrder returns a copy of langs with normalized codes, see
+// store.NormalizeLangCode
+func normalizeLanguageOrder(langs []string) []string {
+	if langs == nil {
+		return nil
+	}
+	res := make([]string, len(langs))
+	for i, lang := range langs {
+		res[i] = store.NormalizeLangCode(lang)
+	}
+	return res
+}
+
+// langPriority returns position of lang in LanguageOrder of the app, or
+// len(LanguageOrder) if it's not there
+func (a *App) langPriority(lang string) int {
+	for i, l := range a.LanguageOrder {
+		if l == lang {
+			return i
+		}
+	}
+	return len(a.LanguageOrder)
+}
+
+// sortLangsByPriority moves languages from LanguageOrder of the app to the
+// front, in that order. Order of other languages doesn't change
+func (a *App) sortLangsByPriority(langs []*store.LangInfo) {
+	sort.SliceStable(langs, func(i, j int) bool {
+		return a.langPriority(langs[i].Code) < a.langPriority(langs[j].Code)
+	})
+}
@@ -0,0 +1,62 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAppsSkipsFailedApps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loadapps")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"good", "good2"} {
+		if err = os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatalf("os.Mkdir() failed with %s", err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, name, "translations.csv"), nil, 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+	}
+	savedDataDir, savedApps := dataDir, appState.Apps
+	defer func() {
+		for _, app := range appState.Apps[len(savedApps):] {
+			app.closeStores()
+		}
+		dataDir, appState.Apps, appState.FailedApps = savedDataDir, savedApps, nil
+	}()
+	dataDir = dir
+
+	loadApps([]AppConfig{
+		{Name: "good", DataDir: "good", AdminTwitterUser: "admin", UploadSecret: "secret"},
+		// data file doesn't exist
+		{Name: "nodata", DataDir: "nodata", AdminTwitterUser: "admin", UploadSecret: "secret"},
+		{Name: "nosecret", DataDir: "good2", AdminTwitterUser: "admin"},
+		{Name: "good2", DataDir: "good2", AdminTwitterUser: "admin", UploadSecret: "secret"},
+	})
+	if findApp("good") == nil || findApp("good2") == nil {
+		t.Fatalf("good apps were not loaded")
+	}
+	if findApp("nodata") != nil || findApp("nosecret") != nil {
+		t.Fatalf("bad apps were loaded")
+	}
+	if len(appState.FailedApps) != 2 || appState.FailedApps[0].Name != "nodata" || appState.FailedApps[1].Name != "nosecret" {
+		t.Fatalf("got failed apps %#v", appState.FailedApps)
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	var h Health
+	if err = json.Unmarshal(rr.Body.Bytes(), &h); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	if !h.Ok || !h.Degraded || len(h.FailedApps) != 2 || h.FailedApps[1].Error == "" {
+		t.Fatalf("unexpected health %#v", h)
+	}
+}
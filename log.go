@@ -46,7 +46,7 @@ func NewCircularMessagesBuf(cap int) *CircularMessagesBuf {
 }
 
 func (b *CircularMessagesBuf) Add(s string) {
-	var msg = TimestampedMsg{time.Now(), s}
+	var msg = TimestampedMsg{clock.Now(), s}
 	if b.pos == cap(b.Msgs) {
 		b.pos = 0
 		b.full = true
@@ -71,6 +71,16 @@ func (b *CircularMessagesBuf) GetOrdered() []*TimestampedMsg {
 	return res
 }
 
+const defaultLogBufferSize = 256
+
+// logBufferSize returns configured size of a log buffer or the default
+func logBufferSize(configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	return defaultLogBufferSize
+}
+
 type ServerLogger struct {
 	Errors    *CircularMessagesBuf
 	Notices   *CircularMessagesBuf
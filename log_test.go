@@ -0,0 +1,38 @@
+// This code is in Public Domain. Take all the code you want, I'll just write more.
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLogBufferSize(t *testing.T) {
+	defer func() { config.LogNoticeBuffer, config.LogErrorBuffer = 0, 0 }()
+
+	n := defaultLogBufferSize * 2
+	fill := func(l *ServerLogger) {
+		for i := 0; i < n; i++ {
+			l.Errors.Add(fmt.Sprintf("error %d", i))
+			l.Notices.Add(fmt.Sprintf("notice %d", i))
+		}
+	}
+
+	l := NewServerLogger(logBufferSize(config.LogErrorBuffer), logBufferSize(config.LogNoticeBuffer), false)
+	fill(l)
+	if got := len(l.Notices.GetOrdered()); got != defaultLogBufferSize {
+		t.Fatalf("got %d notices, expected %d", got, defaultLogBufferSize)
+	}
+
+	config.LogNoticeBuffer = n
+	config.LogErrorBuffer = 10
+	l = NewServerLogger(logBufferSize(config.LogErrorBuffer), logBufferSize(config.LogNoticeBuffer), false)
+	fill(l)
+	notices := l.Notices.GetOrdered()
+	if len(notices) != n || notices[n-1].Msg != "notice 0" {
+		t.Fatalf("got %d notices, expected %d with the oldest kept", len(notices), n)
+	}
+	errors := l.Errors.GetOrdered()
+	if len(errors) != 10 || errors[0].Msg != fmt.Sprintf("error %d", n-1) {
+		t.Fatalf("got %d errors, expected the latest 10", len(errors))
+	}
+}
@@ -0,0 +1,102 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// after that many failed logins from an ip address within
+	// loginFailuresWindow, logins from that address are blocked until the
+	// window ends
+	maxLoginFailures    = 10
+	loginFailuresWindow = 15 * time.Minute
+)
+
+type loginFailures struct {
+	start time.Time
+	count int
+}
+
+// loginLimiter counts failed logins per client ip address
+type loginLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]*loginFailures
+}
+
+func newLoginLimiter(max int, window time.Duration) *loginLimiter {
+	return &loginLimiter{
+		max:      max,
+		window:   window,
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+var loginLimit = newLoginLimiter(maxLoginFailures, loginFailuresWindow)
+
+// removeExpired must be called with l.mu locked
+func (l *loginLimiter) removeExpired(now time.Time) {
+	for ip, f := range l.failures {
+		if now.Sub(f.start) >= l.window {
+			delete(l.failures, ip)
+		}
+	}
+}
+
+// IsBlocked returns true if there were too many failed logins from ip
+func (l *loginLimiter) IsBlocked(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.removeExpired(clock.Now())
+	f := l.failures[ip]
+	return f != nil && f.count >= l.max
+}
+
+// AddFailure records a failed login from ip. Returns number of failures
+// in the current window
+func (l *loginLimiter) AddFailure(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := clock.Now()
+	l.removeExpired(now)
+	f := l.failures[ip]
+	if f == nil {
+		f = &loginFailures{start: now}
+		l.failures[ip] = f
+	}
+	f.count++
+	return f.count
+}
+
+// Reset forgets failed logins from ip, after a successful login
+func (l *loginLimiter) Reset(ip string) {
+	l.mu.Lock()
+	delete(l.failures, ip)
+	l.mu.Unlock()
+}
+
+// checkLoginAllowed responds with 429 and returns false if there were too
+// many failed logins from the client
+func checkLoginAllowed(w http.ResponseWriter, r *http.Request) bool {
+	ip := clientIP(r)
+	if !loginLimit.IsBlocked(ip) {
+		return true
+	}
+	logger.ForRequest(r).Noticef("blocked login from %s after too many failed logins", ip)
+	w.Header().Set("Retry-After", strconv.Itoa(int(loginLimit.window/time.Second)))
+	http.Error(w, "Too many failed logins, try again later", http.StatusTooManyRequests)
+	return false
+}
+
+// loginFailed records a failed login from the client
+func loginFailed(r *http.Request) {
+	ip := clientIP(r)
+	if n := loginLimit.AddFailure(ip); n == loginLimit.max {
+		logger.ForRequest(r).Noticef("%d failed logins from %s, blocking logins", n, ip)
+	}
+}
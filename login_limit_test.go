@@ -0,0 +1,51 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestLoginLimit(t *testing.T) {
+	defer func(l *loginLimiter) { loginLimit = l }(loginLimit)
+	loginLimit = newLoginLimiter(3, time.Minute)
+	fake := store.NewFakeClock(time.Now())
+	defer setClock(setClock(fake))
+
+	callback := func(remoteAddr string) int {
+		r := newRequestWithCookie("GET", "/oauthtwittercb?redirect=/&state=bad", &SecureCookieValue{OAuthState: "good"})
+		r.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handleOauthTwitterCallback(rr, r)
+		return rr.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := callback("10.0.0.1:1234"); code != http.StatusBadRequest {
+			t.Fatalf("attempt %d: got status %d, expected %d", i, code, http.StatusBadRequest)
+		}
+	}
+	if code := callback("10.0.0.1:1234"); code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, expected %d", code, http.StatusTooManyRequests)
+	}
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/login?redirect=/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	handleLogin(rr, r)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d, expected %d", rr.Code, http.StatusTooManyRequests)
+	}
+	// other clients are not affected
+	if code := callback("10.0.0.2:1234"); code != http.StatusBadRequest {
+		t.Fatalf("got status %d, expected %d", code, http.StatusBadRequest)
+	}
+
+	fake.Advance(time.Minute)
+	if code := callback("10.0.0.1:1234"); code != http.StatusBadRequest {
+		t.Fatalf("got status %d after the window, expected %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,259 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// translations made by machine translation are attributed to this user
+const machineUser = "machine"
+
+// source strings are in english
+const sourceLang = "en"
+
+const defaultMachineTranslateRate = 5
+
+// Translator translates text using a machine translation service
+type Translator interface {
+	Translate(text, fromLang, toLang string) (string, error)
+}
+
+// machineTranslator is nil if machine translation is not configured
+var machineTranslator Translator
+
+// our language codes are not standard, this maps them to codes used by
+// machine translation services
+var machineLangCodes = map[string]string{
+	"br":    "pt-BR",
+	"by":    "be",
+	"ca-xv": "ca",
+	"cn":    "zh-CN",
+	"cz":    "cs",
+	"dk":    "da",
+	"fy-nl": "fy",
+	"kr":    "ko",
+	"mm":    "my",
+	"my":    "ms",
+	"sp-rs": "sr-Latn",
+	"sr-rs": "sr",
+	"tw":    "zh-TW",
+	"vn":    "vi",
+}
+
+func machineLangCode(lang string) string {
+	if code, ok := machineLangCodes[lang]; ok {
+		return code
+	}
+	return lang
+}
+
+// googleTranslator uses Google Cloud Translation API
+type googleTranslator struct {
+	apiKey string
+	client *http.Client
+}
+
+func newGoogleTranslator(apiKey string) *googleTranslator {
+	return &googleTranslator{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *googleTranslator) Translate(text, fromLang, toLang string) (string, error) {
+	params := url.Values{
+		"key":    {t.apiKey},
+		"q":      {text},
+		"source": {fromLang},
+		"target": {toLang},
+		"format": {"text"},
+	}
+	resp, err := t.client.PostForm("https://translation.googleapis.com/language/translate/v2", params)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	d, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translate api returned status %d, %s", resp.StatusCode, d)
+	}
+	var res struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(d, &res); err != nil {
+		return "", err
+	}
+	if len(res.Data.Translations) == 0 {
+		return "", errors.New("translate api returned no translations")
+	}
+	return res.Data.Translations[0].TranslatedText, nil
+}
+
+// MachineTranslateResult summarizes machine translation of a language
+type MachineTranslateResult struct {
+	Lang   string   `json:"lang"`
+	Filled int      `json:"filled"`
+	Failed int      `json:"failed"`
+	Errors []string `json:"errors"`
+	// set if translation was stopped before all strings were translated
+	Error string `json:"error,omitempty"`
+}
+
+// machineTranslateUntranslated machine translates all untranslated strings
+// of the app into lang. Translations are marked as fuzzy so that they're
+// reviewed by translators. Calls to the translation service are made at
+// most every interval. Stops if the server switches to read-only mode
+func machineTranslateUntranslated(app *App, lang string, tr Translator, interval time.Duration) *MachineTranslateResult {
+	res := &MachineTranslateResult{Lang: lang, Errors: []string{}}
+	var last time.Time
+	for _, src := range app.Untranslated(lang) {
+		if wait := interval - clock.Now().Sub(last); wait > 0 {
+			clock.Sleep(wait)
+		}
+		if isReadOnly() {
+			res.Error = readOnlyMsg
+			break
+		}
+		last = clock.Now()
+		trans, err := tr.Translate(src, sourceLang, machineLangCode(lang))
+		if err == nil && trans == "" {
+			err = errors.New("empty translation")
+		}
+		if err == nil {
+			err = app.store.WriteFuzzyTranslation(src, trans, lang, machineUser)
+		}
+		if err != nil {
+			res.Failed++
+			res.Errors = append(res.Errors, fmt.Sprintf("%q: %s", src, err))
+			continue
+		}
+		res.Filled++
+	}
+	return res
+}
+
+func machineTranslateInterval() time.Duration {
+	rate := config.MachineTranslateRate
+	if rate <= 0 {
+		rate = defaultMachineTranslateRate
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// MachineTranslateJob is machine translation of a language of an app,
+// running in the background because it can take much longer than a request
+// is allowed to
+type MachineTranslateJob struct {
+	App     string `json:"app"`
+	Running bool   `json:"running"`
+	// number of strings to translate
+	Total int `json:"total"`
+	// zero if still running
+	Finished time.Time `json:"finished"`
+	// nil if still running
+	Result *MachineTranslateResult `json:"result"`
+}
+
+var (
+	machineJobsMu sync.Mutex
+	// the most recent job of each app and language, by machineJobKey()
+	machineJobs = make(map[string]*MachineTranslateJob)
+)
+
+func machineJobKey(app *App, lang string) string {
+	return app.Name + "/" + lang
+}
+
+// startMachineTranslate starts machine translation of lang of app in the
+// background, unless it's already running. Returns a copy of the state of
+// the job
+func startMachineTranslate(app *App, lang string, tr Translator, interval time.Duration) MachineTranslateJob {
+	machineJobsMu.Lock()
+	defer machineJobsMu.Unlock()
+	key := machineJobKey(app, lang)
+	if job := machineJobs[key]; job != nil && job.Running {
+		return *job
+	}
+	job := &MachineTranslateJob{App: app.Name, Running: true, Total: len(app.Untranslated(lang))}
+	machineJobs[key] = job
+	go func() {
+		res := machineTranslateUntranslated(app, lang, tr, interval)
+		logger.Noticef("machine translated %d strings of %s into %s, %d failed", res.Filled, app.Name, lang, res.Failed)
+		if res.Error != "" {
+			logger.Errorf("machine translation of %s into %s stopped: %s", app.Name, lang, res.Error)
+		}
+		machineJobsMu.Lock()
+		job.Running = false
+		job.Finished = clock.Now().UTC()
+		job.Result = res
+		machineJobsMu.Unlock()
+	}()
+	return *job
+}
+
+// machineTranslateJob returns a copy of the state of the most recent machine
+// translation of lang of app, false if there was none
+func machineTranslateJob(app *App, lang string) (MachineTranslateJob, bool) {
+	machineJobsMu.Lock()
+	defer machineJobsMu.Unlock()
+	job := machineJobs[machineJobKey(app, lang)]
+	if job == nil {
+		return MachineTranslateJob{}, false
+	}
+	return *job, true
+}
+
+// url: POST /admin/machinetranslate?app=$app&lang=$lang
+// Starts filling all untranslated strings in lang with machine
+// translations, marked as fuzzy, in the background. Returns 202 with the
+// state of the job. Fails with 503 in read-only mode
+// url: GET /admin/machinetranslate?app=$app&lang=$lang
+// Returns the state of the most recent machine translation of lang, also in
+// read-only mode
+func handleMachineTranslate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && !requireMethod(w, r, "POST") {
+		return
+	}
+	if r.Method == "POST" && isReadOnly() {
+		http.Error(w, readOnlyMsg, http.StatusServiceUnavailable)
+		return
+	}
+	app, lang := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't machine translate")
+		return
+	}
+	if r.Method == "GET" {
+		job, ok := machineTranslateJob(app, lang)
+		if !ok {
+			http404(w, r)
+			return
+		}
+		serveJSON(w, job)
+		return
+	}
+	if machineTranslator == nil {
+		httpErrorf(w, "Machine translation is not configured")
+		return
+	}
+	job := startMachineTranslate(app, lang, machineTranslator, machineTranslateInterval())
+	logger.ForRequest(r).Noticef("machine translation of %s into %s started", app.Name, lang)
+	serveJSONWithStatus(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,145 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeTranslator struct {
+	calls []string
+}
+
+func (t *fakeTranslator) Translate(text, fromLang, toLang string) (string, error) {
+	t.calls = append(t.calls, text)
+	if text == "Fail" {
+		return "", errors.New("translation failed")
+	}
+	return toLang + ":" + text, nil
+}
+
+func TestMachineTranslateUntranslated(t *testing.T) {
+	app := newTestApp(t, "machine", []string{"Open", "Close", "Save", "Fail", "SumatraPDF"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	if err := app.store.SetNoTranslate("SumatraPDF", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	tr := &fakeTranslator{}
+	res := machineTranslateUntranslated(app, "pl", tr, 0)
+	if res.Filled != 2 || res.Failed != 1 || len(tr.calls) != 3 {
+		t.Fatalf("unexpected result %#v, calls: %v", res, tr.calls)
+	}
+	if app.store.IsFuzzy("Open", "pl") {
+		t.Fatalf("existing translation marked as fuzzy")
+	}
+	for _, src := range []string{"Close", "Save"} {
+		if !app.store.IsFuzzy(src, "pl") {
+			t.Fatalf("%q is not fuzzy", src)
+		}
+		if trans, _ := app.TranslationWithFallback(src, "pl"); trans != "pl:"+src {
+			t.Fatalf("%q translated as %q", src, trans)
+		}
+	}
+	if untranslated := app.Untranslated("pl"); len(untranslated) != 1 || untranslated[0] != "Fail" {
+		t.Fatalf("unexpected untranslated strings %v", untranslated)
+	}
+
+	// our language codes are mapped to standard codes
+	tr = &fakeTranslator{}
+	machineTranslateUntranslated(app, "cn", tr, 0)
+	if trans, _ := app.TranslationWithFallback("Open", "cn"); trans != "zh-CN:Open" {
+		t.Fatalf("got %q", trans)
+	}
+
+	// calls are rate-limited
+	start := time.Now()
+	res = machineTranslateUntranslated(app, "de", &fakeTranslator{}, 20*time.Millisecond)
+	if dur := time.Since(start); dur < 60*time.Millisecond {
+		t.Fatalf("%d calls took %s", res.Filled+res.Failed, dur)
+	}
+}
+
+// readOnlyTranslator switches the server to read-only mode after the first
+// translation
+type readOnlyTranslator struct {
+	fakeTranslator
+}
+
+func (t *readOnlyTranslator) Translate(text, fromLang, toLang string) (string, error) {
+	setReadOnly(true)
+	return t.fakeTranslator.Translate(text, fromLang, toLang)
+}
+
+func TestMachineTranslateReadOnly(t *testing.T) {
+	app := newTestApp(t, "machinereadonly", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	defer setReadOnly(false)
+
+	tr := &readOnlyTranslator{}
+	res := machineTranslateUntranslated(app, "pl", tr, 0)
+	if res.Filled != 1 || res.Error == "" || len(tr.calls) != 1 {
+		t.Fatalf("unexpected result %#v, calls: %v", res, tr.calls)
+	}
+	if n := len(app.Untranslated("pl")); n != 2 {
+		t.Fatalf("got %d untranslated strings, expected 2", n)
+	}
+}
+
+func TestMachineTranslateJob(t *testing.T) {
+	app := newTestApp(t, "machinejob", []string{"Open", "Close"})
+	defer closeTestApp(app)
+	machineTranslator = &fakeTranslator{}
+	defer func() { machineTranslator = nil }()
+
+	serve := func(method string) (int, MachineTranslateJob) {
+		r := newRequestWithCookie(method, "/admin/machinetranslate?app=machinejob&lang=pl", &SecureCookieValue{User: "admin"})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		var job MachineTranslateJob
+		if rr.Code == http.StatusOK || rr.Code == http.StatusAccepted {
+			if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, job
+	}
+	if code, _ := serve("GET"); code != http.StatusNotFound {
+		t.Fatalf("got %d before any job", code)
+	}
+	code, job := serve("POST")
+	if code != http.StatusAccepted || job.App != "machinejob" || job.Total != 2 {
+		t.Fatalf("got %d, %#v", code, job)
+	}
+	// the job runs in the background, poll until it's done
+	for i := 0; job.Running; i++ {
+		if i == 100 {
+			t.Fatalf("job didn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if code, job = serve("GET"); code != http.StatusOK {
+			t.Fatalf("got %d", code)
+		}
+	}
+	if job.Result == nil || job.Result.Filled != 2 || job.Finished.IsZero() {
+		t.Fatalf("unexpected job %#v", job)
+	}
+	if !app.store.IsFuzzy("Close", "pl") {
+		t.Fatalf("Close is not machine translated")
+	}
+
+	// jobs can be polled, but not started, in read-only mode
+	setReadOnly(true)
+	defer setReadOnly(false)
+	if code, _ = serve("POST"); code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d for POST in read-only mode", code)
+	}
+	if code, job = serve("GET"); code != http.StatusOK || job.Result == nil {
+		t.Fatalf("got %d, %#v in read-only mode", code, job)
+	}
+}
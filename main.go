@@ -4,15 +4,16 @@ package main
 import (
 	"crypto/tls"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/acme/autocert"
@@ -28,9 +29,11 @@ var (
 	configPath = flag.String("config", "config.json", "Path to configuration file")
 	httpAddr   = flag.String("addr", ":5001", "HTTP server address")
 	//logPath      = flag.String("log", "stdout", "where to log")
-	inProduction = flag.Bool("production", false, "are we running in production")
-	noS3Backup   = flag.Bool("no-backup", false, "don't backup to s3")
-	cookieName   = "ckie"
+	inProduction    = flag.Bool("production", false, "are we running in production")
+	noS3Backup      = flag.Bool("no-backup", false, "don't backup to s3")
+	validatePath    = flag.String("validate", "", "validate translations file (.csv, .po or .json) and exit")
+	checkConfigFlag = flag.Bool("check-config", false, "check config file and data directories of apps and exit")
+	cookieName      = "ckie"
 )
 
 var (
@@ -49,17 +52,92 @@ var (
 		AwsSecret               *string
 		S3BackupBucket          *string
 		S3BackupDir             *string
+		// additional destinations of backups, e.g. to have copies in
+		// both S3 and Google Cloud Storage. See BackupConfig
+		BackupTargets []BackupConfig
+		// used instead of CookieAuthKeyHexStr and CookieEncrKeyHexStr to
+		// rotate keys without logging out users. Newest keys go first and
+		// sign new cookies, cookies signed with older keys are still valid
+		CookieKeys []CookieKeys
+		// strings deleted more than this many days ago are purged with
+		// their translations when stores are compacted. Never purged if 0
+		PurgeAfterDays int
+		// maximum size of request body for uploads, defaultMaxUploadBytes if 0
+		MaxUploadBytes int64
+		// number of notices and errors kept in memory and shown on /logs,
+		// defaultLogBufferSize if 0
+		LogNoticeBuffer int
+		LogErrorBuffer  int
+		// minimum length of UploadSecret of apps, which also must not be
+		// too predictable. Not checked if 0
+		MinSecretLen int
+		// additional mappings of regional variant to base language, see
+		// store.LangFallbacks
+		LangFallbacks map[string]string
+		// languages hidden from the UI, counts and exports, see
+		// store.SetDisabledLangs. Their translations are kept
+		DisabledLanguages []string
+		// ordered languages whose translations are exported for strings
+		// not translated into a given language, e.g. {"de-ch": ["de", "fr"]}
+		FallbackChain map[string][]string
+		// path the server is mounted at behind a proxy, e.g. "/translate"
+		// for https://host/translate/. Empty if mounted at root
+		BasePath string
+		// SameSite attribute of cookies: "lax" (default), "strict" or "none"
+		CookieSameSite string
+		// CIDRs of reverse proxies whose X-Forwarded-For and X-Forwarded-Proto
+		// headers are trusted
+		TrustedProxies []string
+		// enables machine translation with Google Cloud Translation API
+		GoogleTranslateAPIKey string
+		// maximum number of machine translation requests per second,
+		// defaultMachineTranslateRate if 0
+		MachineTranslateRate float64
+		// timeouts of http and https servers in seconds, defaults from
+		// makeHTTPServer() if 0
+		ReadTimeoutSecs  int
+		WriteTimeoutSecs int
+		IdleTimeoutSecs  int
+		// maximum size of request headers, http.DefaultMaxHeaderBytes if 0
+		MaxHeaderBytes int
+		// closes connections after every request instead of keeping them
+		// open for next requests
+		DisableKeepAlives bool
+		// timeout of requests to Twitter, S3 and repositories of webhooks
+		// in seconds, defaultOutboundTimeout if 0
+		OutboundTimeoutSecs int
+		// for local development: when not in production, every request is
+		// made by this user, who is an admin of all apps
+		DevAdminUser string
+		// if true, translations of a source string are marked as fuzzy
+		// when the source string is changed with /admin/rename
+		FuzzyOnSourceChange bool
+		// if set, admin can also log in with http basic auth, see
+		// BasicAuthConfig
+		AdminBasicAuth *BasicAuthConfig
+		// twitter users who administer the whole server, e.g. can see
+		// backups and toggle read-only mode. Admins of apps are not
+		// server admins unless listed here
+		ServerAdminTwitterUsers []string
+		// if not empty, shown at the top of every page, e.g. to announce
+		// maintenance. Plain text, users can dismiss it
+		Banner string
+		// if true, the server starts in read-only mode, in which edits,
+		// uploads and imports are rejected. Can be toggled by an admin
+		// with /admin/readonly
+		ReadOnly bool
+		// if set, digests of new translation issues are emailed, see
+		// SMTPConfig
+		SMTP *SMTPConfig
+		// number of daily samples of untranslated strings kept for
+		// /api/v1/apps/{name}/debt, defaultDebtSamples if 0
+		DebtSamples int
 	}{
-		&oauthClient.Credentials,
-		nil,
-		nil, nil,
-		nil, nil,
-		nil, nil,
+		TwitterOAuthCredentials: &oauthClient.Credentials,
 	}
-	logger        *ServerLogger
-	cookieAuthKey []byte
-	cookieEncrKey []byte
-	secureCookie  *securecookie.SecureCookie
+	logger *ServerLogger
+	// codecs of cookies, see newCookieCodecs()
+	secureCookies []securecookie.Codec
 
 	// this is where we store information about users and translation.
 	// All in one place because I expect this data to be small
@@ -85,6 +163,9 @@ func s3BackupEnabled() bool {
 		logger.Notice("s3 backups disabled because not in production")
 		return false
 	}
+	if len(config.BackupTargets) > 0 {
+		return true
+	}
 	if stringEmpty(config.AwsAccess) {
 		logger.Notice("s3 backups disabled because AwsAccess not defined in config.json\n")
 		return false
@@ -106,25 +187,32 @@ func s3BackupEnabled() bool {
 
 // data dir is ../../data on the server or ~/data/apptranslator locally
 // the important part is that it's outside of directory with the code
-func getDataDir() string {
+var dataDirsToCheck = []string{
+	// on the server, must be done first because ExpandTildeInPath()
+	// doesn't work when cross-compiled on mac for linux
+	filepath.Join("..", "..", "data"),
+	u.ExpandTildeInPath("~/data/apptranslator"),
+}
+
+// findDataDir returns data dir or "" if it doesn't exist
+func findDataDir() string {
 	if dataDir != "" {
 		return dataDir
 	}
-
-	dirsToCheck := []string{
-		// on the server, must be done first because ExpandTildeInPath()
-		// doesn't work when cross-compiled on mac for linux
-		filepath.Join("..", "..", "data"),
-		u.ExpandTildeInPath("~/data/apptranslator"),
-	}
-
-	for _, dir := range dirsToCheck {
+	for _, dir := range dataDirsToCheck {
 		if u.PathExists(dir) {
 			dataDir = dir
 			return dataDir
 		}
 	}
-	log.Fatalf("data directory (%v) doesn't exist\n", dirsToCheck)
+	return ""
+}
+
+func getDataDir() string {
+	if dir := findDataDir(); dir != "" {
+		return dir
+	}
+	log.Fatalf("data directory (%v) doesn't exist\n", dataDirsToCheck)
 	return ""
 }
 
@@ -138,9 +226,34 @@ type AppConfig struct {
 	// of the admin user
 	AdminTwitterUser  string
 	AdminTwitterUser2 string
+	// twitter users who can approve translations, in addition to admins
+	Reviewers []string
 	// an arbitrary string, used to protect the API for uploading new strings
 	// for the app
 	UploadSecret string
+	// required translations of terms, can be replaced with /uploadglossary
+	Glossary Glossary
+	// optional branding of app's pages. ThemeColor is a hex color like #0088cc
+	LogoURL    string
+	ThemeColor string
+	// archived apps are not listed on the main page and in sitemap.xml,
+	// but their pages still work
+	Archived bool
+	// languages shown first in the UI and in downloaded translations, in
+	// that order. Other languages follow in the default order
+	LanguageOrder []string
+	// if true, app's data is not included in backups
+	NoBackup bool
+	// a backup is uploaded when the app wasn't backed up for this many
+	// hours. 0 means the default, every 12 hours. Every backup has data of
+	// all apps
+	BackupFreqHours int
+	// name of the app with strings shared by several apps. Its translations
+	// fill gaps in exports with shared=1
+	SharedFrom string
+	// if set, strings are imported from a git repository on push, see
+	// WebhookConfig
+	StringsWebhook *WebhookConfig
 }
 
 // User describes an user
@@ -152,37 +265,90 @@ type User struct {
 type App struct {
 	AppConfig
 	store *store.StoreCsv
+
+	// protects glossary, stringsHashes, namespaces and issues
+	mu       sync.Mutex
+	glossary Glossary
+	// issues found in translations, see Issues()
+	issues *issuesCache
+	// hash of the last uploaded list of strings in each namespace, see
+	// hashStrings()
+	stringsHashes map[string]string
+	// stores of namespaces other than default, see namespaces.go
+	namespaces map[string]*store.StoreCsv
 }
 
 // AppState describes state of the app
 type AppState struct {
 	Users []*User
 	Apps  []*App
+	// apps from config that failed to load, see loadApps()
+	FailedApps []FailedApp
+}
+
+// FailedApp is an app that failed to load, reported by /health
+type FailedApp struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
 }
 
 // NewApp creates new App
 func NewApp(config *AppConfig) *App {
 	app := &App{AppConfig: *config}
+	app.LanguageOrder = normalizeLanguageOrder(config.LanguageOrder)
+	app.glossary = config.Glossary
+	app.stringsHashes = make(map[string]string)
+	app.namespaces = make(map[string]*store.StoreCsv)
 	return app
 }
 
+// color of links in bootstrap, used when app doesn't have ThemeColor
+const defaultThemeColor = "#0088cc"
+
+// Theme returns theme color of app's pages, used in templates
+func (a *App) Theme() string {
+	if a.ThemeColor == "" {
+		return defaultThemeColor
+	}
+	return a.ThemeColor
+}
+
+// AppStats is a summary of counts for an app, used in templates
+type AppStats struct {
+	LangsCount        int
+	StringsCount      int
+	UntranslatedCount int
+	EditsCount        int
+}
+
+// Stats returns all counts for the app, computed in one pass over the store
+func (a *App) Stats() AppStats {
+	st := a.store.Stats()
+	return AppStats{
+		LangsCount:        st.LangsCount,
+		StringsCount:      st.StringsCount,
+		UntranslatedCount: st.UntranslatedCount,
+		EditsCount:        st.EditsCount,
+	}
+}
+
 // LangsCount returns number of languages, used in templates
 func (a *App) LangsCount() int {
-	return len(store.Languages)
-	//return a.store.LangsCount()
+	return a.Stats().LangsCount
 }
 
 // StringsCount returns number of strings, used in templates
 func (a *App) StringsCount() int {
-	return a.store.StringsCount()
+	return a.Stats().StringsCount
 }
 
 // UntranslatedCount returns number of untranslated strings, used in templates
 func (a *App) UntranslatedCount() int {
-	return a.store.UntranslatedCount()
+	return a.Stats().UntranslatedCount
 }
 
-// EditsCount returns number of edits
+// EditsCount returns number of edits. Unlike Stats(), it doesn't need a
+// pass over the store
 func (a *App) EditsCount() int {
 	return a.store.EditsCount()
 }
@@ -217,12 +383,26 @@ func readAppData(app *App) error {
 	if u.PathExists(path) {
 		if l, err := store.NewStoreCsv(path); err == nil {
 			app.store = l
-			return nil
+			if err = app.loadNamespaces(filepath.Dir(path)); err != nil {
+				return err
+			}
+			return readAppGlossary(app)
 		}
 	}
 	return fmt.Errorf("readAppData: %q data file doesn't exist", path)
 }
 
+// activeApps returns apps that are not archived
+func activeApps() []*App {
+	var res []*App
+	for _, app := range appState.Apps {
+		if !app.Archived {
+			res = append(res, app)
+		}
+	}
+	return res
+}
+
 func findApp(name string) *App {
 	for _, app := range appState.Apps {
 		if app.Name == name {
@@ -236,26 +416,71 @@ func appAlreadyExists(name string) bool {
 	return nil != findApp(name)
 }
 
+// isValidAppDataDir returns true if dir is a relative path of a directory
+// inside data directory. Files of the app are in getDataDir()/dir, so
+// absolute paths and ".." would let them escape the data directory
+func isValidAppDataDir(dir string) bool {
+	if dir == "" || filepath.IsAbs(dir) || strings.HasPrefix(filepath.ToSlash(dir), "/") {
+		return false
+	}
+	clean := filepath.ToSlash(filepath.Clean(dir))
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
 func appInvalidField(app *App) string {
 	app.Name = strings.TrimSpace(app.Name)
 	if app.Name == "" {
 		return "Name"
 	}
-	if app.DataDir == "" {
+	if !isValidAppDataDir(app.DataDir) {
 		return "DataDir"
 	}
 	if app.AdminTwitterUser == "" {
 		return "AdminTwitterUser"
 	}
-	if app.UploadSecret == "" {
+	if app.UploadSecret == "" || checkSecretStrength(app.UploadSecret) != nil {
 		return "UploadSecret"
 	}
+	if validateGlossary(app.Glossary) != nil {
+		return "Glossary"
+	}
+	if app.ThemeColor != "" && !isValidHexColor(app.ThemeColor) {
+		return "ThemeColor"
+	}
+	if validateLanguageOrder(app.LanguageOrder) != nil {
+		return "LanguageOrder"
+	}
+	if app.BackupFreqHours < 0 {
+		return "BackupFreqHours"
+	}
+	if app.SharedFrom == app.Name {
+		return "SharedFrom"
+	}
+	if validateWebhookConfig(app.StringsWebhook) != nil {
+		return "StringsWebhook"
+	}
 	return ""
 }
 
+// describeInvalidField returns field returned by appInvalidField(), quoted,
+// with the reason it's invalid if it's not obvious
+func describeInvalidField(app *App, field string) string {
+	if field == "UploadSecret" {
+		if err := checkSecretStrength(app.UploadSecret); err != nil {
+			return fmt.Sprintf("%q (%s)", field, err)
+		}
+	}
+	if field == "LanguageOrder" {
+		if err := validateLanguageOrder(app.LanguageOrder); err != nil {
+			return fmt.Sprintf("%q (%s)", field, err)
+		}
+	}
+	return fmt.Sprintf("%q", field)
+}
+
 func addApp(app *App) error {
 	if invalidField := appInvalidField(app); invalidField != "" {
-		return fmt.Errorf("App has invalid field %q", invalidField)
+		return fmt.Errorf("App has invalid field %s", describeInvalidField(app, invalidField))
 	}
 	if appAlreadyExists(app.Name) {
 		return errors.New("App already exists")
@@ -267,42 +492,204 @@ func addApp(app *App) error {
 	return nil
 }
 
+// loadApps adds apps from configs. Apps that fail to load are logged and
+// skipped, so that one misconfigured app doesn't stop the others
+func loadApps(configs []AppConfig) {
+	for i := range configs {
+		app := NewApp(&configs[i])
+		if err := addApp(app); err != nil {
+			logger.Errorf("Failed to add the app: %s, err: %s", app.Name, err)
+			appState.FailedApps = append(appState.FailedApps, FailedApp{Name: app.Name, Error: err.Error()})
+			continue
+		}
+		logger.Noticef("Added app %s", app.Name)
+	}
+}
+
+var compactFreq = 24 * time.Hour
+
+// compactStore rewrites the store file in canonical order, purging strings
+// deleted more than config.PurgeAfterDays ago
+func compactStore(st *store.StoreCsv, now time.Time) error {
+	if config.PurgeAfterDays == 0 {
+		return st.Compact()
+	}
+	n, err := st.CompactAndPurge(now.AddDate(0, 0, -config.PurgeAfterDays))
+	if err == nil && n > 0 {
+		logger.Noticef("purged %d records of strings deleted more than %d days ago from %s", n, config.PurgeAfterDays, st.FilePath())
+	}
+	return err
+}
+
+// compactStoresLoop periodically rewrites store files of all apps in
+// canonical order
+func compactStoresLoop() {
+	for {
+		for _, app := range appState.Apps {
+			for _, st := range app.allStores() {
+				if err := compactStore(st, clock.Now()); err != nil {
+					logger.Errorf("Compact() of %s failed with %s", st.FilePath(), err)
+				}
+			}
+		}
+		clock.Sleep(compactFreq)
+	}
+}
+
 func isTopLevelURL(url string) bool {
 	return 0 == len(url) || "/" == url
 }
 
+// devAdminUser returns config.DevAdminUser, unless we're in production
+func devAdminUser() string {
+	if *inProduction {
+		return ""
+	}
+	return config.DevAdminUser
+}
+
 func userIsAdmin(app *App, user string) bool {
 	if user == "" {
 		return false
 	}
+	if userIsServerAdmin(user) {
+		return true
+	}
 	return user == app.AdminTwitterUser || user == app.AdminTwitterUser2
 }
 
+// what a user can do in an app
+const (
+	roleNone = "none"
+	// can edit translations, every logged in user is a translator
+	roleTranslator = "translator"
+	// can also approve translations
+	roleReviewer = "reviewer"
+	// can also change strings and settings of the app
+	roleAdmin = "admin"
+)
+
+// userRole returns role of user in app
+func userRole(app *App, user string) string {
+	if user == "" {
+		return roleNone
+	}
+	if userIsAdmin(app, user) {
+		return roleAdmin
+	}
+	if userIsReviewer(app, user) {
+		return roleReviewer
+	}
+	return roleTranslator
+}
+
+// userIsReviewer returns true if user can approve translations in app
+func userIsReviewer(app *App, user string) bool {
+	if userIsAdmin(app, user) {
+		return true
+	}
+	for _, reviewer := range app.Reviewers {
+		if user != "" && user == reviewer {
+			return true
+		}
+	}
+	return false
+}
+
+// userIsServerAdmin returns true if user administers the whole server, which
+// is the basic auth admin, one of ServerAdminTwitterUsers or, when not in
+// production, DevAdminUser. Admins of apps are not server admins
+func userIsServerAdmin(user string) bool {
+	if user == "" {
+		return false
+	}
+	if user == devAdminUser() || user == basicAuthAdminUser() {
+		return true
+	}
+	for _, u := range config.ServerAdminTwitterUsers {
+		if user == u {
+			return true
+		}
+	}
+	return false
+}
+
 // reads the configuration file from the path specified by
-// the config command line flag.
+// the config command line flag. It can be json, YAML or TOML, see
+// configToJSON()
 func readConfig(configFile string) error {
 	b, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(b, &config)
-	if err != nil {
+	if b, err = configToJSON(configFile, b); err != nil {
+		return fmt.Errorf("%s: %s", configFile, err)
+	}
+	if b, err = expandConfigEnv(b); err != nil {
+		return fmt.Errorf("%s: %s", configFile, err)
+	}
+	if err = decodeConfig(b, &config); err != nil {
+		return fmt.Errorf("%s: %s", configFile, err)
+	}
+	if !isValidBasePath(config.BasePath) {
+		return fmt.Errorf("invalid BasePath %q, must start with '/' and not end with '/'", config.BasePath)
+	}
+	if config.PurgeAfterDays < 0 {
+		return errors.New("PurgeAfterDays must not be negative")
+	}
+	if config.DebtSamples < 0 {
+		return errors.New("DebtSamples must not be negative")
+	}
+	if config.LogNoticeBuffer < 0 || config.LogErrorBuffer < 0 {
+		return errors.New("LogNoticeBuffer and LogErrorBuffer must not be negative")
+	}
+	if !isValidCookieSameSite(config.CookieSameSite) {
+		return fmt.Errorf("invalid CookieSameSite %q", config.CookieSameSite)
+	}
+	if trustedProxyNets, err = parseTrustedProxies(config.TrustedProxies); err != nil {
 		return err
 	}
-	cookieAuthKey, err = hex.DecodeString(*config.CookieAuthKeyHexStr)
-	if err != nil {
+	if config.GoogleTranslateAPIKey != "" {
+		machineTranslator = newGoogleTranslator(config.GoogleTranslateAPIKey)
+	}
+	for lang, fallback := range config.LangFallbacks {
+		if !store.IsValidLangCode(lang) || !store.IsValidLangCode(fallback) {
+			return fmt.Errorf("invalid LangFallbacks entry %q => %q", lang, fallback)
+		}
+		store.LangFallbacks[lang] = fallback
+	}
+	if err = store.SetDisabledLangs(config.DisabledLanguages); err != nil {
+		return fmt.Errorf("invalid DisabledLanguages: %s", err)
+	}
+	if err = validateFallbackChain(config.FallbackChain); err != nil {
+		return err
+	}
+	if err = validateBasicAuthConfig(config.AdminBasicAuth); err != nil {
+		return err
+	}
+	if err = validateSMTPConfig(config.SMTP); err != nil {
+		return err
+	}
+	if err = validateBackupTargets(config.BackupTargets); err != nil {
 		return err
 	}
-	cookieEncrKey, err = hex.DecodeString(*config.CookieEncrKeyHexStr)
+	setReadOnly(config.ReadOnly)
+	cookieKeys, err := configCookieKeys()
 	if err != nil {
 		return err
 	}
-	secureCookie = securecookie.New(cookieAuthKey, cookieEncrKey)
+	if secureCookies, err = newCookieCodecs(cookieKeys); err != nil {
+		return err
+	}
 	// verify auth/encr keys are correct
 	val := map[string]string{
 		"foo": "bar",
 	}
-	_, err = secureCookie.Encode(cookieName, val)
+	for _, codec := range secureCookies {
+		if _, err = codec.Encode(cookieName, val); err != nil {
+			break
+		}
+	}
 	if err != nil {
 		// for convenience, if the auth/encr keys are not set,
 		// generate valid, random value for them
@@ -319,9 +706,9 @@ func readConfig(configFile string) error {
 func makeTimingHandler(fn func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		startTime := time.Now()
+		startTime := clock.Now()
 		fn(w, r)
-		duration := time.Now().Sub(startTime)
+		duration := clock.Now().Sub(startTime)
 		// log urls that take long time to generate i.e. over 1 sec in production
 		// or over 0.1 sec in dev
 		shouldLog := duration.Seconds() > 1.0
@@ -333,7 +720,7 @@ func makeTimingHandler(fn func(http.ResponseWriter, *http.Request)) http.Handler
 			if len(r.URL.RawQuery) > 0 {
 				url = fmt.Sprintf("%s?%s", url, r.URL.RawQuery)
 			}
-			logger.Noticef("%q took %f seconds to serve", url, duration.Seconds())
+			logger.ForRequest(r).Noticef("%q took %f seconds to serve", url, duration.Seconds())
 		}
 	}
 }
@@ -348,13 +735,19 @@ func hostPolicy(ctx netcontext.Context, host string) error {
 func main() {
 	flag.Parse()
 
+	if *validatePath != "" {
+		os.Exit(readAndValidateTransFile(*validatePath))
+	}
+
+	if *checkConfigFlag {
+		os.Exit(checkConfig(*configPath, os.Stdout))
+	}
+
 	if *inProduction {
 		reloadTemplates = false
 		alwaysLogTime = false
 	}
 
-	logger = NewServerLogger(256, 256, !*inProduction)
-
 	/*
 		if *logPath == "stdout" {
 			logger = log.New(os.Stdout, "", 0)
@@ -370,31 +763,33 @@ func main() {
 	if err := readConfig(*configPath); err != nil {
 		log.Fatalf("Failed reading config file %s. %s\n", *configPath, err)
 	}
-
-	for _, appData := range config.Apps {
-		app := NewApp(&appData)
-		if err := addApp(app); err != nil {
-			log.Fatalf("Failed to add the app: %s, err: %s\n", app.Name, err)
+	logger = NewServerLogger(logBufferSize(config.LogErrorBuffer), logBufferSize(config.LogNoticeBuffer), !*inProduction)
+	if config.DevAdminUser != "" {
+		if *inProduction {
+			logger.Noticef("DevAdminUser is ignored in production")
 		} else {
-			logger.Noticef("Added app %s\n", app.Name)
+			logger.Noticef("All requests are made by admin user %s (DevAdminUser)", config.DevAdminUser)
 		}
 	}
 
-	// for testing, add a dummy app if no apps exist
+	loadApps(config.Apps)
 	if len(appState.Apps) == 0 {
-		log.Fatalf("No apps defined in config.json")
+		log.Fatalf("No apps could be loaded from %s, %d failed\n", *configPath, len(appState.FailedApps))
 	}
 
-	backupConfig := &BackupConfig{
-		AwsAccess: *config.AwsAccess,
-		AwsSecret: *config.AwsSecret,
-		Bucket:    *config.S3BackupBucket,
-		S3Dir:     *config.S3BackupDir,
-		LocalDir:  getDataDir(),
+	go compactStoresLoop()
+	go metricsRefreshLoop()
+	go debtSnapshotLoop()
+	if config.SMTP != nil {
+		go issuesDigestLoop(config.SMTP)
 	}
 
 	if s3BackupEnabled() {
-		go s3BackupLoop(backupConfig)
+		for _, c := range backupConfigs(getDataDir()) {
+			backupTargets = append(backupTargets, &BackupTarget{Config: c, Store: &s3BackupStore{config: c}})
+		}
+		backupStore = backupTargets[0].Store
+		go s3BackupLoop(backupTargets)
 	}
 
 	if *inProduction {
@@ -0,0 +1,67 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestMain(m *testing.M) {
+	logger = NewServerLogger(256, 256, false)
+	secureCookies = securecookie.CodecsFromPairs(securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32))
+	os.Exit(m.Run())
+}
+
+// newTestApp creates an app with a store in a temporary directory and adds
+// it to appState. Call closeTestApp() when done
+func newTestApp(t *testing.T, name string, strs []string) *App {
+	dir, err := ioutil.TempDir("", "apptranslator-test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	app := NewApp(&AppConfig{
+		Name:             name,
+		DataDir:          dir,
+		AdminTwitterUser: "admin",
+		UploadSecret:     "secret",
+	})
+	app.store, err = store.NewStoreCsv(filepath.Join(dir, "translations.csv"))
+	if err != nil {
+		t.Fatalf("store.NewStoreCsv() failed with %s", err)
+	}
+	if _, _, _, err = app.store.UpdateStringsList(strs); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	appState.Apps = append(appState.Apps, app)
+	return app
+}
+
+func closeTestApp(app *App) {
+	for i, a := range appState.Apps {
+		if a == app {
+			appState.Apps = append(appState.Apps[:i], appState.Apps[i+1:]...)
+			break
+		}
+	}
+	app.closeStores()
+	os.RemoveAll(app.DataDir)
+}
+
+// setTestServerAdmin enables basic auth admin "root", who is a server
+// admin, and returns the user to use in cookies of requests of the admin.
+// Call the returned function when done
+func setTestServerAdmin() (string, func()) {
+	config.AdminBasicAuth = &BasicAuthConfig{User: "root"}
+	return basicAuthAdminUser(), func() { config.AdminBasicAuth = nil }
+}
+
+func writeTestTranslation(t *testing.T, app *App, str, trans, lang, user string) {
+	if err := app.store.WriteNewTranslation(str, trans, lang, user); err != nil {
+		t.Fatalf("WriteNewTranslation() failed with %s", err)
+	}
+}
@@ -0,0 +1,129 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how often metrics of all apps are re-computed. Metrics of an app are also
+// re-computed on the first scrape after its store changes
+var metricsRefreshFreq = 5 * time.Minute
+
+// AppLangMetrics are translation counts of an app in one language
+type AppLangMetrics struct {
+	Lang         string
+	Untranslated int
+	Fuzzy        int
+	Issues       int
+}
+
+// appMetrics are metrics of an app computed from a given generation of its
+// store
+type appMetrics struct {
+	generation int
+	langs      []AppLangMetrics
+}
+
+var (
+	metricsMu sync.Mutex
+	// by app name
+	metricsCache = make(map[string]*appMetrics)
+)
+
+// computeAppMetrics returns metrics of app for all languages known to the
+// store, which bounds the number of labeled samples
+func computeAppMetrics(app *App) []AppLangMetrics {
+	var res []AppLangMetrics
+	for _, li := range app.store.LangInfos() {
+		m := AppLangMetrics{
+			Lang:         li.Code,
+			Untranslated: li.UntranslatedCount(),
+			Issues:       len(app.Issues(li.Code)),
+		}
+		for _, tr := range li.ActiveStrings {
+			if tr.Fuzzy && tr.IsTranslated() {
+				m.Fuzzy++
+			}
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+// getAppMetrics returns metrics of app, re-computing them if its store
+// changed since they were computed or if refresh is true
+func getAppMetrics(app *App, refresh bool) []AppLangMetrics {
+	gen := app.store.Generation()
+	metricsMu.Lock()
+	cached := metricsCache[app.Name]
+	metricsMu.Unlock()
+	if cached != nil && cached.generation == gen && !refresh {
+		return cached.langs
+	}
+	m := &appMetrics{generation: gen, langs: computeAppMetrics(app)}
+	metricsMu.Lock()
+	metricsCache[app.Name] = m
+	metricsMu.Unlock()
+	return m.langs
+}
+
+// metricsRefreshLoop periodically re-computes metrics, because some of
+// them (e.g. glossary issues) change without changes to the store
+func metricsRefreshLoop() {
+	for {
+		for _, app := range appState.Apps {
+			getAppMetrics(app, true)
+		}
+		clock.Sleep(metricsRefreshFreq)
+	}
+}
+
+// escapes label value for prometheus text format
+func escapeLabelValue(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+var metricsGauges = []struct {
+	name string
+	help string
+	val  func(m *AppLangMetrics) int
+}{
+	{"apptranslator_untranslated_strings", "Number of strings that need translation", func(m *AppLangMetrics) int { return m.Untranslated }},
+	{"apptranslator_fuzzy_strings", "Number of translations that need review", func(m *AppLangMetrics) int { return m.Fuzzy }},
+	{"apptranslator_translation_issues", "Number of problems found in translations", func(m *AppLangMetrics) int { return m.Issues }},
+}
+
+// url: /metrics
+// Translation counts of all apps in prometheus text format, labeled by app
+// and language, and numbers of open http connections
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := make(map[string][]AppLangMetrics)
+	for _, app := range appState.Apps {
+		metrics[app.Name] = getAppMetrics(app, false)
+	}
+	var buf bytes.Buffer
+	for _, g := range metricsGauges {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, app := range appState.Apps {
+			for i := range metrics[app.Name] {
+				m := &metrics[app.Name][i]
+				fmt.Fprintf(&buf, "%s{app=\"%s\",lang=\"%s\"} %d\n", g.name, escapeLabelValue(app.Name), escapeLabelValue(m.Lang), g.val(m))
+			}
+		}
+	}
+	name := "apptranslator_http_connections"
+	fmt.Fprintf(&buf, "# HELP %s Number of open http connections by state\n# TYPE %s gauge\n", name, name)
+	counts := connCounts()
+	for _, state := range []http.ConnState{http.StateNew, http.StateActive, http.StateIdle} {
+		fmt.Fprintf(&buf, "%s{state=\"%s\"} %d\n", name, state, counts[state])
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
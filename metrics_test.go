@@ -0,0 +1,61 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApp(t, "metrics", []string{"Open %s", "Close", "Save"})
+	defer closeTestApp(app)
+
+	scrape := func() string {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+		if rr.Code != 200 {
+			t.Fatalf("got status %d, expected 200", rr.Code)
+		}
+		return rr.Body.String()
+	}
+	expectSamples := func(body string, samples ...string) {
+		for _, s := range samples {
+			if !strings.Contains(body, s+"\n") {
+				t.Fatalf("missing sample %q in:\n%s", s, body)
+			}
+		}
+	}
+
+	body := scrape()
+	expectSamples(body,
+		"# TYPE apptranslator_untranslated_strings gauge",
+		`apptranslator_untranslated_strings{app="metrics",lang="pl"} 3`,
+		`apptranslator_fuzzy_strings{app="metrics",lang="pl"} 0`,
+		`apptranslator_translation_issues{app="metrics",lang="pl"} 0`,
+	)
+
+	// metrics are updated after edits, without waiting for a refresh
+	writeTestTranslation(t, app, "Open %s", "Otwórz", "pl", "user1")
+	if err := app.store.WriteFuzzyTranslation("Close", "Zamknij", "pl", "user1"); err != nil {
+		t.Fatalf("WriteFuzzyTranslation() failed with %s", err)
+	}
+	writeTestTranslation(t, app, "Save", "Speichern", "de", "user1")
+	body = scrape()
+	expectSamples(body,
+		`apptranslator_untranslated_strings{app="metrics",lang="pl"} 1`,
+		`apptranslator_fuzzy_strings{app="metrics",lang="pl"} 1`,
+		`apptranslator_translation_issues{app="metrics",lang="pl"} 1`,
+		`apptranslator_untranslated_strings{app="metrics",lang="de"} 2`,
+		`apptranslator_translation_issues{app="metrics",lang="de"} 0`,
+	)
+	// one sample per app and language known to the store
+	nLangs := len(app.store.LangInfos())
+	if n := strings.Count(body, `apptranslator_fuzzy_strings{app="metrics",`); n != nLangs {
+		t.Fatalf("got %d samples, expected %d", n, nLangs)
+	}
+
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Fatalf("got %q", got)
+	}
+}
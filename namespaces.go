@@ -0,0 +1,115 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+// Strings of an app can be split into namespaces (e.g. "common",
+// "settings"), each stored in its own file translations.${namespace}.csv
+// in app's data directory. Strings in translations.csv are in the default
+// namespace, used when namespace is not given
+
+const defaultNamespace = ""
+
+var reNamespace = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func isValidNamespace(ns string) bool {
+	return ns == defaultNamespace || reNamespace.MatchString(ns)
+}
+
+func namespaceFileName(ns string) string {
+	if ns == defaultNamespace {
+		return "translations.csv"
+	}
+	return "translations." + ns + ".csv"
+}
+
+// loadNamespaces opens stores of all namespaces in dir
+func (a *App) loadNamespaces(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "translations.*.csv"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		ns := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "translations."), ".csv")
+		if !isValidNamespace(ns) {
+			continue
+		}
+		st, err := store.NewStoreCsv(path)
+		if err != nil {
+			return fmt.Errorf("store.NewStoreCsv(%q) failed with %s", path, err)
+		}
+		a.mu.Lock()
+		a.namespaces[ns] = st
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// Namespaces returns names of namespaces other than default, sorted
+func (a *App) Namespaces() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var res []string
+	for ns := range a.namespaces {
+		res = append(res, ns)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// NamespaceStore returns store of namespace ns or nil if it doesn't exist
+func (a *App) NamespaceStore(ns string) *store.StoreCsv {
+	if ns == defaultNamespace {
+		return a.store
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.namespaces[ns]
+}
+
+// createNamespace returns store of namespace ns, creating it if it
+// doesn't exist
+func (a *App) createNamespace(ns string) (*store.StoreCsv, error) {
+	if !isValidNamespace(ns) {
+		return nil, fmt.Errorf("invalid namespace %q", ns)
+	}
+	if st := a.NamespaceStore(ns); st != nil {
+		return st, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if st := a.namespaces[ns]; st != nil {
+		return st, nil
+	}
+	path := filepath.Join(filepath.Dir(a.store.FilePath()), namespaceFileName(ns))
+	st, err := store.NewStoreCsv(path)
+	if err != nil {
+		return nil, err
+	}
+	a.namespaces[ns] = st
+	return st, nil
+}
+
+// allStores returns stores of all namespaces, default first
+func (a *App) allStores() []*store.StoreCsv {
+	res := []*store.StoreCsv{a.store}
+	for _, ns := range a.Namespaces() {
+		res = append(res, a.NamespaceStore(ns))
+	}
+	return res
+}
+
+// closeStores closes stores of all namespaces
+func (a *App) closeStores() {
+	for _, st := range a.allStores() {
+		st.Close()
+	}
+}
@@ -0,0 +1,131 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestNamespaces(t *testing.T) {
+	app := newTestApp(t, "namespaces", []string{"Open"})
+	defer closeTestApp(app)
+
+	for ns, strs := range map[string][]string{"common": {"OK", "Cancel"}, "settings": {"Language"}} {
+		st, err := store.NewStoreCsv(filepath.Join(app.DataDir, namespaceFileName(ns)))
+		if err != nil {
+			t.Fatalf("store.NewStoreCsv() failed with %s", err)
+		}
+		if _, _, _, err = st.UpdateStringsList(strs); err != nil {
+			t.Fatalf("UpdateStringsList() failed with %s", err)
+		}
+		if err = st.WriteNewTranslation(strs[0], "pl "+strs[0], "pl", "user1"); err != nil {
+			t.Fatalf("WriteNewTranslation() failed with %s", err)
+		}
+		st.Close()
+	}
+	if err := app.loadNamespaces(app.DataDir); err != nil {
+		t.Fatalf("loadNamespaces() failed with %s", err)
+	}
+	if got, exp := app.Namespaces(), []string{"common", "settings"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	if app.NamespaceStore("settings").IsActiveString("OK") || !app.NamespaceStore("common").IsActiveString("OK") {
+		t.Fatalf("strings are in a wrong namespace")
+	}
+
+	export := func(ns string) (int, string) {
+		rr := httptest.NewRecorder()
+		u := "/export?app=namespaces&lang=pl&format=csv&ns=" + ns
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", u, nil))
+		return rr.Code, rr.Body.String()
+	}
+	code, body := export("common")
+	if exp := "lang,source,translation\npl,Cancel,\npl,OK,pl OK\n"; code != http.StatusOK || body != exp {
+		t.Fatalf("got %d %q, expected %q", code, body, exp)
+	}
+	if code, _ = export("missing"); code != http.StatusBadRequest {
+		t.Fatalf("got status %d for a missing namespace", code)
+	}
+
+	// uploading strings creates a namespace
+	form := url.Values{
+		"app":     {"namespaces"},
+		"secret":  {"secret"},
+		"ns":      {"help"},
+		"strings": {"AppTranslator strings\nAbout"},
+	}
+	r := httptest.NewRequest("POST", "/uploadstrings", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, expected %d", rr.Code, http.StatusOK)
+	}
+	if st := app.NamespaceStore("help"); st == nil || !st.IsActiveString("About") || app.store.IsActiveString("About") {
+		t.Fatalf("strings weren't uploaded to the namespace")
+	}
+
+	// strings of a namespace are shown and can be edited
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	rr = serve(httptest.NewRequest("GET", "/app/namespaces/pl?ns=common", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "pl OK") || strings.Contains(rr.Body.String(), "Open") {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if rr = serve(httptest.NewRequest("GET", "/app/namespaces/pl?ns=missing", nil)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for a missing namespace", rr.Code)
+	}
+	rr = serve(newRequestWithCookie("POST", "/edittranslation?app=namespaces&lang=pl&ns=common&string=Cancel&translation=Anuluj", &SecureCookieValue{User: "user1"}))
+	if rr.Code != http.StatusFound || !strings.Contains(rr.Header().Get("Location"), "ns=common") {
+		t.Fatalf("got status %d, location %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if got := currentTranslations(app)["pl"]["Cancel"]; got != "" {
+		t.Fatalf("translation was written to the default namespace")
+	}
+	if got := translationsByLang(app.NamespaceStore("common").LangInfos())["pl"]["Cancel"]; got != "Anuluj" {
+		t.Fatalf("got translation %q", got)
+	}
+
+	// batch edits are applied to a namespace
+	r = newRequestWithCookie("POST", "/batchedit?app=namespaces&ns=common", &SecureCookieValue{User: "user1"})
+	r.Body = ioutil.NopCloser(strings.NewReader(`[{"source": "OK", "lang": "de", "value": "OK"}]`))
+	if rr = serve(r); rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if got := translationsByLang(app.NamespaceStore("common").LangInfos())["de"]["OK"]; got != "OK" {
+		t.Fatalf("got translation %q", got)
+	}
+	r = newRequestWithCookie("POST", "/batchedit?app=namespaces&ns=missing", &SecureCookieValue{User: "user1"})
+	r.Body = ioutil.NopCloser(strings.NewReader(`[]`))
+	if rr = serve(r); rr.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d for a missing namespace", rr.Code)
+	}
+
+	// translations are imported into a namespace
+	form = url.Values{
+		"app":          {"namespaces"},
+		"secret":       {"secret"},
+		"lang":         {"de"},
+		"ns":           {"settings"},
+		"translations": {`{"Language": "Sprache"}`},
+	}
+	r = httptest.NewRequest("POST", "/uploadlangtranslations", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if rr = serve(r); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Imported 1 translations") {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if got := translationsByLang(app.NamespaceStore("settings").LangInfos())["de"]["Language"]; got != "Sprache" {
+		t.Fatalf("got translation %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPConfig enables emails with digests of new translation issues
+type SMTPConfig struct {
+	Host string
+	Port int
+	// optional, PLAIN auth is used if set
+	User     string
+	Password string
+	From     string
+	To       []string
+	// how often new issues are checked for, defaultIssuesDigestMinutes
+	// if 0
+	DigestMinutes int
+}
+
+const defaultIssuesDigestMinutes = 60
+
+func validateSMTPConfig(c *SMTPConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.Host == "" || c.Port <= 0 {
+		return errors.New("SMTP.Host and SMTP.Port must be set")
+	}
+	if !strings.Contains(c.From, "@") || len(c.To) == 0 {
+		return errors.New("SMTP.From and SMTP.To must be set")
+	}
+	for _, to := range c.To {
+		if !strings.Contains(to, "@") {
+			return fmt.Errorf("invalid SMTP.To address %q", to)
+		}
+	}
+	if c.DigestMinutes < 0 {
+		return errors.New("SMTP.DigestMinutes must not be negative")
+	}
+	return nil
+}
+
+func issuesDigestFreq(c *SMTPConfig) time.Duration {
+	if c.DigestMinutes > 0 {
+		return time.Duration(c.DigestMinutes) * time.Minute
+	}
+	return defaultIssuesDigestMinutes * time.Minute
+}
+
+// sendMail sends an email. A variable so that tests can fake it
+var sendMail = smtp.SendMail
+
+func issueKey(issue *Issue) string {
+	return strings.Join([]string{issue.Kind, issue.Lang, issue.Source, issue.Translation, issue.Msg}, "\x00")
+}
+
+// issueTracker remembers issues of apps that were already reported
+type issueTracker struct {
+	sync.Mutex
+	// by app name
+	seen       map[string]map[string]bool
+	generation map[string]int
+	// issues found by newIssues() and not yet reported, by app name
+	pending           map[string]map[string]bool
+	pendingGeneration map[string]int
+}
+
+func newIssueTracker() *issueTracker {
+	return &issueTracker{
+		seen:              make(map[string]map[string]bool),
+		generation:        make(map[string]int),
+		pending:           make(map[string]map[string]bool),
+		pendingGeneration: make(map[string]int),
+	}
+}
+
+// newIssues returns issues of app that were not reported yet, see
+// markReported(). Issues that were fixed are forgotten, so they're reported
+// again if they come back. Apps whose store didn't change since the last
+// report are not checked
+func (t *issueTracker) newIssues(app *App) []Issue {
+	t.Lock()
+	defer t.Unlock()
+	gen := app.store.Generation()
+	if seen := t.seen[app.Name]; seen != nil && t.generation[app.Name] == gen {
+		return nil
+	}
+	seen := t.seen[app.Name]
+	current := make(map[string]bool)
+	var res []Issue
+	for _, issue := range app.AllIssues() {
+		key := issueKey(&issue)
+		current[key] = true
+		if !seen[key] {
+			res = append(res, issue)
+		}
+	}
+	t.pending[app.Name] = current
+	t.pendingGeneration[app.Name] = gen
+	return res
+}
+
+// markReported remembers issues returned by the last newIssues() of app as
+// reported
+func (t *issueTracker) markReported(app *App) {
+	t.Lock()
+	defer t.Unlock()
+	current, ok := t.pending[app.Name]
+	if !ok {
+		return
+	}
+	t.seen[app.Name] = current
+	t.generation[app.Name] = t.pendingGeneration[app.Name]
+	delete(t.pending, app.Name)
+	delete(t.pendingGeneration, app.Name)
+}
+
+// buildIssuesDigest returns the text of an email listing issues of apps
+func buildIssuesDigest(issues map[string][]Issue) string {
+	var appNames []string
+	for name := range issues {
+		appNames = append(appNames, name)
+	}
+	sort.Strings(appNames)
+	var buf bytes.Buffer
+	for _, name := range appNames {
+		appIssues := issues[name]
+		fmt.Fprintf(&buf, "%s: %d new issues\n\n", name, len(appIssues))
+		for _, issue := range appIssues {
+			fmt.Fprintf(&buf, "[%s] %s: %s\n", issue.Lang, issue.Kind, issue.Msg)
+			fmt.Fprintf(&buf, "  source:      %s\n", issue.Source)
+			fmt.Fprintf(&buf, "  translation: %s\n", issue.Translation)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func sendIssuesDigest(c *SMTPConfig, issues map[string][]Issue) error {
+	n := 0
+	for _, appIssues := range issues {
+		n += len(appIssues)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: AppTranslator: %d new translation issues\r\n", n)
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.Replace(buildIssuesDigest(issues), "\n", "\r\n", -1))
+	var auth smtp.Auth
+	if c.User != "" {
+		auth = smtp.PlainAuth("", c.User, c.Password, c.Host)
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	return sendMail(addr, auth, c.From, c.To, msg.Bytes())
+}
+
+// notifyNewIssues emails a digest of issues found in apps since the last
+// successfully sent digest. Nothing is sent if there are no new issues. If
+// sending fails, the issues are sent with the next digest
+func notifyNewIssues(c *SMTPConfig, tracker *issueTracker, apps []*App) {
+	issues := make(map[string][]Issue)
+	for _, app := range apps {
+		if appIssues := tracker.newIssues(app); len(appIssues) > 0 {
+			issues[app.Name] = appIssues
+		}
+	}
+	if len(issues) > 0 {
+		if err := sendIssuesDigest(c, issues); err != nil {
+			logger.Errorf("sendIssuesDigest() failed with %s", err)
+			return
+		}
+	}
+	for _, app := range apps {
+		tracker.markReported(app)
+	}
+}
+
+// issuesDigestLoop periodically emails digests of new issues. Issues that
+// exist at startup are not reported
+func issuesDigestLoop(c *SMTPConfig) {
+	tracker := newIssueTracker()
+	for _, app := range appState.Apps {
+		tracker.newIssues(app)
+		tracker.markReported(app)
+	}
+	for {
+		clock.Sleep(issuesDigestFreq(c))
+		notifyNewIssues(c, tracker, appState.Apps)
+	}
+}
@@ -0,0 +1,90 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestIssuesDigest(t *testing.T) {
+	app := newTestApp(t, "notify", []string{"Open %s", "Close %d"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Close %d", "Zamknij", "pl", "user1")
+
+	var sent []string
+	var sentTo []string
+	sendErr := error(nil)
+	defer func(orig func(string, smtp.Auth, string, []string, []byte) error) { sendMail = orig }(sendMail)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" || from != "at@example.com" {
+			t.Errorf("got addr %q, from %q", addr, from)
+		}
+		sent = append(sent, string(msg))
+		sentTo = to
+		return sendErr
+	}
+	c := &SMTPConfig{Host: "smtp.example.com", Port: 587, From: "at@example.com", To: []string{"dev@example.com"}}
+	if err := validateSMTPConfig(c); err != nil {
+		t.Fatalf("validateSMTPConfig() failed with %s", err)
+	}
+
+	// issues existing when tracking starts are not reported
+	tracker := newIssueTracker()
+	if issues := tracker.newIssues(app); len(issues) != 1 {
+		t.Fatalf("got %d issues, expected 1", len(issues))
+	}
+	tracker.markReported(app)
+	notifyNewIssues(c, tracker, []*App{app})
+	if len(sent) != 0 {
+		t.Fatalf("sent %d emails without new issues", len(sent))
+	}
+
+	writeTestTranslation(t, app, "Open %s", "Otwórz %d", "pl", "user1")
+	notifyNewIssues(c, tracker, []*App{app})
+	if len(sent) != 1 {
+		t.Fatalf("sent %d emails, expected 1", len(sent))
+	}
+	msg := sent[0]
+	for _, s := range []string{"To: dev@example.com\r\n", "Subject: AppTranslator: 2 new translation issues", "notify: 2 new issues", "[pl] placeholder: missing %s", "[pl] placeholder: extra %d", "translation: Otwórz %d"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("digest doesn't contain %q:\n%s", s, msg)
+		}
+	}
+	if strings.Contains(msg, "Zamknij") {
+		t.Errorf("digest contains an already reported issue:\n%s", msg)
+	}
+	if len(sentTo) != 1 || sentTo[0] != "dev@example.com" {
+		t.Errorf("sent to %v", sentTo)
+	}
+
+	// nothing new
+	notifyNewIssues(c, tracker, []*App{app})
+	if len(sent) != 1 {
+		t.Fatalf("sent %d emails, expected 1", len(sent))
+	}
+
+	// failures are logged and issues are sent again with the next digest
+	sendErr = errors.New("connection refused")
+	writeTestTranslation(t, app, "Close %d", "Zamknij %s", "pl", "user1")
+	notifyNewIssues(c, tracker, []*App{app})
+	if len(sent) != 2 {
+		t.Fatalf("sent %d emails, expected 2", len(sent))
+	}
+	sendErr = nil
+	notifyNewIssues(c, tracker, []*App{app})
+	if len(sent) != 3 || !strings.Contains(sent[2], "translation: Zamknij %s") {
+		t.Fatalf("sent %d emails, failed digest wasn't sent again", len(sent))
+	}
+	notifyNewIssues(c, tracker, []*App{app})
+	if len(sent) != 3 {
+		t.Fatalf("sent %d emails, expected 3", len(sent))
+	}
+
+	for _, c := range []*SMTPConfig{{Port: 25, From: "a@b", To: []string{"c@d"}}, {Host: "h", Port: 25, From: "a@b"}, {Host: "h", Port: 25, From: "a@b", To: []string{"nope"}}} {
+		if validateSMTPConfig(c) == nil {
+			t.Errorf("expected an error for %#v", c)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// outbound requests to Twitter, S3 and repositories of webhooks are
+// aborted after this time, so that a hung server doesn't block goroutines
+// forever
+const defaultOutboundTimeout = 30 * time.Second
+
+// uploads of backups to S3 can take much longer than other requests
+const s3ReadTimeout = 10 * time.Minute
+
+func outboundTimeout() time.Duration {
+	return timeoutOrDefault(config.OutboundTimeoutSecs, defaultOutboundTimeout)
+}
+
+// outboundContext returns a context for an outbound request made on behalf
+// of parent (e.g. context of the request we're handling), which is
+// cancelled after outboundTimeout()
+func outboundContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, outboundTimeout())
+}
+
+// outboundClient returns a client for libraries that make requests with
+// a given http.Client and don't take a context, like oauth
+func outboundClient() *http.Client {
+	return &http.Client{Timeout: outboundTimeout()}
+}
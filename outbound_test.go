@@ -0,0 +1,56 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/garyburd/go-oauth/oauth"
+)
+
+// newSlowServer returns a server that doesn't respond until the client
+// gives up or done is closed
+func newSlowServer(done chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+}
+
+func TestOutboundTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := newSlowServer(done)
+	defer srv.Close()
+	defer close(done)
+	defer func() { config.OutboundTimeoutSecs = 0 }()
+
+	if d := outboundTimeout(); d != defaultOutboundTimeout {
+		t.Fatalf("got timeout %s, expected %s", d, defaultOutboundTimeout)
+	}
+	config.OutboundTimeoutSecs = 1
+	if d := outboundClient().Timeout; d != time.Second {
+		t.Fatalf("got client timeout %s, expected 1s", d)
+	}
+
+	// aborted at the configured timeout
+	start := time.Now()
+	var info map[string]interface{}
+	err := getTwitter(context.Background(), &oauth.Credentials{}, srv.URL, nil, &info)
+	if dur := time.Since(start); err == nil || dur < time.Second || dur > 5*time.Second {
+		t.Fatalf("getTwitter() returned %v after %s, expected a timeout after 1s", err, dur)
+	}
+
+	// and when the request we're handling is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = fetchRepoFile(ctx, srv.URL, "")
+	if dur := time.Since(start); err == nil || dur > time.Second {
+		t.Fatalf("fetchRepoFile() returned %v after %s, expected to be cancelled", err, dur)
+	}
+}
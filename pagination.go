@@ -0,0 +1,97 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultPerPage = 200
+	maxPerPage     = 1000
+)
+
+// Pagination describes a page of a list of items. Pages are numbered from 1
+type Pagination struct {
+	Page      int
+	PerPage   int
+	Total     int
+	PageCount int
+	// url of the page without page argument
+	baseURL *url.URL
+}
+
+func newPagination(u *url.URL, total, page, perPage int) *Pagination {
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	if page <= 0 {
+		page = 1
+	}
+	pageCount := (total + perPage - 1) / perPage
+	if pageCount == 0 {
+		pageCount = 1
+	}
+	return &Pagination{
+		Page:      page,
+		PerPage:   perPage,
+		Total:     total,
+		PageCount: pageCount,
+		baseURL:   u,
+	}
+}
+
+// Bounds returns range of items on the current page. They're empty if
+// page is out of range
+func (p *Pagination) Bounds() (int, int) {
+	// (Page - 1) * PerPage could overflow for a huge page
+	if p.IsOutOfRange() {
+		return p.Total, p.Total
+	}
+	start := (p.Page - 1) * p.PerPage
+	end := start + p.PerPage
+	if end > p.Total {
+		end = p.Total
+	}
+	return start, end
+}
+
+func (p *Pagination) IsOutOfRange() bool {
+	return p.Page > p.PageCount
+}
+
+func (p *Pagination) HasPrev() bool {
+	return p.Page > 1
+}
+
+func (p *Pagination) HasNext() bool {
+	return p.Page < p.PageCount
+}
+
+func (p *Pagination) PrevPage() int {
+	return p.Page - 1
+}
+
+func (p *Pagination) NextPage() int {
+	return p.Page + 1
+}
+
+// IsPaged returns true if there is more than one page
+func (p *Pagination) IsPaged() bool {
+	return p.PageCount > 1
+}
+
+// URL returns url of a given page, preserving other arguments
+func (p *Pagination) URL(page int) string {
+	u := *p.baseURL
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	if p.PerPage != defaultPerPage {
+		q.Set("per", strconv.Itoa(p.PerPage))
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
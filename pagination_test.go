@@ -0,0 +1,86 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPagination(t *testing.T) {
+	u := &url.URL{Path: "/app/a/pl", RawQuery: "per=2"}
+	tests := []struct {
+		page, total     int
+		start, end      int
+		hasPrev, hasNxt bool
+		outOfRange      bool
+	}{
+		{1, 5, 0, 2, false, true, false},
+		{2, 5, 2, 4, true, true, false},
+		{3, 5, 4, 5, true, false, false},
+		{4, 5, 5, 5, true, false, true},
+		{1, 0, 0, 0, false, false, false},
+		{4611686018427387904, 5, 5, 5, true, false, true},
+	}
+	for _, test := range tests {
+		p := newPagination(u, test.total, test.page, 2)
+		start, end := p.Bounds()
+		if start != test.start || end != test.end {
+			t.Fatalf("page %d of %d: got bounds %d-%d, expected %d-%d", test.page, test.total, start, end, test.start, test.end)
+		}
+		if p.HasPrev() != test.hasPrev || p.HasNext() != test.hasNxt || p.IsOutOfRange() != test.outOfRange {
+			t.Fatalf("page %d of %d: got %#v", test.page, test.total, p)
+		}
+	}
+	if got := newPagination(u, 5, 1, 2).URL(3); got != "/app/a/pl?page=3&per=2" {
+		t.Fatalf("got %q", got)
+	}
+	if got := newPagination(u, 5, 1, maxPerPage+1).PerPage; got != maxPerPage {
+		t.Fatalf("got %d, expected %d", got, maxPerPage)
+	}
+}
+
+func TestAppTranslationsPagination(t *testing.T) {
+	app := newTestApp(t, "paged", []string{"Open", "Close", "Save", "About", "Exit"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+
+	model := buildModelAppTranslations(app, "pl", "")
+	strs := func() []string {
+		var res []string
+		for _, tr := range model.Strings {
+			res = append(res, tr.String)
+		}
+		return res
+	}
+	model.paginate(&url.URL{Path: "/app/paged/pl"}, statusAll, sortDefault, 1, 2)
+	if exp := []string{"About", "Close"}; !reflect.DeepEqual(strs(), exp) {
+		t.Fatalf("got %v, expected %v", strs(), exp)
+	}
+	model.paginate(&url.URL{Path: "/app/paged/pl"}, statusAll, sortDefault, 3, 2)
+	if exp := []string{"Open"}; !reflect.DeepEqual(strs(), exp) {
+		t.Fatalf("got %v, expected %v", strs(), exp)
+	}
+	if model.Pagination.Total != 5 || model.Pagination.PageCount != 3 || model.Pagination.HasNext() {
+		t.Fatalf("got %#v", model.Pagination)
+	}
+	model.paginate(&url.URL{Path: "/app/paged/pl"}, statusAll, sortDefault, 4, 2)
+	if len(model.Strings) != 0 || !model.Pagination.IsOutOfRange() {
+		t.Fatalf("got %v, %#v", strs(), model.Pagination)
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/app/paged/pl?page=9&per=2", nil))
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), "There is no page 9") {
+		t.Fatalf("got status %d, body %s", rr.Code, rr.Body.String())
+	}
+	for _, url := range []string{"/app/paged/pl?page=0", "/app/paged/pl?page=x", "/app/paged/pl?per=0"} {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		if rr.Code != 400 {
+			t.Fatalf("%s: got status %d, expected 400", url, rr.Code)
+		}
+	}
+}
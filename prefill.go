@@ -0,0 +1,66 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// translations prefilled with source strings are attributed to this user
+const prefillUser = "prefill"
+
+// PrefillResult is returned by /prefillsource/{appname}/{lang}
+type PrefillResult struct {
+	App  string `json:"app"`
+	Lang string `json:"lang"`
+	// number of strings prefilled with source
+	Filled int `json:"filled"`
+}
+
+// prefillSource translates untranslated strings of the app into lang with
+// the source strings, marked as fuzzy so that translators replace them.
+// Strings that should not be translated are skipped
+func prefillSource(app *App, lang string) (int, error) {
+	n := 0
+	for _, src := range app.Untranslated(lang) {
+		if err := app.store.WriteFuzzyTranslation(src, src, lang, prefillUser); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// url: POST /prefillsource/{appname}/{lang}
+// Bootstraps a new language by copying source strings to untranslated
+// strings, so that translators edit them in place
+func handlePrefillSource(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	vars := mux.Vars(r)
+	app := findApp(vars["appname"])
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", vars["appname"])
+		return
+	}
+	lang, err := store.ParseLangCode(vars["lang"])
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	if !userIsAdmin(app, decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't prefill translations of this app")
+		return
+	}
+	res := &PrefillResult{App: app.Name, Lang: lang}
+	if res.Filled, err = prefillSource(app, lang); err != nil {
+		logger.ForRequest(r).Errorf("handlePrefillSource(): prefillSource() failed with %s", err)
+		http.Error(w, "Failed to prefill translations", http.StatusInternalServerError)
+		return
+	}
+	logger.ForRequest(r).Noticef("handlePrefillSource(): prefilled %d strings of %s in %s", res.Filled, app.Name, lang)
+	serveJSON(w, res)
+}
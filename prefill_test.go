@@ -0,0 +1,58 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefillSource(t *testing.T) {
+	app := newTestApp(t, "prefill", []string{"Open", "Close", "Save", "SumatraPDF"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	if err := app.store.SetNoTranslate("SumatraPDF", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	prefill := func(user, lang string) (int, *PrefillResult) {
+		r := newRequestWithCookie("POST", "/prefillsource/prefill/"+lang, &SecureCookieValue{User: user})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		var res PrefillResult
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+	if code, _ := prefill("user1", "pl"); code != 400 {
+		t.Fatalf("got status %d for not admin, expected 400", code)
+	}
+	if code, _ := prefill("admin", "xx"); code != 400 {
+		t.Fatalf("got status %d for unknown lang, expected 400", code)
+	}
+	code, res := prefill("admin", "pl")
+	if code != 200 || *res != (PrefillResult{App: "prefill", Lang: "pl", Filled: 2}) {
+		t.Fatalf("got status %d, %#v", code, res)
+	}
+	current := currentTranslations(app)["pl"]
+	exp := map[string]string{"Open": "Otwórz", "Close": "Close", "Save": "Save", "SumatraPDF": ""}
+	for str, trans := range exp {
+		if current[str] != trans {
+			t.Errorf("%q: got translation %q, expected %q", str, current[str], trans)
+		}
+		fuzzy := str == "Close" || str == "Save"
+		if app.store.IsFuzzy(str, "pl") != fuzzy {
+			t.Errorf("%q: expected fuzzy to be %v", str, fuzzy)
+		}
+	}
+	// other languages are not changed
+	if current := currentTranslations(app)["de"]; current["Close"] != "" {
+		t.Fatalf("de was prefilled: %v", current)
+	}
+	if code, res = prefill("admin", "pl"); code != 200 || res.Filled != 0 {
+		t.Fatalf("got status %d, %#v prefilling again", code, res)
+	}
+}
@@ -0,0 +1,72 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// renderPlaceholders substitutes args for placeholders in s. Printf-style
+// placeholders take args in order, unless they have explicit position
+// (%2$s is the second arg). {n} placeholders take n-th arg, counting from 0.
+// Placeholders without an arg, like {name}, are left as they are
+func renderPlaceholders(s string, args []string) string {
+	next := 0
+	return rePlaceholder.ReplaceAllStringFunc(s, func(ph string) string {
+		if ph == "%%" {
+			return "%"
+		}
+		var idx int
+		if strings.HasPrefix(ph, "{") {
+			n, err := strconv.Atoi(ph[1 : len(ph)-1])
+			if err != nil {
+				return ph
+			}
+			idx = n
+		} else if pos := strings.Index(ph, "$"); pos != -1 {
+			n, _ := strconv.Atoi(ph[1:pos])
+			idx = n - 1
+		} else {
+			idx = next
+			next++
+		}
+		if idx < 0 || idx >= len(args) {
+			return ph
+		}
+		return args[idx]
+	})
+}
+
+// Preview is returned by /preview
+type Preview struct {
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+	// placeholder mismatches between source and translation
+	Problems []string `json:"problems"`
+}
+
+// url: GET /preview?source=$source&translation=$translation&arg=$arg1&arg=$arg2
+// Returns Preview with source and translation rendered with sample values
+// of placeholders
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "GET") {
+		return
+	}
+	source := r.FormValue("source")
+	if source == "" {
+		serveJSONError(w, http.StatusBadRequest, "Missing source")
+		return
+	}
+	translation := r.FormValue("translation")
+	args := r.Form["arg"]
+	res := &Preview{
+		Source:      renderPlaceholders(source, args),
+		Translation: renderPlaceholders(translation, args),
+		Problems:    placeholderProblems(source, translation),
+	}
+	if res.Problems == nil {
+		res.Problems = []string{}
+	}
+	serveJSON(w, res)
+}
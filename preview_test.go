@@ -0,0 +1,64 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRenderPlaceholders(t *testing.T) {
+	args := []string{"report.pdf", "3"}
+	tests := []struct {
+		s   string
+		exp string
+	}{
+		{"Open %s", "Open report.pdf"},
+		{"%s has %d pages, 100%%", "report.pdf has 3 pages, 100%"},
+		{"%2$d pages in %1$s", "3 pages in report.pdf"},
+		{"{1} pages in {0}", "3 pages in report.pdf"},
+		{"Hello {name}", "Hello {name}"},
+		{"%s %s %s", "report.pdf 3 %s"},
+	}
+	for _, test := range tests {
+		if got := renderPlaceholders(test.s, args); got != test.exp {
+			t.Errorf("renderPlaceholders(%q): got %q, expected %q", test.s, got, test.exp)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	get := func(source, translation string, args ...string) (int, *Preview) {
+		q := url.Values{"source": {source}, "translation": {translation}, "arg": args}
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/preview?"+q.Encode(), nil))
+		var res Preview
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+
+	code, res := get("%1$s has %2$d pages", "%2$d stron w %1$s", "report.pdf", "3")
+	exp := &Preview{Source: "report.pdf has 3 pages", Translation: "3 stron w report.pdf", Problems: []string{}}
+	if code != 200 || !reflect.DeepEqual(res, exp) {
+		t.Fatalf("got %d %#v, expected %#v", code, res, exp)
+	}
+
+	// translation without a placeholder renders, and the problem is reported
+	code, res = get("Open {0}", "Otwórz", "report.pdf")
+	if code != 200 || res.Source != "Open report.pdf" || res.Translation != "Otwórz" {
+		t.Fatalf("got %d %#v", code, res)
+	}
+	if !reflect.DeepEqual(res.Problems, []string{"missing {0}"}) {
+		t.Fatalf("got problems %v", res.Problems)
+	}
+
+	if code, _ = get("", "Otwórz"); code != 400 {
+		t.Fatalf("missing source: got status %d, expected 400", code)
+	}
+}
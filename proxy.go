@@ -0,0 +1,108 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// networks of reverse proxies (load balancers) whose X-Forwarded-For and
+// X-Forwarded-Proto headers we trust, from config.TrustedProxies
+var trustedProxyNets []*net.IPNet
+
+// parseTrustedProxies parses a list of CIDRs (e.g. "10.0.0.0/8"). Single
+// ip addresses are also accepted
+func parseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	var res []*net.IPNet
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", s)
+		}
+		res = append(res, ipNet)
+	}
+	return res, nil
+}
+
+func isTrustedProxy(ipStr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(ipStr))
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trustedProxyNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns ip address of the client. If the request came from
+// a trusted proxy, it's the right-most address in X-Forwarded-For that is
+// not a trusted proxy. Headers from untrusted peers are ignored because
+// they can be spoofed
+func clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+	var forwarded []string
+	for _, h := range r.Header["X-Forwarded-For"] {
+		forwarded = append(forwarded, strings.Split(h, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			break
+		}
+		ip = addr
+		if !isTrustedProxy(addr) {
+			break
+		}
+	}
+	return ip
+}
+
+// isHTTPS returns true if the client connected over https, either directly
+// or to a trusted proxy that forwarded the request
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !isTrustedProxy(remoteIP(r)) {
+		return false
+	}
+	proto := r.Header.Get("X-Forwarded-Proto")
+	// with multiple proxies, the first value is from the proxy closest to client
+	proto = strings.TrimSpace(strings.Split(proto, ",")[0])
+	return strings.EqualFold(proto, "https")
+}
+
+func requestScheme(r *http.Request) string {
+	if isHTTPS(r) {
+		return "https"
+	}
+	return "http"
+}
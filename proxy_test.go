@@ -0,0 +1,58 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func() { trustedProxyNets = nil }()
+	var err error
+	trustedProxyNets, err = parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() failed with %s", err)
+	}
+	if _, err = parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("parseTrustedProxies() should fail for invalid cidr")
+	}
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		proto      string
+		expIP      string
+		expHTTPS   bool
+	}{
+		// trusted proxies
+		{"10.1.2.3:1234", "1.2.3.4", "https", "1.2.3.4", true},
+		{"192.168.1.1:1234", "1.2.3.4", "http", "1.2.3.4", false},
+		// client can prepend spoofed addresses, we take the last untrusted one
+		{"10.1.2.3:1234", "6.6.6.6, 1.2.3.4, 10.0.0.5", "https", "1.2.3.4", true},
+		{"10.1.2.3:1234", "", "", "10.1.2.3", false},
+		// untrusted peers
+		{"1.2.3.4:1234", "5.6.7.8", "https", "1.2.3.4", false},
+		{"192.168.1.2:1234", "5.6.7.8", "https", "192.168.1.2", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if test.proto != "" {
+			r.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+		if ip := clientIP(r); ip != test.expIP {
+			t.Fatalf("%s, %q: got ip %s, expected %s", test.remoteAddr, test.forwarded, ip, test.expIP)
+		}
+		if isHTTPS(r) != test.expHTTPS {
+			t.Fatalf("%s, %q: isHTTPS() is %v", test.remoteAddr, test.proto, !test.expHTTPS)
+		}
+		rr := httptest.NewRecorder()
+		setSecureCookie(rr, r, &SecureCookieValue{User: "user1"})
+		if c := rr.Result().Cookies()[0]; c.Secure != (test.expHTTPS || *inProduction) {
+			t.Fatalf("%s, %q: cookie Secure is %v", test.remoteAddr, test.proto, c.Secure)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// message of 503 responses to mutating requests in read-only mode
+const readOnlyMsg = "AppTranslator is in read-only mode for maintenance, changes are not allowed right now"
+
+// readOnly is true when changes are not allowed, e.g. during backups or
+// migrations. Starts as config.ReadOnly and can be toggled by an admin
+// with /admin/readonly
+var (
+	readOnlyMu sync.Mutex
+	readOnly   bool
+)
+
+func isReadOnly() bool {
+	readOnlyMu.Lock()
+	defer readOnlyMu.Unlock()
+	return readOnly
+}
+
+func setReadOnly(on bool) {
+	readOnlyMu.Lock()
+	readOnly = on
+	readOnlyMu.Unlock()
+}
+
+// makeMutatingHandler wraps handlers that change data (edits, uploads,
+// imports, app management) so that they fail with 503 in read-only mode
+func makeMutatingHandler(fn func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return makeReadOnlyHandler(fn, func(w http.ResponseWriter, status int, msg string) {
+		http.Error(w, msg, status)
+	})
+}
+
+// makeMutatingJSONHandler is makeMutatingHandler for handlers called by
+// programs, e.g. webhooks, which get the error as json
+func makeMutatingJSONHandler(fn func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return makeReadOnlyHandler(fn, serveJSONError)
+}
+
+// makeReadOnlyHandler wraps fn so that in read-only mode serveError responds
+// with 503 instead of calling fn
+func makeReadOnlyHandler(fn func(http.ResponseWriter, *http.Request), serveError func(http.ResponseWriter, int, string)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnly() {
+			serveError(w, http.StatusServiceUnavailable, readOnlyMsg)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+// ReadOnlyResult is returned by /admin/readonly
+type ReadOnlyResult struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// url: POST /admin/readonly?on=$on
+// Turns read-only mode on (on=1) or off (on=0) until the server restarts.
+// It affects all apps, so only the server admin can change it
+func handleReadOnly(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	user := decodeUserFromCookie(r)
+	if !userIsServerAdmin(user) {
+		httpErrorf(w, "User can't change read-only mode")
+		return
+	}
+	on := strings.TrimSpace(r.FormValue("on"))
+	if on != "0" && on != "1" {
+		httpErrorf(w, "Invalid on %q, must be 0 or 1", on)
+		return
+	}
+	setReadOnly(on == "1")
+	logger.ForRequest(r).Noticef("handleReadOnly(): %s set read-only mode to %v", user, on == "1")
+	serveJSON(w, &ReadOnlyResult{ReadOnly: isReadOnly()})
+}
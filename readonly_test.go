@@ -0,0 +1,59 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	app := newTestApp(t, "readonly", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	setReadOnly(true)
+	defer setReadOnly(false)
+
+	serve := func(method, url, user string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r := newRequestWithCookie(method, url, &SecureCookieValue{User: user})
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	edit := "/edittranslation?app=readonly&lang=pl&string=Open&translation=Otwieraj"
+	rr := serve("POST", edit, "user1")
+	if rr.Code != 503 || !strings.Contains(rr.Body.String(), "read-only") {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if n := len(app.store.EditsByUser("user1")); n != 1 {
+		t.Fatalf("got %d edits in read-only mode, expected 1", n)
+	}
+	rr = serve("GET", "/export?app=readonly&lang=pl&format=csv", "")
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), "Otwórz") {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+
+	// programs get the error as json
+	rr = serve("POST", "/webhook/readonly", "")
+	if rr.Code != 503 || rr.Header().Get("Content-Type") != "application/json" || !strings.Contains(rr.Body.String(), `"error"`) {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+
+	// admin of an app can't freeze other apps
+	for _, user := range []string{"user1", "admin"} {
+		if rr = serve("POST", "/admin/readonly?on=0", user); rr.Code != 400 || !isReadOnly() {
+			t.Fatalf("%s: got status %d, read-only %v", user, rr.Code, isReadOnly())
+		}
+	}
+	root, done := setTestServerAdmin()
+	defer done()
+	if rr = serve("POST", "/admin/readonly?on=0", root); rr.Code != 200 || isReadOnly() {
+		t.Fatalf("admin: got status %d, read-only %v", rr.Code, isReadOnly())
+	}
+	if rr = serve("POST", edit, "user1"); rr.Code != 302 {
+		t.Fatalf("got status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if n := len(app.store.EditsByUser("user1")); n != 2 {
+		t.Fatalf("got %d edits, expected 2", n)
+	}
+}
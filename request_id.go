@@ -0,0 +1,84 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+func genRequestID() string {
+	var d [8]byte
+	rand.Read(d[:])
+	return hex.EncodeToString(d[:])
+}
+
+// we log request ids so only accept short ids made of safe characters
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID assigns an id to each request, taken from X-Request-Id
+// header or generated. It's stored in request context, returned in
+// X-Request-Id response header and added to messages logged with
+// logger.ForRequest()
+func withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = genRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestID returns id of the request, "" if it wasn't assigned
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// RequestLogger logs messages related to a single request
+type RequestLogger struct {
+	l  *ServerLogger
+	id string
+}
+
+// ForRequest returns a logger that prefixes messages with request id
+func (l *ServerLogger) ForRequest(r *http.Request) *RequestLogger {
+	return &RequestLogger{l: l, id: requestID(r)}
+}
+
+func (rl *RequestLogger) prefix(s string) string {
+	if rl.id == "" {
+		return s
+	}
+	return fmt.Sprintf("[%s] %s", rl.id, s)
+}
+
+func (rl *RequestLogger) Noticef(format string, v ...interface{}) {
+	rl.l.Notice(rl.prefix(fmt.Sprintf(format, v...)))
+}
+
+func (rl *RequestLogger) Errorf(format string, v ...interface{}) {
+	rl.l.Error(rl.prefix(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,49 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var gotID string
+	h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = requestID(r)
+		logger.ForRequest(r).Noticef("test message")
+	}))
+
+	tests := []struct {
+		header   string
+		preserve bool
+	}{
+		{"req-123", true},
+		{"", false},
+		{"bad id\nwith newline", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			r.Header.Set(requestIDHeader, test.header)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		if gotID == "" || rr.Header().Get(requestIDHeader) != gotID {
+			t.Fatalf("%q: request id %q not returned in response, got %q", test.header, gotID, rr.Header().Get(requestIDHeader))
+		}
+		if test.preserve != (gotID == test.header) {
+			t.Fatalf("%q: got request id %q", test.header, gotID)
+		}
+		msg := logger.GetNotices()[0].Msg
+		if msg != "["+gotID+"] test message" {
+			t.Fatalf("request id not logged, got %q", msg)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/version", nil))
+	if rr.Header().Get(requestIDHeader) == "" {
+		t.Fatalf("no request id in response")
+	}
+}
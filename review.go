@@ -0,0 +1,116 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/kjk/apptranslator/store"
+)
+
+// canChangeState returns true if user can change state of a translation
+// in app from state from to state to. Every translator can mark
+// translations as needing a review and confirm them, only reviewers can
+// approve translations or revoke approvals
+func canChangeState(app *App, user string, from, to store.State) bool {
+	if user == "" || !store.IsValidTransition(from, to) {
+		return false
+	}
+	if from == store.StateApproved || to == store.StateApproved {
+		return userIsReviewer(app, user)
+	}
+	return true
+}
+
+// countStates returns number of translations in each state
+func countStates(li *store.LangInfo) map[store.State]int {
+	res := make(map[store.State]int)
+	for _, state := range store.States {
+		res[state] = 0
+	}
+	for _, tr := range li.ActiveStrings {
+		if !tr.NoTranslate {
+			res[tr.State()]++
+		}
+	}
+	return res
+}
+
+// url: /setstate?app=${app}&lang=${lang}&string=${string}&state=${state}
+// Changes the state of a translation in the review workflow, see
+// store.State
+func handleSetState(w http.ResponseWriter, r *http.Request) {
+	app, langCode := getAppLangArg(w, r)
+	if app == nil {
+		return
+	}
+	str := strings.TrimSpace(r.FormValue("string"))
+	if !app.store.IsActiveString(str) {
+		httpErrorf(w, "String %q doesn't exist", str)
+		return
+	}
+	state := store.State(strings.TrimSpace(r.FormValue("state")))
+	if !store.IsValidState(state) {
+		httpErrorf(w, "Invalid state %q", state)
+		return
+	}
+	user := decodeUserFromCookie(r)
+	from := app.store.State(str, langCode)
+	if !canChangeState(app, user, from, state) {
+		httpErrorf(w, "User %q can't change state of %q from %s to %s", user, str, from, state)
+		return
+	}
+	if err := app.store.SetState(str, langCode, state); err != nil {
+		httpErrorf(w, "Failed to change state %q", err)
+		return
+	}
+	logger.ForRequest(r).Noticef("%s changed state of %q in %s of %s from %s to %s", user, str, langCode, app.Name, from, state)
+	msg := fmt.Sprintf("Changed state of %q to %s", str, state)
+	url := fmt.Sprintf("/app/%s/%s?msg=%s", app.Name, langCode, url.QueryEscape(msg))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// ApproveAllResult is returned by /approveall/{appname}/{lang}
+type ApproveAllResult struct {
+	App  string `json:"app"`
+	Lang string `json:"lang"`
+	// number of translations that changed state to approved
+	Approved int `json:"approved"`
+}
+
+// url: POST /approveall/{appname}/{lang}
+// Approves all translated and needs review translations of lang, e.g.
+// after a review done outside of apptranslator. Untranslated strings and
+// strings that shouldn't be translated are skipped
+func handleApproveAll(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	vars := mux.Vars(r)
+	app := findApp(vars["appname"])
+	if app == nil {
+		httpErrorf(w, "Application %q doesn't exist", vars["appname"])
+		return
+	}
+	langCode, err := store.ParseLangCode(vars["lang"])
+	if err != nil {
+		httpErrorf(w, "%s", err)
+		return
+	}
+	user := decodeUserFromCookie(r)
+	if !userIsReviewer(app, user) {
+		httpErrorf(w, "User %q can't approve translations of this app", user)
+		return
+	}
+	res := &ApproveAllResult{App: app.Name, Lang: langCode}
+	if res.Approved, err = app.store.ApproveAll(langCode); err != nil {
+		logger.ForRequest(r).Errorf("handleApproveAll(): ApproveAll() failed with %s", err)
+		http.Error(w, "Failed to approve translations", http.StatusInternalServerError)
+		return
+	}
+	logger.ForRequest(r).Noticef("%s approved %d translations in %s of %s", user, res.Approved, langCode, app.Name)
+	serveJSON(w, res)
+}
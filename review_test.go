@@ -0,0 +1,167 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestReviewWorkflow(t *testing.T) {
+	app := newTestApp(t, "review", []string{"Open", "Close", "Save"})
+	defer closeTestApp(app)
+	app.Reviewers = []string{"reviewer1"}
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+
+	setState := func(user, str, state string) int {
+		q := url.Values{"app": {"review"}, "lang": {"pl"}, "string": {str}, "state": {state}}
+		r := newRequestWithCookie("GET", "/setstate?"+q.Encode(), &SecureCookieValue{User: user})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	tests := []struct {
+		user  string
+		str   string
+		state store.State
+		ok    bool
+	}{
+		// not logged in
+		{"", "Open", store.StateNeedsReview, false},
+		// only reviewers approve
+		{"user1", "Open", store.StateApproved, false},
+		{"user1", "Open", store.StateNeedsReview, true},
+		{"user1", "Open", store.StateTranslated, true},
+		{"reviewer1", "Open", store.StateApproved, true},
+		// and revoke approvals
+		{"user1", "Open", store.StateTranslated, false},
+		{"user1", "Open", store.StateNeedsReview, false},
+		{"reviewer1", "Open", store.StateNeedsReview, true},
+		{"admin", "Open", store.StateApproved, true},
+		{"reviewer1", "Open", store.StateTranslated, true},
+		{"reviewer1", "Open", store.StateApproved, true},
+		// untranslated strings can't change state
+		{"reviewer1", "Save", store.StateApproved, false},
+		{"reviewer1", "Save", store.StateNeedsReview, false},
+		{"reviewer1", "Open", store.StateUntranslated, false},
+		{"reviewer1", "Open", "bogus", false},
+	}
+	expected := store.StateTranslated
+	for i, test := range tests {
+		code := setState(test.user, test.str, string(test.state))
+		if (code == 302) != test.ok {
+			t.Fatalf("%d: %s changing %q to %s: got status %d", i, test.user, test.str, test.state, code)
+		}
+		if test.ok && test.str == "Open" {
+			expected = test.state
+		}
+		if state := app.store.State("Open", "pl"); state != expected {
+			t.Fatalf("%d: got state %s, expected %s", i, state, expected)
+		}
+	}
+	if role := userRole(app, "reviewer1"); role != roleReviewer {
+		t.Fatalf("got role %q, expected %q", role, roleReviewer)
+	}
+
+	// counts of states in progress api
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/review/progress", nil))
+	var progress struct {
+		Langs []LangProgress `json:"langs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &progress); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	for _, lp := range progress.Langs {
+		if lp.Lang != "pl" {
+			continue
+		}
+		exp := map[store.State]int{store.StateUntranslated: 1, store.StateTranslated: 1, store.StateNeedsReview: 0, store.StateApproved: 1}
+		if !reflect.DeepEqual(lp.States, exp) {
+			t.Fatalf("got states %v, expected %v", lp.States, exp)
+		}
+	}
+
+	// export of approved translations only
+	rr = httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=review&lang=pl&format=csv&only=approved", nil))
+	entries, err := parseTransFile(rr.Body.Bytes(), formatCsv)
+	exp := []TransEntry{{"pl", "Open", "Otwórz", false}}
+	if err != nil || !reflect.DeepEqual(entries, exp) {
+		t.Fatalf("got %#v, %v, expected %#v", entries, err, exp)
+	}
+}
+
+func TestApproveAll(t *testing.T) {
+	app := newTestApp(t, "approveall", []string{"Open", "Close", "Save", "Quit", "OK"})
+	defer closeTestApp(app)
+	app.Reviewers = []string{"reviewer1"}
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, "Close", "Zamknij", "pl", "user1")
+	writeTestTranslation(t, app, "Quit", "Wyjdź", "pl", "user1")
+	writeTestTranslation(t, app, "OK", "OK", "pl", "user1")
+	writeTestTranslation(t, app, "Open", "Öffnen", "de", "user1")
+	if err := app.store.SetState("Close", "pl", store.StateNeedsReview); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+	if err := app.store.SetState("Quit", "pl", store.StateApproved); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+	if err := app.store.SetNoTranslate("OK", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	approveAll := func(user, lang string) *httptest.ResponseRecorder {
+		r := newRequestWithCookie("POST", "/approveall/approveall/"+lang, &SecureCookieValue{User: user})
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr
+	}
+	for _, user := range []string{"", "user1"} {
+		if rr := approveAll(user, "pl"); rr.Code != 400 {
+			t.Fatalf("%q: got status %d, expected 400", user, rr.Code)
+		}
+	}
+	if state := app.store.State("Open", "pl"); state != store.StateTranslated {
+		t.Fatalf("got state %s, expected %s", state, store.StateTranslated)
+	}
+	if rr := approveAll("reviewer1", "xx"); rr.Code != 400 {
+		t.Fatalf("got status %d for unknown lang, expected 400", rr.Code)
+	}
+
+	rr := approveAll("reviewer1", "pl")
+	var res ApproveAllResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json.Unmarshal() of %q failed with %s", rr.Body.String(), err)
+	}
+	if exp := (ApproveAllResult{App: "approveall", Lang: "pl", Approved: 2}); res != exp {
+		t.Fatalf("got %#v, expected %#v", res, exp)
+	}
+	expected := map[string]store.State{
+		"Open":  store.StateApproved,
+		"Close": store.StateApproved,
+		"Quit":  store.StateApproved,
+		"Save":  store.StateUntranslated,
+		"OK":    store.StateTranslated,
+	}
+	for str, exp := range expected {
+		if state := app.store.State(str, "pl"); state != exp {
+			t.Errorf("%q: got state %s, expected %s", str, state, exp)
+		}
+	}
+	// other languages are not changed
+	if state := app.store.State("Open", "de"); state != store.StateTranslated {
+		t.Errorf("got state %s in de, expected %s", state, store.StateTranslated)
+	}
+	// approving again doesn't change anything
+	rr = approveAll("admin", "pl")
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil || res.Approved != 0 {
+		t.Fatalf("got %#v, %v, expected 0 approved", res, err)
+	}
+}
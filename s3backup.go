@@ -2,13 +2,19 @@
 package main
 
 import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"mime"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/crowdmob/goamz/aws"
@@ -23,11 +29,68 @@ var bucketDelim = "/"
 const MaxBackupsToKeep = 64
 
 type BackupConfig struct {
+	// identifies the target in logs, /health and /admin/backup. Bucket
+	// if empty
+	Name      string
 	AwsAccess string
 	AwsSecret string
 	Bucket    string
 	S3Dir     string
-	LocalDir  string
+	// of S3 compatible storage, e.g. https://storage.googleapis.com for
+	// Google Cloud Storage with HMAC keys. Amazon S3 if empty
+	Endpoint string
+	LocalDir string
+}
+
+// BackupTarget is a destination of backups
+type BackupTarget struct {
+	Config *BackupConfig
+	Store  BackupStore
+}
+
+func (t *BackupTarget) name() string {
+	if t.Config.Name != "" {
+		return t.Config.Name
+	}
+	return t.Config.Bucket
+}
+
+// validateBackupTargets checks targets from config.BackupTargets
+func validateBackupTargets(targets []BackupConfig) error {
+	seen := make(map[string]bool)
+	for i, c := range targets {
+		if c.AwsAccess == "" || c.AwsSecret == "" || c.Bucket == "" || c.S3Dir == "" {
+			return fmt.Errorf("BackupTargets[%d]: AwsAccess, AwsSecret, Bucket and S3Dir must be set", i)
+		}
+		t := &BackupTarget{Config: &c}
+		if seen[t.name()] {
+			return fmt.Errorf("BackupTargets[%d]: duplicate name %q", i, t.name())
+		}
+		seen[t.name()] = true
+	}
+	return nil
+}
+
+// backupConfigs returns configs of backup targets of localDir: the one
+// defined with AwsAccess, AwsSecret, S3BackupBucket and S3BackupDir, if
+// any, followed by config.BackupTargets
+func backupConfigs(localDir string) []*BackupConfig {
+	var res []*BackupConfig
+	if !stringEmpty(config.AwsAccess) && !stringEmpty(config.AwsSecret) && !stringEmpty(config.S3BackupBucket) && !stringEmpty(config.S3BackupDir) {
+		res = append(res, &BackupConfig{
+			AwsAccess: *config.AwsAccess,
+			AwsSecret: *config.AwsSecret,
+			Bucket:    *config.S3BackupBucket,
+			S3Dir:     *config.S3BackupDir,
+			LocalDir:  localDir,
+		})
+	}
+	for i := range config.BackupTargets {
+		c := config.BackupTargets[i]
+		c.LocalDir = localDir
+		res = append(res, &c)
+	}
+	return res
 }
 
 // removes "/" if exists and adds delim if missing
@@ -41,17 +104,186 @@ func sanitizeDirForList(dir, delim string) string {
 	return dir
 }
 
-func listBackupFiles(config *BackupConfig, max int) (*s3.ListResp, error) {
-	auth := aws.Auth{AccessKey: config.AwsAccess, SecretKey: config.AwsSecret}
-	b := s3.New(auth, aws.USEast).Bucket(config.Bucket)
-	dir := sanitizeDirForList(config.S3Dir, bucketDelim)
-	return b.List(dir, bucketDelim, "", max)
+// BackupInfo describes a file in BackupStore
+type BackupInfo struct {
+	// name of the file, including the directory
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
 }
 
-func s3Del(config *BackupConfig, keyName string) error {
+// BackupStore is a remote storage for backup files
+type BackupStore interface {
+	List() ([]BackupInfo, error)
+	Put(local, remote string) error
+	Get(remote string) ([]byte, error)
+	Del(remote string) error
+}
+
+// backupTargets are destinations of backups, empty if backups are not
+// enabled. Backups are listed and downloaded from backupStore, which is
+// the store of the first target
+var (
+	backupTargets []*BackupTarget
+	backupStore   BackupStore
+)
+
+// backups write to the same temporary file so only one can run at a time
+var backupMu sync.Mutex
+
+// time of the last backup that included an app, by name of backup target
+// and app name. Protected by backupMu
+var lastAppBackup = make(map[string]map[string]time.Time)
+
+// appBackupFreq returns how often app is backed up, 0 if it's never backed up
+func appBackupFreq(app *App) time.Duration {
+	if app.NoBackup {
+		return 0
+	}
+	if app.BackupFreqHours > 0 {
+		return time.Duration(app.BackupFreqHours) * time.Hour
+	}
+	return backupFreq
+}
+
+// backupLoopFreq returns how often the backup loop runs, which is as often
+// as the most frequently backed up app needs
+func backupLoopFreq() time.Duration {
+	res := backupFreq
+	for _, app := range appState.Apps {
+		if freq := appBackupFreq(app); freq > 0 && freq < res {
+			res = freq
+		}
+	}
+	return res
+}
+
+// appsToBackup splits apps into those included in backups and data
+// directories of those that opted out of backups. Every backup has data of
+// all other apps, so that any backup can restore the whole server
+func appsToBackup(localDir string) ([]*App, []string) {
+	var apps []*App
+	var skipDirs []string
+	for _, app := range appState.Apps {
+		if appBackupFreq(app) == 0 {
+			skipDirs = append(skipDirs, filepath.Join(localDir, app.DataDir))
+			continue
+		}
+		apps = append(apps, app)
+	}
+	return apps, skipDirs
+}
+
+// isBackupDue returns true if a backup of apps should be uploaded to target
+// at now, because one of them wasn't backed up to it for longer than its
+// backup frequency. Must be called with backupMu locked
+func isBackupDue(target string, apps []*App, now time.Time) bool {
+	for _, app := range apps {
+		last, ok := lastAppBackup[target][app.Name]
+		if !ok || now.Sub(last) >= appBackupFreq(app) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// createBackupZip is like u.CreateZipWithDirContent() but skips content
+// of skipDirs
+func createBackupZip(zipFilePath, dirToZip string, skipDirs []string) error {
+	zf, err := os.Create(zipFilePath)
+	if err != nil {
+		return err
+	}
+	defer zf.Close()
+	zw := zip.NewWriter(zf)
+	dirToZip = filepath.Clean(dirToZip)
+	err = filepath.Walk(dirToZip, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isInDirs(p, skipDirs) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		w, err := zw.Create(p[len(dirToZip)+1:])
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// s3BackupStore stores backups in s3 bucket
+type s3BackupStore struct {
+	config *BackupConfig
+}
+
+func (s *s3BackupStore) List() ([]BackupInfo, error) {
+	rsp, err := listBackupFiles(s.config, 1024)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]BackupInfo, 0, len(rsp.Contents))
+	for _, key := range rsp.Contents {
+		// unparsable time is left as zero time
+		t, _ := time.Parse(time.RFC3339, key.LastModified)
+		res = append(res, BackupInfo{Name: key.Key, Time: t, Size: key.Size})
+	}
+	return res, nil
+}
+
+func (s *s3BackupStore) Put(local, remote string) error {
+	return s3Put(s.config, local, remote, true)
+}
+
+func (s *s3BackupStore) Get(remote string) ([]byte, error) {
+	return s3Bucket(s.config).Get(remote)
+}
+
+func (s *s3BackupStore) Del(remote string) error {
+	return s3Bucket(s.config).Del(remote)
+}
+
+func s3Bucket(config *BackupConfig) *s3.Bucket {
 	auth := aws.Auth{AccessKey: config.AwsAccess, SecretKey: config.AwsSecret}
-	b := s3.New(auth, aws.USEast).Bucket(config.Bucket)
-	return b.Del(keyName)
+	region := aws.USEast
+	if config.Endpoint != "" {
+		region.S3Endpoint = config.Endpoint
+	}
+	s := s3.New(auth, region)
+	// s3 client doesn't take a context, it can only time out
+	s.ConnectTimeout = outboundTimeout()
+	s.ReadTimeout = s3ReadTimeout
+	return s.Bucket(config.Bucket)
+}
+
+func listBackupFiles(config *BackupConfig, max int) (*s3.ListResp, error) {
+	dir := sanitizeDirForList(config.S3Dir, bucketDelim)
+	return s3Bucket(config).List(dir, bucketDelim, "", max)
 }
 
 func s3Put(config *BackupConfig, local, remote string, public bool) error {
@@ -65,8 +297,7 @@ func s3Put(config *BackupConfig, local, remote string, public bool) error {
 		return err
 	}
 
-	auth := aws.Auth{AccessKey: config.AwsAccess, SecretKey: config.AwsSecret}
-	b := s3.New(auth, aws.USEast).Bucket(config.Bucket)
+	b := s3Bucket(config)
 
 	acl := s3.Private
 	if public {
@@ -105,14 +336,14 @@ func ensureValidConfig(config *BackupConfig) {
 // Grabs 10 newest files and checks if sha1 is part of the name, on the theory
 // that if the content hasn't changed, the last backup file should have
 // the same content, so we don't need to check all files
-func alreadyUploaded(config *BackupConfig, sha1 string) bool {
-	rsp, err := listBackupFiles(config, 1024)
+func alreadyUploaded(bs BackupStore, sha1 string) bool {
+	files, err := bs.List()
 	if err != nil {
-		logger.Errorf("alreadyUploaded(): listBackupFiles() failed with %q", err)
+		logger.Errorf("alreadyUploaded(): List() failed with %q", err)
 		return false
 	}
-	for _, key := range rsp.Contents {
-		if strings.Contains(key.Key, sha1) {
+	for _, f := range files {
+		if strings.Contains(f.Name, sha1) {
 			//fmt.Printf("Backup file with sha1 %s already exists: %s\n", sha1, key.Key)
 			return true
 		}
@@ -140,16 +371,16 @@ func isBackupFile(s string) bool {
 	return strings.HasSuffix(parts[2], ".zip")
 }
 
-func deleteOldBackups(config *BackupConfig, maxToKeep int) {
-	rsp, err := listBackupFiles(config, 1024)
+func deleteOldBackups(bs BackupStore, maxToKeep int) {
+	files, err := bs.List()
 	if err != nil {
-		logger.Errorf("deleteOldBackups(): listBackupFiles() failed with %s", err)
+		logger.Errorf("deleteOldBackups(): List() failed with %s", err)
 		return
 	}
 	keys := make([]string, 0)
-	for _, key := range rsp.Contents {
-		if isBackupFile(key.Key) {
-			keys = append(keys, key.Key)
+	for _, f := range files {
+		if isBackupFile(f.Name) {
+			keys = append(keys, f.Name)
 		}
 	}
 	toDelete := len(keys) - maxToKeep
@@ -161,7 +392,7 @@ func deleteOldBackups(config *BackupConfig, maxToKeep int) {
 	// delete those first
 	for i := 0; i < toDelete; i++ {
 		key := keys[i]
-		if err = s3Del(config, key); err != nil {
+		if err = bs.Del(key); err != nil {
 			logger.Noticef("deleteOldBackups(): failed to delete %s, error: %s", key, err)
 		} else {
 			logger.Noticef("deleteOldBackups(): deleted %s", key)
@@ -169,43 +400,194 @@ func deleteOldBackups(config *BackupConfig, maxToKeep int) {
 	}
 }
 
-func doBackup(config *BackupConfig) {
-	startTime := time.Now()
+// markAppsBackedUp records that apps were backed up to target at t. Must be
+// called with backupMu locked
+func markAppsBackedUp(target string, apps []*App, t time.Time) {
+	if lastAppBackup[target] == nil {
+		lastAppBackup[target] = make(map[string]time.Time)
+	}
+	for _, app := range apps {
+		lastAppBackup[target][app.Name] = t
+	}
+}
+
+// BackupTargetResult is the result of a backup to one target
+type BackupTargetResult struct {
+	Target   string   `json:"target"`
+	Ok       bool     `json:"ok"`
+	Error    string   `json:"error,omitempty"`
+	Uploaded []string `json:"uploaded"`
+}
+
+// doBackup uploads zipped data directory to all targets, except those that
+// already have a backup with the same content. Data of all apps, except
+// those that opted out, is included, see appsToBackup(). Unless all is
+// true, a target only gets the backup if an app is due for a backup to it
+// according to its backup frequency, so a target that failed gets the next
+// backup even if others succeeded. A failed upload to one target doesn't
+// prevent uploads to others, but makes doBackup() return an error. Apps
+// count as backed up if any target has the backup
+func doBackup(targets []*BackupTarget, all bool) ([]BackupTargetResult, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	startTime := clock.Now()
+	// all targets back up the same directory
+	localDir := filepath.Clean(targets[0].Config.LocalDir)
+	apps, skipDirs := appsToBackup(localDir)
+	var due []*BackupTarget
+	for _, t := range targets {
+		if all || isBackupDue(t.name(), apps, startTime) {
+			due = append(due, t)
+		}
+	}
+	dueResults := make(map[string]BackupTargetResult)
+	if len(due) > 0 {
+		for _, res := range uploadBackups(localDir, due, skipDirs, startTime) {
+			dueResults[res.Target] = res
+		}
+	}
+	var results []BackupTargetResult
+	var failed []string
+	for _, t := range targets {
+		res, ok := dueResults[t.name()]
+		if !ok {
+			// not due, nothing to do
+			results = append(results, BackupTargetResult{Target: t.name(), Ok: true, Uploaded: []string{}})
+			continue
+		}
+		results = append(results, res)
+		var err error
+		if res.Ok {
+			markAppsBackedUp(res.Target, apps, startTime)
+		} else {
+			err = errors.New(res.Error)
+			failed = append(failed, res.Target+": "+res.Error)
+		}
+		recordTargetBackupResult(res.Target, startTime, err)
+	}
+	if len(due) == 0 {
+		return results, nil
+	}
+	var err error
+	if len(failed) > 0 {
+		err = fmt.Errorf("backup to %d of %d targets failed: %s", len(failed), len(due), strings.Join(failed, "; "))
+	}
+	if len(failed) < len(due) {
+		recordBackupResult(apps, startTime, nil)
+	} else {
+		recordBackupResult(apps, startTime, err)
+	}
+	return results, err
+}
+
+// uploadBackups does the work of doBackup(): zips localDir without skipDirs
+// and uploads the zip to every target
+func uploadBackups(localDir string, targets []*BackupTarget, skipDirs []string, startTime time.Time) []BackupTargetResult {
+	results := make([]BackupTargetResult, len(targets))
+	for i, t := range targets {
+		results[i] = BackupTargetResult{Target: t.name(), Uploaded: []string{}}
+	}
+	failAll := func(err error) []BackupTargetResult {
+		for i := range results {
+			results[i].Error = err.Error()
+		}
+		return results
+	}
 	zipLocalPath := filepath.Join(os.TempDir(), "apptranslator-tmp-backup.zip")
 	// TODO: do I need os.Remove() won't os.Create() over-write the file anyway?
 	os.Remove(zipLocalPath) // remove before trying to create a new one, just in cased
-	err := u.CreateZipWithDirContent(zipLocalPath, config.LocalDir)
+	err := createBackupZip(zipLocalPath, localDir, skipDirs)
 	defer os.Remove(zipLocalPath)
 	if err != nil {
-		return
+		return failAll(fmt.Errorf("createBackupZip() failed with %s", err))
 	}
 	sha1, err := sha1HexOfFile(zipLocalPath)
 	if err != nil {
-		return
+		return failAll(fmt.Errorf("sha1HexOfFile() failed with %s", err))
 	}
-	if alreadyUploaded(config, sha1) {
-		dur := time.Now().Sub(startTime)
-		logger.Noticef("s3 backup not done because data (%s) didn't changed, took %.2f secs", sha1, dur.Seconds())
-		return
+	name := clock.Now().Format("060102_1504_") + sha1 + ".zip"
+	for i, t := range targets {
+		uploaded, err := uploadBackup(t, zipLocalPath, name, sha1, startTime)
+		results[i].Uploaded = uploaded
+		results[i].Ok = err == nil
+		if err != nil {
+			results[i].Error = err.Error()
+		}
 	}
-	timeStr := time.Now().Format("060102_1504_")
-	zipS3Path := path.Join(config.S3Dir, timeStr+sha1+".zip")
+	return results
+}
 
-	if err = s3Put(config, zipLocalPath, zipS3Path, true); err != nil {
-		logger.Errorf("s3Put of %q to %q failed with %s", zipLocalPath, zipS3Path, err)
-		return
+// uploadBackup uploads backup zip at zipLocalPath with a given name and sha1
+// of the content to target t, unless it was already uploaded. Returns
+// remote paths of uploaded files
+func uploadBackup(t *BackupTarget, zipLocalPath, name, sha1 string, startTime time.Time) ([]string, error) {
+	uploaded := []string{}
+	if alreadyUploaded(t.Store, sha1) {
+		dur := clock.Now().Sub(startTime)
+		logger.Noticef("backup to %s not done because data (%s) didn't changed, took %.2f secs", t.name(), sha1, dur.Seconds())
+		return uploaded, nil
+	}
+	zipS3Path := path.Join(t.Config.S3Dir, name)
+	if err := t.Store.Put(zipLocalPath, zipS3Path); err != nil {
+		return uploaded, fmt.Errorf("Put of %q to %q failed with %s", zipLocalPath, zipS3Path, err)
 	}
+	uploaded = append(uploaded, zipS3Path)
 
-	deleteOldBackups(config, MaxBackupsToKeep)
+	deleteOldBackups(t.Store, MaxBackupsToKeep)
 
-	dur := time.Now().Sub(startTime)
-	logger.Noticef("s3 backup of %q to %q took %.2f secs", zipLocalPath, zipS3Path, dur.Seconds())
+	dur := clock.Now().Sub(startTime)
+	logger.Noticef("backup of %q to %s %q took %.2f secs", zipLocalPath, t.name(), zipS3Path, dur.Seconds())
+	return uploaded, nil
 }
 
-func s3BackupLoop(config *BackupConfig) {
-	ensureValidConfig(config)
+func s3BackupLoop(targets []*BackupTarget) {
+	for _, t := range targets {
+		ensureValidConfig(t.Config)
+	}
 	for {
-		doBackup(config)
-		time.Sleep(backupFreq)
+		if _, err := doBackup(targets, false); err != nil {
+			logger.Errorf("doBackup() failed with %s", err)
+		}
+		clock.Sleep(backupLoopFreq())
+	}
+}
+
+// BackupResult is the result of backup triggered with /admin/backup
+type BackupResult struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// remote paths of uploaded files, of all targets
+	Uploaded []string             `json:"uploaded"`
+	Targets  []BackupTargetResult `json:"targets"`
+}
+
+// url: POST /admin/backup
+// Does a backup now, instead of waiting for the next scheduled backup. All
+// apps are included, except those that opted out of backups
+func handleBackupNow(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	if !userIsServerAdmin(decodeUserFromCookie(r)) {
+		httpErrorf(w, "User can't do backups")
+		return
+	}
+	if len(backupTargets) == 0 {
+		httpErrorf(w, "Backups are not configured")
+		return
+	}
+	results, err := doBackup(backupTargets, true)
+	res := &BackupResult{Ok: err == nil, Uploaded: []string{}, Targets: results}
+	for _, tr := range results {
+		res.Uploaded = append(res.Uploaded, tr.Uploaded...)
+	}
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleBackupNow(): doBackup() failed with %s", err)
+		res.Error = err.Error()
+		serveJSONWithStatus(w, http.StatusInternalServerError, res)
+		return
 	}
+	logger.ForRequest(r).Noticef("handleBackupNow(): uploaded %v", res.Uploaded)
+	serveJSON(w, res)
 }
@@ -0,0 +1,255 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingBackupStore fails all uploads
+type failingBackupStore struct {
+	*fakeBackupStore
+}
+
+func (s *failingBackupStore) Put(local, remote string) error {
+	return errors.New("upload failed")
+}
+
+func zipFileNames(t *testing.T, d []byte) []string {
+	zr, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() failed with %s", err)
+	}
+	var res []string
+	for _, f := range zr.File {
+		res = append(res, filepath.ToSlash(f.Name))
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestBackupNow(t *testing.T) {
+	app := newTestApp(t, "backup", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	if err := ioutil.WriteFile(filepath.Join(app.DataDir, "glossary.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+
+	bs := newFakeBackupStore()
+	target := &BackupTarget{Config: &BackupConfig{Name: "s3", S3Dir: "apptranslator", LocalDir: app.DataDir}, Store: bs}
+	backupStore = bs
+	backupTargets = []*BackupTarget{target}
+	defer func() { backupStore, backupTargets = nil, nil }()
+
+	backup := func(user string) (int, *BackupResult) {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, newRequestWithCookie("POST", "/admin/backup", &SecureCookieValue{User: user}))
+		var res BackupResult
+		if rr.Code != 400 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+
+	root, done := setTestServerAdmin()
+	defer done()
+	for _, user := range []string{"", "admin"} {
+		if code, _ := backup(user); code != 400 {
+			t.Fatalf("%q: got status %d, expected 400", user, code)
+		}
+	}
+	code, res := backup(root)
+	if code != 200 || !res.Ok || len(res.Uploaded) != 1 {
+		t.Fatalf("got status %d, %#v", code, res)
+	}
+	remote := res.Uploaded[0]
+	if !isBackupFile(remote) {
+		t.Fatalf("%q is not a backup file name", remote)
+	}
+	exp := []string{"glossary.json", "translations.csv"}
+	if got := zipFileNames(t, bs.files[remote]); len(got) != len(exp) || got[0] != exp[0] || got[1] != exp[1] {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+
+	// data didn't change, so there's nothing to upload
+	code, res = backup(root)
+	if code != 200 || !res.Ok || len(res.Uploaded) != 0 || len(bs.files) != 1 {
+		t.Fatalf("got status %d, %#v", code, res)
+	}
+
+	writeTestTranslation(t, app, "Open", "Otwórz plik", "pl", "user1")
+	target.Store = &failingBackupStore{bs}
+	code, res = backup(root)
+	if code != 500 || res.Ok || res.Error == "" {
+		t.Fatalf("got status %d, %#v", code, res)
+	}
+	if _, err := os.Stat(filepath.Join(os.TempDir(), "apptranslator-tmp-backup.zip")); !os.IsNotExist(err) {
+		t.Fatalf("temporary backup file wasn't removed")
+	}
+}
+
+func TestBackupPerAppSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apptranslator-test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed with %s", err)
+	}
+	defer os.RemoveAll(dir)
+	var apps []*App
+	for _, conf := range []AppConfig{
+		{Name: "hot", DataDir: "hot"},
+		{Name: "optedout", DataDir: "optedout", NoBackup: true},
+		{Name: "daily", DataDir: "daily", BackupFreqHours: 24},
+	} {
+		if err = os.Mkdir(filepath.Join(dir, conf.DataDir), 0755); err != nil {
+			t.Fatalf("os.Mkdir() failed with %s", err)
+		}
+		path := filepath.Join(dir, conf.DataDir, "translations.csv")
+		if err = ioutil.WriteFile(path, []byte("s,0,"+conf.Name+"\n"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+		app := NewApp(&conf)
+		apps = append(apps, app)
+		appState.Apps = append(appState.Apps, app)
+	}
+	defer func() {
+		appState.Apps = appState.Apps[:len(appState.Apps)-len(apps)]
+		delete(lastAppBackup, "s3")
+	}()
+
+	bs := newFakeBackupStore()
+	targets := []*BackupTarget{{Config: &BackupConfig{Name: "s3", S3Dir: "apptranslator", LocalDir: dir}, Store: bs}}
+	// returns names of files in the uploaded zip, nil if nothing was uploaded
+	backup := func(all bool) []string {
+		results, err := doBackup(targets, all)
+		if err != nil {
+			t.Fatalf("doBackup() failed with %s", err)
+		}
+		uploaded := results[0].Uploaded
+		if len(uploaded) > 1 {
+			t.Fatalf("got %v, expected at most one uploaded file", uploaded)
+		}
+		if len(uploaded) == 0 {
+			return nil
+		}
+		return zipFileNames(t, bs.files[uploaded[0]])
+	}
+	writeFile := func(name string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() failed with %s", err)
+		}
+	}
+
+	exp := []string{"daily/translations.csv", "hot/translations.csv"}
+	if got := backup(false); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	// data changed, but no app is due for a backup
+	writeFile("hot/glossary.json")
+	if got := backup(false); got != nil {
+		t.Fatalf("got %v, expected no upload", got)
+	}
+	// pretend that the last backup was done 13 hours ago: "hot" is due
+	// again, "daily" is not, but its data is in the backup anyway
+	for _, app := range apps {
+		lastAppBackup["s3"][app.Name] = lastAppBackup["s3"][app.Name].Add(-13 * time.Hour)
+	}
+	exp = []string{"daily/translations.csv", "hot/glossary.json", "hot/translations.csv"}
+	if got := backup(false); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	if freq := backupLoopFreq(); freq != backupFreq {
+		t.Fatalf("got loop frequency %s, expected %s", freq, backupFreq)
+	}
+
+	// backups done with /admin/backup don't wait for apps to be due
+	writeFile("daily/glossary.json")
+	exp = []string{"daily/glossary.json", "daily/translations.csv", "hot/glossary.json", "hot/translations.csv"}
+	if got := backup(true); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestBackupMultipleTargets(t *testing.T) {
+	app := newTestApp(t, "backuptargets", []string{"Open"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	defer func() {
+		backupMu.Lock()
+		delete(lastAppBackup, "gcs")
+		delete(lastAppBackup, "s3")
+		backupMu.Unlock()
+		backupStatusMu.Lock()
+		targetBackupStatus = make(map[string]*BackupStatus)
+		backupStatusMu.Unlock()
+	}()
+
+	s3 := newFakeBackupStore()
+	gcs := newFakeBackupStore()
+	target := func(name string, bs BackupStore) *BackupTarget {
+		return &BackupTarget{Config: &BackupConfig{Name: name, S3Dir: "apptranslator", LocalDir: app.DataDir}, Store: bs}
+	}
+	backupStore = s3
+	backupTargets = []*BackupTarget{target("gcs", &failingBackupStore{gcs}), target("s3", s3)}
+	defer func() { backupStore, backupTargets = nil, nil }()
+
+	results, err := doBackup(backupTargets, true)
+	if err == nil || !strings.Contains(err.Error(), "gcs: Put of") {
+		t.Fatalf("got error %v, expected failed upload to gcs", err)
+	}
+	if len(results) != 2 || results[0].Ok || results[0].Error == "" || !results[1].Ok || len(results[1].Uploaded) != 1 {
+		t.Fatalf("unexpected results %#v", results)
+	}
+	if len(gcs.files) != 0 || s3.files[results[1].Uploaded[0]] == nil {
+		t.Fatalf("got %d files in gcs, %d in s3", len(gcs.files), len(s3.files))
+	}
+	// the app is backed up to s3 only, and the failing target makes the
+	// server degraded
+	backupMu.Lock()
+	_, inS3 := lastAppBackup["s3"][app.Name]
+	_, inGcs := lastAppBackup["gcs"][app.Name]
+	backupMu.Unlock()
+	if !inS3 || inGcs {
+		t.Fatalf("app should be marked as backed up to s3 only")
+	}
+	h := buildHealth(clock.Now())
+	if !h.Degraded || len(h.BackupTargets) != 2 || !h.BackupTargets[0].Backup.Failed || h.BackupTargets[1].Backup.Failed {
+		t.Fatalf("unexpected health %#v", h)
+	}
+
+	// once gcs works again, it gets the backup on the next regular backup
+	// even though the app isn't due for a backup to s3
+	backupTargets[0].Store = gcs
+	results, err = doBackup(backupTargets, false)
+	if err != nil {
+		t.Fatalf("doBackup() failed with %s", err)
+	}
+	if len(results[0].Uploaded) != 1 || len(results[1].Uploaded) != 0 || len(gcs.files) != 1 {
+		t.Fatalf("unexpected results %#v", results)
+	}
+	if h = buildHealth(clock.Now()); h.BackupTargets[0].Backup.Failed {
+		t.Fatalf("unexpected health %#v", h.BackupTargets[0])
+	}
+
+	c := BackupConfig{AwsAccess: "a", AwsSecret: "s", Bucket: "b", S3Dir: "d"}
+	if err = validateBackupTargets([]BackupConfig{c, c}); err == nil {
+		t.Fatalf("expected an error for duplicate targets")
+	}
+	c.Name = "gcs"
+	if err = validateBackupTargets([]BackupConfig{c, {Bucket: "b"}}); err == nil {
+		t.Fatalf("expected an error for incomplete target")
+	}
+}
@@ -4,5 +4,6 @@ set -o nounset
 set -o errexit
 set -o pipefail
 
-GOOS=linux GOARCH=amd64 go build -o apptranslator_app_linux
+. ./scripts/ldflags.sh
+GOOS=linux GOARCH=amd64 go build -ldflags "${LDFLAGS}" -o apptranslator_app_linux
 fab deploy
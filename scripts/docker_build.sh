@@ -4,6 +4,7 @@ set -o nounset
 set -o errexit
 set -o pipefail
 
-GOOS=linux GOARCH=amd64 go build -o apptranslator_linux
+. ./scripts/ldflags.sh
+GOOS=linux GOARCH=amd64 go build -ldflags "${LDFLAGS}" -o apptranslator_linux
 
 docker build --tag apptranslator:latest .
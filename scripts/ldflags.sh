@@ -0,0 +1,6 @@
+# sourced by build scripts, sets LDFLAGS to inject build info shown by /version
+
+GIT_COMMIT=$(git rev-parse --short HEAD)
+BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+BUILD_VERSION=$(git describe --tags --always --dirty)
+LDFLAGS="-X main.buildVersion=${BUILD_VERSION} -X main.gitCommit=${GIT_COMMIT} -X main.buildTime=${BUILD_TIME}"
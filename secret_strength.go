@@ -0,0 +1,47 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// secrets must have at least this many bits of entropy per character of
+// config.MinSecretLen, so that long but repetitive secrets like
+// "123412341234" are rejected
+const minSecretEntropyPerChar = 2.5
+
+// secretEntropy returns Shannon entropy of s in bits, assuming that its
+// characters are independent
+func secretEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	res := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		res -= float64(count) * math.Log2(p)
+	}
+	return res
+}
+
+// checkSecretStrength returns an error if secret is shorter than
+// config.MinSecretLen or is too predictable. Secrets are not checked if
+// config.MinSecretLen is 0
+func checkSecretStrength(secret string) error {
+	minLen := config.MinSecretLen
+	if minLen <= 0 {
+		return nil
+	}
+	if utf8.RuneCountInString(secret) < minLen {
+		return fmt.Errorf("must be at least %d characters long", minLen)
+	}
+	if secretEntropy(secret) < minSecretEntropyPerChar*float64(minLen) {
+		return fmt.Errorf("is too predictable, use random characters")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUploadSecretStrength(t *testing.T) {
+	defer func() { config.MinSecretLen = 0 }()
+	newApp := func(secret string) *App {
+		return NewApp(&AppConfig{Name: "a", DataDir: "a", AdminTwitterUser: "admin", UploadSecret: secret})
+	}
+
+	// not checked by default
+	if got := appInvalidField(newApp("1234")); got != "" {
+		t.Fatalf("got invalid field %q with MinSecretLen 0", got)
+	}
+
+	config.MinSecretLen = 12
+	for _, weak := range []string{"1234", "secret", "aaaaaaaaaaaaaaaa", "123412341234", "abababababababababab"} {
+		app := newApp(weak)
+		if got := appInvalidField(app); got != "UploadSecret" {
+			t.Errorf("%q: got invalid field %q, expected UploadSecret", weak, got)
+		}
+		err := addApp(app)
+		if err == nil || !strings.Contains(err.Error(), "UploadSecret") {
+			t.Errorf("%q: got error %v", weak, err)
+		}
+	}
+	for _, strong := range []string{"x7Gq2LpW9zKe", "correct-horse-battery-staple", "3f8a1c9e07b24d6f"} {
+		if got := appInvalidField(newApp(strong)); got != "" {
+			t.Errorf("%q: got invalid field %q", strong, got)
+		}
+	}
+	if got := describeInvalidField(newApp("1234"), "UploadSecret"); !strings.Contains(got, "at least 12 characters") {
+		t.Fatalf("got %q, expected a reason", got)
+	}
+}
@@ -0,0 +1,172 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// BundleEdit is a single translation of a string in a Bundle
+type BundleEdit struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Lang        string    `json:"lang"`
+	Translation string    `json:"translation"`
+	Note        string    `json:"note,omitempty"`
+}
+
+// BundleString is a string with its metadata and translation history
+type BundleString struct {
+	Text   string            `json:"text"`
+	Active bool              `json:"active"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	// languages in which the current translation is fuzzy, sorted
+	Fuzzy []string `json:"fuzzy,omitempty"`
+	// languages in which the current translation is approved, sorted
+	Approved []string `json:"approved,omitempty"`
+	// oldest first
+	Edits []BundleEdit `json:"edits"`
+}
+
+// Bundle is the entire content of a store in a portable form, used to move
+// translations between instances. Strings are sorted by text
+type Bundle struct {
+	Strings []BundleString `json:"strings"`
+}
+
+// Validate returns an error if b can't be imported
+func (b *Bundle) Validate() error {
+	seen := make(map[string]bool)
+	for _, str := range b.Strings {
+		if str.Text == "" {
+			return errors.New("empty string")
+		}
+		if seen[str.Text] {
+			return fmt.Errorf("duplicate string %q", str.Text)
+		}
+		seen[str.Text] = true
+		for _, lang := range str.Fuzzy {
+			if !IsValidLangCode(lang) {
+				return fmt.Errorf("invalid lang %q in fuzzy of %q", lang, str.Text)
+			}
+		}
+		for _, lang := range str.Approved {
+			if !IsValidLangCode(lang) {
+				return fmt.Errorf("invalid lang %q in approved of %q", lang, str.Text)
+			}
+		}
+		for _, edit := range str.Edits {
+			if !IsValidLangCode(edit.Lang) {
+				return fmt.Errorf("invalid lang %q in edit of %q", edit.Lang, str.Text)
+			}
+			if edit.User == "" {
+				return fmt.Errorf("empty user in edit of %q", str.Text)
+			}
+		}
+	}
+	return nil
+}
+
+// ExportBundle returns the content of the store, including unused strings
+// and translation history
+func (s *StoreCsv) ExportBundle() *Bundle {
+	s.Lock()
+	defer s.Unlock()
+	n := s.allStringsCount()
+	strs := make([]BundleString, n)
+	for id := range strs {
+		str := &strs[id]
+		str.Text = s.stringByIdMust(id)
+		str.Active = !s.isUnused(id)
+		str.Edits = []BundleEdit{}
+		if len(s.stringsMeta[id]) > 0 {
+			str.Meta = make(map[string]string)
+			for key, value := range s.stringsMeta[id] {
+				str.Meta[key] = value
+			}
+		}
+	}
+	for _, edit := range s.edits {
+		str := &strs[edit.stringId]
+		str.Edits = append(str.Edits, BundleEdit{
+			// the store keeps times with a precision of a second
+			Time:        time.Unix(edit.time.Unix(), 0).UTC(),
+			User:        s.userById(edit.userId),
+			Lang:        s.langById(edit.langId),
+			Translation: edit.translation,
+			Note:        edit.note,
+		})
+	}
+	for id := range strs {
+		strs[id].Fuzzy = s.langsWithFlag(s.fuzzy, id)
+		strs[id].Approved = s.langsWithFlag(s.approved, id)
+	}
+	sort.Slice(strs, func(i, j int) bool {
+		return strs[i].Text < strs[j].Text
+	})
+	return &Bundle{Strings: strs}
+}
+
+// IsEmpty returns true if the store doesn't have any strings
+func (s *StoreCsv) IsEmpty() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.allStringsCount() == 0 && len(s.edits) == 0
+}
+
+// ImportBundle adds the content of b to the store, which must be empty.
+// Times of translations are preserved
+func (s *StoreCsv) ImportBundle(b *Bundle) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.allStringsCount() != 0 || len(s.edits) != 0 {
+		return errors.New("store is not empty")
+	}
+	var recs [][]string
+	var active []int
+	for id, str := range b.Strings {
+		strId := strconv.Itoa(id)
+		recs = append(recs, []string{recIdNewString, strId, str.Text})
+		if str.Active {
+			active = append(active, id)
+		}
+		keys := make([]string, 0, len(str.Meta))
+		for key := range str.Meta {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			recs = append(recs, []string{recIdStringMeta, compactedStateTime, strId, key, str.Meta[key]})
+		}
+		for _, edit := range str.Edits {
+			timeStr := strconv.FormatInt(edit.Time.Unix(), 10)
+			rec := []string{recIdTrans, timeStr, edit.User, edit.Lang, strId, edit.Translation}
+			if edit.Note != "" {
+				rec = append(rec, edit.Note)
+			}
+			recs = append(recs, rec)
+		}
+		for _, lang := range str.Fuzzy {
+			recs = append(recs, []string{recIdFuzzy, compactedStateTime, lang, strId, "1"})
+		}
+		for _, lang := range str.Approved {
+			recs = append(recs, []string{recIdApproved, compactedStateTime, lang, strId, "1"})
+		}
+	}
+	if len(active) > 0 {
+		recs = append(recs, buildActiveSetRec(active))
+	}
+	if err := s.w.WriteAll(recs); err != nil {
+		return err
+	}
+	// the simplest way to update in-memory state is to re-read the file
+	err := s.load()
+	s.resetCaches()
+	return err
+}
@@ -0,0 +1,82 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	path := "transtest_bundle_src.dat"
+	s := newStatsTestStore(path, 4)
+	defer os.Remove(path)
+	defer s.Close()
+	if err := s.WriteNewTranslationWithNote("string 1", "napis 1", "pl", "user1", "short, on purpose"); err != nil {
+		t.Fatalf("WriteNewTranslationWithNote() failed with %s", err)
+	}
+	if err := s.WriteFuzzyTranslation("string 2", "maschine 2", "de", "machine"); err != nil {
+		t.Fatalf("WriteFuzzyTranslation() failed with %s", err)
+	}
+	if err := s.SetMaxLen("string 0", 12); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+	// "string 3" becomes unused but keeps its translations
+	s.updateStringsListMust([]string{"string 0", "string 1", "string 2"})
+
+	b := s.ExportBundle()
+	// the bundle must survive being sent as json
+	d, err := json.Marshal(b)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed with %s", err)
+	}
+	var b2 Bundle
+	if err = json.Unmarshal(d, &b2); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+
+	path2 := "transtest_bundle_dst.dat"
+	os.Remove(path2)
+	defer os.Remove(path2)
+	s2 := NewTestStore(path2)
+	if err = s2.ImportBundle(&b2); err != nil {
+		t.Fatalf("ImportBundle() failed with %s", err)
+	}
+	if got := s2.ExportBundle(); !reflect.DeepEqual(got, b) {
+		t.Fatalf("bundle changed after import\n got: %#v\nwant: %#v", got, b)
+	}
+	if !s2.IsFuzzy("string 2", "de") || s2.MaxLen("string 0") != 12 || s2.IsActiveString("string 3") {
+		t.Fatalf("imported store has wrong state")
+	}
+	if s2.Stats() != s.Stats() {
+		t.Fatalf("got stats %#v, want %#v", s2.Stats(), s.Stats())
+	}
+
+	// the imported content is persisted
+	s2.Close()
+	s2 = NewTestStore(path2)
+	defer s2.Close()
+	if got := s2.ExportBundle(); !reflect.DeepEqual(got, b) {
+		t.Fatalf("bundle changed after reopening\n got: %#v\nwant: %#v", got, b)
+	}
+
+	if err = s2.ImportBundle(b); err == nil {
+		t.Fatalf("ImportBundle() into a non-empty store should fail")
+	}
+}
+
+func TestBundleValidate(t *testing.T) {
+	tests := []*Bundle{
+		{Strings: []BundleString{{Text: ""}}},
+		{Strings: []BundleString{{Text: "a"}, {Text: "a"}}},
+		{Strings: []BundleString{{Text: "a", Fuzzy: []string{"xx-invalid"}}}},
+		{Strings: []BundleString{{Text: "a", Edits: []BundleEdit{{User: "u", Lang: "xx-invalid"}}}}},
+		{Strings: []BundleString{{Text: "a", Edits: []BundleEdit{{Lang: "pl"}}}}},
+	}
+	for i, b := range tests {
+		if b.Validate() == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
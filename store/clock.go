@@ -0,0 +1,66 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Time is read and waited for through a Clock so
+// that tests can control it with FakeClock
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// RealClock is the system clock
+var RealClock Clock = realClock{}
+
+// FakeClock is a Clock for tests. Its time only changes with Advance() and
+// Sleep(), which returns immediately
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to t
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the time forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// clock gives times of records written by stores
+var clock = RealClock
+
+// SetClock sets the clock used by stores and returns the previous one
+func SetClock(c Clock) Clock {
+	prev := clock
+	clock = c
+	return prev
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 func panicif(cond bool, args ...interface{}) {
@@ -30,12 +31,28 @@ type Translation struct {
 	// last string is current translation, previous strings
 	// are a history of how translation changed
 	Translations []string
+	// notes of translators, for each of Translations
+	Notes []string
+	// true if the string should not be translated
+	NoTranslate bool
+	// true if the translation needs to be reviewed
+	Fuzzy bool
+	// true if the translation was approved by a reviewer
+	Approved bool
+	// time of the last translation, zero if not translated
+	Modified time.Time
+	// maximum length of translation in characters, 0 if there is no limit
+	MaxLen int
+	// url of a screenshot or a page showing where the string is used
+	ContextURL string
+	// group of related strings, "" if not in a group
+	Group string
 }
 
 func NewTranslation(id int, s, trans string) *Translation {
 	t := &Translation{Id: id, String: s}
 	if trans != "" {
-		t.add(trans)
+		t.add(trans, "")
 	}
 	return t
 }
@@ -52,6 +69,11 @@ func (t *Translation) IsTranslated() bool {
 	return len(t.Translations) > 0
 }
 
+// NeedsTranslation returns true if the string is still waiting for a translator
+func (t *Translation) NeedsTranslation() bool {
+	return !t.IsTranslated() && !t.NoTranslate
+}
+
 func (t *Translation) History() []string {
 	n := len(t.Translations)
 	if n < 2 {
@@ -60,8 +82,27 @@ func (t *Translation) History() []string {
 	return t.Translations[0 : n-1]
 }
 
-func (t *Translation) add(trans string) {
+// Note returns note of the translator about current translation
+func (t *Translation) Note() string {
+	n := len(t.Notes)
+	if 0 == n {
+		return ""
+	}
+	return t.Notes[n-1]
+}
+
+// HistoryNotes returns notes for translations returned by History()
+func (t *Translation) HistoryNotes() []string {
+	n := len(t.Notes)
+	if n < 2 {
+		return nil
+	}
+	return t.Notes[0 : n-1]
+}
+
+func (t *Translation) add(trans, note string) {
 	t.Translations = append(t.Translations, trans)
+	t.Notes = append(t.Notes, note)
 }
 
 const (
@@ -86,15 +127,33 @@ type ByString struct{ TranslationSeq }
 func (s ByString) Less(i, j int) bool {
 	s1 := s.TranslationSeq[i].String
 	s2 := s.TranslationSeq[j].String
-	trans1 := s.TranslationSeq[i].Current()
-	trans2 := s.TranslationSeq[j].Current()
-	if trans1 == "" && trans2 != "" {
+	// strings that need translation go first
+	untrans1 := s.TranslationSeq[i].Current() == "" && !s.TranslationSeq[i].NoTranslate
+	untrans2 := s.TranslationSeq[j].Current() == "" && !s.TranslationSeq[j].NoTranslate
+	if untrans1 != untrans2 {
+		return untrans1
+	}
+	if transStringLess(s1, s2) {
 		return true
 	}
-	if trans2 == "" && trans1 != "" {
+	if transStringLess(s2, s1) {
 		return false
 	}
-	return transStringLess(s1, s2)
+	// strings that compare equal ignoring case and punctuation are
+	// ordered by exact value, so that the order is stable
+	return s1 < s2
+}
+
+// for sorting by time of last translation, most recent first
+type ByModified struct{ TranslationSeq }
+
+func (s ByModified) Less(i, j int) bool {
+	t1 := s.TranslationSeq[i].Modified
+	t2 := s.TranslationSeq[j].Modified
+	if !t1.Equal(t2) {
+		return t1.After(t2)
+	}
+	return ByString2{s.TranslationSeq}.Less(i, j)
 }
 
 type ByString2 struct{ TranslationSeq }
@@ -102,7 +161,13 @@ type ByString2 struct{ TranslationSeq }
 func (s ByString2) Less(i, j int) bool {
 	s1 := s.TranslationSeq[i].String
 	s2 := s.TranslationSeq[j].String
-	return transStringLess(s1, s2)
+	if transStringLess(s1, s2) {
+		return true
+	}
+	if transStringLess(s2, s1) {
+		return false
+	}
+	return s1 < s2
 }
 
 type LangInfo struct {
@@ -149,10 +214,21 @@ func (li *LangInfo) UntranslatedCount() int {
 	if li.untranslated == -1 {
 		li.untranslated = 0
 		for _, tr := range li.ActiveStrings {
-			if !tr.IsTranslated() {
+			if tr.NeedsTranslation() {
 				li.untranslated++
 			}
 		}
 	}
 	return li.untranslated
 }
+
+// UntranslatedStrings returns active strings that still need a translation
+func (li *LangInfo) UntranslatedStrings() []*Translation {
+	res := make([]*Translation, 0)
+	for _, tr := range li.ActiveStrings {
+		if tr.NeedsTranslation() {
+			res = append(res, tr)
+		}
+	}
+	return res
+}
@@ -0,0 +1,242 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// records describing current state (active strings, metadata, fuzzy and
+// approved flags) are written with this time, so that compacting the same
+// content always produces the same file. Their time is not used when loading
+const compactedStateTime = "0"
+
+// langsWithFlag returns sorted languages of translations of string strId
+// set in flags
+func (s *StoreCsv) langsWithFlag(flags map[strLang]bool, strId int) []string {
+	var langs []string
+	for key := range flags {
+		if key.strId == strId {
+			langs = append(langs, s.langById(key.langId))
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// writeSorted writes the content of the store in canonical order: for each
+// string, sorted by string, its 's' record followed by its metadata,
+// translations (by language, oldest first), fuzzy and approved flags. Active
+// strings are written last. String ids are renumbered in the order of
+// strings. Strings in purge are not written
+func (s *StoreCsv) writeSorted(w io.Writer, purge map[int]bool) error {
+	n := s.allStringsCount()
+	ids := make([]int, 0, n)
+	for id := 0; id < n; id++ {
+		if !purge[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return s.stringByIdMust(ids[i]) < s.stringByIdMust(ids[j])
+	})
+	newIds := make([]int, n)
+	for newId, id := range ids {
+		newIds[id] = newId
+	}
+
+	edits := make([][]TranslationRec, n)
+	for _, edit := range s.edits {
+		edits[edit.stringId] = append(edits[edit.stringId], edit)
+	}
+
+	cw := csv.NewWriter(w)
+	for newId, id := range ids {
+		strId := strconv.Itoa(newId)
+		recs := [][]string{{recIdNewString, strId, s.stringByIdMust(id)}}
+
+		keys := make([]string, 0, len(s.stringsMeta[id]))
+		for key := range s.stringsMeta[id] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			recs = append(recs, []string{recIdStringMeta, compactedStateTime, strId, key, s.stringsMeta[id][key]})
+		}
+
+		strEdits := edits[id]
+		sort.SliceStable(strEdits, func(i, j int) bool {
+			return s.langById(strEdits[i].langId) < s.langById(strEdits[j].langId)
+		})
+		for _, edit := range strEdits {
+			timeStr := strconv.FormatInt(edit.time.Unix(), 10)
+			rec := []string{recIdTrans, timeStr, s.userById(edit.userId), s.langById(edit.langId), strId, edit.translation}
+			if edit.note != "" {
+				rec = append(rec, edit.note)
+			}
+			recs = append(recs, rec)
+		}
+
+		for _, lang := range s.langsWithFlag(s.fuzzy, id) {
+			recs = append(recs, []string{recIdFuzzy, compactedStateTime, lang, strId, "1"})
+		}
+		for _, lang := range s.langsWithFlag(s.approved, id) {
+			recs = append(recs, []string{recIdApproved, compactedStateTime, lang, strId, "1"})
+		}
+		if err := cw.WriteAll(recs); err != nil {
+			return err
+		}
+	}
+
+	if len(s.activeStrings) == 0 {
+		return nil
+	}
+	active := make([]int, len(s.activeStrings))
+	for i, id := range s.activeStrings {
+		active[i] = newIds[id]
+	}
+	rec := buildActiveSetRec(active)
+	rec[1] = compactedStateTime
+	return cw.WriteAll([][]string{rec})
+}
+
+// stringsToPurge returns ids of strings deleted before deletedBefore and
+// the number of records they have
+func (s *StoreCsv) stringsToPurge(deletedBefore time.Time) (map[int]bool, int) {
+	purge := make(map[int]bool)
+	nRecords := 0
+	for id, isDeleted := range s.deletedStringsBitmap {
+		t := s.deletedAt(id)
+		if !isDeleted || t.IsZero() || !t.Before(deletedBefore) {
+			continue
+		}
+		purge[id] = true
+		nRecords += 1 + len(s.stringsMeta[id])
+	}
+	for _, edit := range s.edits {
+		if purge[edit.stringId] {
+			nRecords++
+		}
+	}
+	for _, flags := range []map[strLang]bool{s.fuzzy, s.approved} {
+		for key := range flags {
+			if purge[key.strId] {
+				nRecords++
+			}
+		}
+	}
+	return purge, nRecords
+}
+
+func (s *StoreCsv) compact(purge map[int]bool) error {
+	tmpPath := s.filePath + ".compact"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = s.writeSorted(f, purge)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	s.w.Flush()
+	s.file.Close()
+	err = os.Rename(tmpPath, s.filePath)
+	if err == nil {
+		err = s.load()
+	}
+	var err2 error
+	s.file, s.w, err2 = openCsv(s.filePath)
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Compact rewrites the store file in canonical, sorted order, so that files
+// with the same content are identical (e.g. for clean diffs when the file
+// is kept in version control). Translation history is preserved
+func (s *StoreCsv) Compact() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.compact(nil)
+}
+
+// CompactStats describes the store file before and after compaction
+type CompactStats struct {
+	SizeBefore    int64
+	SizeAfter     int64
+	RecordsBefore int
+	RecordsAfter  int
+}
+
+// fileStats returns size of the store file and number of records in it
+func (s *StoreCsv) fileStats() (int64, int, error) {
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		return 0, 0, err
+	}
+	f, err := os.Open(s.filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	n := 0
+	for {
+		_, err = r.Read()
+		if err == io.EOF {
+			return fi.Size(), n, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		n++
+	}
+}
+
+// CompactWithStats is Compact() that also returns sizes and numbers of
+// records of the file before and after compaction
+func (s *StoreCsv) CompactWithStats() (*CompactStats, error) {
+	s.Lock()
+	defer s.Unlock()
+	var res CompactStats
+	var err error
+	if res.SizeBefore, res.RecordsBefore, err = s.fileStats(); err != nil {
+		return nil, err
+	}
+	if err = s.compact(nil); err != nil {
+		return nil, err
+	}
+	if res.SizeAfter, res.RecordsAfter, err = s.fileStats(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CompactAndPurge is Compact() that also drops strings deleted before
+// deletedBefore, with their translations and metadata. Strings deleted
+// before deletion times were recorded are kept. Returns the number of
+// purged records
+func (s *StoreCsv) CompactAndPurge(deletedBefore time.Time) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	purge, nRecords := s.stringsToPurge(deletedBefore)
+	if err := s.compact(purge); err != nil {
+		return 0, err
+	}
+	return nRecords, nil
+}
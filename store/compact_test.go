@@ -0,0 +1,166 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// the same content, written in different order and with different ids
+var compactTestFiles = []string{
+	`s,0,Open
+s,1,Close
+as,100,0-1
+t,100,user1,pl,0,Otworz
+t,101,user2,pl,0,Otwórz
+t,102,user1,de,1,Schließen
+m,103,1,note,"a, b"
+fz,104,pl,0,1
+s,2,Exit
+as,105,0-2
+t,106,user1,de,0,Öffnen
+as,107,0,2
+`,
+	`s,0,Exit
+s,1,Close
+s,2,Open
+t,102,user1,de,1,Schließen
+t,100,user1,pl,2,Otworz
+t,101,user2,pl,2,Otwórz
+t,106,user1,de,2,Öffnen
+m,50,1,note,"a, b"
+fz,60,pl,2,1
+as,100,1-2
+as,107,0,2
+`,
+}
+
+func compactTestFile(t *testing.T, path, content string) []byte {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+	s := NewTestStore(path)
+	defer s.Close()
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() failed with %s", err)
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile() failed with %s", err)
+	}
+	return d
+}
+
+func TestCompact(t *testing.T) {
+	path := "transtest_compact.dat"
+	defer os.Remove(path)
+
+	d1 := compactTestFile(t, path, compactTestFiles[0])
+	d2 := compactTestFile(t, path, compactTestFiles[1])
+	if !bytes.Equal(d1, d2) {
+		t.Fatalf("compacted files differ:\n%s\n%s", d1, d2)
+	}
+	if d3 := compactTestFile(t, path, string(d1)); !bytes.Equal(d1, d3) {
+		t.Fatalf("compacting again changed the file:\n%s\n%s", d1, d3)
+	}
+
+	s := NewTestStore(path)
+	defer s.Close()
+	li := langInfoByCode(s.LangInfos(), "pl")
+	if len(li.ActiveStrings) != 2 || len(li.UnusedStrings) != 1 || li.UnusedStrings[0].String != "Close" {
+		t.Fatalf("wrong active/unused strings: %#v, %#v", li.ActiveStrings, li.UnusedStrings)
+	}
+	for _, tr := range li.ActiveStrings {
+		if tr.String == "Open" {
+			if !reflect.DeepEqual(tr.Translations, []string{"Otworz", "Otwórz"}) || !tr.Fuzzy {
+				t.Fatalf("wrong translation of Open: %#v", tr)
+			}
+		}
+	}
+	if got := s.StringMeta("Close", "note"); got != "a, b" {
+		t.Fatalf("got meta %q", got)
+	}
+	// edits are in chronological order
+	edits := s.RecentEdits(10)
+	if len(edits) != 4 || edits[0].Translation != "Öffnen" || edits[3].Translation != "Otworz" {
+		t.Fatalf("wrong recent edits: %#v", edits)
+	}
+
+	// store is usable after compaction
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() failed with %s", err)
+	}
+	s.writeNewTranslationMust("Exit", "Wyjście", "pl", "user1")
+	if got, _ := translationOf(s, "Exit", "pl"); got != "Wyjście" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestCompactAndPurge(t *testing.T) {
+	path := "transtest_purge.dat"
+	defer os.Remove(path)
+	now := time.Now()
+	recent := strconv.FormatInt(now.AddDate(0, 0, -1).Unix(), 10)
+	content := `s,0,Open
+s,1,Close
+s,2,Exit
+as,100,0-2
+t,100,user1,pl,0,Otwórz
+t,101,user1,pl,1,Zamknij
+m,102,1,note,a
+fz,103,pl,1,1
+t,104,user1,pl,2,Wyjdź
+as,105,0,2
+as,` + recent + `,0
+`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile() failed with %s", err)
+	}
+	s := NewTestStore(path)
+	defer func() { s.Close() }()
+
+	// s, note, deletion time, translation and fuzzy records of Close
+	n, err := s.CompactAndPurge(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("CompactAndPurge() failed with %s", err)
+	}
+	if n != 5 {
+		t.Fatalf("purged %d records, expected 5", n)
+	}
+	s.Close()
+	s = NewTestStore(path)
+	if _, ok := s.strings.strToId["Close"]; ok {
+		t.Fatalf("Close was not purged")
+	}
+	if got := s.GetUnusedStrings(); !reflect.DeepEqual(got, []string{"Exit"}) {
+		t.Fatalf("got unused strings %v, expected [Exit]", got)
+	}
+	li := langInfoByCode(s.LangInfos(), "pl")
+	if len(li.UnusedStrings) != 1 || li.UnusedStrings[0].Current() != "Wyjdź" {
+		t.Fatalf("translation of recently deleted Exit was not kept: %#v", li.UnusedStrings)
+	}
+
+	// deletion time is kept by compaction
+	if n, err = s.CompactAndPurge(now); err != nil || n != 3 {
+		t.Fatalf("CompactAndPurge() returned %d, %v, expected 3 purged records", n, err)
+	}
+	if got := s.GetUnusedStrings(); len(got) != 0 {
+		t.Fatalf("got unused strings %v", got)
+	}
+
+	// strings that are active again are not purged
+	if _, _, _, err = s.UpdateStringsList([]string{"Open", "Save"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	if _, _, _, err = s.UpdateStringsList([]string{"Open", "Save", "Exit"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	if n, err = s.CompactAndPurge(now.Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("CompactAndPurge() returned %d, %v, expected nothing purged", n, err)
+	}
+}
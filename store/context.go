@@ -0,0 +1,30 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import "strings"
+
+// ContextSeparator separates context from source in keys of strings that
+// have a context, like in gettext .mo files: "${context}\x04${source}". The
+// same source can have different translations in different contexts, e.g.
+// "Open" of a menu item and "Open" describing a state of a file. Such keys
+// are stored and translated like any other string
+const ContextSeparator = "\x04"
+
+// ContextKey returns key of source in context, which is source itself if
+// context is empty
+func ContextKey(source, context string) string {
+	if context == "" {
+		return source
+	}
+	return context + ContextSeparator + source
+}
+
+// SplitContextKey returns source and context of a key created with
+// ContextKey. Context is empty for strings without a context
+func SplitContextKey(key string) (source, context string) {
+	idx := strings.Index(key, ContextSeparator)
+	if idx < 0 {
+		return key, ""
+	}
+	return key[idx+len(ContextSeparator):], key[:idx]
+}
@@ -0,0 +1,48 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestContextKey(t *testing.T) {
+	for _, test := range []struct{ source, context string }{
+		{"Open", ""},
+		{"Open", "file state"},
+		{"", "menu"},
+	} {
+		key := ContextKey(test.source, test.context)
+		if source, context := SplitContextKey(key); source != test.source || context != test.context {
+			t.Errorf("%q: got %q, %q, expected %q, %q", key, source, context, test.source, test.context)
+		}
+	}
+	if key := ContextKey("Open", ""); key != "Open" {
+		t.Fatalf("got key %q for string without context", key)
+	}
+}
+
+func TestStringContexts(t *testing.T) {
+	path := "transtest_context.dat"
+	os.Remove(path)
+	defer os.Remove(path)
+
+	menu, state := ContextKey("Open", "menu"), ContextKey("Open", "file state")
+	s := NewTestStore(path)
+	if _, _, _, err := s.UpdateStringsList([]string{"Open", menu, state}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	s.writeNewTranslationMust(menu, "Öffnen", "de", "user1")
+	s.writeNewTranslationMust(state, "Geöffnet", "de", "user1")
+	s.Close()
+
+	// contexts are kept after reloading the store
+	s = NewTestStore(path)
+	defer s.Close()
+	exp := map[string]string{"Open": "", menu: "Öffnen", state: "Geöffnet"}
+	for key, expTrans := range exp {
+		if got, ok := translationOf(s, key, "de"); !ok || got != expTrans {
+			t.Errorf("%q: got %q, %v, expected %q", key, got, ok, expTrans)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 // This code is under BSD license. See license-bsd.txt
 package store
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
 
 type Lang struct {
 	Code       string
@@ -93,8 +98,53 @@ func LangToId(code string) int {
 	return -1
 }
 
+// LangsCount returns number of languages that are not disabled
 func LangsCount() int {
-	return len(Languages)
+	disabledLangsMu.RLock()
+	defer disabledLangsMu.RUnlock()
+	return len(Languages) - len(disabledLangs)
+}
+
+// disabledLangs are languages excluded from counts and listings of
+// translations, e.g. in the UI and in exports, without changing Languages.
+// Translations into them stay in stores and are back when they are enabled.
+// disabledLangsGeneration changes with every change to disabledLangs, so
+// that stores know when to recompute cached results
+var (
+	disabledLangsMu         sync.RWMutex
+	disabledLangs           = map[string]bool{}
+	disabledLangsGeneration int
+)
+
+// SetDisabledLangs disables languages with codes, enabling all other
+func SetDisabledLangs(codes []string) error {
+	m := make(map[string]bool)
+	for _, code := range codes {
+		code, err := ParseLangCode(code)
+		if err != nil {
+			return err
+		}
+		m[code] = true
+	}
+	disabledLangsMu.Lock()
+	defer disabledLangsMu.Unlock()
+	disabledLangs = m
+	disabledLangsGeneration++
+	return nil
+}
+
+// IsLangDisabled returns true if language with code is disabled, see
+// SetDisabledLangs
+func IsLangDisabled(code string) bool {
+	disabledLangsMu.RLock()
+	defer disabledLangsMu.RUnlock()
+	return disabledLangs[code]
+}
+
+func langsGeneration() int {
+	disabledLangsMu.RLock()
+	defer disabledLangsMu.RUnlock()
+	return disabledLangsGeneration
 }
 
 func LangCodeById(id int) string {
@@ -121,3 +171,97 @@ func IsValidLangCode(code string) bool {
 	}
 	return false
 }
+
+// well-formed language code: BCP 47 language subtag followed by optional
+// subtags, like region, in lower case
+var langCodeRx = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// some of our language codes are not standard, this maps standard codes
+// (normalized to lower case with "-") to them
+var langCodeAliases = map[string]string{
+	"pt-br": "br",
+	"zh-cn": "cn",
+	"zh-tw": "tw",
+	"ko-kr": "kr",
+	"cs-cz": "cz",
+	"da-dk": "dk",
+}
+
+// NormalizeLangCode returns code in the form used by Languages, so that
+// e.g. "DE", "de_DE" and "de-DE" all are "de": lower case, with "-" between
+// subtags and without region that only repeats the language. Standard codes
+// of languages with non-standard codes, e.g. "pt-BR", become our codes, see
+// langCodeAliases. Codes that don't become a known language code are only
+// trimmed and lower cased
+func NormalizeLangCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	normalized := strings.Replace(code, "_", "-", -1)
+	if IsValidLangCode(normalized) {
+		return normalized
+	}
+	if alias, ok := langCodeAliases[normalized]; ok {
+		return alias
+	}
+	parts := strings.Split(normalized, "-")
+	if len(parts) == 2 && parts[0] == parts[1] && IsValidLangCode(parts[0]) {
+		return parts[0]
+	}
+	return code
+}
+
+// ParseLangCode returns normalized code, see NormalizeLangCode, or an error
+// if code is not a well-formed code of a known language
+func ParseLangCode(code string) (string, error) {
+	normalized := NormalizeLangCode(code)
+	if !langCodeRx.MatchString(strings.Replace(normalized, "_", "-", -1)) {
+		return "", fmt.Errorf("invalid lang code %q", code)
+	}
+	if !IsValidLangCode(normalized) {
+		return "", fmt.Errorf("unknown lang code %q", code)
+	}
+	return normalized, nil
+}
+
+// validateLanguages checks that codes of langs are well-formed, normalized
+// and unique
+func validateLanguages(langs []Lang) error {
+	seen := make(map[string]bool)
+	for _, lang := range langs {
+		if !langCodeRx.MatchString(lang.Code) {
+			return fmt.Errorf("invalid lang code %q of %s", lang.Code, lang.Name)
+		}
+		if seen[lang.Code] {
+			return fmt.Errorf("duplicate lang code %q", lang.Code)
+		}
+		seen[lang.Code] = true
+	}
+	return nil
+}
+
+func init() {
+	if err := validateLanguages(Languages[:]); err != nil {
+		panic(err)
+	}
+}
+
+// LangFallbacks maps a regional variant of a language to the language it
+// falls back to when a string isn't translated. Variants with codes in the
+// form "${base}-${region}" fall back to ${base} without being listed here.
+var LangFallbacks = map[string]string{
+	"br": "pt", // Portuguese - Brazil => Portuguese - Portugal
+}
+
+// FallbackLangCode returns code of the language that translations for a
+// given language fall back to, or "" if there is none
+func FallbackLangCode(code string) string {
+	if fallback, ok := LangFallbacks[code]; ok {
+		return fallback
+	}
+	if idx := strings.Index(code, "-"); idx > 0 {
+		base := code[:idx]
+		if IsValidLangCode(base) {
+			return base
+		}
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLangCode(t *testing.T) {
+	tests := []struct {
+		code string
+		exp  string
+	}{
+		{"de", "de"},
+		{"DE", "de"},
+		{" de ", "de"},
+		{"de_DE", "de"},
+		{"de-DE", "de"},
+		{"fy_NL", "fy-nl"},
+		{"SR-rs", "sr-rs"},
+		// not the same language, so not normalized to "de"
+		{"de-AT", "de-at"},
+		{"pt-BR", "br"},
+		{"pt_BR", "br"},
+		{"zh-CN", "cn"},
+		{"zh_TW", "tw"},
+		{"ko-KR", "kr"},
+		{"cs-CZ", "cz"},
+		{"da-DK", "dk"},
+		// our codes don't change
+		{"br", "br"},
+	}
+	for _, test := range tests {
+		if got := NormalizeLangCode(test.code); got != test.exp {
+			t.Errorf("NormalizeLangCode(%q): got %q, expected %q", test.code, got, test.exp)
+		}
+	}
+}
+
+func TestParseLangCode(t *testing.T) {
+	for _, code := range []string{"DE", "de_DE", "de-DE"} {
+		if got, err := ParseLangCode(code); err != nil || got != "de" {
+			t.Errorf("ParseLangCode(%q): got %q, %v, expected de", code, got, err)
+		}
+	}
+	for code, exp := range langCodeAliases {
+		if !IsValidLangCode(exp) {
+			t.Errorf("alias %q of unknown lang code %q", code, exp)
+		}
+		if got, err := ParseLangCode(strings.ToUpper(code)); err != nil || got != exp {
+			t.Errorf("ParseLangCode(%q): got %q, %v, expected %s", code, got, err, exp)
+		}
+	}
+	for _, code := range []string{"", "d", "deutsch", "de--de", "de_", "12", "<b>", "xx", "de-at"} {
+		if got, err := ParseLangCode(code); err == nil {
+			t.Errorf("ParseLangCode(%q): got %q, expected an error", code, got)
+		}
+	}
+}
+
+func TestValidateLanguages(t *testing.T) {
+	if err := validateLanguages(Languages[:]); err != nil {
+		t.Fatalf("validateLanguages() failed with %s", err)
+	}
+	invalid := [][]Lang{
+		{{"de", "German", "Deutsch"}, {"de", "German", "Deutsch"}},
+		{{"DE", "German", "Deutsch"}},
+		{{"de_at", "German - Austria", "Deutsch"}},
+		{{"", "Empty", "Empty"}},
+	}
+	for _, langs := range invalid {
+		if validateLanguages(langs) == nil {
+			t.Errorf("expected an error for %v", langs)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// State is the state of a translation in the review workflow. A translator
+// translates a string, a reviewer approves the translation. A translation
+// that needs to be reviewed (fuzzy) must be approved or confirmed
+type State string
+
+const (
+	StateUntranslated State = "untranslated"
+	// translated and waiting for approval
+	StateTranslated State = "translated"
+	// fuzzy, e.g. machine translated or the source string changed
+	StateNeedsReview State = "needs_review"
+	StateApproved    State = "approved"
+)
+
+// States are all states, in the order of the workflow
+var States = []State{StateUntranslated, StateTranslated, StateNeedsReview, StateApproved}
+
+// IsValidState returns true if s is one of States
+func IsValidState(s State) bool {
+	for _, state := range States {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// State returns the state of the translation
+func (t *Translation) State() State {
+	switch {
+	case !t.IsTranslated():
+		return StateUntranslated
+	case t.Fuzzy:
+		return StateNeedsReview
+	case t.Approved:
+		return StateApproved
+	}
+	return StateTranslated
+}
+
+// IsValidTransition returns true if SetState() can change a translation
+// from state from to state to. Translating is the only way out of
+// StateUntranslated and a translation can't be removed
+func IsValidTransition(from, to State) bool {
+	if from == StateUntranslated || to == StateUntranslated {
+		return false
+	}
+	return IsValidState(from) && IsValidState(to)
+}
+
+func (s *StoreCsv) setApproved(strId, langId int, approved bool) {
+	if approved {
+		s.approved[strLang{strId, langId}] = true
+		delete(s.fuzzy, strLang{strId, langId})
+	} else {
+		delete(s.approved, strLang{strId, langId})
+	}
+	s.resetCaches()
+}
+
+// ap, ${timeUnix}, ${langStr}, ${strId}, ${0|1}
+func (s *StoreCsv) decodeApprovedRecord(rec []string) error {
+	if len(rec) != 5 {
+		return fmt.Errorf("'ap' record should have 5 fields, is '%#v'", rec)
+	}
+	langId := LangToId(rec[2])
+	if langId < 0 {
+		return fmt.Errorf("rec[2] (%q) is not a valid language", rec[2])
+	}
+	strId, err := strconv.Atoi(rec[3])
+	if err != nil {
+		return fmt.Errorf("rec[3] (%q) failed to parse as int, error: %q", rec[3], err)
+	}
+	if _, ok := s.strings.GetById(strId); !ok {
+		return fmt.Errorf("rec[3] (%q, '%d') is not a valid string id", rec[3], strId)
+	}
+	s.setApproved(strId, langId, rec[4] == "1")
+	return nil
+}
+
+func (s *StoreCsv) writeApproved(strId int, lang string, approved bool) error {
+	val := "0"
+	if approved {
+		val = "1"
+	}
+	timeStr := strconv.FormatInt(clock.Now().Unix(), 10)
+	rec := []string{recIdApproved, timeStr, lang, strconv.Itoa(strId), val}
+	if err := s.writeCsv(rec); err != nil {
+		return err
+	}
+	s.setApproved(strId, LangToId(lang), approved)
+	return nil
+}
+
+func (s *StoreCsv) state(strId, langId int) State {
+	key := strLang{strId, langId}
+	switch {
+	case s.fuzzy[key]:
+		return StateNeedsReview
+	case s.approved[key]:
+		return StateApproved
+	}
+	for i := len(s.edits) - 1; i >= 0; i-- {
+		if e := &s.edits[i]; e.stringId == strId && e.langId == langId {
+			return StateTranslated
+		}
+	}
+	return StateUntranslated
+}
+
+// State returns the state of translation of str into lang
+func (s *StoreCsv) State(str, lang string) State {
+	s.Lock()
+	defer s.Unlock()
+	strId, exists := s.strings.strToId[str]
+	langId := LangToId(lang)
+	if !exists || langId < 0 {
+		return StateUntranslated
+	}
+	return s.state(strId, langId)
+}
+
+// SetState changes the state of translation of str into lang. Returns an
+// error if it's not a valid transition, see IsValidTransition()
+func (s *StoreCsv) SetState(str, lang string, state State) error {
+	s.Lock()
+	defer s.Unlock()
+	strId, exists := s.strings.strToId[str]
+	if !exists {
+		return fmt.Errorf("string %q doesn't exist", str)
+	}
+	langId := LangToId(lang)
+	if langId < 0 {
+		return fmt.Errorf("invalid lang %q", lang)
+	}
+	from := s.state(strId, langId)
+	if !IsValidTransition(from, state) {
+		return fmt.Errorf("can't change state of %q in %s from %s to %s", str, lang, from, state)
+	}
+	if from == state {
+		return nil
+	}
+	switch state {
+	case StateApproved:
+		return s.writeApproved(strId, lang, true)
+	case StateNeedsReview:
+		return s.writeFuzzy(str, lang, true)
+	}
+	// to StateTranslated
+	if from == StateApproved {
+		return s.writeApproved(strId, lang, false)
+	}
+	return s.writeFuzzy(str, lang, false)
+}
+
+// ApproveAll approves translations of all active strings into lang that are
+// translated or need review. Strings that should not be translated are
+// skipped. Returns number of approved translations
+func (s *StoreCsv) ApproveAll(lang string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	langId := LangToId(lang)
+	if langId < 0 {
+		return 0, fmt.Errorf("invalid lang %q", lang)
+	}
+	// strings that have a translation, see state()
+	translated := make(map[int]bool)
+	for i := range s.edits {
+		if e := &s.edits[i]; e.langId == langId {
+			translated[e.stringId] = true
+		}
+	}
+	n := 0
+	for _, strId := range s.activeStrings {
+		key := strLang{strId, langId}
+		if s.isNoTranslate(strId) || !s.fuzzy[key] && (s.approved[key] || !translated[strId]) {
+			continue
+		}
+		if err := s.writeApproved(strId, lang, true); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
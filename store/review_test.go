@@ -0,0 +1,138 @@
+// This code is under BSD license. See license-bsd.txt
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStateTransitions(t *testing.T) {
+	path := "transtest_review.dat"
+	defer os.Remove(path)
+	s := NewTestStore(path)
+	defer func() { s.Close() }()
+	if _, _, _, err := s.UpdateStringsList([]string{"Open", "Close"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+
+	if state := s.State("Open", "pl"); state != StateUntranslated {
+		t.Fatalf("got state %s, expected %s", state, StateUntranslated)
+	}
+	// nothing can be done with an untranslated string except translating it
+	for _, to := range States {
+		if err := s.SetState("Open", "pl", to); err == nil {
+			t.Fatalf("changed untranslated string to %s", to)
+		}
+	}
+	s.writeNewTranslationMust("Open", "Otwórz", "pl", "user1")
+
+	tests := []struct {
+		to State
+		ok bool
+	}{
+		{StateUntranslated, false},
+		{StateTranslated, true},
+		{StateNeedsReview, true},
+		{StateTranslated, true},
+		{StateApproved, true},
+		{StateApproved, true},
+		{StateNeedsReview, true},
+		{StateApproved, true},
+		{StateTranslated, true},
+		{StateNeedsReview, true},
+		{StateUntranslated, false},
+		{State("bogus"), false},
+	}
+	expected := StateTranslated
+	for i, test := range tests {
+		err := s.SetState("Open", "pl", test.to)
+		if (err == nil) != test.ok {
+			t.Fatalf("%d: SetState(%s) from %s returned %v", i, test.to, expected, err)
+		}
+		if test.ok {
+			expected = test.to
+		}
+		if state := s.State("Open", "pl"); state != expected {
+			t.Fatalf("%d: got state %s, expected %s", i, state, expected)
+		}
+	}
+	if err := s.SetState("Open", "pl", StateApproved); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+
+	// states survive re-opening and compaction
+	s.Close()
+	s = NewTestStore(path)
+	if state := s.State("Open", "pl"); state != StateApproved {
+		t.Fatalf("got state %s after re-opening, expected %s", state, StateApproved)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() failed with %s", err)
+	}
+	if state := s.State("Open", "pl"); state != StateApproved {
+		t.Fatalf("got state %s after compaction, expected %s", state, StateApproved)
+	}
+	if state := s.State("Open", "de"); state != StateUntranslated {
+		t.Fatalf("approval leaked to another language: %s", state)
+	}
+
+	// a new translation must be approved again, marking as fuzzy revokes
+	// the approval
+	s.writeNewTranslationMust("Open", "Otwieraj", "pl", "user2")
+	if state := s.State("Open", "pl"); state != StateTranslated {
+		t.Fatalf("got state %s after a new translation, expected %s", state, StateTranslated)
+	}
+	if err := s.SetState("Open", "pl", StateApproved); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+	if err := s.SetFuzzy("Open", "pl", true); err != nil {
+		t.Fatalf("SetFuzzy() failed with %s", err)
+	}
+	if state := s.State("Open", "pl"); state != StateNeedsReview {
+		t.Fatalf("got state %s after SetFuzzy(), expected %s", state, StateNeedsReview)
+	}
+}
+
+func TestApproveAll(t *testing.T) {
+	path := "transtest_approveall.dat"
+	defer os.Remove(path)
+	s := NewTestStore(path)
+	defer func() { s.Close() }()
+	if _, _, _, err := s.UpdateStringsList([]string{"Open", "Close", "Save", "Exit", "PDF"}); err != nil {
+		t.Fatalf("UpdateStringsList() failed with %s", err)
+	}
+	s.writeNewTranslationMust("Open", "Otwórz", "pl", "user1")
+	s.writeNewTranslationMust("Close", "Zamknij", "pl", "user1")
+	s.writeNewTranslationMust("Save", "Zapisz", "pl", "user1")
+	s.writeNewTranslationMust("PDF", "PDF", "pl", "user1")
+	s.writeNewTranslationMust("Open", "Open", "de", "user1")
+	if err := s.SetState("Close", "pl", StateNeedsReview); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+	if err := s.SetState("Save", "pl", StateApproved); err != nil {
+		t.Fatalf("SetState() failed with %s", err)
+	}
+	if err := s.SetNoTranslate("PDF", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	n, err := s.ApproveAll("pl")
+	if err != nil || n != 2 {
+		t.Fatalf("ApproveAll() returned %d, %v, expected 2", n, err)
+	}
+	exp := map[string]State{"Open": StateApproved, "Close": StateApproved, "Save": StateApproved, "Exit": StateUntranslated, "PDF": StateTranslated}
+	for str, state := range exp {
+		if got := s.State(str, "pl"); got != state {
+			t.Fatalf("%s: got state %s, expected %s", str, got, state)
+		}
+	}
+	if got := s.State("Open", "de"); got != StateTranslated {
+		t.Fatalf("translation into de got state %s", got)
+	}
+	if n, err = s.ApproveAll("pl"); err != nil || n != 0 {
+		t.Fatalf("second ApproveAll() returned %d, %v, expected 0", n, err)
+	}
+	if _, err = s.ApproveAll("xx"); err == nil {
+		t.Fatalf("expected an error for invalid lang")
+	}
+}
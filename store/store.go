@@ -19,12 +19,35 @@ import (
 s,  ${strId}, ${str}
 t,  ${timeUnix}, ${userStr}, ${langStr}, ${strId}, ${translation}
 as, ${timeUnix}, ${strId}, ...
+m,  ${timeUnix}, ${strId}, ${key}, ${value}
+fz, ${timeUnix}, ${langStr}, ${strId}, ${0|1}
+ap, ${timeUnix}, ${langStr}, ${strId}, ${0|1}
+rn, ${timeUnix}, ${strId}, ${newStr}
 
 */
 const (
-	recIdNewString = "s"
-	recIdTrans     = "t"
-	recIdActiveSet = "as"
+	recIdNewString  = "s"
+	recIdTrans      = "t"
+	recIdActiveSet  = "as"
+	recIdStringMeta = "m"
+	recIdFuzzy      = "fz"
+	recIdApproved   = "ap"
+	recIdRename     = "rn"
+)
+
+// keys of string metadata
+const (
+	// if not empty, the string should not be translated (e.g. brand names)
+	MetaNoTranslate = "notranslate"
+	// maximum length of translations, in characters
+	MetaMaxLen = "maxlen"
+	// url of a screenshot or a page showing where the string is used
+	MetaContextURL = "contexturl"
+	// name of the group of related strings, shown together in the UI
+	MetaGroup = "group"
+	// unix time when the string was removed from active strings. Set by
+	// the store, see setActiveStringsAt()
+	MetaDeletedAt = "deletedat"
 )
 
 type TranslationRec struct {
@@ -33,6 +56,8 @@ type TranslationRec struct {
 	stringId    int
 	translation string
 	time        time.Time
+	// optional note of the translator about this translation
+	note string
 }
 
 type Edit struct {
@@ -41,6 +66,13 @@ type Edit struct {
 	Text        string
 	Translation string
 	Time        time.Time
+	Note        string
+}
+
+// identifies translation of a string into a language
+type strLang struct {
+	strId  int
+	langId int
 }
 
 type Translator struct {
@@ -58,6 +90,28 @@ type StoreCsv struct {
 	activeStrings        []int
 	deletedStringsBitmap []bool
 	edits                []TranslationRec
+	// metadata of strings, indexed by string id and key
+	stringsMeta map[int]map[string]string
+	// translations that need to be reviewed (e.g. machine translations)
+	fuzzy map[strLang]bool
+	// translations approved by a reviewer, never fuzzy
+	approved map[strLang]bool
+	// cached results of computeStats() and langInfos(), reset on every
+	// change to the store
+	stats          *Stats
+	langInfosCache []*LangInfo
+	// disabledLangsGeneration the caches were computed for
+	langsGeneration int
+	// incremented on every change to the store
+	generation int
+}
+
+// Stats is a summary of counts for the store, computed in one pass
+type Stats struct {
+	LangsCount        int
+	StringsCount      int
+	UntranslatedCount int
+	EditsCount        int
 }
 
 func openCsv(path string) (*os.File, *csv.Writer, error) {
@@ -72,18 +126,10 @@ func openCsv(path string) (*os.File, *csv.Writer, error) {
 func NewStoreCsv(path string) (*StoreCsv, error) {
 	//fmt.Printf("NewStoreCsv: %q\n", path)
 	var err error
-	s := &StoreCsv{
-		filePath: path,
-		strings:  NewStringInterner(),
-		users:    NewStringInterner(),
-		edits:    make([]TranslationRec, 0),
-	}
-	if u.PathExists(path) {
-		if err = s.readExistingRecords(path); err != nil {
-			return nil, err
-		}
+	s := &StoreCsv{filePath: path, langsGeneration: langsGeneration()}
+	if err = s.load(); err != nil {
+		return nil, err
 	}
-	s.setActiveStrings(s.activeStrings)
 	if s.file, s.w, err = openCsv(path); err != nil {
 		return nil, err
 	}
@@ -91,6 +137,33 @@ func NewStoreCsv(path string) (*StoreCsv, error) {
 	return s, nil
 }
 
+// FilePath returns path of the store file
+func (s *StoreCsv) FilePath() string {
+	return s.filePath
+}
+
+// load replaces in-memory state with the content of the store file
+func (s *StoreCsv) load() error {
+	s.strings = NewStringInterner()
+	s.users = NewStringInterner()
+	s.activeStrings = nil
+	s.edits = make([]TranslationRec, 0)
+	s.stringsMeta = make(map[int]map[string]string)
+	s.fuzzy = make(map[strLang]bool)
+	s.approved = make(map[strLang]bool)
+	if u.PathExists(s.filePath) {
+		if err := s.readExistingRecords(s.filePath); err != nil {
+			return err
+		}
+	}
+	s.setActiveStrings(s.activeStrings)
+	// compacted file is sorted by string, not by time
+	sort.SliceStable(s.edits, func(i, j int) bool {
+		return s.edits[i].time.Before(s.edits[j].time)
+	})
+	return nil
+}
+
 func (s *StoreCsv) writeCsv(rec []string) error {
 	recs := [][]string{rec}
 	return s.w.WriteAll(recs)
@@ -113,7 +186,7 @@ func (s *StoreCsv) internStringAndWriteIfNecessary(str string) (int, error) {
 }
 
 func buildActiveSetRec(activeStrings []int) []string {
-	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+	timeStr := strconv.FormatInt(clock.Now().Unix(), 10)
 
 	r := IntRangeFromIntArray(activeStrings)
 	n := len(r)
@@ -146,7 +219,7 @@ func (s *StoreCsv) decodeNewStringRecord(rec []string) error {
 	return nil
 }
 
-func (s *StoreCsv) addTranslationRec(strId, langId, userId int, trans string, time time.Time) {
+func (s *StoreCsv) addTranslationRec(strId, langId, userId int, trans, note string, time time.Time) {
 	if strId >= s.allStringsCount() {
 		panic(fmt.Sprintf("strId >= s.allStringsCount() (%d >= %d)", strId, s.allStringsCount()))
 	}
@@ -156,14 +229,19 @@ func (s *StoreCsv) addTranslationRec(strId, langId, userId int, trans string, ti
 		stringId:    strId,
 		translation: trans,
 		time:        time,
+		note:        note,
 	}
 	s.edits = append(s.edits, tr)
+	// a new translation is no longer fuzzy and needs a new approval
+	delete(s.fuzzy, strLang{strId, langId})
+	delete(s.approved, strLang{strId, langId})
+	s.resetCaches()
 }
 
-// t,  ${timeUnix}, ${userStr}, ${langStr}, ${strId}, ${translation}
+// t,  ${timeUnix}, ${userStr}, ${langStr}, ${strId}, ${translation}[, ${note}]
 func (s *StoreCsv) decodeTranslationRecord(rec []string) error {
-	if len(rec) != 6 {
-		return fmt.Errorf("'t' record should have 6 fields, is '%#v'", rec)
+	if len(rec) != 6 && len(rec) != 7 {
+		return fmt.Errorf("'t' record should have 6 or 7 fields, is '%#v'", rec)
 	}
 	timeSecs, err := strconv.ParseInt(rec[1], 10, 64)
 	if err != nil {
@@ -181,7 +259,11 @@ func (s *StoreCsv) decodeTranslationRecord(rec []string) error {
 		return fmt.Errorf("rec[4] (%q, '%d') is not a valid string id", rec[4], strId)
 	}
 	trans := rec[5]
-	s.addTranslationRec(strId, langId, userId, trans, time)
+	note := ""
+	if len(rec) == 7 {
+		note = rec[6]
+	}
+	s.addTranslationRec(strId, langId, userId, trans, note, time)
 	return nil
 }
 
@@ -200,7 +282,97 @@ func (s *StoreCsv) decodeActiveSetRecord(rec []string) error {
 		activeRange[i] = ir
 	}
 
-	s.setActiveStrings(IntRangeToArray(activeRange))
+	// deletion times are unknown if time doesn't parse, which only delays
+	// purging of deleted strings
+	var t time.Time
+	if timeSecs, err := strconv.ParseInt(rec[1], 10, 64); err == nil {
+		t = time.Unix(timeSecs, 0)
+	}
+	s.setActiveStringsAt(IntRangeToArray(activeRange), t)
+	return nil
+}
+
+func (s *StoreCsv) setStringMeta(strId int, key, value string) {
+	m := s.stringsMeta[strId]
+	if m == nil {
+		m = make(map[string]string)
+		s.stringsMeta[strId] = m
+	}
+	if value == "" {
+		delete(m, key)
+	} else {
+		m[key] = value
+	}
+	s.resetCaches()
+}
+
+// m,  ${timeUnix}, ${strId}, ${key}, ${value}
+func (s *StoreCsv) decodeStringMetaRecord(rec []string) error {
+	if len(rec) != 5 {
+		return fmt.Errorf("'m' record should have 5 fields, is '%#v'", rec)
+	}
+	strId, err := strconv.Atoi(rec[2])
+	if err != nil {
+		return fmt.Errorf("rec[2] (%q) failed to parse as int, error: %q", rec[2], err)
+	}
+	if _, ok := s.strings.GetById(strId); !ok {
+		return fmt.Errorf("rec[2] (%q, '%d') is not a valid string id", rec[2], strId)
+	}
+	s.setStringMeta(strId, rec[3], rec[4])
+	return nil
+}
+
+func (s *StoreCsv) setFuzzy(strId, langId int, fuzzy bool) {
+	if fuzzy {
+		s.fuzzy[strLang{strId, langId}] = true
+		delete(s.approved, strLang{strId, langId})
+	} else {
+		delete(s.fuzzy, strLang{strId, langId})
+	}
+	s.resetCaches()
+}
+
+// fz, ${timeUnix}, ${langStr}, ${strId}, ${0|1}
+func (s *StoreCsv) decodeFuzzyRecord(rec []string) error {
+	if len(rec) != 5 {
+		return fmt.Errorf("'fz' record should have 5 fields, is '%#v'", rec)
+	}
+	langId := LangToId(rec[2])
+	if langId < 0 {
+		return fmt.Errorf("rec[2] (%q) is not a valid language", rec[2])
+	}
+	strId, err := strconv.Atoi(rec[3])
+	if err != nil {
+		return fmt.Errorf("rec[3] (%q) failed to parse as int, error: %q", rec[3], err)
+	}
+	if _, ok := s.strings.GetById(strId); !ok {
+		return fmt.Errorf("rec[3] (%q, '%d') is not a valid string id", rec[3], strId)
+	}
+	s.setFuzzy(strId, langId, rec[4] == "1")
+	return nil
+}
+
+func (s *StoreCsv) renameString(strId int, newStr string) {
+	s.strings.Rename(strId, newStr)
+	s.resetCaches()
+}
+
+// rn, ${timeUnix}, ${strId}, ${newStr}
+func (s *StoreCsv) decodeRenameRecord(rec []string) error {
+	if len(rec) != 4 {
+		return fmt.Errorf("'rn' record should have 4 fields, is '%#v'", rec)
+	}
+	strId, err := strconv.Atoi(rec[2])
+	if err != nil {
+		return fmt.Errorf("rec[2] (%q) failed to parse as int, error: %q", rec[2], err)
+	}
+	if _, ok := s.strings.GetById(strId); !ok {
+		return fmt.Errorf("rec[2] (%q, '%d') is not a valid string id", rec[2], strId)
+	}
+	if _, exists := s.strings.strToId[rec[3]]; exists {
+		return fmt.Errorf("rec[3] (%q) already exists", rec[3])
+	}
+	s.renameString(strId, rec[3])
 	return nil
 }
 
@@ -216,6 +388,14 @@ func (s *StoreCsv) decodeRecord(rec []string) error {
 		err = s.decodeActiveSetRecord(rec)
 	case recIdTrans:
 		err = s.decodeTranslationRecord(rec)
+	case recIdStringMeta:
+		err = s.decodeStringMetaRecord(rec)
+	case recIdFuzzy:
+		err = s.decodeFuzzyRecord(rec)
+	case recIdApproved:
+		err = s.decodeApprovedRecord(rec)
+	case recIdRename:
+		err = s.decodeRenameRecord(rec)
 	default:
 		err = fmt.Errorf("unkown record type %q", rec[0])
 	}
@@ -263,12 +443,12 @@ func (s *StoreCsv) activeStringsCount() int {
 func (s *StoreCsv) translatedCountForLangs() map[int]int {
 	m := make(map[int][]bool)
 	totalStrings := s.strings.Count()
-	for langId := 0; langId < LangsCount(); langId++ {
+	for langId := range Languages {
 		m[langId] = make([]bool, totalStrings, totalStrings)
 	}
 	res := make(map[int]int)
 	for _, trec := range s.edits {
-		if !s.isUnused(trec.stringId) {
+		if !s.isUnused(trec.stringId) && !s.isNoTranslate(trec.stringId) {
 			arr := m[trec.langId]
 			arr[trec.stringId] = true
 		}
@@ -285,10 +465,24 @@ func (s *StoreCsv) translatedCountForLangs() map[int]int {
 	return res
 }
 
+// strings marked as not to be translated don't count as untranslated
+func (s *StoreCsv) translatableStringsCount() int {
+	n := 0
+	for _, strId := range s.activeStrings {
+		if !s.isNoTranslate(strId) {
+			n++
+		}
+	}
+	return n
+}
+
 func (s *StoreCsv) untranslatedCount() int {
 	n := 0
-	totalStrings := s.activeStringsCount()
-	for _, translatedCount := range s.translatedCountForLangs() {
+	totalStrings := s.translatableStringsCount()
+	for langId, translatedCount := range s.translatedCountForLangs() {
+		if IsLangDisabled(LangCodeById(langId)) {
+			continue
+		}
 		n += (totalStrings - translatedCount)
 	}
 	return n
@@ -299,7 +493,39 @@ func (s *StoreCsv) untranslatedForLang(lang string) int {
 	langId := LangToId(lang)
 	panicif(langId == -1, "LangToId(lang) returned -1")
 	translated := translatedPerLang[langId]
-	return s.activeStringsCount() - translated
+	return s.translatableStringsCount() - translated
+}
+
+func (s *StoreCsv) resetCaches() {
+	s.stats = nil
+	s.langInfosCache = nil
+	s.generation++
+}
+
+// resetCachesIfLangsChanged resets caches if languages were enabled or
+// disabled since they were computed
+func (s *StoreCsv) resetCachesIfLangsChanged() {
+	if gen := langsGeneration(); gen != s.langsGeneration {
+		s.resetCaches()
+		s.langsGeneration = gen
+	}
+}
+
+// Generation returns a number that changes with every change to the store,
+// so that callers can tell if values they computed from it are stale
+func (s *StoreCsv) Generation() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.generation
+}
+
+func (s *StoreCsv) computeStats() *Stats {
+	return &Stats{
+		LangsCount:        LangsCount(),
+		StringsCount:      s.activeStringsCount(),
+		UntranslatedCount: s.untranslatedCount(),
+		EditsCount:        len(s.edits),
+	}
 }
 
 func (s *StoreCsv) userById(id int) string {
@@ -329,18 +555,22 @@ func (s *StoreCsv) recentEdits(max int) []Edit {
 	}
 	res := make([]Edit, n, n)
 	for i := 0; i < n; i++ {
-		tr := &(s.edits[transCount-i-1])
-		var e Edit
-		e.Lang = s.langById(tr.langId)
-		e.User = s.userById(tr.userId)
-		e.Text = s.stringByIdMust(tr.stringId)
-		e.Translation = tr.translation
-		e.Time = tr.time
-		res[i] = e
+		res[i] = s.editFromRec(&(s.edits[transCount-i-1]))
 	}
 	return res
 }
 
+func (s *StoreCsv) editFromRec(tr *TranslationRec) Edit {
+	return Edit{
+		Lang:        s.langById(tr.langId),
+		User:        s.userById(tr.userId),
+		Text:        s.stringByIdMust(tr.stringId),
+		Translation: tr.translation,
+		Time:        tr.time,
+		Note:        tr.note,
+	}
+}
+
 func (s *StoreCsv) isUnused(strId int) bool {
 	if strId >= s.allStringsCount() {
 		fmt.Printf("strId %d too large, all strings: %d, bitmap len: %d\n", strId, s.allStringsCount(), len(s.deletedStringsBitmap))
@@ -351,11 +581,27 @@ func (s *StoreCsv) isUnused(strId int) bool {
 	return s.deletedStringsBitmap[strId]
 }
 
+func (s *StoreCsv) isNoTranslate(strId int) bool {
+	return s.stringsMeta[strId][MetaNoTranslate] != ""
+}
+
+func parseMaxLen(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
 func (s *StoreCsv) translationsForLang(langId int) ([]*Translation, []*Translation) {
 	n := len(s.strings.strings)
 	all := make([]*Translation, n)
 	for strId, str := range s.strings.strings {
 		all[strId] = NewTranslation(strId, str, "")
+		all[strId].NoTranslate = s.isNoTranslate(strId)
+		all[strId].MaxLen = parseMaxLen(s.stringsMeta[strId][MetaMaxLen])
+		all[strId].ContextURL = s.stringsMeta[strId][MetaContextURL]
+		all[strId].Group = s.stringsMeta[strId][MetaGroup]
 	}
 
 	for _, edit := range s.edits {
@@ -363,7 +609,18 @@ func (s *StoreCsv) translationsForLang(langId int) ([]*Translation, []*Translati
 			continue
 		}
 		tr := all[edit.stringId]
-		tr.add(edit.translation)
+		tr.add(edit.translation, edit.note)
+		tr.Modified = edit.time
+	}
+	for key := range s.fuzzy {
+		if key.langId == langId {
+			all[key.strId].Fuzzy = true
+		}
+	}
+	for key := range s.approved {
+		if key.langId == langId {
+			all[key.strId].Approved = true
+		}
 	}
 
 	active := make([]*Translation, 0)
@@ -382,10 +639,15 @@ func (s *StoreCsv) langInfos() []*LangInfo {
 	res := make([]*LangInfo, 0)
 	for langId, lang := range Languages {
 		langCode := lang.Code
+		if IsLangDisabled(langCode) {
+			continue
+		}
 		li := NewLangInfo(langCode)
 		li.ActiveStrings, li.UnusedStrings = s.translationsForLang(langId)
 		sort.Sort(ByString{li.ActiveStrings})
 		sort.Sort(ByString2{li.UnusedStrings})
+		// calculate now so that it's not lazily modified after being cached
+		li.UntranslatedCount()
 		res = append(res, li)
 	}
 	sort.Sort(ByUntranslated{res})
@@ -397,21 +659,25 @@ func (s *StoreCsv) editsByUser(user string) []Edit {
 	transCount := len(s.edits)
 	for i := 0; i < transCount; i++ {
 		tr := &(s.edits[transCount-i-1])
-		editUser := s.userById(tr.userId)
-		if editUser == user {
-			var e = Edit{
-				Lang:        s.langById(tr.langId),
-				User:        editUser,
-				Text:        s.stringByIdMust(tr.stringId),
-				Translation: tr.translation,
-				Time:        tr.time,
-			}
-			res = append(res, e)
+		if s.userById(tr.userId) == user {
+			res = append(res, s.editFromRec(tr))
 		}
 	}
 	return res
 }
 
+// edits made before since are not counted
+func (s *StoreCsv) editsCountByUser(since time.Time) map[string]int {
+	res := make(map[string]int)
+	for _, tr := range s.edits {
+		if tr.time.Before(since) {
+			continue
+		}
+		res[s.userById(tr.userId)]++
+	}
+	return res
+}
+
 func (s *StoreCsv) editsForLang(lang string, max int) []Edit {
 	res := make([]Edit, 0)
 	transCount := len(s.edits)
@@ -419,14 +685,7 @@ func (s *StoreCsv) editsForLang(lang string, max int) []Edit {
 		tr := &(s.edits[transCount-i-1])
 		editLang := s.langById(tr.langId)
 		if editLang == lang {
-			var e = Edit{
-				Lang:        s.langById(tr.langId),
-				User:        s.userById(tr.userId),
-				Text:        s.stringByIdMust(tr.stringId),
-				Translation: tr.translation,
-				Time:        tr.time,
-			}
-			res = append(res, e)
+			res = append(res, s.editFromRec(tr))
 			if max != -1 && len(res) >= max {
 				return res
 			}
@@ -475,9 +734,43 @@ func (s *StoreCsv) setActiveStrings(activeStrings []int) {
 		bitmap[id] = false
 	}
 	s.deletedStringsBitmap = bitmap
+	s.resetCaches()
 	//fmt.Printf("setActiveStrings: n1: %d, n2: %d\n", n, len(s.deletedStringsBitmap))
 }
 
+// setActiveStringsAt is setActiveStrings() done at time t. Remembers when
+// strings were deleted, so that they can be purged after a while
+func (s *StoreCsv) setActiveStringsAt(activeStrings []int, t time.Time) {
+	wasActive := make(map[int]bool)
+	for _, id := range s.activeStrings {
+		wasActive[id] = true
+	}
+	s.setActiveStrings(activeStrings)
+	for _, id := range s.activeStrings {
+		if s.stringsMeta[id][MetaDeletedAt] != "" {
+			s.setStringMeta(id, MetaDeletedAt, "")
+		}
+	}
+	if t.IsZero() {
+		return
+	}
+	for id := range wasActive {
+		if s.isUnused(id) {
+			s.setStringMeta(id, MetaDeletedAt, strconv.FormatInt(t.Unix(), 10))
+		}
+	}
+}
+
+// deletedAt returns when a deleted string was deleted, zero time if not
+// known
+func (s *StoreCsv) deletedAt(strId int) time.Time {
+	timeSecs, err := strconv.ParseInt(s.stringsMeta[strId][MetaDeletedAt], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(timeSecs, 0)
+}
+
 func (s *StoreCsv) getDeletedStrings() []string {
 	res := make([]string, 0)
 	for strId, isDeleted := range s.deletedStringsBitmap {
@@ -490,8 +783,8 @@ func (s *StoreCsv) getDeletedStrings() []string {
 	return res
 }
 
-// t,  ${timeUnix}, ${userStr}, ${langStr}, ${strId}, ${translation}
-func (s *StoreCsv) writeNewTranslation(txt, trans, lang, user string) error {
+// t,  ${timeUnix}, ${userStr}, ${langStr}, ${strId}, ${translation}[, ${note}]
+func (s *StoreCsv) writeNewTranslation(txt, trans, lang, user, note string) error {
 	strId, err := s.internStringAndWriteIfNecessary(txt)
 	if err != nil {
 		return err
@@ -499,20 +792,46 @@ func (s *StoreCsv) writeNewTranslation(txt, trans, lang, user string) error {
 	langId := LangToId(lang)
 	panicif(langId < 0, "invalid lang: %s", lang)
 	userId, _ := s.users.Intern(user)
-	t := time.Now()
+	t := clock.Now()
 	timeSecsStr := strconv.FormatInt(t.Unix(), 10)
 	recs := []string{recIdTrans, timeSecsStr, user, lang, strconv.Itoa(strId), trans}
+	if note != "" {
+		recs = append(recs, note)
+	}
 	if err = s.writeCsv(recs); err != nil {
 		return err
 	}
-	s.addTranslationRec(strId, langId, userId, trans, t)
+	s.addTranslationRec(strId, langId, userId, trans, note, t)
+	return nil
+}
+
+func (s *StoreCsv) writeFuzzy(str, lang string, fuzzy bool) error {
+	strId, exists := s.strings.strToId[str]
+	if !exists {
+		return fmt.Errorf("string %q doesn't exist", str)
+	}
+	langId := LangToId(lang)
+	panicif(langId < 0, "invalid lang: %s", lang)
+	if s.fuzzy[strLang{strId, langId}] == fuzzy {
+		return nil
+	}
+	val := "0"
+	if fuzzy {
+		val = "1"
+	}
+	timeStr := strconv.FormatInt(clock.Now().Unix(), 10)
+	rec := []string{recIdFuzzy, timeStr, lang, strconv.Itoa(strId), val}
+	if err := s.writeCsv(rec); err != nil {
+		return err
+	}
+	s.setFuzzy(strId, langId, fuzzy)
 	return nil
 }
 
 func (s *StoreCsv) duplicateTranslation(origStr, newStr string) error {
 	origStrId := s.strings.IdByStrMust(origStr)
 	// find most recent translations for each language
-	nLangs := LangsCount()
+	nLangs := len(Languages)
 	langTrans := make([]string, nLangs, nLangs)
 	langUserId := make([]int, nLangs, nLangs)
 	for _, edit := range s.edits {
@@ -530,7 +849,7 @@ func (s *StoreCsv) duplicateTranslation(origStr, newStr string) error {
 		lang := s.langById(langId)
 		user := s.userById(langUserId[langId])
 		trans := langTrans[langId]
-		if err := s.writeNewTranslation(newStr, trans, lang, user); err != nil {
+		if err := s.writeNewTranslation(newStr, trans, lang, user, ""); err != nil {
 			return err
 		}
 	}
@@ -541,7 +860,69 @@ func (s *StoreCsv) duplicateTranslation(origStr, newStr string) error {
 func (s *StoreCsv) WriteNewTranslation(txt, trans, lang, user string) error {
 	s.Lock()
 	defer s.Unlock()
-	return s.writeNewTranslation(txt, trans, lang, user)
+	return s.writeNewTranslation(txt, trans, lang, user, "")
+}
+
+// WriteNewTranslationWithNote writes a translation with a note of the
+// translator, e.g. explaining a debatable choice
+func (s *StoreCsv) WriteNewTranslationWithNote(txt, trans, lang, user, note string) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.writeNewTranslation(txt, trans, lang, user, note)
+}
+
+// WriteFuzzyTranslation writes a translation that needs to be reviewed,
+// e.g. a machine translation
+func (s *StoreCsv) WriteFuzzyTranslation(txt, trans, lang, user string) error {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.writeNewTranslation(txt, trans, lang, user, ""); err != nil {
+		return err
+	}
+	return s.writeFuzzy(txt, lang, true)
+}
+
+// SetFuzzy marks translation of str into lang as needing a review (or not)
+func (s *StoreCsv) SetFuzzy(str, lang string, fuzzy bool) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.writeFuzzy(str, lang, fuzzy)
+}
+
+// IsFuzzy returns true if translation of str into lang needs a review
+func (s *StoreCsv) IsFuzzy(str, lang string) bool {
+	s.Lock()
+	defer s.Unlock()
+	strId, exists := s.strings.strToId[str]
+	if !exists {
+		return false
+	}
+	return s.fuzzy[strLang{strId, LangToId(lang)}]
+}
+
+func (s *StoreCsv) writeRename(oldStr, newStr string) error {
+	strId, exists := s.strings.strToId[oldStr]
+	if !exists {
+		return fmt.Errorf("string %q doesn't exist", oldStr)
+	}
+	if _, exists = s.strings.strToId[newStr]; exists {
+		return fmt.Errorf("string %q already exists", newStr)
+	}
+	timeStr := strconv.FormatInt(clock.Now().Unix(), 10)
+	rec := []string{recIdRename, timeStr, strconv.Itoa(strId), newStr}
+	if err := s.writeCsv(rec); err != nil {
+		return err
+	}
+	s.renameString(strId, newStr)
+	return nil
+}
+
+// RenameString changes text of a string, keeping its translations, history
+// and metadata. newStr must not already exist
+func (s *StoreCsv) RenameString(oldStr, newStr string) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.writeRename(oldStr, newStr)
 }
 
 func (s *StoreCsv) DuplicateTranslation(origStr, newStr string) error {
@@ -550,6 +931,126 @@ func (s *StoreCsv) DuplicateTranslation(origStr, newStr string) error {
 	return s.duplicateTranslation(origStr, newStr)
 }
 
+func (s *StoreCsv) isActiveString(str string) bool {
+	id, exists := s.strings.strToId[str]
+	return exists && !s.isUnused(id)
+}
+
+// ActiveStrings returns strings to translate
+func (s *StoreCsv) ActiveStrings() []string {
+	s.Lock()
+	defer s.Unlock()
+	res := make([]string, len(s.activeStrings))
+	for i, strId := range s.activeStrings {
+		res[i] = s.strings.strings[strId]
+	}
+	return res
+}
+
+// IsActiveString returns true if str is one of the strings to translate
+func (s *StoreCsv) IsActiveString(str string) bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.isActiveString(str)
+}
+
+// HasString returns true if str is known to the store, also if it's no
+// longer one of the strings to translate
+func (s *StoreCsv) HasString(str string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, exists := s.strings.strToId[str]
+	return exists
+}
+
+func (s *StoreCsv) writeStringMeta(str, key, value string) error {
+	strId, exists := s.strings.strToId[str]
+	if !exists {
+		return fmt.Errorf("string %q doesn't exist", str)
+	}
+	if s.stringsMeta[strId][key] == value {
+		return nil
+	}
+	timeStr := strconv.FormatInt(clock.Now().Unix(), 10)
+	rec := []string{recIdStringMeta, timeStr, strconv.Itoa(strId), key, value}
+	if err := s.writeCsv(rec); err != nil {
+		return err
+	}
+	s.setStringMeta(strId, key, value)
+	return nil
+}
+
+// SetStringMeta sets metadata value for a given key of a string. Empty value
+// removes the key
+func (s *StoreCsv) SetStringMeta(str, key, value string) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.writeStringMeta(str, key, value)
+}
+
+// StringMeta returns metadata value for a given key of a string, "" if not set
+func (s *StoreCsv) StringMeta(str, key string) string {
+	s.Lock()
+	defer s.Unlock()
+	strId, exists := s.strings.strToId[str]
+	if !exists {
+		return ""
+	}
+	return s.stringsMeta[strId][key]
+}
+
+// SetNoTranslate marks str as a string that should not be translated
+func (s *StoreCsv) SetNoTranslate(str string, noTranslate bool) error {
+	value := ""
+	if noTranslate {
+		value = "1"
+	}
+	return s.SetStringMeta(str, MetaNoTranslate, value)
+}
+
+// IsNoTranslate returns true if str should not be translated
+func (s *StoreCsv) IsNoTranslate(str string) bool {
+	return s.StringMeta(str, MetaNoTranslate) != ""
+}
+
+// SetMaxLen sets maximum length of translations of str, in characters.
+// 0 means no limit
+func (s *StoreCsv) SetMaxLen(str string, maxLen int) error {
+	value := ""
+	if maxLen > 0 {
+		value = strconv.Itoa(maxLen)
+	}
+	return s.SetStringMeta(str, MetaMaxLen, value)
+}
+
+// MaxLen returns maximum length of translations of str, in characters,
+// or 0 if there is no limit
+func (s *StoreCsv) MaxLen(str string) int {
+	return parseMaxLen(s.StringMeta(str, MetaMaxLen))
+}
+
+// SetContextURL sets url of a screenshot or a page that shows translators
+// where str is used. Empty url removes it
+func (s *StoreCsv) SetContextURL(str, url string) error {
+	return s.SetStringMeta(str, MetaContextURL, url)
+}
+
+// ContextURL returns url set with SetContextURL() or ""
+func (s *StoreCsv) ContextURL(str string) string {
+	return s.StringMeta(str, MetaContextURL)
+}
+
+// SetGroup sets the group of related strings str belongs to. Empty group
+// removes str from its group
+func (s *StoreCsv) SetGroup(str, group string) error {
+	return s.SetStringMeta(str, MetaGroup, group)
+}
+
+// Group returns group set with SetGroup() or ""
+func (s *StoreCsv) Group(str string) string {
+	return s.StringMeta(str, MetaGroup)
+}
+
 func (s *StoreCsv) LangsCount() int {
 	return LangsCount()
 }
@@ -560,6 +1061,8 @@ func (s *StoreCsv) StringsCount() int {
 	return s.activeStringsCount()
 }
 
+// EditsCount returns number of translation records, read when the store
+// is loaded and appended on every edit
 func (s *StoreCsv) EditsCount() int {
 	s.Lock()
 	defer s.Unlock()
@@ -572,16 +1075,37 @@ func (s *StoreCsv) UntranslatedCount() int {
 	return s.untranslatedCount()
 }
 
+// Stats returns all counts at once. The result is cached until the next
+// change to the store
+func (s *StoreCsv) Stats() Stats {
+	s.Lock()
+	defer s.Unlock()
+	s.resetCachesIfLangsChanged()
+	if s.stats == nil {
+		s.stats = s.computeStats()
+	}
+	return *s.stats
+}
+
 func (s *StoreCsv) UntranslatedForLang(lang string) int {
 	s.Lock()
 	defer s.Unlock()
 	return s.untranslatedForLang(lang)
 }
 
+// LangInfos returns translations for all languages. The result is cached
+// until the next change to the store, so callers must not modify the
+// returned LangInfo values. They can re-order the returned slice.
 func (s *StoreCsv) LangInfos() []*LangInfo {
 	s.Lock()
 	defer s.Unlock()
-	return s.langInfos()
+	s.resetCachesIfLangsChanged()
+	if s.langInfosCache == nil {
+		s.langInfosCache = s.langInfos()
+	}
+	res := make([]*LangInfo, len(s.langInfosCache))
+	copy(res, s.langInfosCache)
+	return res
 }
 
 func (s *StoreCsv) RecentEdits(max int) []Edit {
@@ -596,6 +1120,32 @@ func (s *StoreCsv) EditsByUser(user string) []Edit {
 	return s.editsByUser(user)
 }
 
+// EditsCountByUser returns number of edits made by each user since a given
+// time. Use zero time to count all edits
+func (s *StoreCsv) EditsCountByUser(since time.Time) map[string]int {
+	s.Lock()
+	defer s.Unlock()
+	return s.editsCountByUser(since)
+}
+
+// StringHistory returns edits of translations of str into all languages,
+// most recent first
+func (s *StoreCsv) StringHistory(str string) []Edit {
+	s.Lock()
+	defer s.Unlock()
+	res := make([]Edit, 0)
+	strId, exists := s.strings.strToId[str]
+	if !exists {
+		return res
+	}
+	for i := len(s.edits) - 1; i >= 0; i-- {
+		if s.edits[i].stringId == strId {
+			res = append(res, s.editFromRec(&s.edits[i]))
+		}
+	}
+	return res
+}
+
 func (s *StoreCsv) EditsForLang(user string, max int) []Edit {
 	s.Lock()
 	defer s.Unlock()
@@ -625,15 +1175,42 @@ func (s *StoreCsv) writeActiveStrings(activeStrings []string) (err error) {
 	if err = s.writeActiveStringsRec(activeStrIds); err != nil {
 		return err
 	}
-	s.setActiveStrings(activeStrIds)
+	s.setActiveStringsAt(activeStrIds, clock.Now())
 	return nil
 }
 
+// UpdateStringsList makes newStrings the strings to translate. Returns,
+// sorted, strings that were not known before, active strings that are not
+// in newStrings and known strings that were not active
 func (s *StoreCsv) UpdateStringsList(newStrings []string) ([]string, []string, []string, error) {
 	s.Lock()
 	defer s.Unlock()
-	err := s.writeActiveStrings(newStrings)
-	return nil, nil, nil, err
+	added, undeleted := []string{}, []string{}
+	isNew := make(map[string]bool, len(newStrings))
+	for _, str := range newStrings {
+		if isNew[str] {
+			continue
+		}
+		isNew[str] = true
+		if _, exists := s.strings.strToId[str]; !exists {
+			added = append(added, str)
+		} else if !s.isActiveString(str) {
+			undeleted = append(undeleted, str)
+		}
+	}
+	deleted := []string{}
+	for _, strId := range s.activeStrings {
+		if str := s.strings.strings[strId]; !isNew[str] {
+			deleted = append(deleted, str)
+		}
+	}
+	if err := s.writeActiveStrings(newStrings); err != nil {
+		return nil, nil, nil, err
+	}
+	sort.Strings(added)
+	sort.Strings(deleted)
+	sort.Strings(undeleted)
+	return added, deleted, undeleted, nil
 }
 
 func (s *StoreCsv) GetUnusedStrings() []string {
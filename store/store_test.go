@@ -2,7 +2,10 @@
 package store
 
 import (
+	"encoding/csv"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -148,18 +151,498 @@ func TestTransLog2(t *testing.T) {
 	s = NewTestStore(path)
 	s.ensureStateAfter2()
 
-	s.updateStringsListMust([]string{"foo", "bar", "go"})
-	//added, deleted, undeleted := s.updateStringsListMust([]string{"foo", "bar", "go"})
-	//panicif(len(added) != 2, "len(added) = %d != 2", len(added))
-	//panicif(len(deleted) != 0, "len(deleted) = %d != 0", len(deleted))
-	//panicif(len(undeleted) != 0, "len(undeleted) = %d != 0", len(undeleted))
+	// "foo" is known because it has translations
+	added, deleted, undeleted := s.updateStringsListMust([]string{"foo", "bar", "go"})
+	panicif(!reflect.DeepEqual(added, []string{"bar", "go"}), "added = %v", added)
+	panicif(len(deleted) != 0, "len(deleted) = %d != 0", len(deleted))
+	panicif(!reflect.DeepEqual(undeleted, []string{"foo"}), "undeleted = %v", undeleted)
 	s.ensureStringsAre([]string{"foo", "bar", "go"})
 
-	_, _, _ = s.updateStringsListMust([]string{"foo", "bar"})
-	//panicif(len(deleted) != 1, "len(deleted) != 1")
+	added, deleted, undeleted = s.updateStringsListMust([]string{"foo", "bar"})
+	panicif(len(added) != 0 || len(undeleted) != 0, "added = %v, undeleted = %v", added, undeleted)
+	panicif(!reflect.DeepEqual(deleted, []string{"go"}), "deleted = %v", deleted)
 
-	_, _, _ = s.updateStringsListMust([]string{"foo", "bar", "go"})
-	//panicif(len(undeleted) != 1, "len(undeleted) != 1")
+	added, deleted, undeleted = s.updateStringsListMust([]string{"foo", "bar", "go"})
+	panicif(len(added) != 0 || len(deleted) != 0, "added = %v, deleted = %v", added, deleted)
+	panicif(!reflect.DeepEqual(undeleted, []string{"go"}), "undeleted = %v", undeleted)
 
 	s.Close()
 }
+
+func newStatsTestStore(path string, nStrings int) *StoreCsv {
+	os.Remove(path)
+	s := NewTestStore(path)
+	strs := make([]string, nStrings)
+	for i := range strs {
+		strs[i] = fmt.Sprintf("string %d", i)
+	}
+	s.updateStringsListMust(strs)
+	for i, str := range strs {
+		if i%2 == 0 {
+			s.writeNewTranslationMust(str, str+"-pl", "pl", "user1")
+		}
+		if i%3 == 0 {
+			s.writeNewTranslationMust(str, str+"-de", "de", "user2")
+		}
+	}
+	return s
+}
+
+func TestStats(t *testing.T) {
+	path := "transtest_stats.dat"
+	s := newStatsTestStore(path, 10)
+	defer os.Remove(path)
+	defer s.Close()
+
+	st := s.Stats()
+	if st.StringsCount != s.StringsCount() || st.EditsCount != s.EditsCount() ||
+		st.UntranslatedCount != s.UntranslatedCount() || st.LangsCount != s.LangsCount() {
+		t.Fatalf("Stats() %#v doesn't match separate counts", st)
+	}
+	// 5 pl and 4 de translations
+	if st.EditsCount != 9 {
+		t.Fatalf("st.EditsCount is %d, expected 9", st.EditsCount)
+	}
+
+	s.writeNewTranslationMust("string 1", "string 1-pl", "pl", "user1")
+	st2 := s.Stats()
+	if st2.EditsCount != st.EditsCount+1 || st2.UntranslatedCount != st.UntranslatedCount-1 {
+		t.Fatalf("Stats() not updated after an edit, before: %#v, after: %#v", st, st2)
+	}
+}
+
+// countTransRecords counts translation records in the store file
+func countTransRecords(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("os.Open() failed with %s", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	recs, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() failed with %s", err)
+	}
+	n := 0
+	for _, rec := range recs {
+		if rec[0] == recIdTrans {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEditsCountMatchesRecount(t *testing.T) {
+	path := "transtest_editscount.dat"
+	s := newStatsTestStore(path, 6)
+	defer os.Remove(path)
+	defer func() { s.Close() }()
+
+	s.writeNewTranslationMust("string 1", "string 1-pl", "pl", "user1")
+	s.writeNewTranslationMust("string 1", "string 1-pl2", "pl", "user2")
+	if err := s.WriteNewTranslationWithNote("string 2", "string 2-de", "de", "user1", "note"); err != nil {
+		t.Fatalf("WriteNewTranslationWithNote() failed with %s", err)
+	}
+	if err := s.DuplicateTranslation("string 0", "string 5"); err != nil {
+		t.Fatalf("DuplicateTranslation() failed with %s", err)
+	}
+	// changes that are not edits
+	if err := s.SetFuzzy("string 1", "pl", true); err != nil {
+		t.Fatalf("SetFuzzy() failed with %s", err)
+	}
+	if err := s.SetNoTranslate("string 3", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+
+	n := s.EditsCount()
+	if recount := countTransRecords(t, path); n != recount {
+		t.Fatalf("EditsCount() is %d, %s has %d translation records", n, path, recount)
+	}
+	if n != s.Stats().EditsCount || n != len(s.RecentEdits(1000)) {
+		t.Fatalf("EditsCount() is %d, Stats() %d, RecentEdits() %d", n, s.Stats().EditsCount, len(s.RecentEdits(1000)))
+	}
+	s.Close()
+	s = NewTestStore(path)
+	if got := s.EditsCount(); got != n {
+		t.Fatalf("EditsCount() is %d after re-opening, expected %d", got, n)
+	}
+}
+
+func BenchmarkStatsSeparate(b *testing.B) {
+	path := "transtest_bench.dat"
+	s := newStatsTestStore(path, 1000)
+	defer os.Remove(path)
+	defer s.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.StringsCount()
+		s.UntranslatedCount()
+		s.EditsCount()
+		s.LangsCount()
+	}
+}
+
+func BenchmarkStatsCombined(b *testing.B) {
+	path := "transtest_bench.dat"
+	s := newStatsTestStore(path, 1000)
+	defer os.Remove(path)
+	defer s.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.stats = nil // measure a single pass, not the cache
+		s.Stats()
+	}
+}
+
+func langInfoByCode(langs []*LangInfo, code string) *LangInfo {
+	for _, li := range langs {
+		if li.Code == code {
+			return li
+		}
+	}
+	return nil
+}
+
+func TestLangInfosCache(t *testing.T) {
+	path := "transtest_cache.dat"
+	s := newStatsTestStore(path, 4)
+	defer os.Remove(path)
+	defer s.Close()
+
+	li := langInfoByCode(s.LangInfos(), "pl")
+	if li.UntranslatedCount() != 2 {
+		t.Fatalf("pl has %d untranslated strings, expected 2", li.UntranslatedCount())
+	}
+	// re-ordering the result must not affect the cache
+	langs := s.LangInfos()
+	ByUntranslated{langs}.Swap(0, len(langs)-1)
+	if reflect.DeepEqual(langs, s.LangInfos()) {
+		t.Fatalf("cached LangInfos() re-ordered by the caller")
+	}
+
+	s.writeNewTranslationMust("string 1", "string 1-pl", "pl", "user1")
+	li2 := langInfoByCode(s.LangInfos(), "pl")
+	if li2 == li || li2.UntranslatedCount() != 1 {
+		t.Fatalf("stale LangInfos() after an edit")
+	}
+
+	s.updateStringsListMust([]string{"string 0", "string 1"})
+	li3 := langInfoByCode(s.LangInfos(), "pl")
+	if len(li3.ActiveStrings) != 2 || len(li3.UnusedStrings) != 2 {
+		t.Fatalf("stale LangInfos() after updating strings list")
+	}
+}
+
+func TestNoTranslate(t *testing.T) {
+	path := "transtest_notranslate.dat"
+	s := newStatsTestStore(path, 4)
+	defer os.Remove(path)
+
+	// "string 1" and "string 3" are not translated into pl
+	before := s.UntranslatedForLang("pl")
+	total := s.UntranslatedCount()
+	if err := s.SetNoTranslate("string 1", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+	if err := s.SetNoTranslate("missing", true); err == nil {
+		t.Fatalf("SetNoTranslate() of a missing string should fail")
+	}
+	if n := s.UntranslatedForLang("pl"); n != before-1 {
+		t.Fatalf("pl has %d untranslated strings, expected %d", n, before-1)
+	}
+	// not translated into any language, so it was counted for every language
+	if n := s.UntranslatedCount(); n != total-LangsCount() {
+		t.Fatalf("UntranslatedCount() is %d, expected %d", n, total-LangsCount())
+	}
+	if n := s.Stats().UntranslatedCount; n != total-LangsCount() {
+		t.Fatalf("Stats().UntranslatedCount is %d, expected %d", n, total-LangsCount())
+	}
+	li := langInfoByCode(s.LangInfos(), "pl")
+	if li.UntranslatedCount() != 1 {
+		t.Fatalf("pl has %d untranslated strings, expected 1", li.UntranslatedCount())
+	}
+	todo := li.UntranslatedStrings()
+	if len(todo) != 1 || todo[0].String != "string 3" {
+		t.Fatalf("unexpected strings to translate: %v", todo)
+	}
+
+	// the flag is persisted
+	s.Close()
+	s = NewTestStore(path)
+	defer s.Close()
+	if !s.IsNoTranslate("string 1") || s.IsNoTranslate("string 3") {
+		t.Fatalf("no translate flag not persisted")
+	}
+	if err := s.SetNoTranslate("string 1", false); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+	if n := s.UntranslatedForLang("pl"); n != before {
+		t.Fatalf("pl has %d untranslated strings, expected %d", n, before)
+	}
+}
+
+func TestMaxLen(t *testing.T) {
+	path := "transtest_maxlen.dat"
+	s := newStatsTestStore(path, 2)
+	defer os.Remove(path)
+
+	if n := s.MaxLen("string 0"); n != 0 {
+		t.Fatalf("MaxLen() is %d, expected no limit", n)
+	}
+	if err := s.SetMaxLen("string 0", 12); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+	if err := s.SetMaxLen("missing", 12); err == nil {
+		t.Fatalf("SetMaxLen() of a missing string should fail")
+	}
+	li := langInfoByCode(s.LangInfos(), "pl")
+	for _, tr := range li.ActiveStrings {
+		exp := 0
+		if tr.String == "string 0" {
+			exp = 12
+		}
+		if tr.MaxLen != exp {
+			t.Fatalf("MaxLen of %q is %d, expected %d", tr.String, tr.MaxLen, exp)
+		}
+	}
+
+	// the limit is persisted
+	s.Close()
+	s = NewTestStore(path)
+	defer s.Close()
+	if n := s.MaxLen("string 0"); n != 12 {
+		t.Fatalf("MaxLen() is %d, expected 12", n)
+	}
+	if err := s.SetMaxLen("string 0", 0); err != nil {
+		t.Fatalf("SetMaxLen() failed with %s", err)
+	}
+	if n := s.MaxLen("string 0"); n != 0 {
+		t.Fatalf("MaxLen() is %d, expected no limit", n)
+	}
+}
+
+func TestContextURL(t *testing.T) {
+	path := "transtest_contexturl.dat"
+	s := newStatsTestStore(path, 2)
+	defer os.Remove(path)
+
+	url := "https://example.com/shots/open.png"
+	if err := s.SetContextURL("string 1", url); err != nil {
+		t.Fatalf("SetContextURL() failed with %s", err)
+	}
+	for _, tr := range langInfoByCode(s.LangInfos(), "pl").ActiveStrings {
+		exp := ""
+		if tr.String == "string 1" {
+			exp = url
+		}
+		if tr.ContextURL != exp {
+			t.Fatalf("ContextURL of %q is %q, expected %q", tr.String, tr.ContextURL, exp)
+		}
+	}
+
+	// the url is persisted
+	s.Close()
+	s = NewTestStore(path)
+	defer s.Close()
+	if got := s.ContextURL("string 1"); got != url {
+		t.Fatalf("ContextURL() is %q, expected %q", got, url)
+	}
+	if err := s.SetContextURL("string 1", ""); err != nil {
+		t.Fatalf("SetContextURL() failed with %s", err)
+	}
+	if got := s.ContextURL("string 1"); got != "" {
+		t.Fatalf("ContextURL() is %q after removing it", got)
+	}
+}
+
+func TestTranslationNote(t *testing.T) {
+	path := "transtest_note.dat"
+	s := newStatsTestStore(path, 2)
+	defer os.Remove(path)
+
+	if err := s.WriteNewTranslationWithNote("string 0", "napis, 0", "pl", "user1", "comma on purpose"); err != nil {
+		t.Fatalf("WriteNewTranslationWithNote() failed with %s", err)
+	}
+	s.writeNewTranslationMust("string 0", "niemiecki 0", "de", "user2")
+
+	check := func() {
+		h := s.StringHistory("string 0")
+		if len(h) != 4 {
+			t.Fatalf("unexpected history %#v", h)
+		}
+		// compaction re-orders edits, so look them up by translation
+		for _, e := range h {
+			if e.Translation == "napis, 0" && e.Note != "comma on purpose" {
+				t.Fatalf("got note %q", e.Note)
+			}
+			if e.Translation != "napis, 0" && e.Note != "" {
+				t.Fatalf("unexpected note %q for %q", e.Note, e.Translation)
+			}
+		}
+		for _, tr := range langInfoByCode(s.LangInfos(), "pl").ActiveStrings {
+			if tr.String == "string 0" && tr.Note() != "comma on purpose" {
+				t.Fatalf("got note %q", tr.Note())
+			}
+		}
+	}
+	check()
+	if h := s.StringHistory("string 0"); h[0].Lang != "de" || h[1].Note != "comma on purpose" {
+		t.Fatalf("history is not newest first: %#v", h)
+	}
+	if h := s.StringHistory("missing"); len(h) != 0 {
+		t.Fatalf("unexpected history %#v", h)
+	}
+
+	// the note is persisted and survives compaction
+	s.Close()
+	s = NewTestStore(path)
+	check()
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() failed with %s", err)
+	}
+	s.Close()
+	s = NewTestStore(path)
+	defer s.Close()
+	check()
+}
+
+func TestFuzzy(t *testing.T) {
+	path := "transtest_fuzzy.dat"
+	s := newStatsTestStore(path, 4)
+	defer os.Remove(path)
+
+	untranslated := s.UntranslatedForLang("pl")
+	if err := s.WriteFuzzyTranslation("string 1", "maszynowe", "pl", "machine"); err != nil {
+		t.Fatalf("WriteFuzzyTranslation() failed with %s", err)
+	}
+	if !s.IsFuzzy("string 1", "pl") || s.IsFuzzy("string 1", "de") {
+		t.Fatalf("IsFuzzy() returned wrong value")
+	}
+	// fuzzy translations are translations
+	if n := s.UntranslatedForLang("pl"); n != untranslated-1 {
+		t.Fatalf("pl has %d untranslated strings, expected %d", n, untranslated-1)
+	}
+	li := langInfoByCode(s.LangInfos(), "pl")
+	for _, tr := range li.ActiveStrings {
+		if tr.Fuzzy != (tr.String == "string 1") {
+			t.Fatalf("%q has Fuzzy %v", tr.String, tr.Fuzzy)
+		}
+	}
+
+	// fuzzy state is persisted
+	s.Close()
+	s = NewTestStore(path)
+	defer s.Close()
+	if !s.IsFuzzy("string 1", "pl") {
+		t.Fatalf("fuzzy state not persisted")
+	}
+	// a new translation is not fuzzy
+	s.writeNewTranslationMust("string 1", "poprawione", "pl", "user1")
+	if s.IsFuzzy("string 1", "pl") {
+		t.Fatalf("new translation is still fuzzy")
+	}
+	if err := s.SetFuzzy("string 1", "pl", true); err != nil || !s.IsFuzzy("string 1", "pl") {
+		t.Fatalf("SetFuzzy() failed with %v", err)
+	}
+}
+
+func translationOf(s *StoreCsv, str, lang string) (string, bool) {
+	for _, tr := range langInfoByCode(s.LangInfos(), lang).ActiveStrings {
+		if tr.String == str {
+			return tr.Current(), true
+		}
+	}
+	return "", false
+}
+
+func TestRenameString(t *testing.T) {
+	path := "transtest_rename.dat"
+	s := newStatsTestStore(path, 4)
+	defer os.Remove(path)
+
+	if err := s.SetNoTranslate("string 0", true); err != nil {
+		t.Fatalf("SetNoTranslate() failed with %s", err)
+	}
+	if err := s.RenameString("string 0", "string zero"); err != nil {
+		t.Fatalf("RenameString() failed with %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		if s.IsActiveString("string 0") || !s.IsActiveString("string zero") {
+			t.Fatalf("string wasn't renamed")
+		}
+		if _, ok := translationOf(s, "string 0", "pl"); ok {
+			t.Fatalf("old string still has translations")
+		}
+		for _, lang := range []string{"pl", "de"} {
+			if got, _ := translationOf(s, "string zero", lang); got != "string 0-"+lang {
+				t.Fatalf("got %q translation %q, expected %q", lang, got, "string 0-"+lang)
+			}
+		}
+		if !s.IsNoTranslate("string zero") {
+			t.Fatalf("metadata wasn't preserved")
+		}
+		// rename is persisted
+		s.Close()
+		s = NewTestStore(path)
+	}
+	defer s.Close()
+
+	if err := s.RenameString("string zero", "string 1"); err == nil {
+		t.Fatalf("renaming to existing string should fail")
+	}
+	if err := s.RenameString("string 0", "string 0 again"); err == nil {
+		t.Fatalf("renaming non-existing string should fail")
+	}
+}
+
+func TestDisabledLangs(t *testing.T) {
+	path := "transtest_disabled_langs.dat"
+	s := newStatsTestStore(path, 4)
+	defer os.Remove(path)
+	defer s.Close()
+	defer SetDisabledLangs(nil)
+
+	nLangs := LangsCount()
+	before := s.Stats()
+	// "string 1" and "string 3" are not translated into pl
+	plUntranslated := s.UntranslatedForLang("pl")
+	if err := SetDisabledLangs([]string{"PL", "xx"}); err == nil {
+		t.Fatalf("SetDisabledLangs() with unknown lang should fail")
+	}
+	if err := SetDisabledLangs([]string{"PL"}); err != nil {
+		t.Fatalf("SetDisabledLangs() failed with %s", err)
+	}
+	if !IsLangDisabled("pl") || IsLangDisabled("de") {
+		t.Fatalf("only pl should be disabled")
+	}
+	if n := LangsCount(); n != nLangs-1 {
+		t.Fatalf("LangsCount() is %d, expected %d", n, nLangs-1)
+	}
+	st := s.Stats()
+	if st.LangsCount != nLangs-1 || st.UntranslatedCount != before.UntranslatedCount-plUntranslated {
+		t.Fatalf("got stats %#v, expected one language and %d untranslated strings less than %#v", st, plUntranslated, before)
+	}
+	if n := s.UntranslatedCount(); n != st.UntranslatedCount {
+		t.Fatalf("UntranslatedCount() is %d, expected %d", n, st.UntranslatedCount)
+	}
+	langs := s.LangInfos()
+	if len(langs) != nLangs-1 || langInfoByCode(langs, "pl") != nil {
+		t.Fatalf("pl is listed in LangInfos()")
+	}
+	// translations are kept
+	if n := s.UntranslatedForLang("pl"); n != plUntranslated {
+		t.Fatalf("pl has %d untranslated strings, expected %d", n, plUntranslated)
+	}
+
+	if err := SetDisabledLangs(nil); err != nil {
+		t.Fatalf("SetDisabledLangs() failed with %s", err)
+	}
+	if st := s.Stats(); st != before {
+		t.Fatalf("got stats %#v after enabling pl, expected %#v", st, before)
+	}
+	li := langInfoByCode(s.LangInfos(), "pl")
+	if li == nil || li.UntranslatedCount() != plUntranslated {
+		t.Fatalf("pl is not restored in LangInfos()")
+	}
+}
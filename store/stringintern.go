@@ -43,6 +43,14 @@ func (i *StringInterner) IdByStrMust(s string) int {
 	}
 }
 
+// Rename changes the string with a given id to s, which must not be
+// interned yet
+func (i *StringInterner) Rename(id int, s string) {
+	delete(i.strToId, i.strings[id])
+	i.strings[id] = s
+	i.strToId[s] = id
+}
+
 func (i *StringInterner) Count() int {
 	return len(i.strings)
 }
@@ -0,0 +1,71 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kjk/apptranslator/store"
+)
+
+func TestParseUploadedJSONStringsWithContext(t *testing.T) {
+	got, err := parseUploadedJSONStrings(`["Open", {"source": "Open", "context": "file state"}]`)
+	exp := []string{"Open", store.ContextKey("Open", "file state")}
+	if err != nil || !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %#v, %v, expected %#v", got, err, exp)
+	}
+	for _, s := range []string{
+		`[{"source": "", "context": "menu"}]`,
+		`[{"source": "Open", "context": "menu"}, {"source": "Open", "context": "menu"}]`,
+		`[1]`,
+	} {
+		if _, err := parseUploadedJSONStrings(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestPoContextRoundTrip(t *testing.T) {
+	menu, state := store.ContextKey("Open", "menu"), store.ContextKey("Open", "file state")
+	app := newTestApp(t, "contexts", []string{"Open", menu, state})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+	writeTestTranslation(t, app, menu, "Otwórz plik", "pl", "user1")
+	writeTestTranslation(t, app, state, "Otwarty", "pl", "user1")
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/export?app=contexts&lang=pl&format=po", nil))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d", rr.Code)
+	}
+	s := rr.Body.String()
+	for _, exp := range []string{"msgctxt \"menu\"\nmsgid \"Open\"\nmsgstr \"Otwórz plik\"", "msgctxt \"file state\"\nmsgid \"Open\"\nmsgstr \"Otwarty\""} {
+		if !strings.Contains(s, exp) {
+			t.Fatalf("%q is missing in:\n%s", exp, s)
+		}
+	}
+
+	entries, err := parseTransFile(rr.Body.Bytes(), formatPo)
+	if err != nil {
+		t.Fatalf("parseTransFile() failed with %s", err)
+	}
+	got := make(map[string]string)
+	for _, e := range entries {
+		got[e.Source] = e.Translation
+	}
+	exp := map[string]string{"Open": "Otwórz", menu: "Otwórz plik", state: "Otwarty"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %#v, expected %#v", got, exp)
+	}
+	var buf bytes.Buffer
+	if err = writePoTrans(&buf, "pl", entries); err != nil || buf.String() != s {
+		t.Fatalf("writePoTrans() returned %v:\n%s\nexpected:\n%s", err, buf.String(), s)
+	}
+
+	if _, err = parseTransFile([]byte("msgid \"\"\nmsgstr \"Language: pl\\n\"\n\nmsgctxt \"menu\"\n"), formatPo); err == nil {
+		t.Fatalf("expected an error for msgctxt without msgid")
+	}
+}
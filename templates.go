@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"path/filepath"
 	"text/template"
+
+	"github.com/kjk/apptranslator/store"
 )
 
 var (
@@ -13,9 +16,14 @@ var (
 	tmplAppTrans  = "apptrans.html"
 	tmplUser      = "user.html"
 	tmplLogs      = "logs.html"
+	tmplStats     = "stats.html"
+	tmplTodo      = "todo.html"
+	tmplBackups   = "backups.html"
+	tmplNotFound  = "404.html"
 	templateNames = [...]string{
-		tmplMain, tmplApp, tmplAppTrans, tmplUser, tmplLogs, "header.html",
-		"footer.html"}
+		tmplMain, tmplApp, tmplAppTrans, tmplUser, tmplLogs, tmplStats,
+		tmplTodo, tmplBackups, tmplNotFound, "header.html", "footer.html",
+		"branding.html", "banner.html"}
 	templatePaths   []string
 	templates       *template.Template
 	reloadTemplates = true
@@ -28,12 +36,22 @@ func GetTemplates() *template.Template {
 				templatePaths = append(templatePaths, filepath.Join("tmpl", name))
 			}
 		}
-		templates = template.Must(template.ParseFiles(templatePaths...))
+		funcs := template.FuncMap{
+			"siteBanner": siteBanner,
+			// prefix of local links, see withBasePath()
+			"basePath":      func() string { return config.BasePath },
+			"displayString": displayString,
+		}
+		templates = template.Must(template.New("").Funcs(funcs).ParseFiles(templatePaths...))
 	}
 	return templates
 }
 
 func ExecTemplate(w http.ResponseWriter, templateName string, model interface{}) bool {
+	return ExecTemplateWithStatus(w, http.StatusOK, templateName, model)
+}
+
+func ExecTemplateWithStatus(w http.ResponseWriter, status int, templateName string, model interface{}) bool {
 	var buf bytes.Buffer
 	if err := GetTemplates().ExecuteTemplate(&buf, templateName, model); err != nil {
 		logger.Errorf("Failed to execute template %q, error: %s", templateName, err)
@@ -41,7 +59,18 @@ func ExecTemplate(w http.ResponseWriter, templateName string, model interface{})
 		return false
 	} else {
 		// at this point we ignore error
+		w.WriteHeader(status)
 		w.Write(buf.Bytes())
 	}
 	return true
 }
+
+// displayString returns a string as shown in the ui, followed by its context
+// if it has one, see store.ContextKey
+func displayString(key string) string {
+	source, context := store.SplitContextKey(key)
+	if context == "" {
+		return source
+	}
+	return fmt.Sprintf("%s [%s]", source, context)
+}
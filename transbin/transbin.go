@@ -0,0 +1,139 @@
+// This code is under BSD license. See license-bsd.txt
+
+// Package transbin encodes and decodes translations into a single language
+// in a compact binary format, exported by apptranslator with format=bin.
+// It's meant for clients that load translations at startup and can't
+// afford parsing json or po files. This package is the reference decoder.
+//
+// All integers are unsigned, 32-bit, little-endian. Strings are UTF-8,
+// prefixed with their length in bytes. A file is:
+//
+//	magic    4 bytes "ATB1"
+//	lang     uint32 length, bytes of language code
+//	count    uint32 number of entries
+//	entries  count times: uint32 length, bytes of key, uint32 length,
+//	         bytes of translation
+//
+// Keys are source strings (or keys derived from them, see keystyle export
+// option). Entries are in the order of the export.
+package transbin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// Magic identifies the format and its version
+const Magic = "ATB1"
+
+// Entry is a translation of a single key
+type Entry struct {
+	Key         string
+	Translation string
+}
+
+// Encode writes translations into lang to w
+func Encode(w io.Writer, lang string, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(Magic)
+	writeString(bw, lang)
+	writeUint32(bw, uint32(len(entries)))
+	for _, e := range entries {
+		writeString(bw, e.Key)
+		writeString(bw, e.Translation)
+	}
+	return bw.Flush()
+}
+
+func writeUint32(w *bufio.Writer, n uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], n)
+	w.Write(b[:])
+}
+
+func writeString(w *bufio.Writer, s string) {
+	writeUint32(w, uint32(len(s)))
+	w.WriteString(s)
+}
+
+// Decode returns language and translations encoded in d
+func Decode(d []byte) (string, []Entry, error) {
+	if len(d) < len(Magic) || string(d[:len(Magic)]) != Magic {
+		return "", nil, errors.New("not a transbin file")
+	}
+	r := &reader{d: d, pos: len(Magic)}
+	lang := r.readString()
+	n := r.readUint32()
+	// each entry takes at least 8 bytes, which guards against huge
+	// allocations for corrupted counts
+	if r.err == nil && int64(n) > int64(len(d)-r.pos)/8 {
+		r.err = fmt.Errorf("count %d is larger than the data", n)
+	}
+	var entries []Entry
+	if r.err == nil {
+		entries = make([]Entry, 0, n)
+	}
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		e := Entry{Key: r.readString(), Translation: r.readString()}
+		entries = append(entries, e)
+	}
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	if r.pos != len(d) {
+		return "", nil, fmt.Errorf("%d bytes after the last entry", len(d)-r.pos)
+	}
+	return lang, entries, nil
+}
+
+// DecodeReader is Decode() of data read from r
+func DecodeReader(r io.Reader) (string, []Entry, error) {
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", nil, err
+	}
+	return Decode(d)
+}
+
+// reader reads from d, remembering the first error
+type reader struct {
+	d   []byte
+	pos int
+	err error
+}
+
+func (r *reader) readUint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.d)-r.pos < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	n := binary.LittleEndian.Uint32(r.d[r.pos:])
+	r.pos += 4
+	return n
+}
+
+func (r *reader) readString() string {
+	n := r.readUint32()
+	if r.err != nil {
+		return ""
+	}
+	if int64(n) > int64(len(r.d)-r.pos) {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	b := r.d[r.pos : r.pos+int(n)]
+	if !utf8.Valid(b) {
+		r.err = fmt.Errorf("invalid UTF-8 string at offset %d", r.pos)
+		return ""
+	}
+	r.pos += int(n)
+	return string(b)
+}
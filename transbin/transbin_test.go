@@ -0,0 +1,59 @@
+// This code is under BSD license. See license-bsd.txt
+package transbin
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	entries := []Entry{
+		{"Open", "Otwórz"},
+		{"Save", "Zapisz"},
+		{"Empty", ""},
+		{"", "empty key"},
+		{"Emoji 🎉", "Świętuj 🎉"},
+		{"Greek", "Καλημέρα"},
+		{"Japanese", "こんにちは"},
+		{"Multi\nline", "tab\tand\x00zero byte"},
+	}
+	for _, c := range [][]Entry{entries, {}} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, "pl", c); err != nil {
+			t.Fatalf("Encode() failed with %s", err)
+		}
+		lang, got, err := DecodeReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Decode() failed with %s", err)
+		}
+		if lang != "pl" || len(got) != len(c) || (len(c) > 0 && !reflect.DeepEqual(got, c)) {
+			t.Fatalf("got %q, %#v, expected %#v", lang, got, c)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, "de", []Entry{{"Open", "Öffnen"}}); err != nil {
+		t.Fatalf("Encode() failed with %s", err)
+	}
+	d := buf.Bytes()
+	// the smallest file: magic, empty lang and no entries
+	if _, _, err := Decode([]byte("ATB1\x00\x00\x00\x00\x00\x00\x00\x00")); err != nil {
+		t.Fatalf("Decode() failed with %s", err)
+	}
+	invalid := map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("ATB2"), d[4:]...),
+		"truncated": d[:len(d)-1],
+		"trailing":  append(append([]byte{}, d...), 0),
+		"count":     []byte("ATB1\x00\x00\x00\x00\xff\xff\xff\xff"),
+		"utf8":      []byte("ATB1\x02\x00\x00\x00\xff\xfe\x00\x00\x00\x00"),
+	}
+	for name, d := range invalid {
+		if _, _, err := Decode(d); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
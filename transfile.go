@@ -0,0 +1,672 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kjk/apptranslator/store"
+	"github.com/kjk/apptranslator/transbin"
+)
+
+// formats of translation files we can import and export
+const (
+	formatCsv  = "csv"
+	formatPo   = "po"
+	formatJson = "json"
+	// export only
+	formatXliff = "xliff"
+	formatBin   = "bin"
+	// import only
+	formatTsv = "tsv"
+)
+
+// flag of po entries for strings that should not be translated
+const poFlagNoTranslate = "no-translate"
+
+// TransEntry is a translation of a single string into a single language
+type TransEntry struct {
+	Lang        string
+	Source      string
+	Translation string
+	// only preserved by po and xliff formats
+	NoTranslate bool
+}
+
+func isValidTransFormat(format string) bool {
+	switch format {
+	case formatCsv, formatPo, formatJson, formatTsv:
+		return true
+	}
+	return false
+}
+
+func isValidExportFormat(format string) bool {
+	switch format {
+	case formatCsv, formatPo, formatJson, formatXliff, formatBin:
+		return true
+	}
+	return false
+}
+
+// returns "" if format can't be inferred from file extension
+func transFormatFromPath(path string) string {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if isValidTransFormat(format) {
+		return format
+	}
+	return ""
+}
+
+// InvalidTransError lists problems found by validateTransEntries
+type InvalidTransError struct {
+	Problems []string
+}
+
+func (e *InvalidTransError) Error() string {
+	return "invalid translations:\n" + strings.Join(e.Problems, "\n")
+}
+
+// Translations are exported with EncodeTranslations and imported with
+// DecodeTranslations. For formats we can import and export, decoding the
+// result of encoding gives back the same translations, so exporting translations of
+// an app and importing them back doesn't change them. Only po format
+// preserves NoTranslate flag.
+
+// EncodeTranslations returns entries serialized in a given format. lang is
+// used by formats that hold translations for a single language
+func EncodeTranslations(lang string, entries []TransEntry, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeTransFile(&buf, lang, entries, format); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeTranslations parses and validates translations in a given format.
+// Returns *InvalidTransError if they parse but can't be imported
+func DecodeTranslations(d []byte, format string) ([]TransEntry, error) {
+	entries, err := parseTransFile(d, format)
+	if err != nil {
+		return nil, err
+	}
+	if problems := validateTransEntries(entries); len(problems) > 0 {
+		return nil, &InvalidTransError{Problems: problems}
+	}
+	return entries, nil
+}
+
+func parseTransFile(d []byte, format string) ([]TransEntry, error) {
+	d = []byte(normalizeUploadedText(string(d)))
+	var entries []TransEntry
+	var err error
+	switch format {
+	case formatCsv:
+		entries, err = parseCsvTrans(d)
+	case formatPo:
+		entries, err = parsePoTrans(d)
+	case formatJson:
+		entries, err = parseJsonTrans(d)
+	case formatTsv:
+		entries, err = parseTsvTrans(d)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	// "DE" and "de_DE" are imported as translations into "de"
+	for i := range entries {
+		entries[i].Lang = store.NormalizeLangCode(entries[i].Lang)
+	}
+	return entries, err
+}
+
+// csv file has a header line followed by one translation per line:
+/*
+lang,source,translation
+de,Open,Öffnen
+pl,Open,Otwórz
+*/
+func parseCsvTrans(d []byte) ([]TransEntry, error) {
+	r := csv.NewReader(bytes.NewReader(d))
+	r.FieldsPerRecord = 3
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(header, ",") != "lang,source,translation" {
+		return nil, &CantParseError{Msg: "header is not 'lang,source,translation'", LineNo: 1}
+	}
+	var res []TransEntry
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, TransEntry{Lang: rec[0], Source: rec[1], Translation: rec[2]})
+	}
+}
+
+// tsv file, e.g. exported from Google Sheets, has a header line with
+// languages after the first column, followed by a line for each source
+// string with translations into those languages. Cells can be quoted, e.g.
+// to have new lines in them. Empty cells are untranslated strings:
+/*
+source	de	pl
+Open	Öffnen	Otwórz
+Close		Zamknij
+*/
+func parseTsvTrans(d []byte) ([]TransEntry, error) {
+	r := csv.NewReader(bytes.NewReader(d))
+	r.Comma = '\t'
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) < 2 {
+		return nil, &CantParseError{Msg: "header has no languages", LineNo: 1}
+	}
+	langs := header[1:]
+	for i, lang := range langs {
+		langs[i] = strings.TrimSpace(lang)
+		if langs[i] == "" {
+			return nil, &CantParseError{Msg: fmt.Sprintf("no language in column %d of the header", i+2), LineNo: 1}
+		}
+	}
+	var res []TransEntry
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the source is an entry for each language even without translations,
+		// so that unknown sources are reported
+		for i, lang := range langs {
+			res = append(res, TransEntry{Lang: lang, Source: rec[0], Translation: rec[i+1]})
+		}
+	}
+}
+
+// json file maps language to an object mapping source strings to translations:
+/*
+{
+  "de": { "Open": "Öffnen" },
+  "pl": { "Open": "Otwórz" }
+}
+*/
+// We decode tokens ourselves because json.Unmarshal() silently drops
+// duplicate keys, which we want to report
+func parseJsonTrans(d []byte) ([]TransEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	if err := expectJsonDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	var res []TransEntry
+	for dec.More() {
+		lang, err := readJsonString(dec)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := readJsonLangTrans(dec, lang)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, entries...)
+	}
+	if err := expectJsonDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// readJsonLangTrans reads an object mapping source strings to translations
+// in language lang
+func readJsonLangTrans(dec *json.Decoder, lang string) ([]TransEntry, error) {
+	if err := expectJsonDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	var res []TransEntry
+	for dec.More() {
+		var err error
+		var e = TransEntry{Lang: lang}
+		if e.Source, err = readJsonString(dec); err != nil {
+			return nil, err
+		}
+		if e.Translation, err = readJsonString(dec); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err := expectJsonDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DecodeLangTranslations parses and validates translations for a single
+// language lang, which are a json object mapping source strings to
+// translations:
+/*
+{ "Open": "Öffnen", "Close": "Schließen" }
+*/
+// Empty d means no translations
+func DecodeLangTranslations(d []byte, lang string) ([]TransEntry, error) {
+	lang = store.NormalizeLangCode(lang)
+	if !store.IsValidLangCode(lang) {
+		return nil, fmt.Errorf("unknown language %q", lang)
+	}
+	d = []byte(normalizeUploadedText(string(d)))
+	if len(bytes.TrimSpace(d)) == 0 {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(d))
+	entries, err := readJsonLangTrans(dec, lang)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after translations")
+	}
+	if problems := validateTransEntries(entries); len(problems) > 0 {
+		return nil, &InvalidTransError{Problems: problems}
+	}
+	return entries, nil
+}
+
+func expectJsonDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected '%s', got %v", delim, t)
+	}
+	return nil
+}
+
+func readJsonString(dec *json.Decoder) (string, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	s, ok := t.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string, got %v", t)
+	}
+	return s, nil
+}
+
+// po file is a gettext catalog for a single language, which is taken
+// from the "Language:" field of the header entry. msgctxt is the context of
+// the string, see store.ContextKey:
+/*
+msgid ""
+msgstr ""
+"Language: de\n"
+
+msgid "Open"
+msgstr "Öffnen"
+
+msgctxt "file state"
+msgid "Open"
+msgstr "Geöffnet"
+*/
+func parsePoTrans(d []byte) ([]TransEntry, error) {
+	s := normalizeNewlines(string(d))
+	lines := strings.Split(s, "\n")
+	var res []TransEntry
+	var msgctxt, msgid, msgstr *string
+	var curr *string
+	var flags []string
+	lang := ""
+	seenHeader := false
+
+	finishEntry := func(lineNo int) error {
+		if msgid == nil && msgstr == nil {
+			if msgctxt != nil {
+				return &CantParseError{Msg: "msgctxt without msgid", LineNo: lineNo}
+			}
+			return nil
+		}
+		if msgid == nil || msgstr == nil {
+			return &CantParseError{Msg: "msgid without msgstr", LineNo: lineNo}
+		}
+		if *msgid == "" && !seenHeader {
+			seenHeader = true
+			lang = poHeaderLang(*msgstr)
+		} else {
+			context := ""
+			if msgctxt != nil {
+				context = *msgctxt
+			}
+			e := TransEntry{
+				Source:      store.ContextKey(*msgid, context),
+				Translation: *msgstr,
+				NoTranslate: hasString(flags, poFlagNoTranslate),
+			}
+			res = append(res, e)
+		}
+		msgctxt, msgid, msgstr, curr, flags = nil, nil, nil, nil, nil
+		return nil
+	}
+
+	for i, l := range lines {
+		lineNo := i + 1
+		l = strings.TrimSpace(l)
+		if l == "" {
+			if err := finishEntry(lineNo); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if strings.HasPrefix(l, "#") {
+			// comments start a new entry
+			if msgstr != nil {
+				if err := finishEntry(lineNo); err != nil {
+					return nil, err
+				}
+			}
+			if strings.HasPrefix(l, "#,") {
+				for _, flag := range strings.Split(l[2:], ",") {
+					flags = append(flags, strings.TrimSpace(flag))
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(l, "\"") {
+			if curr == nil {
+				return nil, &CantParseError{Msg: "unexpected string", LineNo: lineNo}
+			}
+			str, err := strconv.Unquote(l)
+			if err != nil {
+				return nil, &CantParseError{Msg: err.Error(), LineNo: lineNo}
+			}
+			*curr += str
+			continue
+		}
+		parts := strings.SplitN(l, " ", 2)
+		if len(parts) != 2 {
+			return nil, &CantParseError{Msg: fmt.Sprintf("invalid line %q", l), LineNo: lineNo}
+		}
+		str, err := strconv.Unquote(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, &CantParseError{Msg: err.Error(), LineNo: lineNo}
+		}
+		switch parts[0] {
+		case "msgctxt":
+			if err := finishEntry(lineNo); err != nil {
+				return nil, err
+			}
+			msgctxt = &str
+			curr = msgctxt
+		case "msgid":
+			// msgctxt, if any, belongs to this entry
+			if msgid != nil {
+				if err := finishEntry(lineNo); err != nil {
+					return nil, err
+				}
+			}
+			msgid = &str
+			curr = msgid
+		case "msgstr":
+			if msgid == nil || msgstr != nil {
+				return nil, &CantParseError{Msg: "msgstr without msgid", LineNo: lineNo}
+			}
+			msgstr = &str
+			curr = msgstr
+		default:
+			return nil, &CantParseError{Msg: fmt.Sprintf("unsupported keyword %q", parts[0]), LineNo: lineNo}
+		}
+	}
+	if err := finishEntry(len(lines)); err != nil {
+		return nil, err
+	}
+	if lang == "" {
+		return nil, errors.New("po file has no 'Language:' header")
+	}
+	for i := range res {
+		res[i].Lang = lang
+	}
+	return res, nil
+}
+
+func hasString(a []string, s string) bool {
+	for _, el := range a {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
+
+func poHeaderLang(header string) string {
+	for _, l := range strings.Split(header, "\n") {
+		if strings.HasPrefix(l, "Language:") {
+			return strings.TrimSpace(strings.TrimPrefix(l, "Language:"))
+		}
+	}
+	return ""
+}
+
+// lang is used by formats that hold translations for a single language
+func writeTransFile(w io.Writer, lang string, entries []TransEntry, format string) error {
+	switch format {
+	case formatCsv:
+		return writeCsvTrans(w, entries)
+	case formatPo:
+		return writePoTrans(w, lang, entries)
+	case formatJson:
+		return writeJsonTrans(w, entries)
+	case formatXliff:
+		return writeXliffTrans(w, lang, entries)
+	case formatBin:
+		return writeBinTrans(w, lang, entries)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// csv and po files are written directly to the destination, which for large
+// apps uses much less memory than building the whole file first. Written
+// data is flushed after every this many entries
+const transFileFlushEntries = 1000
+
+func writeCsvTrans(w io.Writer, entries []TransEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"lang", "source", "translation"})
+	for i, e := range entries {
+		cw.Write([]string{e.Lang, e.Source, e.Translation})
+		if (i+1)%transFileFlushEntries == 0 {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJsonTrans(w io.Writer, entries []TransEntry) error {
+	m := make(map[string]map[string]string)
+	for _, e := range entries {
+		if m[e.Lang] == nil {
+			m[e.Lang] = make(map[string]string)
+		}
+		m[e.Lang][e.Source] = e.Translation
+	}
+	d, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(d)
+	return err
+}
+
+// po file holds translations for a single language, so all entries must
+// be for that language
+func writePoTrans(w io.Writer, lang string, entries []TransEntry) error {
+	// checked first, so that nothing is written for invalid entries
+	for _, e := range entries {
+		if e.Lang != lang {
+			return fmt.Errorf("po file can't have translations for both %q and %q", lang, e.Lang)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "msgid \"\"\nmsgstr \"\"\n%s\n", strconv.Quote("Language: "+lang+"\n"))
+	for i, e := range entries {
+		bw.WriteString("\n")
+		if e.NoTranslate {
+			fmt.Fprintf(bw, "#, %s\n", poFlagNoTranslate)
+		}
+		source, context := store.SplitContextKey(e.Source)
+		if context != "" {
+			fmt.Fprintf(bw, "msgctxt %s\n", strconv.Quote(context))
+		}
+		fmt.Fprintf(bw, "msgid %s\nmsgstr %s\n", strconv.Quote(source), strconv.Quote(e.Translation))
+		if (i+1)%transFileFlushEntries == 0 {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+type xliffDoc struct {
+	XMLName xml.Name  `xml:"urn:oasis:names:tc:xliff:document:1.2 xliff"`
+	Version string    `xml:"version,attr"`
+	File    xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	Original       string      `xml:"original,attr"`
+	SourceLanguage string      `xml:"source-language,attr"`
+	TargetLanguage string      `xml:"target-language,attr"`
+	Datatype       string      `xml:"datatype,attr"`
+	Units          []xliffUnit `xml:"body>trans-unit"`
+}
+
+type xliffUnit struct {
+	Id        string `xml:"id,attr"`
+	Translate string `xml:"translate,attr,omitempty"`
+	Source    string `xml:"source"`
+	Target    string `xml:"target,omitempty"`
+}
+
+// xliff 1.2 file holds translations for a single language. Strings that
+// should not be translated are marked with translate="no"
+func writeXliffTrans(w io.Writer, lang string, entries []TransEntry) error {
+	doc := xliffDoc{
+		Version: "1.2",
+		File: xliffFile{
+			Original:       "translations",
+			SourceLanguage: "en",
+			TargetLanguage: lang,
+			Datatype:       "plaintext",
+		},
+	}
+	for i, e := range entries {
+		if e.Lang != lang {
+			return fmt.Errorf("xliff file can't have translations for both %q and %q", lang, e.Lang)
+		}
+		unit := xliffUnit{
+			Id:     strconv.Itoa(i + 1),
+			Source: e.Source,
+			Target: e.Translation,
+		}
+		if e.NoTranslate {
+			unit.Translate = "no"
+		}
+		doc.File.Units = append(doc.File.Units, unit)
+	}
+	d, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = w.Write(d)
+	return err
+}
+
+// bin file holds translations for a single language in compact binary
+// format described in package transbin
+func writeBinTrans(w io.Writer, lang string, entries []TransEntry) error {
+	binEntries := make([]transbin.Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.Lang != lang {
+			return fmt.Errorf("bin file can't have translations for both %q and %q", lang, e.Lang)
+		}
+		binEntries = append(binEntries, transbin.Entry{Key: e.Source, Translation: e.Translation})
+	}
+	return transbin.Encode(w, lang, binEntries)
+}
+
+// validateTransEntries returns a list of problems with the entries, an empty
+// list if the entries can be imported
+func validateTransEntries(entries []TransEntry) []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if !store.IsValidLangCode(e.Lang) {
+			problems = append(problems, fmt.Sprintf("unknown language %q for string %q", e.Lang, e.Source))
+			continue
+		}
+		if strings.TrimSpace(e.Source) == "" {
+			problems = append(problems, fmt.Sprintf("empty source string for language %q", e.Lang))
+			continue
+		}
+		key := e.Lang + "\x00" + e.Source
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("duplicate string %q for language %q", e.Source, e.Lang))
+			continue
+		}
+		seen[key] = true
+	}
+	return problems
+}
+
+// readAndValidateTransFile is used by -validate command line option.
+// It prints problems to stdout and returns the exit code for the process
+func readAndValidateTransFile(path string) int {
+	format := transFormatFromPath(path)
+	if format == "" {
+		fmt.Printf("%s: unknown file format, must be one of .csv, .po, .json, .tsv\n", path)
+		return 1
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	entries, err := parseTransFile(d, format)
+	if err != nil {
+		fmt.Printf("%s: %s\n", path, err)
+		return 1
+	}
+	problems := validateTransEntries(entries)
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", path, p)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	fmt.Printf("%s: ok, %d translations\n", path, len(entries))
+	return 0
+}
@@ -0,0 +1,196 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testCsvTrans = `lang,source,translation
+de,Open,Öffnen
+pl,Open,Otwórz
+pl,"Save
+as",Zapisz jako
+`
+	testJsonTrans = `{
+  "de": { "Open": "Öffnen" },
+  "pl": { "Open": "Otwórz", "Save\nas": "Zapisz jako" }
+}`
+	testTsvTrans = "source\tde\tpl\n" +
+		"Open\tÖffnen\tOtwórz\n" +
+		"\"Save\nas\"\t\t\"Zapisz \"\"jako\"\"\"\n"
+	testPoTrans = `# comment
+msgid ""
+msgstr ""
+"Language: pl\n"
+
+msgid "Open"
+msgstr "Otwórz"
+
+msgid "Save\n"
+"as"
+msgstr "Zapisz jako"
+`
+)
+
+func TestParseTransFile(t *testing.T) {
+	tests := []struct {
+		format string
+		s      string
+		exp    []TransEntry
+	}{
+		{formatCsv, testCsvTrans, []TransEntry{
+			{"de", "Open", "Öffnen", false},
+			{"pl", "Open", "Otwórz", false},
+			{"pl", "Save\nas", "Zapisz jako", false},
+		}},
+		{formatJson, testJsonTrans, []TransEntry{
+			{"de", "Open", "Öffnen", false},
+			{"pl", "Open", "Otwórz", false},
+			{"pl", "Save\nas", "Zapisz jako", false},
+		}},
+		{formatTsv, testTsvTrans, []TransEntry{
+			{"de", "Open", "Öffnen", false},
+			{"pl", "Open", "Otwórz", false},
+			{"de", "Save\nas", "", false},
+			{"pl", "Save\nas", `Zapisz "jako"`, false},
+		}},
+		{formatPo, testPoTrans, []TransEntry{
+			{"pl", "Open", "Otwórz", false},
+			{"pl", "Save\nas", "Zapisz jako", false},
+		}},
+		// lang codes are normalized
+		{formatCsv, "lang,source,translation\nDE,Open,Öffnen\nde_DE,Close,Schließen\npl-PL,Open,Otwórz\n", []TransEntry{
+			{"de", "Open", "Öffnen", false},
+			{"de", "Close", "Schließen", false},
+			{"pl", "Open", "Otwórz", false},
+		}},
+	}
+	for _, test := range tests {
+		entries, err := parseTransFile([]byte(test.s), test.format)
+		if err != nil {
+			t.Fatalf("%s: parseTransFile() failed with %s", test.format, err)
+		}
+		if !reflect.DeepEqual(entries, test.exp) {
+			t.Fatalf("%s: got %#v, expected %#v", test.format, entries, test.exp)
+		}
+		if problems := validateTransEntries(entries); len(problems) != 0 {
+			t.Fatalf("%s: unexpected problems %v", test.format, problems)
+		}
+		// files edited on windows parse the same
+		variants := map[string]string{
+			"bom":      utf8BOM + test.s,
+			"crlf":     strings.Replace(test.s, "\n", "\r\n", -1),
+			"bom+crlf": utf8BOM + strings.Replace(test.s, "\n", "\r\n", -1),
+		}
+		for name, s := range variants {
+			entries, err = parseTransFile([]byte(s), test.format)
+			if err != nil {
+				t.Fatalf("%s %s: parseTransFile() failed with %s", test.format, name, err)
+			}
+			if !reflect.DeepEqual(entries, test.exp) {
+				t.Fatalf("%s %s: got %#v, expected %#v", test.format, name, entries, test.exp)
+			}
+		}
+	}
+}
+
+func TestParseUploadedStrings(t *testing.T) {
+	exp := []string{"Open", "Save as"}
+	for _, s := range []string{
+		"AppTranslator strings\nOpen\nSave as",
+		utf8BOM + "AppTranslator strings\nOpen\nSave as",
+		"AppTranslator strings\r\nOpen\r\nSave as",
+		utf8BOM + "AppTranslator strings\r\nOpen\r\nSave as",
+	} {
+		got, err := parseUploadedStrings(s)
+		if err != nil || !reflect.DeepEqual(got, exp) {
+			t.Fatalf("%q: got %#v, %v, expected %#v", s, got, err, exp)
+		}
+	}
+}
+
+func TestValidateTransEntries(t *testing.T) {
+	tests := []struct {
+		format string
+		s      string
+		exp    string
+	}{
+		{formatCsv, "lang,source,translation\nxx,Open,Open\n", "unknown language"},
+		{formatCsv, "lang,source,translation\nde,,Öffnen\n", "empty source string"},
+		{formatCsv, "lang,source,translation\nde,Open,Öffnen\nde,Open,Auf\n", "duplicate string"},
+		{formatJson, `{"xx": {"Open": "Open"}}`, "unknown language"},
+		{formatJson, `{"de": {"": "Öffnen"}}`, "empty source string"},
+		{formatJson, `{"de": {"Open": "Öffnen", "Open": "Auf"}}`, "duplicate string"},
+		{formatPo, "msgid \"\"\nmsgstr \"Language: xx\\n\"\n\nmsgid \"Open\"\nmsgstr \"Open\"\n", "unknown language"},
+		{formatPo, "msgid \"\"\nmsgstr \"Language: de\\n\"\n\nmsgid \" \"\nmsgstr \"Öffnen\"\n", "empty source string"},
+		{formatPo, "msgid \"\"\nmsgstr \"Language: de\\n\"\n\nmsgid \"Open\"\nmsgstr \"Öffnen\"\n\nmsgid \"Open\"\nmsgstr \"Auf\"\n", "duplicate string"},
+	}
+	for _, test := range tests {
+		entries, err := parseTransFile([]byte(test.s), test.format)
+		if err != nil {
+			t.Fatalf("%s: parseTransFile(%q) failed with %s", test.format, test.s, err)
+		}
+		problems := validateTransEntries(entries)
+		if len(problems) != 1 || !strings.Contains(problems[0], test.exp) {
+			t.Fatalf("%s: got %v for %q, expected %q", test.format, problems, test.s, test.exp)
+		}
+	}
+}
+
+func TestTranslationsRoundTrip(t *testing.T) {
+	strs := []string{"Open", "Save\nas", "Say \"hello\"", "Close"}
+	for _, format := range []string{formatCsv, formatPo, formatJson} {
+		app := newTestApp(t, "roundtrip", strs)
+		writeTestTranslation(t, app, "Open", "Otwórz", "pl", "user1")
+		writeTestTranslation(t, app, "Save\nas", "Zapisz\njako", "pl", "user1")
+		writeTestTranslation(t, app, "Say \"hello\"", "Powiedz \"cześć\"", "pl", "user1")
+		exported, err := app.ExportTranslations("pl", format, &ExportOptions{})
+		if err != nil {
+			t.Fatalf("%s: ExportTranslations() failed with %s", format, err)
+		}
+
+		// importing exported translations doesn't change anything
+		entries, err := DecodeTranslations(exported, format)
+		if err != nil {
+			t.Fatalf("%s: DecodeTranslations() failed with %s", format, err)
+		}
+		edits := app.store.EditsCount()
+		n, report, err := importTranslations(app.store, entries, importKeepCurrent)
+		if err != nil || n != 0 || len(report.Unknown) != 0 || len(report.Conflicts) != 0 || app.store.EditsCount() != edits {
+			t.Fatalf("%s: re-import changed the store: %d, %v, %v", format, n, report, err)
+		}
+
+		// importing into another app gives the same translations
+		app2 := newTestApp(t, "roundtrip2", strs)
+		n, report, err = importTranslations(app2.store, entries, importKeepCurrent)
+		if err != nil || n != 3 || len(report.Unknown) != 0 {
+			t.Fatalf("%s: import failed: %d, %v, %v", format, n, report, err)
+		}
+		if got, exp := currentTranslations(app2)["pl"], currentTranslations(app)["pl"]; !reflect.DeepEqual(got, exp) {
+			t.Fatalf("%s: got %v, expected %v", format, got, exp)
+		}
+		exported2, err := app2.ExportTranslations("pl", format, &ExportOptions{})
+		if err != nil {
+			t.Fatalf("%s: ExportTranslations() failed with %s", format, err)
+		}
+		if string(exported2) != string(exported) {
+			t.Fatalf("%s: got %q, expected %q", format, exported2, exported)
+		}
+		closeTestApp(app2)
+		closeTestApp(app)
+	}
+}
+
+func TestDecodeInvalidTranslations(t *testing.T) {
+	_, err := DecodeTranslations([]byte("lang,source,translation\nxx,Open,Open\n"), formatCsv)
+	if invalid, ok := err.(*InvalidTransError); !ok || len(invalid.Problems) != 1 {
+		t.Fatalf("got %#v, expected *InvalidTransError", err)
+	}
+	_, err = DecodeTranslations([]byte("lang,source\n"), formatCsv)
+	if _, ok := err.(*InvalidTransError); ok || err == nil {
+		t.Fatalf("got %#v, expected parse error", err)
+	}
+}
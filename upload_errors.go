@@ -0,0 +1,60 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// codes of errors returned by upload handlers (/uploadstrings,
+// /uploadtranslations, /uploadlangtranslations, /uploadglossary), so that
+// scripts can tell them apart
+const (
+	uploadErrBadSecret  = "bad_secret"
+	uploadErrUnknownApp = "unknown_app"
+	uploadErrParse      = "parse_error"
+	uploadErrTooLarge   = "too_large"
+)
+
+var uploadErrStatus = map[string]int{
+	uploadErrBadSecret:  http.StatusForbidden,
+	uploadErrUnknownApp: http.StatusNotFound,
+	uploadErrParse:      http.StatusBadRequest,
+	uploadErrTooLarge:   http.StatusRequestEntityTooLarge,
+}
+
+// UploadError is returned as json when an upload fails
+type UploadError struct {
+	// human-readable message
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// serveUploadError responds with UploadError and http status matching code
+func serveUploadError(w http.ResponseWriter, code, format string, args ...interface{}) {
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	serveJSONWithStatus(w, uploadErrStatus[code], &UploadError{Error: msg, Code: code})
+}
+
+// getUploadApp returns the app from "app" argument if "secret" argument
+// matches its upload secret. Otherwise responds with an error and returns nil
+func getUploadApp(w http.ResponseWriter, r *http.Request, what string) *App {
+	appName := strings.TrimSpace(r.FormValue("app"))
+	app := findApp(appName)
+	if app == nil {
+		logger.ForRequest(r).Noticef("Someone tried to upload %s for non-existing app %s", what, appName)
+		serveUploadError(w, uploadErrUnknownApp, "Application %q doesn't exist", appName)
+		return nil
+	}
+	secret := strings.TrimSpace(r.FormValue("secret"))
+	if secret != app.UploadSecret {
+		logger.ForRequest(r).Noticef("Someone tried to upload %s for %s with invalid secret %s", what, appName, secret)
+		serveUploadError(w, uploadErrBadSecret, "Invalid secret for app %q", appName)
+		return nil
+	}
+	return app
+}
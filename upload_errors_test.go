@@ -0,0 +1,53 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestUploadErrors(t *testing.T) {
+	app := newTestApp(t, "uploaderr", nil)
+	defer closeTestApp(app)
+	defer func() { config.MaxUploadBytes = 0 }()
+	config.MaxUploadBytes = 256
+
+	tests := []struct {
+		path   string
+		form   url.Values
+		status int
+		code   string
+	}{
+		{"/uploadstrings", url.Values{"app": {"nope"}, "secret": {"secret"}, "strings": {"AppTranslator strings\nOpen"}}, 404, uploadErrUnknownApp},
+		{"/uploadstrings", url.Values{"app": {"uploaderr"}, "secret": {"wrong"}, "strings": {"AppTranslator strings\nOpen"}}, 403, uploadErrBadSecret},
+		{"/uploadstrings", url.Values{"app": {"uploaderr"}, "secret": {"secret"}, "strings": {"Open"}}, 400, uploadErrParse},
+		{"/uploadstrings", url.Values{"app": {"uploaderr"}, "secret": {"secret"}, "strings": {"AppTranslator strings\n" + strings.Repeat("a", 300)}}, 413, uploadErrTooLarge},
+		{"/uploadtranslations", url.Values{"app": {"nope"}, "secret": {"secret"}, "format": {"json"}}, 404, uploadErrUnknownApp},
+		{"/uploadtranslations", url.Values{"app": {"uploaderr"}, "secret": {"wrong"}, "format": {"json"}}, 403, uploadErrBadSecret},
+		{"/uploadtranslations", url.Values{"app": {"uploaderr"}, "secret": {"secret"}, "format": {"json"}, "translations": {"{"}}, 400, uploadErrParse},
+		{"/uploadtranslations", url.Values{"app": {"uploaderr"}, "secret": {"secret"}, "format": {"xls"}}, 400, uploadErrParse},
+		{"/uploadglossary", url.Values{"app": {"uploaderr"}, "secret": {"wrong"}, "glossary": {"{}"}}, 403, uploadErrBadSecret},
+		{"/uploadglossary", url.Values{"app": {"uploaderr"}, "secret": {"secret"}, "glossary": {`{"xx-invalid": {}}`}}, 400, uploadErrParse},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", test.path, strings.NewReader(test.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		if rr.Code != test.status {
+			t.Errorf("%s %v: got status %d, expected %d", test.path, test.form, rr.Code, test.status)
+			continue
+		}
+		var res UploadError
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s %v: json.Unmarshal() failed with %s", test.path, test.form, err)
+			continue
+		}
+		if res.Code != test.code || res.Error == "" {
+			t.Errorf("%s %v: got %#v, expected code %q", test.path, test.form, res, test.code)
+		}
+	}
+}
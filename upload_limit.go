@@ -0,0 +1,57 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxUploadBytes = 10 * 1024 * 1024
+
+func maxUploadBytes() int64 {
+	if config.MaxUploadBytes > 0 {
+		return config.MaxUploadBytes
+	}
+	return defaultMaxUploadBytes
+}
+
+// isRequestTooLarge returns true if err is caused by reading more than
+// allowed by http.MaxBytesReader
+func isRequestTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+func httpRequestTooLarge(w http.ResponseWriter) {
+	serveUploadError(w, uploadErrTooLarge, "Request too large, the limit is %d bytes", maxUploadBytes())
+}
+
+// makeUploadHandler limits size of request body to maxUploadBytes() and
+// responds with 413 if it's exceeded. Form data is parsed here so that
+// handlers can use r.FormValue(), which hides errors. Handlers that read
+// the body directly must check for isRequestTooLarge() errors themselves
+func makeUploadHandler(fn func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := maxUploadBytes()
+		if r.ContentLength > maxBytes {
+			httpRequestTooLarge(w)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		ct := r.Header.Get("Content-Type")
+		var err error
+		// ParseMultipartForm() of a form that is not multipart returns
+		// ErrNotMultipart instead of the error of reading the form
+		if strings.HasPrefix(ct, "application/x-www-form-urlencoded") {
+			err = r.ParseForm()
+		} else if strings.HasPrefix(ct, "multipart/form-data") {
+			err = r.ParseMultipartForm(maxBytes)
+		}
+		if isRequestTooLarge(err) {
+			httpRequestTooLarge(w)
+			return
+		}
+		fn(w, r)
+	}
+}
@@ -0,0 +1,52 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMaxUploadBytes(t *testing.T) {
+	app := newTestApp(t, "limit", nil)
+	defer closeTestApp(app)
+	defer func() { config.MaxUploadBytes = 0 }()
+	config.MaxUploadBytes = 128
+
+	var chunked bool
+	post := func(strs string) int {
+		form := url.Values{
+			"app":     {"limit"},
+			"secret":  {"secret"},
+			"strings": {"AppTranslator strings\n" + strs},
+		}
+		r := httptest.NewRequest("POST", "/uploadstrings", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if chunked {
+			// size is not known until the body is read
+			r.ContentLength = -1
+		}
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	for _, chunked = range []bool{false, true} {
+		if code := post(strings.Repeat("a", 200)); code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("got status %d for a large upload, chunked: %v", code, chunked)
+		}
+	}
+	chunked = false
+	if isRequestTooLarge(errors.New("http: request body too large")) || isRequestTooLarge(nil) {
+		t.Fatalf("only errors of http.MaxBytesReader are too large requests")
+	}
+	if code := post("Open"); code != http.StatusOK {
+		t.Fatalf("got status %d for a small upload", code)
+	}
+	if !app.store.IsActiveString("Open") {
+		t.Fatalf("small upload wasn't applied")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func panicif(cond bool, args ...interface{}) {
@@ -25,8 +28,45 @@ func panicif(cond bool, args ...interface{}) {
 	panic(msg)
 }
 
+// isAPIPath returns true for urls of json api, whose errors are also json
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/")
+}
+
+type ModelNotFound struct {
+	PageTitle   string
+	User        string
+	RedirectUrl string
+	Path        string
+}
+
 func http404(w http.ResponseWriter, r *http.Request) {
-	http.NotFound(w, r)
+	if isAPIPath(r.URL.Path) {
+		serveJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	model := &ModelNotFound{
+		PageTitle:   "Page not found",
+		User:        decodeUserFromCookie(r),
+		RedirectUrl: r.URL.String(),
+		Path:        r.URL.Path,
+	}
+	ExecTemplateWithStatus(w, http.StatusNotFound, tmplNotFound, model)
+}
+
+// requireMethod responds with 405 and returns false if request doesn't use
+// method
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	if isAPIPath(r.URL.Path) {
+		serveJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return false
+	}
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	return false
 }
 
 func httpErrorf(w http.ResponseWriter, format string, args ...interface{}) {
@@ -37,6 +77,59 @@ func httpErrorf(w http.ResponseWriter, format string, args ...interface{}) {
 	http.Error(w, msg, http.StatusBadRequest)
 }
 
+// fileModTime returns modification time of a file, truncated to seconds,
+// which is the precision of http dates
+func fileModTime(path string) (time.Time, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime().UTC().Truncate(time.Second), nil
+}
+
+// checkNotModified sets Last-Modified header to modTime. If the client
+// already has the content, because If-Modified-Since is not older than
+// modTime, responds with 304 and returns true
+func checkNotModified(w http.ResponseWriter, r *http.Request, modTime time.Time) bool {
+	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modTime.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// isValidHexColor returns true for css colors in #rgb or #rrggbb format
+func isValidHexColor(s string) bool {
+	if len(s) != 4 && len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// formIntArg returns non-negative integer value of form argument name or
+// def if the argument is not given
+func formIntArg(r *http.Request, name string, def int) (int, error) {
+	s := strings.TrimSpace(r.FormValue(name))
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return n, nil
+}
+
 func sha1OfFile(path string) ([]byte, error) {
 	f, err := os.Open(path)
 	if err != nil {
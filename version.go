@@ -0,0 +1,38 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// set at build time with:
+// go build -ldflags "-X main.buildVersion=... -X main.gitCommit=... -X main.buildTime=..."
+// see scripts/docker_build.sh
+var (
+	buildVersion = "dev"
+	gitCommit    = ""
+	buildTime    = ""
+)
+
+// VersionInfo describes the running build
+type VersionInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+func versionInfo() VersionInfo {
+	return VersionInfo{
+		Version:   buildVersion,
+		GitCommit: gitCommit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// url: /version
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, versionInfo())
+}
@@ -0,0 +1,30 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	defer func(v, c, b string) {
+		buildVersion, gitCommit, buildTime = v, c, b
+	}(buildVersion, gitCommit, buildTime)
+	buildVersion, gitCommit, buildTime = "1.2", "abc123", "2017-01-02T03:04:05Z"
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/version", nil))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d", rr.Code)
+	}
+	var got VersionInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	exp := VersionInfo{"1.2", "abc123", "2017-01-02T03:04:05Z", runtime.Version()}
+	if got != exp {
+		t.Fatalf("got %#v, expected %#v", got, exp)
+	}
+}
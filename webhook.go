@@ -0,0 +1,179 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// WebhookConfig configures /webhook/{appname}, which imports strings from
+// a git repository when GitHub or GitLab notifies us about a push
+type WebhookConfig struct {
+	// secret of the webhook. GitHub signs payloads with it, GitLab sends
+	// it in X-Gitlab-Token header
+	Secret string
+	// url of the strings file in the repository, e.g.
+	// https://raw.githubusercontent.com/user/repo/main/strings.txt
+	StringsURL string
+	// optional, sent as bearer token when fetching StringsURL from a
+	// private repository
+	Token string
+	// format of the strings file, like format argument of /uploadstrings
+	Format string
+	// namespace strings are imported to, default namespace if empty
+	Namespace string
+	// if not empty, only pushes to this branch import strings
+	Branch string
+}
+
+func validateWebhookConfig(c *WebhookConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.Secret == "" {
+		return errors.New("Secret is empty")
+	}
+	if !isValidContextURL(c.StringsURL) {
+		return fmt.Errorf("StringsURL %q is not http or https url", c.StringsURL)
+	}
+	if !isValidStringsFormat(c.Format) {
+		return fmt.Errorf("invalid Format %q", c.Format)
+	}
+	if !isValidNamespace(c.Namespace) {
+		return fmt.Errorf("invalid Namespace %q", c.Namespace)
+	}
+	return nil
+}
+
+// fetchRepoFile returns content of the file at url, sending token as bearer
+// token if not empty. The request is aborted when ctx is done or after
+// outboundTimeout(). A variable so that tests can fake the repository
+var fetchRepoFile = func(ctx context.Context, url, token string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	ctx, cancel := outboundContext(ctx)
+	defer cancel()
+	rsp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s failed with status %d", url, rsp.StatusCode)
+	}
+	d, err := ioutil.ReadAll(io.LimitReader(rsp.Body, maxUploadBytes()+1))
+	if err == nil && int64(len(d)) > maxUploadBytes() {
+		err = fmt.Errorf("%s is larger than %d bytes", url, maxUploadBytes())
+	}
+	return d, err
+}
+
+// verifyWebhook returns true if r comes from GitHub with payload signed
+// with secret or from GitLab with secret token
+func verifyWebhook(r *http.Request, payload []byte, secret string) bool {
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+	sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// isPushEvent returns true for push events of GitHub and GitLab
+func isPushEvent(r *http.Request) bool {
+	return r.Header.Get("X-GitHub-Event") == "push" || r.Header.Get("X-Gitlab-Event") == "Push Hook"
+}
+
+// WebhookResult is returned by /webhook/{appname}
+type WebhookResult struct {
+	// false if the event was not a push to the configured branch
+	Imported bool `json:"imported"`
+	// false if imported strings are the same as before
+	Changed bool `json:"changed"`
+	Strings int  `json:"strings"`
+}
+
+// url: POST /webhook/{appname}
+// Receives GitHub and GitLab push events. On a push, fetches app's
+// StringsWebhook.StringsURL and imports strings from it like /uploadstrings
+func handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, "POST") {
+		return
+	}
+	appName := mux.Vars(r)["appname"]
+	app := findApp(appName)
+	if app == nil || app.StringsWebhook == nil {
+		serveJSONError(w, http.StatusNotFound, "Application "+appName+" doesn't exist or doesn't have a webhook")
+		return
+	}
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		serveJSONError(w, http.StatusBadRequest, "Failed to read the payload")
+		return
+	}
+	c := app.StringsWebhook
+	if !verifyWebhook(r, payload, c.Secret) {
+		logger.ForRequest(r).Noticef("handleWebhook(): invalid signature of webhook for %s", appName)
+		serveJSONError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+	// other events, e.g. GitHub's ping, are acknowledged and ignored
+	if !isPushEvent(r) {
+		serveJSON(w, &WebhookResult{})
+		return
+	}
+	var push struct {
+		Ref string `json:"ref"`
+	}
+	if err = json.Unmarshal(payload, &push); err != nil {
+		serveJSONError(w, http.StatusBadRequest, "Invalid payload: "+err.Error())
+		return
+	}
+	if c.Branch != "" && push.Ref != "refs/heads/"+c.Branch {
+		serveJSON(w, &WebhookResult{})
+		return
+	}
+	d, err := fetchRepoFile(r.Context(), c.StringsURL, c.Token)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleWebhook(): fetchRepoFile() failed with %s", err)
+		serveJSONError(w, http.StatusBadGateway, "Failed to fetch strings from the repository")
+		return
+	}
+	// txt upload only adds strings, the repository is the complete list
+	newStrings, err := parseStringsUpload(app, c.Namespace, string(d), c.Format, true)
+	if err != nil {
+		serveJSONError(w, http.StatusBadRequest, "Error parsing strings from the repository: "+err.Error())
+		return
+	}
+	diff, err := updateStrings(app, c.Namespace, newStrings)
+	if err != nil {
+		logger.ForRequest(r).Errorf("handleWebhook(): updateStrings() failed with %s", err)
+		serveJSONError(w, http.StatusInternalServerError, "Failed to update strings")
+		return
+	}
+	changed := diff != nil
+	logger.ForRequest(r).Noticef("handleWebhook(): imported %d strings for %s, changed: %v", len(newStrings), appName, changed)
+	serveJSON(w, &WebhookResult{Imported: true, Changed: changed, Strings: len(newStrings)})
+}
@@ -0,0 +1,117 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookImport(t *testing.T) {
+	app := newTestApp(t, "webhook", []string{"Open"})
+	defer closeTestApp(app)
+	app.StringsWebhook = &WebhookConfig{
+		Secret:     "hooksecret",
+		StringsURL: "https://example.com/repo/strings.txt",
+		Token:      "repotoken",
+		Format:     "txt",
+		Branch:     "main",
+	}
+	if err := validateWebhookConfig(app.StringsWebhook); err != nil {
+		t.Fatalf("validateWebhookConfig() failed with %s", err)
+	}
+
+	repoFile := "Open\nClose\n\nSave\n"
+	var fetched []string
+	savedFetch := fetchRepoFile
+	defer func() { fetchRepoFile = savedFetch }()
+	fetchRepoFile = func(ctx context.Context, url, token string) ([]byte, error) {
+		if token != "repotoken" {
+			return nil, errors.New("invalid token")
+		}
+		fetched = append(fetched, url)
+		return []byte(repoFile), nil
+	}
+
+	post := func(event, ref, secret string) (int, *WebhookResult) {
+		payload := []byte(`{"ref": "` + ref + `"}`)
+		r := httptest.NewRequest("POST", "/webhook/webhook", bytes.NewReader(payload))
+		r.Header.Set("X-GitHub-Event", event)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		r.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, r)
+		var res WebhookResult
+		if rr.Code == 200 {
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("json.Unmarshal() failed with %s", err)
+			}
+		}
+		return rr.Code, &res
+	}
+
+	if code, _ := post("push", "refs/heads/main", "wrong"); code != 401 {
+		t.Fatalf("bad signature: got status %d, expected 401", code)
+	}
+	if code, res := post("ping", "", "hooksecret"); code != 200 || res.Imported {
+		t.Fatalf("ping: got status %d, %#v", code, res)
+	}
+	if code, res := post("push", "refs/heads/feature", "hooksecret"); code != 200 || res.Imported {
+		t.Fatalf("other branch: got status %d, %#v", code, res)
+	}
+	if len(fetched) != 0 {
+		t.Fatalf("repository was fetched %d times, expected 0", len(fetched))
+	}
+
+	code, res := post("push", "refs/heads/main", "hooksecret")
+	if code != 200 || !res.Imported || !res.Changed || res.Strings != 3 {
+		t.Fatalf("push: got status %d, %#v", code, res)
+	}
+	if len(fetched) != 1 || fetched[0] != app.StringsWebhook.StringsURL {
+		t.Fatalf("unexpected fetches %v", fetched)
+	}
+	for _, s := range []string{"Open", "Close", "Save"} {
+		if !app.store.IsActiveString(s) {
+			t.Fatalf("%q is not active after import", s)
+		}
+	}
+
+	// the repository is the complete list of strings
+	repoFile = "Open\nExit\n"
+	if code, res = post("push", "refs/heads/main", "hooksecret"); code != 200 || !res.Changed {
+		t.Fatalf("second push: got status %d, %#v", code, res)
+	}
+	if app.store.IsActiveString("Close") || !app.store.IsActiveString("Exit") {
+		t.Fatalf("strings not in the repository weren't removed")
+	}
+	if code, res = post("push", "refs/heads/main", "hooksecret"); code != 200 || res.Changed {
+		t.Fatalf("unchanged push: got status %d, %#v", code, res)
+	}
+
+	// GitLab sends the secret in a header
+	r := httptest.NewRequest("POST", "/webhook/webhook", bytes.NewReader([]byte(`{"ref": "refs/heads/main"}`)))
+	r.Header.Set("X-Gitlab-Event", "Push Hook")
+	r.Header.Set("X-Gitlab-Token", "hooksecret")
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, r)
+	if rr.Code != 200 || len(fetched) != 4 {
+		t.Fatalf("gitlab: got status %d, %d fetches", rr.Code, len(fetched))
+	}
+
+	for _, c := range []*WebhookConfig{
+		{StringsURL: "https://example.com/strings.txt"},
+		{Secret: "s", StringsURL: "file:///etc/passwd"},
+		{Secret: "s", StringsURL: "https://example.com/strings.txt", Format: "xml"},
+	} {
+		if validateWebhookConfig(c) == nil {
+			t.Errorf("expected an error for %#v", c)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import "unicode"
+
+// scripts written without spaces between words (or, in case of Hangul,
+// with words too long to compare with words of other scripts), in which
+// every character counts as a word
+var charCountScripts = []*unicode.RangeTable{
+	unicode.Han,
+	unicode.Hiragana,
+	unicode.Katakana,
+	unicode.Hangul,
+}
+
+// countWords returns number of words in s, which are separated by spaces.
+// Characters of charCountScripts count as words on their own, so that the
+// count depends on the text and not on the language it's translated into
+func countWords(s string) int {
+	n := 0
+	inWord := false
+	for _, r := range s {
+		switch {
+		case unicode.In(r, charCountScripts...):
+			n++
+			inWord = false
+		case unicode.IsSpace(r):
+			inWord = false
+		case !inWord:
+			n++
+			inWord = true
+		}
+	}
+	return n
+}
+
+// translatedWordsPercent is translatedPercent() weighted by number of
+// words in strings, which is closer to the amount of work done
+func translatedWordsPercent(p *LangProgress) int {
+	if p.Words == 0 {
+		return 100
+	}
+	return (p.Words - p.UntranslatedWords) * 100 / p.Words
+}
+
+// WordCounts returns number of words in strings that are not yet translated
+// into a given language, indexed by language. It's the amount of remaining
+// work, used for budgeting translations. See countWords() for how words are
+// counted
+func (a *App) WordCounts() map[string]int {
+	res := make(map[string]int)
+	for _, li := range a.store.LangInfos() {
+		n := 0
+		for _, tr := range li.UntranslatedStrings() {
+			n += countWords(tr.String)
+		}
+		res[li.Code] = n
+	}
+	return res
+}
@@ -0,0 +1,117 @@
+// This code is under BSD license. See license-bsd.txt
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp int
+	}{
+		{"Open file", 2},
+		{"  Save all\tfiles  ", 3},
+		{"", 0},
+		{"打开文件", 4},
+		{"ファイルを開く", 7},
+		{"파일 열기", 4},
+		// words of other scripts next to characters
+		{"打开PDF文件", 5},
+	}
+	for _, test := range tests {
+		if got := countWords(test.s); got != test.exp {
+			t.Errorf("countWords(%q) = %d, expected %d", test.s, got, test.exp)
+		}
+	}
+}
+
+func TestWordCounts(t *testing.T) {
+	app := newTestApp(t, "words", []string{"Open file", "Save all files now"})
+	defer closeTestApp(app)
+	writeTestTranslation(t, app, "Open file", "Datei öffnen", "de", "user1")
+
+	counts := app.WordCounts()
+	if counts["de"] != 4 {
+		t.Fatalf("de: got %d words, expected 4", counts["de"])
+	}
+	if counts["pl"] != 6 {
+		t.Fatalf("pl: got %d words, expected 6", counts["pl"])
+	}
+	// english source strings have the same number of words in every
+	// language, including those written without spaces
+	if counts["ja"] != 6 {
+		t.Fatalf("ja: got %d words, expected 6", counts["ja"])
+	}
+
+	rr := httptest.NewRecorder()
+	makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/words/progress", nil))
+	if rr.Code != 200 {
+		t.Fatalf("got status %d, expected 200", rr.Code)
+	}
+	var res struct {
+		Langs []*LangProgress `json:"langs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("json.Unmarshal() failed with %s", err)
+	}
+	found := false
+	for _, lp := range res.Langs {
+		if lp.Lang == "de" {
+			found = true
+			if lp.Strings != 2 || lp.Untranslated != 1 || lp.UntranslatedWords != 4 {
+				t.Fatalf("unexpected progress %#v", lp)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("no progress of de in %s", rr.Body.String())
+	}
+}
+
+func TestProgressByWords(t *testing.T) {
+	// one long string and many short ones
+	long := "Do you want to save changes to the document before closing it"
+	app := newTestApp(t, "progresswords", []string{long, "Open", "Save", "Exit"})
+	defer closeTestApp(app)
+	for _, str := range []string{"Open", "Save", "Exit"} {
+		writeTestTranslation(t, app, str, str+"-de", "de", "user1")
+	}
+
+	progressOf := func(query string) (int, *LangProgress) {
+		rr := httptest.NewRecorder()
+		makeHTTPServer().Handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/apps/progresswords/progress"+query, nil))
+		var res struct {
+			By    string          `json:"by"`
+			Langs []*LangProgress `json:"langs"`
+		}
+		if rr.Code != 200 {
+			return rr.Code, nil
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("json.Unmarshal() failed with %s", err)
+		}
+		for _, lp := range res.Langs {
+			if lp.Lang == "de" {
+				return rr.Code, lp
+			}
+		}
+		t.Fatalf("no progress of de in %s", rr.Body.String())
+		return 0, nil
+	}
+
+	// 3 of 4 strings, but only 3 of 15 words are translated
+	for _, query := range []string{"", "?by=strings"} {
+		if _, lp := progressOf(query); lp.Percent != 75 || lp.Words != 15 || lp.UntranslatedWords != 12 {
+			t.Fatalf("%q: unexpected progress %#v", query, lp)
+		}
+	}
+	if _, lp := progressOf("?by=words"); lp.Percent != 20 || lp.Strings != 4 || lp.Untranslated != 1 {
+		t.Fatalf("by words: unexpected progress %#v", lp)
+	}
+	if code, _ := progressOf("?by=chars"); code != 400 {
+		t.Fatalf("got status %d for invalid by, expected 400", code)
+	}
+}